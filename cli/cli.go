@@ -0,0 +1,129 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+const (
+	ExitOK = 0
+	// The command ran but failed, for instance the crawl could not save its results
+	ExitFailure = 1
+	// The command line or the config file is not valid
+	ExitUsage = 2
+)
+
+// Command is a subcommand of the command line, the output goes to stdout and the messages to stderr
+type Command struct {
+	Name    string
+	Summary string
+	Run     func(args []string, stdout io.Writer, stderr io.Writer) int
+}
+
+var commands = map[string]*Command{}
+
+func register(command *Command) {
+	commands[command.Name] = command
+}
+
+func init() {
+	register(&Command{Name: "crawl", Summary: "crawl the seeds and save the results", Run: runCrawl})
+	register(&Command{Name: "index", Summary: "index the results of crawls into the index dumps", Run: runIndex})
+	register(&Command{Name: "search", Summary: "search the index or the results of a crawl", Run: runSearch})
+	register(&Command{Name: "serve", Summary: "serve the search over http", Run: runServe})
+	register(&Command{Name: "monitor", Summary: "scrape and index the new items of rss and atom feeds", Run: runMonitor})
+	register(&Command{Name: "schedule", Summary: "run the crawl jobs of a jobs file on their schedules", Run: runSchedule})
+	register(&Command{Name: "stats", Summary: "print the statistics of the results of a crawl", Run: runStats})
+	register(&Command{Name: "export", Summary: "export reports, sitemaps and link graphs of crawls", Run: runExport})
+}
+
+// Run runs the subcommand named by the first argument and returns the exit code
+func Run(args []string, stdout io.Writer, stderr io.Writer) int {
+	if len(args) == 0 || args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		usage(stderr)
+		if len(args) == 0 {
+			return ExitUsage
+		}
+		return ExitOK
+	}
+	command, exists := commands[args[0]]
+	if !exists {
+		fmt.Fprintf(stderr, "unknown command: %s\n\n", args[0])
+		usage(stderr)
+		return ExitUsage
+	}
+	return command.Run(args[1:], stdout, stderr)
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintf(w, "Usage: crawler <command> [flags] [arguments]\n\nCommands:\n")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "  %-8s %s\n", name, commands[name].Summary)
+	}
+	fmt.Fprintf(w, "\nRun crawler <command> -h for the flags of a command.\n")
+}
+
+// newFlagSet returns the flag set of a command which reports its errors instead of exiting
+func newFlagSet(name string, arguments string, stderr io.Writer) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: crawler %s [flags] %s\n\nFlags:\n", name, arguments)
+		flags.PrintDefaults()
+	}
+	return flags
+}
+
+// parseFlags parses the flags of a command, the exit code is returned when the command should stop
+func parseFlags(flags *flag.FlagSet, args []string) (int, bool) {
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return ExitOK, false
+		}
+		return ExitUsage, false
+	}
+	return ExitOK, true
+}
+
+// setFlags returns the names of the flags given on the command line
+func setFlags(flags *flag.FlagSet) map[string]bool {
+	set := map[string]bool{}
+	flags.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+	return set
+}
+
+func fail(stderr io.Writer, code int, format string, args ...interface{}) int {
+	message := fmt.Sprintf(format, args...)
+	fmt.Fprintf(stderr, "crawler: %s\n", strings.TrimRight(message, "\n"))
+	return code
+}
+
+func writeJSON(w io.Writer, v interface{}) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+// stringList is a flag which can be given more than once
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
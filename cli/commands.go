@@ -0,0 +1,400 @@
+package cli
+
+import (
+	"crawler/collector"
+	"crawler/graph"
+	"crawler/searcher"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+type CrawlSummary struct {
+	Seeds              []string `json:"seeds"`
+	ResultsFile        string   `json:"results_file"`
+	TotalPages         int      `json:"total_pages"`
+	SucceededPages     int      `json:"succeeded_pages"`
+	FailedPages        int      `json:"failed_pages"`
+	ExecutionInSeconds float64  `json:"execution_in_seconds"`
+}
+
+type IndexSummary struct {
+	Files     []string       `json:"files"`
+	Index     string         `json:"index"`
+	Pages     int            `json:"pages"`
+	Tokens    int            `json:"tokens"`
+	Languages map[string]int `json:"languages"`
+}
+
+type SearchOutput struct {
+	Query   string                  `json:"query"`
+	Field   string                  `json:"field,omitempty"`
+	Total   int                     `json:"total"`
+	Results []searcher.SearchResult `json:"results"`
+}
+
+type CrawlStats struct {
+	Seed               string                    `json:"seed"`
+	Depth              int                       `json:"depth"`
+	BeginTimestamp     time.Time                 `json:"begin_timestamp"`
+	EndTimestamp       time.Time                 `json:"end_timestamp"`
+	ExecutionInSeconds float64                   `json:"execution_in_seconds"`
+	PageRatePerSec     float64                   `json:"page_rate_per_sec"`
+	TotalPages         int                       `json:"total_pages"`
+	SucceededPages     int                       `json:"succeeded_pages"`
+	FailedPages        int                       `json:"failed_pages"`
+	SuppressedUrls     int                       `json:"suppressed_urls"`
+	SuppressedByReason map[string]int            `json:"suppressed_by_reason,omitempty"`
+	StatusCodes        map[int]int               `json:"status_codes"`
+	ContentTypes       map[string]int            `json:"content_types"`
+	Languages          map[string]int            `json:"languages"`
+	Transport          *collector.TransportStats `json:"transport,omitempty"`
+	Graph              graph.GraphStats          `json:"graph"`
+}
+
+func runCrawl(args []string, stdout io.Writer, stderr io.Writer) int {
+	flags := newFlagSet("crawl", "[seed...]", stderr)
+	configFile := flags.String("config", "", "yaml or json config file")
+	depth := flags.Int("depth", DefaultDepth, "depth of the crawl")
+	out := flags.String("out", DefaultResultsFile, "results file of the crawl")
+	dir := flags.String("dir", "", "crawl the html files of the directory instead of the seeds")
+	baseURL := flags.String("base-url", "", "public url the pages of the directory or of the file seeds are recorded under")
+	maxPages := flags.Int("max-pages", 0, "number of pages to scrape at most, zero means no limit")
+	concurrency := flags.Int("concurrency", collector.DefaultConcurrency, "number of pages scraped at the same time by the priority crawl")
+	priority := flags.String("priority", "", "scrape the best scored urls first: depth, inlinks, sitemap or topic")
+	topic := flags.String("topic", "", "topic of the topic priority")
+	sitemap := flags.String("sitemap", "", "sitemap url of the sitemap priority")
+	var allowedHosts stringList
+	flags.Var(&allowedHosts, "allow-host", "follow the links only to this host, can be given more than once")
+	mainText := flags.Bool("main-text", false, "extract the main content of the pages")
+	detectLanguage := flags.Bool("detect-language", true, "detect the language of the pages which do not declare it")
+	var linkSources stringList
+	flags.Var(&linkSources, "link-source", "find the links in this source, can be given more than once: "+
+		strings.Join(collector.LinkSources, ", "))
+	assets := flags.Bool("assets", false, "record the assets of the pages")
+	checkAssets := flags.Bool("check-assets", false, "record the assets of the pages and check their availability")
+	dedup := flags.Bool("dedup", false, "detect the near duplicate pages")
+	storage := flags.String("storage", "", "keep the pages and the frontier on the disk under this directory")
+	asJSON := flags.Bool("json", false, "print the summary as json")
+	if code, ok := parseFlags(flags, args); !ok {
+		return code
+	}
+	config, err := LoadConfig(*configFile)
+	if err != nil {
+		return fail(stderr, ExitUsage, "%s", err.Error())
+	}
+	crawl := &config.Crawl
+	set := setFlags(flags)
+	if flags.NArg() > 0 {
+		crawl.Seeds = flags.Args()
+	}
+	if set["depth"] {
+		crawl.Depth = *depth
+	}
+	if set["out"] {
+		crawl.ResultsFile = *out
+	}
+	if set["dir"] {
+		crawl.Dir = *dir
+	}
+	if set["base-url"] {
+		crawl.BaseURL = *baseURL
+	}
+	if set["max-pages"] {
+		crawl.MaxPages = *maxPages
+	}
+	if set["concurrency"] {
+		crawl.Concurrency = *concurrency
+	}
+	if set["priority"] {
+		crawl.Priority = *priority
+	}
+	if set["topic"] {
+		crawl.Topic = *topic
+	}
+	if set["sitemap"] {
+		crawl.Sitemap = *sitemap
+	}
+	if len(allowedHosts) > 0 {
+		crawl.AllowedHosts = allowedHosts
+	}
+	if *mainText {
+		crawl.MainText = true
+	}
+	if set["detect-language"] {
+		crawl.DetectLanguage = detectLanguage
+	}
+	if len(linkSources) > 0 {
+		crawl.LinkSources = linkSources
+	}
+	if *assets || *checkAssets {
+		crawl.Assets = &AssetsConfig{Check: *checkAssets}
+	}
+	if *dedup && crawl.Deduplication == nil {
+		crawl.Deduplication = &DeduplicationConfig{MaxDistance: collector.DefaultMaxHammingDistance}
+	}
+	if set["storage"] {
+		crawl.StorageDir = *storage
+	}
+
+	if err := crawl.Validate(); err != nil {
+		return fail(stderr, ExitUsage, "%s", err.Error())
+	}
+	c, err := crawl.NewCollector()
+	if err != nil {
+		return fail(stderr, ExitFailure, "%s", err.Error())
+	}
+	defer c.Close()
+	// The progress goes to stderr so that stdout only carries the output of the command
+	c.Output = stderr
+	if _, err := c.StartCrawling(); err != nil {
+		return fail(stderr, ExitFailure, "crawling failed: %s", err.Error())
+	}
+	c.End = time.Now()
+	if _, err := c.SaveResultsToFile(); err != nil {
+		return fail(stderr, ExitFailure, "results could not be saved: %s", err.Error())
+	}
+	summary := &CrawlSummary{
+		Seeds:              append([]string{c.Seed}, c.AdditionalSeeds...),
+		ResultsFile:        crawl.ResultsFile,
+		SucceededPages:     c.Scrapper.NumberOfPagesSucceed(),
+		FailedPages:        c.Scrapper.NumberOfPagesFailed(),
+		ExecutionInSeconds: c.End.Sub(c.Begin).Seconds(),
+	}
+	summary.TotalPages = summary.SucceededPages + summary.FailedPages
+	if *asJSON {
+		return output(stderr, writeJSON(stdout, summary))
+	}
+	fmt.Fprintf(stdout, "Crawled %d pages (%d succeeded, %d failed) in %.1f seconds\nResults saved into %s\n",
+		summary.TotalPages, summary.SucceededPages, summary.FailedPages, summary.ExecutionInSeconds, summary.ResultsFile)
+	return ExitOK
+}
+
+func runIndex(args []string, stdout io.Writer, stderr io.Writer) int {
+	flags := newFlagSet("index", "results.json...", stderr)
+	configFile := flags.String("config", "", "yaml or json config file")
+	index := flags.String("index", searcher.IndexDumpFile, "index dump to write, the other dumps are written next to it")
+	asJSON := flags.Bool("json", false, "print the summary as json")
+	if code, ok := parseFlags(flags, args); !ok {
+		return code
+	}
+	config, err := LoadConfig(*configFile)
+	if err != nil {
+		return fail(stderr, ExitUsage, "%s", err.Error())
+	}
+	files := flags.Args()
+	if len(files) == 0 {
+		files = []string{config.Crawl.ResultsFile}
+	}
+	indexer, err := config.Index.NewIndexer(stderr)
+	if err != nil {
+		return fail(stderr, ExitFailure, "%s", err.Error())
+	}
+	summary := &IndexSummary{Files: files, Index: *index}
+	// Page ranks and duplicates are computed per crawl and merged by the indexer
+	for _, file := range files {
+		data, err := collector.LoadResultData(file)
+		if err != nil {
+			return fail(stderr, ExitFailure, "results file %s could not be loaded: %s", file, err.Error())
+		}
+		for url, page := range data.Succeed {
+			indexer.IndexPage(url, page)
+		}
+		summary.Pages += len(data.Succeed)
+		indexer.ComputePageRanks(data)
+		indexer.ComputeDuplicates(data)
+	}
+	if err := indexer.SaveIndexDumpTo(*index); err != nil {
+		return fail(stderr, ExitFailure, "index dump could not be saved: %s", err.Error())
+	}
+	summary.Tokens = len(indexer.Indexes)
+	summary.Languages = indexer.Languages
+	if *asJSON {
+		return output(stderr, writeJSON(stdout, summary))
+	}
+	fmt.Fprintf(stdout, "Indexed %d pages with %d tokens into %s\n", summary.Pages, summary.Tokens, summary.Index)
+	return ExitOK
+}
+
+func runSearch(args []string, stdout io.Writer, stderr io.Writer) int {
+	flags := newFlagSet("search", "phrase...", stderr)
+	configFile := flags.String("config", "", "yaml or json config file")
+	index := flags.String("index", searcher.IndexDumpFile, "index dump to search")
+	results := flags.String("results", "", "search the results file of a crawl instead of the index dump")
+	field := flags.String("field", "", "search the custom extracted field instead of the page texts")
+	limit := flags.Int("limit", 10, "number of results to print, zero prints all")
+	asJSON := flags.Bool("json", false, "print the results as json")
+	if code, ok := parseFlags(flags, args); !ok {
+		return code
+	}
+	query := strings.Join(flags.Args(), " ")
+	if strings.TrimSpace(query) == "" {
+		flags.Usage()
+		return ExitUsage
+	}
+	config, err := LoadConfig(*configFile)
+	if err != nil {
+		return fail(stderr, ExitUsage, "%s", err.Error())
+	}
+	indexer, err := loadIndexer(&config.Index, *index, *results, stderr)
+	if err != nil {
+		return fail(stderr, ExitFailure, "%s", err.Error())
+	}
+	out := search(indexer, query, *field, *limit)
+	if *asJSON {
+		return output(stderr, writeJSON(stdout, out))
+	}
+	for _, result := range out.Results {
+		fmt.Fprintf(stdout, "%f\t%s\n", result.Rank, result.Url)
+	}
+	return ExitOK
+}
+
+func runStats(args []string, stdout io.Writer, stderr io.Writer) int {
+	flags := newFlagSet("stats", "[results.json]", stderr)
+	asJSON := flags.Bool("json", false, "print the statistics as json")
+	if code, ok := parseFlags(flags, args); !ok {
+		return code
+	}
+	file := DefaultResultsFile
+	if flags.NArg() > 0 {
+		file = flags.Arg(0)
+	}
+	data, err := collector.LoadResultData(file)
+	if err != nil {
+		return fail(stderr, ExitFailure, "results file %s could not be loaded: %s", file, err.Error())
+	}
+	stats := BuildCrawlStats(data)
+	if *asJSON {
+		return output(stderr, writeJSON(stdout, stats))
+	}
+	fmt.Fprintf(stdout, "Seed:        %s (depth %d)\n", stats.Seed, stats.Depth)
+	fmt.Fprintf(stdout, "Crawled:     %s, %.1f seconds, %.2f pages per second\n",
+		stats.BeginTimestamp.Format("2006-01-02 15:04:05 MST"), stats.ExecutionInSeconds, stats.PageRatePerSec)
+	fmt.Fprintf(stdout, "Pages:       %d (%d succeeded, %d failed)\n", stats.TotalPages, stats.SucceededPages, stats.FailedPages)
+	fmt.Fprintf(stdout, "Suppressed:  %d urls\n", stats.SuppressedUrls)
+	fmt.Fprintf(stdout, "Link graph:  %d nodes, %d edges, %d components, %d orphans, max depth %d\n",
+		stats.Graph.Nodes, stats.Graph.Edges, stats.Graph.Components, stats.Graph.Orphans, stats.Graph.MaxDepth)
+	writeCounts(stdout, "Status codes", intCounts(stats.StatusCodes))
+	writeCounts(stdout, "Content types", stats.ContentTypes)
+	writeCounts(stdout, "Languages", stats.Languages)
+	return ExitOK
+}
+
+// BuildCrawlStats counts the pages of a crawl by their status codes, content types and languages, the pages
+// saved without a status code are left out of the status codes
+func BuildCrawlStats(data *collector.ResultData) *CrawlStats {
+	linkGraph := graph.BuildLinkGraph(data)
+	stats := &CrawlStats{
+		Seed:               data.Seed,
+		Depth:              data.Depth,
+		BeginTimestamp:     data.BeginTimestamp,
+		EndTimestamp:       data.EndTimestamp,
+		ExecutionInSeconds: data.ExecutionInSeconds,
+		PageRatePerSec:     data.PageRatePerSec,
+		TotalPages:         len(data.Succeed) + len(data.Failed),
+		SucceededPages:     len(data.Succeed),
+		FailedPages:        len(data.Failed),
+		SuppressedUrls:     data.SuppressedUrls,
+		SuppressedByReason: data.SuppressedByReason,
+		StatusCodes:        map[int]int{},
+		ContentTypes:       map[string]int{},
+		Languages:          map[string]int{},
+		Transport:          data.Transport,
+		Graph:              linkGraph.Stats(),
+	}
+	for _, page := range data.Succeed {
+		if page.StatusCode > 0 {
+			stats.StatusCodes[page.StatusCode]++
+		}
+		contentType := collector.MediaType(page.ContentType, page.Url)
+		if contentType == "" {
+			contentType = "unknown"
+		}
+		stats.ContentTypes[contentType]++
+		if page.Language != "" {
+			stats.Languages[page.Language]++
+		}
+	}
+	for _, page := range data.Failed {
+		if page.StatusCode > 0 {
+			stats.StatusCodes[page.StatusCode]++
+		}
+	}
+	return stats
+}
+
+// loadIndexer loads the index dump, or indexes the results file when it is given, the progress goes to the output
+func loadIndexer(config *IndexConfig, index string, results string, output io.Writer) (*searcher.Indexer, error) {
+	indexer, err := config.NewIndexer(output)
+	if err != nil {
+		return nil, err
+	}
+	if results != "" {
+		if err := indexer.LoadCollectorDocument(results, false); err != nil {
+			return nil, errors.New(fmt.Sprintf("results file %s could not be loaded: %s", results, err.Error()))
+		}
+		return indexer, nil
+	}
+	if err := indexer.LoadIndexDump(index); err != nil {
+		return nil, errors.New(fmt.Sprintf("index dump %s could not be loaded: %s", index, err.Error()))
+	}
+	return indexer, nil
+}
+
+func search(indexer *searcher.Indexer, query string, field string, limit int) *SearchOutput {
+	var results []searcher.SearchResult
+	if field != "" {
+		results = indexer.SearchField(field, query)
+	} else {
+		results = indexer.Search(query)
+	}
+	out := &SearchOutput{Query: query, Field: field, Total: len(results), Results: results}
+	if limit > 0 && len(results) > limit {
+		out.Results = results[:limit]
+	}
+	return out
+}
+
+// output reports the error of writing the output of a command
+func output(stderr io.Writer, err error) int {
+	if err != nil {
+		return fail(stderr, ExitFailure, "output could not be written: %s", err.Error())
+	}
+	return ExitOK
+}
+
+func writeCounts(w io.Writer, title string, counts map[string]int) {
+	if len(counts) == 0 {
+		return
+	}
+	keys := make([]string, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if counts[keys[i]] == counts[keys[j]] {
+			return keys[i] < keys[j]
+		}
+		return counts[keys[i]] > counts[keys[j]]
+	})
+	fmt.Fprintf(w, "%s:\n", title)
+	for _, key := range keys {
+		fmt.Fprintf(w, "  %-30s %d\n", key, counts[key])
+	}
+}
+
+func intCounts(counts map[int]int) map[string]int {
+	converted := make(map[string]int, len(counts))
+	for key, count := range counts {
+		label := fmt.Sprintf("%d", key)
+		if key == 0 {
+			label = "no response"
+		}
+		converted[label] = count
+	}
+	return converted
+}
@@ -0,0 +1,437 @@
+package cli
+
+import (
+	"bytes"
+	"crawler/collector"
+	"crawler/searcher"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultResultsFile = "results.json"
+	DefaultDepth       = 2
+	DefaultServeAddr   = ":8080"
+)
+
+const (
+	PriorityDepth   = "depth"
+	PriorityInLinks = "inlinks"
+	PrioritySitemap = "sitemap"
+	PriorityTopic   = "topic"
+)
+
+// Config is the configuration file of the commands, either yaml or json. The options left out keep the
+// defaults of the collector and the indexer, the flags of a command override the file.
+type Config struct {
+	Crawl    CrawlConfig    `json:"crawl" yaml:"crawl"`
+	Index    IndexConfig    `json:"index" yaml:"index"`
+	Serve    ServeConfig    `json:"serve" yaml:"serve"`
+	Monitor  MonitorConfig  `json:"monitor" yaml:"monitor"`
+	Schedule ScheduleConfig `json:"schedule" yaml:"schedule"`
+}
+
+type CrawlConfig struct {
+	Seeds       []string `json:"seeds" yaml:"seeds"`
+	Depth       int      `json:"depth" yaml:"depth"`
+	ResultsFile string   `json:"results_file" yaml:"results_file"`
+	// Directory crawled from the disk, its pages are recorded under the base url when it is given
+	Dir     string `json:"dir" yaml:"dir"`
+	BaseURL string `json:"base_url" yaml:"base_url"`
+	// Robots directives and trap heuristics are on unless they are turned off
+	RespectRobots *bool    `json:"respect_robots" yaml:"respect_robots"`
+	Traps         *bool    `json:"traps" yaml:"traps"`
+	AllowedHosts  []string `json:"allowed_hosts" yaml:"allowed_hosts"`
+	// Any of depth, inlinks, sitemap and topic makes the crawl scrape the best scored urls first
+	Priority    string `json:"priority" yaml:"priority"`
+	Topic       string `json:"topic" yaml:"topic"`
+	Sitemap     string `json:"sitemap" yaml:"sitemap"`
+	MaxPages    int    `json:"max_pages" yaml:"max_pages"`
+	Concurrency int    `json:"concurrency" yaml:"concurrency"`
+	MainText    bool   `json:"main_text" yaml:"main_text"`
+	// Elements the links are found in besides the anchors, such as iframe, meta_refresh or srcset
+	LinkSources []string `json:"link_sources" yaml:"link_sources"`
+	// The declared languages are recorded unless language is turned off, the other pages are detected from their
+	// text unless detect_language is turned off
+	Language        *bool                `json:"language" yaml:"language"`
+	DetectLanguage  *bool                `json:"detect_language" yaml:"detect_language"`
+	Deduplication   *DeduplicationConfig `json:"deduplication" yaml:"deduplication"`
+	Assets          *AssetsConfig        `json:"assets" yaml:"assets"`
+	ExtractionRules string               `json:"extraction_rules" yaml:"extraction_rules"`
+	StorageDir      string               `json:"storage_dir" yaml:"storage_dir"`
+	ExpectedUrls    int                  `json:"expected_urls" yaml:"expected_urls"`
+	Transport       *TransportConfig     `json:"transport" yaml:"transport"`
+	Login           *LoginConfig         `json:"login" yaml:"login"`
+}
+
+type DeduplicationConfig struct {
+	MaxDistance int  `json:"max_distance" yaml:"max_distance"`
+	SkipLinks   bool `json:"skip_links" yaml:"skip_links"`
+}
+
+type AssetsConfig struct {
+	Check bool `json:"check" yaml:"check"`
+}
+
+// TransportConfig holds the durations as strings such as "10s" or "5m"
+type TransportConfig struct {
+	RequestTimeout        string `json:"request_timeout" yaml:"request_timeout"`
+	DialTimeout           string `json:"dial_timeout" yaml:"dial_timeout"`
+	TLSHandshakeTimeout   string `json:"tls_handshake_timeout" yaml:"tls_handshake_timeout"`
+	ResponseHeaderTimeout string `json:"response_header_timeout" yaml:"response_header_timeout"`
+	IdleConnTimeout       string `json:"idle_conn_timeout" yaml:"idle_conn_timeout"`
+	MaxIdleConnsPerHost   int    `json:"max_idle_conns_per_host" yaml:"max_idle_conns_per_host"`
+	MaxConnsPerHost       int    `json:"max_conns_per_host" yaml:"max_conns_per_host"`
+	DNSCacheTTL           string `json:"dns_cache_ttl" yaml:"dns_cache_ttl"`
+	DNSNegativeTTL        string `json:"dns_negative_ttl" yaml:"dns_negative_ttl"`
+}
+
+// LoginConfig is the login form submitted before the crawl, the values of the fields may reference the
+// environment variables such as ${KB_PASSWORD} so that the secrets are kept out of the file
+type LoginConfig struct {
+	Url            string            `json:"url" yaml:"url"`
+	FormSelector   string            `json:"form_selector" yaml:"form_selector"`
+	Fields         map[string]string `json:"fields" yaml:"fields"`
+	CSRFSelector   string            `json:"csrf_selector" yaml:"csrf_selector"`
+	CSRFAttr       string            `json:"csrf_attr" yaml:"csrf_attr"`
+	CSRFField      string            `json:"csrf_field" yaml:"csrf_field"`
+	ExpiredPattern string            `json:"expired_pattern" yaml:"expired_pattern"`
+	LogoutPattern  string            `json:"logout_pattern" yaml:"logout_pattern"`
+	MaxLogins      int               `json:"max_logins" yaml:"max_logins"`
+}
+
+// Form returns the login form of the collector with the environment variables of the fields expanded
+func (l *LoginConfig) Form() collector.LoginForm {
+	fields := make(map[string]string, len(l.Fields))
+	for name, value := range l.Fields {
+		fields[name] = os.ExpandEnv(value)
+	}
+	return collector.LoginForm{
+		Url:            l.Url,
+		FormSelector:   l.FormSelector,
+		Fields:         fields,
+		CSRFSelector:   l.CSRFSelector,
+		CSRFAttr:       l.CSRFAttr,
+		CSRFField:      l.CSRFField,
+		ExpiredPattern: l.ExpiredPattern,
+		LogoutPattern:  l.LogoutPattern,
+		MaxLogins:      l.MaxLogins,
+	}
+}
+
+type IndexConfig struct {
+	IncludeNoIndex     bool     `json:"include_noindex" yaml:"include_noindex"`
+	PageRankWeight     *float64 `json:"page_rank_weight" yaml:"page_rank_weight"`
+	CollapseDuplicates *bool    `json:"collapse_duplicates" yaml:"collapse_duplicates"`
+}
+
+type ServeConfig struct {
+	Addr string `json:"addr" yaml:"addr"`
+}
+
+func DefaultConfig() *Config {
+	return &Config{
+		Crawl: CrawlConfig{
+			Depth:       DefaultDepth,
+			ResultsFile: DefaultResultsFile,
+			Concurrency: collector.DefaultConcurrency,
+		},
+		Serve:    ServeConfig{Addr: DefaultServeAddr},
+		Monitor:  MonitorConfig{Interval: DefaultMonitorInterval, StateFile: DefaultMonitorStateFile},
+		Schedule: ScheduleConfig{JobsFile: DefaultScheduleJobsFile, HistoryFile: DefaultScheduleHistoryFile},
+	}
+}
+
+// LoadConfig reads the configuration file over the defaults, the files ending with .yaml or .yml are read
+// as yaml and the others as json. Unknown options are reported as errors.
+func LoadConfig(path string) (*Config, error) {
+	config := DefaultConfig()
+	if path == "" {
+		return config, nil
+	}
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(bytes.NewReader(content))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(config); err != nil {
+			return nil, errors.New(fmt.Sprintf("config file %s could not be read: %s", path, err.Error()))
+		}
+	default:
+		decoder := json.NewDecoder(bytes.NewReader(content))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(config); err != nil {
+			return nil, errors.New(fmt.Sprintf("config file %s could not be read: %s", path, err.Error()))
+		}
+	}
+	return config, nil
+}
+
+// Validate checks the options of the crawl given by the config file and the flags, the errors of Validate are
+// errors of the input while the errors of NewCollector after a successful Validate are failures of the crawl
+func (c *CrawlConfig) Validate() error {
+	if c.Dir == "" && len(c.Seeds) == 0 {
+		return errors.New("no seed is given")
+	}
+	for _, seed := range c.Seeds {
+		if _, err := url.ParseRequestURI(seed); err != nil {
+			return errors.New(fmt.Sprintf("seed is not valid url: %s", err.Error()))
+		}
+	}
+	if c.Depth <= 0 {
+		return errors.New("depth should be a positive number")
+	}
+	if c.BaseURL != "" {
+		if _, err := url.ParseRequestURI(c.BaseURL); err != nil {
+			return errors.New(fmt.Sprintf("base url is not valid url: %s", err.Error()))
+		}
+		if c.Dir == "" && !strings.HasPrefix(c.Seeds[0], "file:") {
+			return errors.New("base url needs a directory or a file seed")
+		}
+	}
+	switch c.Priority {
+	case "", PriorityDepth, PriorityInLinks:
+	case PrioritySitemap:
+		if c.Sitemap == "" {
+			return errors.New("sitemap priority needs the sitemap url")
+		}
+	case PriorityTopic:
+		if c.Topic == "" {
+			return errors.New("topic priority needs the topic")
+		}
+	default:
+		return errors.New(fmt.Sprintf("unknown priority: %s", c.Priority))
+	}
+	if len(c.LinkSources) > 0 {
+		if _, err := collector.NewLinkExtractor(c.LinkSources...); err != nil {
+			return err
+		}
+	}
+	if c.Deduplication != nil {
+		if distance := c.Deduplication.MaxDistance; distance < 0 || distance > collector.MaxHammingDistance {
+			return errors.New(fmt.Sprintf("deduplication max_distance should be between 0 and %d: %d",
+				collector.MaxHammingDistance, distance))
+		}
+	}
+	if c.Transport != nil {
+		if _, _, err := c.Transport.Options(); err != nil {
+			return err
+		}
+	}
+	if c.Login != nil {
+		if _, err := collector.NewLoginSession(c.Login.Form()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// NewCollector creates the collector of the crawl with the configured options, the results are saved by the caller
+func (c *CrawlConfig) NewCollector() (*collector.Collector, error) {
+	crawler, err := c.newCollector()
+	if err != nil {
+		return nil, err
+	}
+	if c.RespectRobots != nil {
+		crawler.RespectRobots = *c.RespectRobots
+	}
+	if c.Traps != nil && !*c.Traps {
+		crawler.Traps = nil
+	}
+	crawler.AllowedHosts = c.AllowedHosts
+	if c.Concurrency > 0 {
+		crawler.Concurrency = c.Concurrency
+	}
+	if c.Transport != nil {
+		options, timeout, err := c.Transport.Options()
+		if err != nil {
+			return nil, err
+		}
+		crawler.ConfigureTransport(options)
+		if timeout > 0 {
+			crawler.Scrapper.Timeout = timeout
+		}
+	}
+	if c.Login != nil {
+		if err := crawler.EnableLogin(c.Login.Form()); err != nil {
+			return nil, err
+		}
+	}
+	if c.Language != nil && !*c.Language {
+		crawler.Scrapper.LanguageDetector = nil
+	} else if c.DetectLanguage != nil && !*c.DetectLanguage {
+		crawler.DisableLanguageDetection()
+	}
+	if c.MainText {
+		crawler.EnableMainTextExtraction()
+	}
+	if len(c.LinkSources) > 0 {
+		if err := crawler.EnableLinkSources(c.LinkSources...); err != nil {
+			return nil, err
+		}
+	}
+	if c.Deduplication != nil {
+		if err := crawler.EnableDeduplication(c.Deduplication.MaxDistance, c.Deduplication.SkipLinks); err != nil {
+			return nil, err
+		}
+	}
+	if c.Assets != nil {
+		crawler.EnableAssetInventory(c.Assets.Check)
+	}
+	if c.ExtractionRules != "" {
+		if err := crawler.LoadExtractionRules(c.ExtractionRules); err != nil {
+			return nil, err
+		}
+	}
+	// Storage comes before the priority crawl so that the frontier spills to the disk
+	if c.StorageDir != "" {
+		if err := crawler.EnableDiskStorage(c.StorageDir, c.ExpectedUrls); err != nil {
+			return nil, err
+		}
+	}
+	if c.Priority != "" || c.MaxPages > 0 {
+		score, err := c.Score(crawler)
+		if err != nil {
+			return nil, err
+		}
+		crawler.EnablePriorityCrawl(score, c.MaxPages)
+	}
+	return crawler, nil
+}
+
+// newCollector creates the collector of the seeds, or of the directory when it is given
+func (c *CrawlConfig) newCollector() (*collector.Collector, error) {
+	seeds := c.Seeds
+	var crawler *collector.Collector
+	var err error
+	switch {
+	case c.Dir != "":
+		crawler, err = collector.NewLocalCollector(c.Dir, c.BaseURL, c.Depth, false, c.ResultsFile)
+	case len(seeds) == 0:
+		return nil, errors.New("no seed is given")
+	default:
+		crawler, err = collector.NewCollector(seeds[0], c.Depth, false, c.ResultsFile)
+		seeds = seeds[1:]
+		if err == nil && c.BaseURL != "" {
+			// File seeds are recorded under the base url
+			var root string
+			if root, err = collector.LocalRoot(crawler.Seed); err == nil {
+				err = crawler.EnableLocalSite(root, c.BaseURL)
+			} else {
+				err = errors.New("base url needs a directory or a file seed")
+			}
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, seed := range seeds {
+		if err := crawler.AddSeed(seed); err != nil {
+			return nil, err
+		}
+	}
+	return crawler, nil
+}
+
+// Score returns the score function of the priority crawl of the collector, nil is the depth score of the collector
+func (c *CrawlConfig) Score(crawler *collector.Collector) (collector.ScoreFunc, error) {
+	switch c.Priority {
+	case "", PriorityDepth:
+		return nil, nil
+	case PriorityInLinks:
+		return collector.CombineScores(
+			collector.WeightedScore{Weight: 0.5, Score: collector.DepthScore},
+			collector.WeightedScore{Weight: 0.5, Score: collector.InLinkScore},
+		), nil
+	case PrioritySitemap:
+		if c.Sitemap == "" {
+			return nil, errors.New("sitemap priority needs the sitemap url")
+		}
+		sitemap, err := crawler.FetchSitemap(c.Sitemap)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("sitemap could not be fetched: %s", err.Error()))
+		}
+		return collector.SitemapScore(sitemap.Priorities()), nil
+	case PriorityTopic:
+		if c.Topic == "" {
+			return nil, errors.New("topic priority needs the topic")
+		}
+		indexer, err := searcher.NewIndexer()
+		if err != nil {
+			return nil, err
+		}
+		indexer.Output = nil
+		return searcher.NewTopicScorer(indexer, c.Topic).Score, nil
+	}
+	return nil, errors.New(fmt.Sprintf("unknown priority: %s", c.Priority))
+}
+
+// Options returns the transport options over the defaults and the request timeout
+func (t *TransportConfig) Options() (collector.TransportOptions, time.Duration, error) {
+	options := collector.DefaultTransportOptions()
+	durations := []struct {
+		Value  string
+		Target *time.Duration
+	}{
+		{t.DialTimeout, &options.DialTimeout},
+		{t.TLSHandshakeTimeout, &options.TLSHandshakeTimeout},
+		{t.ResponseHeaderTimeout, &options.ResponseHeaderTimeout},
+		{t.IdleConnTimeout, &options.IdleConnTimeout},
+		{t.DNSCacheTTL, &options.DNSCacheTTL},
+		{t.DNSNegativeTTL, &options.DNSNegativeTTL},
+	}
+	for _, duration := range durations {
+		if duration.Value == "" {
+			continue
+		}
+		value, err := time.ParseDuration(duration.Value)
+		if err != nil {
+			return options, 0, errors.New(fmt.Sprintf("invalid duration in the transport options: %s", duration.Value))
+		}
+		*duration.Target = value
+	}
+	if t.MaxIdleConnsPerHost > 0 {
+		options.MaxIdleConnsPerHost = t.MaxIdleConnsPerHost
+	}
+	if t.MaxConnsPerHost > 0 {
+		options.MaxConnsPerHost = t.MaxConnsPerHost
+	}
+	var timeout time.Duration
+	if t.RequestTimeout != "" {
+		value, err := time.ParseDuration(t.RequestTimeout)
+		if err != nil {
+			return options, 0, errors.New(fmt.Sprintf("invalid request timeout: %s", t.RequestTimeout))
+		}
+		timeout = value
+	}
+	return options, timeout, nil
+}
+
+// NewIndexer creates the indexer with the configured options, its progress is printed to the output
+func (c *IndexConfig) NewIndexer(output io.Writer) (*searcher.Indexer, error) {
+	indexer, err := searcher.NewIndexer()
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("indexer could not be initialized: %s", err.Error()))
+	}
+	indexer.Output = output
+	indexer.IncludeNoIndex = c.IncludeNoIndex
+	if c.PageRankWeight != nil {
+		indexer.PageRankWeight = *c.PageRankWeight
+	}
+	if c.CollapseDuplicates != nil {
+		indexer.CollapseDuplicates = *c.CollapseDuplicates
+	}
+	return indexer, nil
+}
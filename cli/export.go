@@ -0,0 +1,194 @@
+package cli
+
+import (
+	"crawler/collector"
+	"crawler/graph"
+	"crawler/report"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+const (
+	GraphFormatGraphML = "graphml"
+	GraphFormatDOT     = "dot"
+	GraphFormatCSV     = "csv"
+	GraphFormatNodes   = "nodes"
+)
+
+var exports = map[string]*Command{}
+
+func init() {
+	for _, command := range []*Command{
+		{Name: "health", Summary: "health report of a crawl", Run: runExportHealth},
+		{Name: "diff", Summary: "changes between two crawls", Run: runExportDiff},
+		{Name: "sitemap", Summary: "sitemap.xml files of a crawl", Run: runExportSitemap},
+		{Name: "graph", Summary: "link graph of a crawl", Run: runExportGraph},
+	} {
+		exports[command.Name] = command
+	}
+}
+
+func runExport(args []string, stdout io.Writer, stderr io.Writer) int {
+	if len(args) == 0 {
+		exportUsage(stderr)
+		return ExitUsage
+	}
+	command, exists := exports[args[0]]
+	if !exists {
+		fmt.Fprintf(stderr, "unknown export: %s\n\n", args[0])
+		exportUsage(stderr)
+		return ExitUsage
+	}
+	return command.Run(args[1:], stdout, stderr)
+}
+
+func exportUsage(w io.Writer) {
+	fmt.Fprintf(w, "Usage: crawler export <export> [flags] [arguments]\n\nExports:\n")
+	for _, name := range []string{"health", "diff", "sitemap", "graph"} {
+		fmt.Fprintf(w, "  %-8s %s\n", name, exports[name].Summary)
+	}
+}
+
+// Health reports exit with ExitFailure when they find issues and the -strict flag is given
+func runExportHealth(args []string, stdout io.Writer, stderr io.Writer) int {
+	flags := newFlagSet("export health", "[results.json]", stderr)
+	format := flags.String("format", report.ReportFormatJSON, "format of the report: json, markdown or html")
+	out := flags.String("o", "", "file to write the report into instead of stdout")
+	strict := flags.Bool("strict", false, "exit with 1 when the report finds issues")
+	options := report.DefaultHealthOptions()
+	flags.Int64Var(&options.OversizeBytes, "oversize-bytes", options.OversizeBytes, "pages larger than this are reported")
+	flags.Int64Var(&options.SlowResponseMs, "slow-ms", options.SlowResponseMs, "pages slower than this are reported")
+	flags.Int64Var(&options.HeavyPageBytes, "heavy-bytes", options.HeavyPageBytes, "pages heavier than this with their assets are reported")
+	if code, ok := parseFlags(flags, args); !ok {
+		return code
+	}
+	data, code := loadResults(flags.Args(), stderr)
+	if data == nil {
+		return code
+	}
+	healthReport := report.BuildHealthReport(data, options)
+	if code := writeOutput(*out, stdout, stderr, func(w io.Writer) error {
+		return healthReport.Write(w, *format)
+	}); code != ExitOK {
+		return code
+	}
+	if *strict && healthReport.HasIssues() {
+		return ExitFailure
+	}
+	return ExitOK
+}
+
+// Diffs exit with ExitFailure when the crawls differ and the -strict flag is given
+func runExportDiff(args []string, stdout io.Writer, stderr io.Writer) int {
+	flags := newFlagSet("export diff", "old.json new.json", stderr)
+	format := flags.String("format", report.ReportFormatJSON, "format of the diff: json, markdown or html")
+	out := flags.String("o", "", "file to write the diff into instead of stdout")
+	strict := flags.Bool("strict", false, "exit with 1 when the crawls differ")
+	if code, ok := parseFlags(flags, args); !ok {
+		return code
+	}
+	if flags.NArg() != 2 {
+		flags.Usage()
+		return ExitUsage
+	}
+	diff, err := report.LoadCrawlDiff(flags.Arg(0), flags.Arg(1))
+	if err != nil {
+		return fail(stderr, ExitFailure, "results files could not be loaded: %s", err.Error())
+	}
+	if code := writeOutput(*out, stdout, stderr, func(w io.Writer) error {
+		return diff.Write(w, *format)
+	}); code != ExitOK {
+		return code
+	}
+	if *strict && diff.HasChanges() {
+		return ExitFailure
+	}
+	return ExitOK
+}
+
+func runExportSitemap(args []string, stdout io.Writer, stderr io.Writer) int {
+	flags := newFlagSet("export sitemap", "[results.json]", stderr)
+	dir := flags.String("dir", ".", "directory to write the sitemaps into")
+	baseURL := flags.String("base-url", "", "url the sitemaps are published under, needed when there is a sitemap index")
+	asJSON := flags.Bool("json", false, "print the written files as json")
+	if code, ok := parseFlags(flags, args); !ok {
+		return code
+	}
+	data, code := loadResults(flags.Args(), stderr)
+	if data == nil {
+		return code
+	}
+	files, err := report.ExportSitemaps(data, *dir, *baseURL)
+	if err != nil {
+		return fail(stderr, ExitFailure, "sitemaps could not be exported: %s", err.Error())
+	}
+	if *asJSON {
+		return output(stderr, writeJSON(stdout, files))
+	}
+	fmt.Fprintf(stdout, "%s\n", strings.Join(files, "\n"))
+	return ExitOK
+}
+
+func runExportGraph(args []string, stdout io.Writer, stderr io.Writer) int {
+	flags := newFlagSet("export graph", "[results.json]", stderr)
+	format := flags.String("format", GraphFormatGraphML, "format of the graph: graphml, dot, csv for the edges or nodes for the nodes")
+	out := flags.String("o", "", "file to write the graph into instead of stdout")
+	if code, ok := parseFlags(flags, args); !ok {
+		return code
+	}
+	var write func(g *graph.LinkGraph, w io.Writer) error
+	switch *format {
+	case GraphFormatGraphML:
+		write = (*graph.LinkGraph).WriteGraphML
+	case GraphFormatDOT:
+		write = (*graph.LinkGraph).WriteDOT
+	case GraphFormatCSV:
+		write = (*graph.LinkGraph).WriteCSV
+	case GraphFormatNodes:
+		write = (*graph.LinkGraph).WriteNodesCSV
+	default:
+		return fail(stderr, ExitUsage, "unknown graph format: %s", *format)
+	}
+	data, code := loadResults(flags.Args(), stderr)
+	if data == nil {
+		return code
+	}
+	linkGraph := graph.BuildLinkGraph(data)
+	return writeOutput(*out, stdout, stderr, func(w io.Writer) error {
+		return write(linkGraph, w)
+	})
+}
+
+// loadResults loads the results file given as the argument, results.json by default
+func loadResults(args []string, stderr io.Writer) (*collector.ResultData, int) {
+	if len(args) > 1 {
+		return nil, fail(stderr, ExitUsage, "only one results file is expected")
+	}
+	file := DefaultResultsFile
+	if len(args) == 1 {
+		file = args[0]
+	}
+	data, err := collector.LoadResultData(file)
+	if err != nil {
+		return nil, fail(stderr, ExitFailure, "results file %s could not be loaded: %s", file, err.Error())
+	}
+	return data, ExitOK
+}
+
+// writeOutput writes into the file when it is given, stdout otherwise
+func writeOutput(path string, stdout io.Writer, stderr io.Writer, write func(w io.Writer) error) int {
+	if path == "" {
+		return output(stderr, write(stdout))
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return fail(stderr, ExitFailure, "output file could not be created: %s", err.Error())
+	}
+	if err := write(file); err != nil {
+		_ = file.Close()
+		return fail(stderr, ExitFailure, "output could not be written: %s", err.Error())
+	}
+	return output(stderr, file.Close())
+}
@@ -0,0 +1,161 @@
+package cli
+
+import (
+	"crawler/collector"
+	"crawler/searcher"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+const (
+	DefaultMonitorInterval  = "15m"
+	DefaultMonitorStateFile = "feeds.json"
+)
+
+// MonitorConfig is the feed monitoring mode, the crawl options such as the transport, the login or the main text
+// extraction apply to the pages of the feed items
+type MonitorConfig struct {
+	Feeds     []string `json:"feeds" yaml:"feeds"`
+	Interval  string   `json:"interval" yaml:"interval"`
+	StateFile string   `json:"state_file" yaml:"state_file"`
+}
+
+// Validate checks the feeds and the interval of the monitoring and returns the interval
+func (m *MonitorConfig) Validate() (time.Duration, error) {
+	if len(m.Feeds) == 0 {
+		return 0, errors.New("no feed is given")
+	}
+	for _, feed := range m.Feeds {
+		if _, err := url.ParseRequestURI(feed); err != nil {
+			return 0, errors.New(fmt.Sprintf("feed is not valid url: %s", err.Error()))
+		}
+	}
+	interval, err := time.ParseDuration(m.Interval)
+	if err != nil {
+		return 0, errors.New(fmt.Sprintf("interval is not valid: %s", err.Error()))
+	}
+	if interval <= 0 {
+		return 0, errors.New("interval should be a positive duration")
+	}
+	return interval, nil
+}
+
+type MonitorSummary struct {
+	Feeds       []string `json:"feeds"`
+	NewPages    int      `json:"new_pages"`
+	ResultsFile string   `json:"results_file"`
+	Index       string   `json:"index"`
+}
+
+func runMonitor(args []string, stdout io.Writer, stderr io.Writer) int {
+	flags := newFlagSet("monitor", "feed...", stderr)
+	configFile := flags.String("config", "", "yaml or json config file")
+	interval := flags.String("interval", DefaultMonitorInterval, "time between the polls of the feeds, such as 30s or 15m")
+	state := flags.String("state", DefaultMonitorStateFile, "file keeping the seen items of the feeds")
+	out := flags.String("out", DefaultResultsFile, "results file the pages of the new items are added to")
+	index := flags.String("index", searcher.IndexDumpFile, "index dump the pages of the new items are indexed into")
+	once := flags.Bool("once", false, "poll the feeds once and exit")
+	asJSON := flags.Bool("json", false, "print the summary of the poll given by -once as json")
+	if code, ok := parseFlags(flags, args); !ok {
+		return code
+	}
+	config, err := LoadConfig(*configFile)
+	if err != nil {
+		return fail(stderr, ExitUsage, "%s", err.Error())
+	}
+	monitor := &config.Monitor
+	set := setFlags(flags)
+	if flags.NArg() > 0 {
+		monitor.Feeds = flags.Args()
+	}
+	if set["interval"] {
+		monitor.Interval = *interval
+	}
+	if set["state"] {
+		monitor.StateFile = *state
+	}
+	crawl := config.Crawl
+	if set["out"] {
+		crawl.ResultsFile = *out
+	}
+	// The monitored pages are scraped from the feeds rather than crawled from the seeds
+	crawl.Seeds = monitor.Feeds
+	crawl.Dir = ""
+	pollInterval, err := monitor.Validate()
+	if err != nil {
+		return fail(stderr, ExitUsage, "%s", err.Error())
+	}
+	if err := crawl.Validate(); err != nil {
+		return fail(stderr, ExitUsage, "%s", err.Error())
+	}
+
+	c, err := crawl.NewCollector()
+	if err != nil {
+		return fail(stderr, ExitFailure, "%s", err.Error())
+	}
+	defer c.Close()
+	c.Output = stderr
+	indexer, err := config.Index.NewIndexer(stderr)
+	if err != nil {
+		return fail(stderr, ExitFailure, "%s", err.Error())
+	}
+	// The pages of the new items are added to the index of the earlier polls
+	if _, err := os.Stat(*index); err == nil {
+		if err := indexer.LoadIndexDump(*index); err != nil {
+			return fail(stderr, ExitFailure, "index dump %s could not be loaded: %s", *index, err.Error())
+		}
+	}
+	m, err := collector.NewFeedMonitor(c, pollInterval, monitor.StateFile)
+	if err != nil {
+		return fail(stderr, ExitFailure, "%s", err.Error())
+	}
+	for _, feed := range monitor.Feeds {
+		if err := m.AddFeed(feed); err != nil {
+			return fail(stderr, ExitUsage, "%s", err.Error())
+		}
+	}
+	var indexErr error
+	m.OnPages = func(pages []*collector.SucceededPage) {
+		for _, page := range pages {
+			indexer.IndexPage(page.Url, page)
+		}
+		if indexErr = indexer.SaveIndexDumpTo(*index); indexErr != nil {
+			c.Loggers.Log(collector.ERROR, fmt.Sprintf("Index dump could not be saved: %s\n", indexErr.Error()))
+			fmt.Fprintf(stderr, "crawler: index dump could not be saved: %s\n", indexErr.Error())
+		}
+	}
+
+	if *once {
+		pages, err := m.Poll()
+		if err != nil {
+			return fail(stderr, ExitFailure, "polling the feeds failed: %s", err.Error())
+		}
+		if indexErr != nil {
+			return ExitFailure
+		}
+		summary := &MonitorSummary{Feeds: monitor.Feeds, NewPages: pages, ResultsFile: crawl.ResultsFile, Index: *index}
+		if *asJSON {
+			return output(stderr, writeJSON(stdout, summary))
+		}
+		fmt.Fprintf(stdout, "Polled %d feeds, %d new pages saved into %s and indexed into %s\n",
+			len(summary.Feeds), summary.NewPages, summary.ResultsFile, summary.Index)
+		return ExitOK
+	}
+	stop := make(chan struct{})
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+	go func() {
+		<-signals
+		close(stop)
+	}()
+	fmt.Fprintf(stderr, "Monitoring %d feeds every %s\n", len(monitor.Feeds), pollInterval)
+	m.Run(stop)
+	return ExitOK
+}
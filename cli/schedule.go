@@ -0,0 +1,113 @@
+package cli
+
+import (
+	"crawler/scheduler"
+	"crawler/searcher"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+const (
+	DefaultScheduleJobsFile    = "crawl_jobs.json"
+	DefaultScheduleHistoryFile = "crawl_history.json"
+)
+
+// ScheduleConfig is the scheduled crawl mode, the jobs file holds the array of the crawl jobs and their schedules
+type ScheduleConfig struct {
+	JobsFile    string `json:"jobs_file" yaml:"jobs_file"`
+	HistoryFile string `json:"history_file" yaml:"history_file"`
+}
+
+// Validate checks the files of the scheduling
+func (s *ScheduleConfig) Validate() error {
+	if s.JobsFile == "" {
+		return errors.New("no jobs file is given")
+	}
+	if s.HistoryFile == "" {
+		return errors.New("no history file is given")
+	}
+	return nil
+}
+
+func runSchedule(args []string, stdout io.Writer, stderr io.Writer) int {
+	flags := newFlagSet("schedule", "[jobs file]", stderr)
+	configFile := flags.String("config", "", "yaml or json config file")
+	history := flags.String("history", DefaultScheduleHistoryFile, "file the runs of the jobs are recorded into")
+	index := flags.String("index", searcher.IndexDumpFile, "index dump the results of the runs are indexed into")
+	run := flags.String("run", "", "run the named job once right away and exit")
+	asJSON := flags.Bool("json", false, "print the record of the run given by -run as json")
+	if code, ok := parseFlags(flags, args); !ok {
+		return code
+	}
+	if flags.NArg() > 1 {
+		return fail(stderr, ExitUsage, "only one jobs file is accepted")
+	}
+	config, err := LoadConfig(*configFile)
+	if err != nil {
+		return fail(stderr, ExitUsage, "%s", err.Error())
+	}
+	schedule := &config.Schedule
+	if flags.NArg() == 1 {
+		schedule.JobsFile = flags.Arg(0)
+	}
+	if setFlags(flags)["history"] {
+		schedule.HistoryFile = *history
+	}
+	if err := schedule.Validate(); err != nil {
+		return fail(stderr, ExitUsage, "%s", err.Error())
+	}
+
+	indexer, err := config.Index.NewIndexer(stderr)
+	if err != nil {
+		return fail(stderr, ExitFailure, "%s", err.Error())
+	}
+	// The results of the runs are added to the index of the earlier runs
+	if _, err := os.Stat(*index); err == nil {
+		if err := indexer.LoadIndexDump(*index); err != nil {
+			return fail(stderr, ExitFailure, "index dump %s could not be loaded: %s", *index, err.Error())
+		}
+	}
+	s, err := scheduler.NewScheduler(schedule.HistoryFile, indexer)
+	if err != nil {
+		return fail(stderr, ExitFailure, "%s", err.Error())
+	}
+	defer s.Close()
+	s.IndexFile = *index
+	if err := s.LoadJobs(schedule.JobsFile); err != nil {
+		return fail(stderr, ExitUsage, "jobs file %s could not be loaded: %s", schedule.JobsFile, err.Error())
+	}
+
+	if *run != "" {
+		record, err := s.RunJob(*run)
+		if record == nil {
+			return fail(stderr, ExitUsage, "%s", err.Error())
+		}
+		if err != nil {
+			return fail(stderr, ExitFailure, "run could not be recorded: %s", err.Error())
+		}
+		if record.Status == scheduler.RunFailed {
+			return fail(stderr, ExitFailure, "job %s failed: %s", record.Job, record.Error)
+		}
+		if *asJSON {
+			return output(stderr, writeJSON(stdout, record))
+		}
+		fmt.Fprintf(stdout, "Job %s %s, %d pages crawled and indexed into %s\n",
+			record.Job, record.Status, record.Summary.TotalPages, s.IndexFile)
+		return ExitOK
+	}
+	stop := make(chan struct{})
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+	go func() {
+		<-signals
+		close(stop)
+	}()
+	fmt.Fprintf(stderr, "Scheduling %d jobs of %s\n", len(s.Jobs), schedule.JobsFile)
+	s.Run(stop)
+	return ExitOK
+}
@@ -0,0 +1,99 @@
+package cli
+
+import (
+	"crawler/collector"
+	"crawler/searcher"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+)
+
+// SearchHandler serves the searches over the indexer as json, the query is given by the q parameter
+type SearchHandler struct {
+	Indexer *searcher.Indexer
+	// Statistics of the crawl served at /stats when the results file is given
+	Stats *CrawlStats
+}
+
+func (h *SearchHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/search":
+		h.serveSearch(w, r)
+	case "/stats":
+		h.serveStats(w)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (h *SearchHandler) serveSearch(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if query.Get("q") == "" {
+		writeError(w, http.StatusBadRequest, "missing the q parameter")
+		return
+	}
+	limit := 10
+	if value := query.Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid limit: %s", value))
+			return
+		}
+		limit = parsed
+	}
+	writeResponse(w, http.StatusOK, search(h.Indexer, query.Get("q"), query.Get("field"), limit))
+}
+
+func (h *SearchHandler) serveStats(w http.ResponseWriter) {
+	if h.Stats == nil {
+		writeError(w, http.StatusNotFound, "no results file is served")
+		return
+	}
+	writeResponse(w, http.StatusOK, h.Stats)
+}
+
+func writeResponse(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = writeJSON(w, v)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeResponse(w, status, map[string]string{"error": message})
+}
+
+func runServe(args []string, stdout io.Writer, stderr io.Writer) int {
+	flags := newFlagSet("serve", "", stderr)
+	configFile := flags.String("config", "", "yaml or json config file")
+	addr := flags.String("addr", DefaultServeAddr, "address to listen on")
+	index := flags.String("index", searcher.IndexDumpFile, "index dump to serve")
+	results := flags.String("results", "", "index and serve the results file of a crawl instead of the index dump")
+	if code, ok := parseFlags(flags, args); !ok {
+		return code
+	}
+	config, err := LoadConfig(*configFile)
+	if err != nil {
+		return fail(stderr, ExitUsage, "%s", err.Error())
+	}
+	if setFlags(flags)["addr"] {
+		config.Serve.Addr = *addr
+	}
+	indexer, err := loadIndexer(&config.Index, *index, *results, stderr)
+	if err != nil {
+		return fail(stderr, ExitFailure, "%s", err.Error())
+	}
+	handler := &SearchHandler{Indexer: indexer}
+	if *results != "" {
+		data, err := collector.LoadResultData(*results)
+		if err != nil {
+			return fail(stderr, ExitFailure, "results file %s could not be loaded: %s", *results, err.Error())
+		}
+		handler.Stats = BuildCrawlStats(data)
+	}
+	fmt.Fprintf(stderr, "Serving the search on %s\n", config.Serve.Addr)
+	if err := http.ListenAndServe(config.Serve.Addr, handler); err != nil {
+		return fail(stderr, ExitFailure, "%s", err.Error())
+	}
+	return ExitOK
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"crawler/cli"
+	"crawler/collector"
+	"crawler/distributed"
+	"flag"
+	"fmt"
+	"log"
+)
+
+// Runs a node of a distributed crawl, for instance on a single machine:
+//
+//	go run ./cmd/distributed -mode coordinator -addr :8090 -seed https://vtk.org/ -depth 3
+//	go run ./cmd/distributed -mode worker -coordinator http://localhost:8090
+//	go run ./cmd/distributed -mode worker -coordinator http://localhost:8090
+//
+// The coordinator and the workers given the same crawl config scrape with the same transport, login and
+// extraction options:
+//
+//	go run ./cmd/distributed -mode coordinator -config data/crawler.example.yaml
+//	go run ./cmd/distributed -mode worker -config data/crawler.example.yaml -coordinator http://localhost:8090
+func main() {
+	mode := flag.String("mode", "", "coordinator or worker")
+	configFile := flag.String("config", "", "yaml or json config file of the crawl")
+	addr := flag.String("addr", ":8090", "address the coordinator listens on")
+	seed := flag.String("seed", "", "seed url of the crawl")
+	depth := flag.Int("depth", cli.DefaultDepth, "depth of the crawl")
+	file := flag.String("file", cli.DefaultResultsFile, "results file of the crawl")
+	maxPages := flag.Int("max-pages", 0, "number of pages to scrape at most, zero means no limit")
+	coordinator := flag.String("coordinator", "http://localhost:8090", "url of the coordinator")
+	concurrency := flag.Int("concurrency", collector.DefaultConcurrency, "number of hosts a worker scrapes at the same time")
+	flag.Parse()
+
+	config, err := cli.LoadConfig(*configFile)
+	if err != nil {
+		log.Fatalf("Config could not be loaded: %s\n", err.Error())
+	}
+	crawl := &config.Crawl
+	set := map[string]bool{}
+	flag.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+	if set["seed"] {
+		crawl.Seeds = []string{*seed}
+	}
+	if set["depth"] {
+		crawl.Depth = *depth
+	}
+	if set["file"] {
+		crawl.ResultsFile = *file
+	}
+	if set["max-pages"] {
+		crawl.MaxPages = *maxPages
+	}
+
+	switch *mode {
+	case "coordinator":
+		if err := crawl.Validate(); err != nil {
+			log.Fatalf("Crawl config is not valid: %s\n", err.Error())
+		}
+		// The coordinator leases the urls of every host in the order they are found, it keeps the page budget only
+		budget := crawl.MaxPages
+		crawl.Priority = ""
+		crawl.MaxPages = 0
+		c, err := crawl.NewCollector()
+		if err != nil {
+			log.Fatalf("Collector could not be initialized: %s\n", err.Error())
+		}
+		c.MaxPages = budget
+		c.SaveToFile = true
+		fmt.Printf("Coordinator listening on %s for the crawl of %s\n", *addr, c.Seed)
+		if err := distributed.NewCoordinator(c).ListenAndServe(*addr); err != nil {
+			log.Fatalf("Coordinator failed: %s\n", err.Error())
+		}
+		fmt.Printf("Crawl finished, results saved into %s\n", crawl.ResultsFile)
+	case "worker":
+		var w *distributed.Worker
+		if *configFile == "" {
+			w, err = distributed.NewWorker(*coordinator)
+		} else {
+			// The frontier, the page budget and the results are kept by the coordinator
+			crawl.StorageDir = ""
+			crawl.Priority = ""
+			crawl.MaxPages = 0
+			if err := crawl.Validate(); err != nil {
+				log.Fatalf("Crawl config is not valid: %s\n", err.Error())
+			}
+			var c *collector.Collector
+			if c, err = crawl.NewCollector(); err == nil {
+				w, err = distributed.NewCollectorWorker(*coordinator, c)
+			}
+		}
+		if err != nil {
+			log.Fatalf("Worker could not be initialized: %s\n", err.Error())
+		}
+		w.Concurrency = *concurrency
+		if err := w.Run(make(chan struct{})); err != nil {
+			log.Fatalf("Worker failed: %s\n", err.Error())
+		}
+	default:
+		log.Fatalf("Mode should be coordinator or worker\n")
+	}
+}
@@ -0,0 +1,268 @@
+package collector
+
+import (
+	"errors"
+	"github.com/PuerkitoBio/goquery"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+const (
+	AssetImage      = "image"
+	AssetScript     = "script"
+	AssetStylesheet = "stylesheet"
+	AssetIcon       = "icon"
+	AssetFont       = "font"
+	AssetIframe     = "iframe"
+	AssetVideo      = "video"
+	AssetAudio      = "audio"
+	AssetObject     = "object"
+	// Number of the assets of a page checked at the same time
+	DefaultAssetChecks = 4
+)
+
+// Elements referencing the assets with the attribute holding the url, the type and the attributes recorded
+var assetSelectors = []struct {
+	Selector   string
+	Attr       string
+	Type       string
+	Attributes []string
+}{
+	{"img[src]", "src", AssetImage, []string{"alt", "width", "height", "loading", "srcset"}},
+	{"input[type=image][src]", "src", AssetImage, []string{"alt", "width", "height"}},
+	{"picture source[srcset]", "srcset", AssetImage, []string{"media", "type"}},
+	{"script[src]", "src", AssetScript, []string{"type", "async", "defer", "integrity"}},
+	{"link[href]", "href", "", []string{"media", "type", "as", "integrity"}},
+	{"iframe[src]", "src", AssetIframe, []string{"title", "width", "height", "loading"}},
+	{"video[src]", "src", AssetVideo, []string{"width", "height", "autoplay", "preload"}},
+	{"video[poster]", "poster", AssetImage, []string{"width", "height"}},
+	{"video source[src]", "src", AssetVideo, []string{"type", "media"}},
+	{"audio[src]", "src", AssetAudio, []string{"autoplay", "preload"}},
+	{"audio source[src]", "src", AssetAudio, []string{"type"}},
+	{"embed[src]", "src", AssetObject, []string{"type", "width", "height"}},
+	{"object[data]", "data", AssetObject, []string{"type", "width", "height"}},
+}
+
+// PageAsset is a resource referenced by a page, the status, the size and the content type are filled by
+// the availability checks
+type PageAsset struct {
+	Type       string            `json:"type"`
+	Url        string            `json:"url"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+	// Images without an alt attribute, an empty alt marks a decorative image and is not missing
+	MissingAlt  bool   `json:"missing_alt,omitempty"`
+	Checked     bool   `json:"checked,omitempty"`
+	StatusCode  int    `json:"status_code,omitempty"`
+	Size        int64  `json:"size,omitempty"`
+	ContentType string `json:"content_type,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+// Broken is true when the availability check of the asset failed
+func (a *PageAsset) Broken() bool {
+	return a.Checked && a.Error != ""
+}
+
+// PageWeight sums the sizes of the html and the distinct assets of a page
+type PageWeight struct {
+	HTMLBytes  int64            `json:"html_bytes"`
+	AssetBytes int64            `json:"asset_bytes"`
+	TotalBytes int64            `json:"total_bytes"`
+	ByType     map[string]int64 `json:"by_type"`
+	Assets     int              `json:"assets"`
+	// Assets which are not checked or whose size is not reported
+	UnknownSizes int `json:"unknown_sizes"`
+	BrokenAssets int `json:"broken_assets"`
+}
+
+type AssetExtractorInterface interface {
+	Extract(pageURL string, doc *goquery.Document) []*PageAsset
+	Check(assets []*PageAsset, requester *Request)
+}
+
+type AssetExtractor struct {
+	// Assets are requested with head requests when set, the results are shared by the pages referencing them
+	CheckAvailability bool
+	MaxChecks         int
+	Checked           map[string]*PageAsset
+	Mutex             sync.Mutex
+}
+
+func NewAssetExtractor(checkAvailability bool) *AssetExtractor {
+	return &AssetExtractor{
+		CheckAvailability: checkAvailability,
+		MaxChecks:         DefaultAssetChecks,
+		Checked:           map[string]*PageAsset{},
+	}
+}
+
+// Extract returns the assets referenced by the document, the data urls are left out
+func (e *AssetExtractor) Extract(pageURL string, doc *goquery.Document) []*PageAsset {
+	assets := []*PageAsset{}
+	for _, selector := range assetSelectors {
+		doc.Find(selector.Selector).Each(func(i int, s *goquery.Selection) {
+			assetType := selector.Type
+			if assetType == "" {
+				if assetType = linkAssetType(s); assetType == "" {
+					return
+				}
+			}
+			value := strings.TrimSpace(s.AttrOr(selector.Attr, ""))
+			if selector.Attr == "srcset" {
+				value = firstSrcsetUrl(value)
+			}
+			if value == "" || strings.HasPrefix(value, "data:") {
+				return
+			}
+			absoluteUrl, err := AbsoluteURL(pageURL, value)
+			if err != nil {
+				return
+			}
+			asset := &PageAsset{Type: assetType, Url: absoluteUrl}
+			for _, name := range selector.Attributes {
+				if value, exists := s.Attr(name); exists {
+					if asset.Attributes == nil {
+						asset.Attributes = map[string]string{}
+					}
+					asset.Attributes[name] = value
+				}
+			}
+			if assetType == AssetImage && (goquery.NodeName(s) == "img" || goquery.NodeName(s) == "input") {
+				_, hasAlt := s.Attr("alt")
+				asset.MissingAlt = !hasAlt
+			}
+			assets = append(assets, asset)
+		})
+	}
+	return assets
+}
+
+// Check requests the assets with head requests, the assets checked before for another page are not requested
+// again. Assets whose head requests are refused or do not report the size are requested with get requests.
+func (e *AssetExtractor) Check(assets []*PageAsset, requester *Request) {
+	maxChecks := e.MaxChecks
+	if maxChecks <= 0 {
+		maxChecks = 1
+	}
+	semaphore := make(chan struct{}, maxChecks)
+	wg := sync.WaitGroup{}
+	for _, asset := range assets {
+		wg.Add(1)
+		semaphore <- struct{}{}
+		go func(asset *PageAsset) {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+			result := e.check(asset.Url, requester)
+			asset.Checked = true
+			asset.StatusCode = result.StatusCode
+			asset.Size = result.Size
+			asset.ContentType = result.ContentType
+			asset.Error = result.Error
+		}(asset)
+	}
+	wg.Wait()
+}
+
+func (e *AssetExtractor) check(u string, requester *Request) *PageAsset {
+	e.Mutex.Lock()
+	result, exists := e.Checked[u]
+	e.Mutex.Unlock()
+	if exists {
+		return result
+	}
+	result = &PageAsset{Url: u}
+	response, err := requester.HeadRequest(u)
+	var statusError *StatusError
+	if errors.As(err, &statusError) && (statusError.StatusCode == http.StatusMethodNotAllowed ||
+		statusError.StatusCode == http.StatusNotImplemented) {
+		response, err = requester.GetRequest(u)
+	} else if err == nil && response.ContentLength < 0 {
+		response.Body.Close()
+		response, err = requester.GetRequest(u)
+	}
+	if err != nil {
+		result.Error = strings.TrimSpace(err.Error())
+		if errors.As(err, &statusError) {
+			result.StatusCode = statusError.StatusCode
+		}
+	} else {
+		result.StatusCode = response.StatusCode
+		result.ContentType = strings.ToLower(response.Header.Get("Content-Type"))
+		result.Size = response.ContentLength
+		if result.Size < 0 && response.Request.Method == http.MethodGet {
+			result.Size, _ = io.Copy(ioutil.Discard, io.LimitReader(response.Body, MaxDocumentSize))
+		}
+		response.Body.Close()
+	}
+	e.Mutex.Lock()
+	e.Checked[u] = result
+	e.Mutex.Unlock()
+	return result
+}
+
+// AssetWeight returns the weight of a page, every asset is counted once however many times it is referenced
+func AssetWeight(page *SucceededPage) *PageWeight {
+	weight := &PageWeight{HTMLBytes: page.ContentLength, ByType: map[string]int64{}}
+	if weight.HTMLBytes < 0 {
+		weight.HTMLBytes = 0
+	}
+	counted := map[string]bool{}
+	for _, asset := range page.Assets {
+		if counted[asset.Url] {
+			continue
+		}
+		counted[asset.Url] = true
+		weight.Assets++
+		if asset.Broken() {
+			weight.BrokenAssets++
+			continue
+		}
+		if asset.Size <= 0 {
+			weight.UnknownSizes++
+			continue
+		}
+		weight.AssetBytes += asset.Size
+		weight.ByType[asset.Type] += asset.Size
+	}
+	weight.TotalBytes = weight.HTMLBytes + weight.AssetBytes
+	return weight
+}
+
+// linkAssetType returns the asset type of a link element by its relations, links which do not load a
+// resource return an empty type
+func linkAssetType(s *goquery.Selection) string {
+	for _, rel := range strings.Fields(strings.ToLower(s.AttrOr("rel", ""))) {
+		switch rel {
+		case "stylesheet":
+			return AssetStylesheet
+		case "icon", "apple-touch-icon", "mask-icon":
+			return AssetIcon
+		case "preload", "prefetch", "modulepreload":
+			switch strings.ToLower(s.AttrOr("as", "")) {
+			case "font":
+				return AssetFont
+			case "style":
+				return AssetStylesheet
+			case "script":
+				return AssetScript
+			case "image":
+				return AssetImage
+			}
+			if rel == "modulepreload" {
+				return AssetScript
+			}
+		}
+	}
+	return ""
+}
+
+// firstSrcsetUrl returns the url of the first candidate of a srcset attribute which is not a data url
+func firstSrcsetUrl(srcset string) string {
+	if urls := SrcsetUrls(srcset); len(urls) > 0 {
+		return urls[0]
+	}
+	return ""
+}
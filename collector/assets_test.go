@@ -0,0 +1,202 @@
+package collector
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestAssetExtractorFindsTheAssets(t *testing.T) {
+	page := `<html><head>
+<link rel="stylesheet" href="/style.css" media="screen">
+<link rel="icon" href="/favicon.ico">
+<link rel="preload" href="/font.woff2" as="font">
+<link rel="canonical" href="/page">
+<script src="app.js" defer></script>
+<script>inline()</script>
+</head><body>
+<img src="/logo.png" width="10">
+<img src="/spacer.gif" alt="">
+<img src="data:image/png;base64,AAAA" alt="inline">
+<input type="image" src="/submit.png" alt="Submit">
+<picture><source srcset="/wide,1.webp 1x, /wide-2x.webp 2x" media="(min-width: 800px)"></picture>
+<video poster="/poster.jpg"><source src="/clip.mp4" type="video/mp4"></video>
+<iframe src="https://video.example.com/embed" title="Video"></iframe>
+</body></html>`
+	assets := NewAssetExtractor(false).Extract("https://example.com/dir/", parseDocument(t, page))
+	type found struct {
+		Type       string
+		Url        string
+		MissingAlt bool
+	}
+	got := []found{}
+	for _, asset := range assets {
+		got = append(got, found{asset.Type, asset.Url, asset.MissingAlt})
+	}
+	want := []found{
+		{AssetImage, "https://example.com/logo.png", true},
+		// An empty alt marks a decorative image
+		{AssetImage, "https://example.com/spacer.gif", false},
+		{AssetImage, "https://example.com/submit.png", false},
+		{AssetImage, "https://example.com/wide,1.webp", false},
+		{AssetScript, "https://example.com/dir/app.js", false},
+		{AssetStylesheet, "https://example.com/style.css", false},
+		{AssetIcon, "https://example.com/favicon.ico", false},
+		{AssetFont, "https://example.com/font.woff2", false},
+		{AssetIframe, "https://video.example.com/embed", false},
+		{AssetImage, "https://example.com/poster.jpg", false},
+		{AssetVideo, "https://example.com/clip.mp4", false},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got assets\n%v\nwant\n%v", got, want)
+	}
+	if want := map[string]string{"width": "10"}; !reflect.DeepEqual(assets[0].Attributes, want) {
+		t.Errorf("got attributes %v of the logo", assets[0].Attributes)
+	}
+	if want := map[string]string{"media": "screen"}; !reflect.DeepEqual(assets[5].Attributes, want) {
+		t.Errorf("got attributes %v of the stylesheet", assets[5].Attributes)
+	}
+}
+
+// assetServer serves the assets and counts their requests by method and path
+type assetServer struct {
+	*httptest.Server
+	mutex    sync.Mutex
+	requests map[string]int
+}
+
+func serveAssets() *assetServer {
+	s := &assetServer{requests: map[string]int{}}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mutex.Lock()
+		s.requests[r.Method+" "+r.URL.Path]++
+		s.mutex.Unlock()
+		switch r.URL.Path {
+		case "/logo.png":
+			w.Header().Set("Content-Type", "image/png")
+			w.Header().Set("Content-Length", "100")
+		case "/app.js":
+			// Servers refusing the head requests are asked with get requests
+			if r.Method == http.MethodHead {
+				http.Error(w, "", http.StatusMethodNotAllowed)
+				return
+			}
+			w.Header().Set("Content-Type", "text/javascript")
+			fmt.Fprint(w, "console.log('app')")
+		case "/style.css":
+			// The size of a streamed response is counted from its body
+			w.Header().Set("Content-Type", "text/css")
+			if r.Method == http.MethodGet {
+				fmt.Fprint(w, "body {")
+				w.(http.Flusher).Flush()
+				fmt.Fprint(w, "}")
+			}
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	return s
+}
+
+func TestAssetExtractorChecksTheAvailability(t *testing.T) {
+	server := serveAssets()
+	defer server.Close()
+	requester := NewRequestWithTransport(5*time.Second, NewTransport(DefaultTransportOptions()))
+	newAssets := func() []*PageAsset {
+		return []*PageAsset{
+			{Type: AssetImage, Url: server.URL + "/logo.png"},
+			{Type: AssetScript, Url: server.URL + "/app.js"},
+			{Type: AssetStylesheet, Url: server.URL + "/style.css"},
+			{Type: AssetImage, Url: server.URL + "/gone.png"},
+		}
+	}
+	extractor := NewAssetExtractor(true)
+	assets := newAssets()
+	extractor.Check(assets, requester)
+	for i, want := range []struct {
+		statusCode  int
+		size        int64
+		contentType string
+		broken      bool
+	}{
+		{200, 100, "image/png", false},
+		{200, 18, "text/javascript", false},
+		{200, 7, "text/css", false},
+		{404, 0, "", true},
+	} {
+		asset := assets[i]
+		if !asset.Checked || asset.StatusCode != want.statusCode || asset.Size != want.size ||
+			asset.ContentType != want.contentType || asset.Broken() != want.broken {
+			t.Errorf("%s: got %+v", asset.Url, asset)
+		}
+	}
+
+	// The assets checked for a page are not requested again for the other pages
+	extractor.Check(newAssets(), requester)
+	want := map[string]int{
+		"HEAD /logo.png": 1,
+		"HEAD /app.js":   1, "GET /app.js": 1,
+		"HEAD /style.css": 1, "GET /style.css": 1,
+		"HEAD /gone.png": 1,
+	}
+	if !reflect.DeepEqual(server.requests, want) {
+		t.Errorf("got requests %v, want %v", server.requests, want)
+	}
+}
+
+func TestAssetWeight(t *testing.T) {
+	page := &SucceededPage{
+		ContentLength: 1000,
+		Assets: []*PageAsset{
+			{Type: AssetImage, Url: "https://example.com/a.png", Checked: true, Size: 300},
+			// An asset referenced twice is counted once
+			{Type: AssetImage, Url: "https://example.com/a.png", Checked: true, Size: 300},
+			{Type: AssetScript, Url: "https://example.com/app.js", Checked: true, Size: 200},
+			{Type: AssetScript, Url: "https://example.com/gone.js", Checked: true, StatusCode: 404, Error: "status code 404"},
+			{Type: AssetStylesheet, Url: "https://example.com/style.css"},
+		},
+	}
+	want := &PageWeight{
+		HTMLBytes:    1000,
+		AssetBytes:   500,
+		TotalBytes:   1500,
+		ByType:       map[string]int64{AssetImage: 300, AssetScript: 200},
+		Assets:       4,
+		UnknownSizes: 1,
+		BrokenAssets: 1,
+	}
+	if got := AssetWeight(page); !reflect.DeepEqual(got, want) {
+		t.Errorf("got weight %+v, want %+v", got, want)
+	}
+}
+
+func TestCollectorInventoriesTheAssets(t *testing.T) {
+	assets := serveAssets()
+	defer assets.Close()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprintf(w, `<html><body><img src="%s/logo.png"><img src="%s/gone.png" alt="Gone"></body></html>`, assets.URL, assets.URL)
+	}))
+	defer server.Close()
+	loggers := discardLoggers()
+	c := &Collector{Seed: server.URL + "/", Depth: 1, Scrapper: NewScrapper(loggers), Loggers: loggers}
+	c.Scrapper.Transport = NewTransport(DefaultTransportOptions())
+	c.EnableAssetInventory(true)
+	if _, err := c.StartCrawling(); err != nil {
+		t.Fatal(err)
+	}
+	page := c.Scrapper.Succeed[server.URL+"/"]
+	if page == nil {
+		t.Fatalf("page is not scraped: %v", c.Scrapper.Failed)
+	}
+	if len(page.Assets) != 2 || !page.Assets[0].MissingAlt || page.Assets[0].Size != 100 || !page.Assets[1].Broken() {
+		t.Errorf("got assets %+v %+v", page.Assets[0], page.Assets[1])
+	}
+	if page.Weight == nil || page.Weight.AssetBytes != 100 || page.Weight.BrokenAssets != 1 {
+		t.Errorf("got weight %+v", page.Weight)
+	}
+}
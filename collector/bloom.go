@@ -0,0 +1,121 @@
+package collector
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+)
+
+// BloomFilter tells that a key is certainly not added, or that it is probably added with the false positive
+// rate it is sized for
+type BloomFilter struct {
+	Bits   []uint64
+	Size   uint64
+	Hashes int
+}
+
+// NewBloomFilter sizes the filter for the expected number of keys and the false positive rate
+func NewBloomFilter(expectedKeys int, falsePositiveRate float64) *BloomFilter {
+	if expectedKeys < 1 {
+		expectedKeys = 1
+	}
+	if falsePositiveRate <= 0 || falsePositiveRate >= 1 {
+		falsePositiveRate = 0.01
+	}
+	size := uint64(math.Ceil(-float64(expectedKeys) * math.Log(falsePositiveRate) / (math.Ln2 * math.Ln2)))
+	size = (size + 63) / 64 * 64
+	hashes := int(math.Round(float64(size) / float64(expectedKeys) * math.Ln2))
+	if hashes < 1 {
+		hashes = 1
+	}
+	return &BloomFilter{
+		Bits:   make([]uint64, size/64),
+		Size:   size,
+		Hashes: hashes,
+	}
+}
+
+func (b *BloomFilter) Add(key string) {
+	b.AddHash(keyHash(key))
+}
+
+// AddHash adds the key of the 64 bit fnv hash, so that the keys known by their hashes only are added too
+func (b *BloomFilter) AddHash(sum uint64) {
+	h1, h2 := splitHash(sum)
+	for i := 0; i < b.Hashes; i++ {
+		bit := (h1 + uint64(i)*h2) % b.Size
+		b.Bits[bit/64] |= 1 << (bit % 64)
+	}
+}
+
+func (b *BloomFilter) MayContain(key string) bool {
+	h1, h2 := bloomHashes(key)
+	for i := 0; i < b.Hashes; i++ {
+		bit := (h1 + uint64(i)*h2) % b.Size
+		if b.Bits[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Save writes the filter into the file, the file is replaced only once the filter is written
+func (b *BloomFilter) Save(path string) error {
+	file, err := os.Create(path + ".tmp")
+	if err != nil {
+		return err
+	}
+	writer := bufio.NewWriter(file)
+	for _, value := range []interface{}{b.Size, uint64(b.Hashes), b.Bits} {
+		if err := binary.Write(writer, binary.LittleEndian, value); err != nil {
+			file.Close()
+			return err
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// LoadBloomFilter reads the filter saved into the file
+func LoadBloomFilter(path string) (*BloomFilter, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	reader := bufio.NewReader(file)
+	var header [2]uint64
+	if err := binary.Read(reader, binary.LittleEndian, &header); err != nil {
+		return nil, err
+	}
+	if header[0] == 0 || header[0]%64 != 0 || header[1] == 0 {
+		return nil, errors.New(fmt.Sprintf("bloom filter is not valid: %d bits and %d hashes", header[0], header[1]))
+	}
+	b := &BloomFilter{Bits: make([]uint64, header[0]/64), Size: header[0], Hashes: int(header[1])}
+	if err := binary.Read(reader, binary.LittleEndian, b.Bits); err != nil {
+		return nil, err
+	}
+	if _, err := reader.ReadByte(); err != io.EOF {
+		return nil, errors.New("bloom filter is longer than its bits")
+	}
+	return b, nil
+}
+
+// bloomHashes derives the hashes of the key by double hashing the two halves of its 64 bit fnv hash
+func bloomHashes(key string) (uint64, uint64) {
+	return splitHash(keyHash(key))
+}
+
+func splitHash(sum uint64) (uint64, uint64) {
+	return sum & 0xffffffff, sum>>32 | 1
+}
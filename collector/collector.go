@@ -1,13 +1,20 @@
 package collector
 
 import (
+	"bufio"
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"golang.org/x/net/publicsuffix"
+	"io"
 	"io/ioutil"
 	"log"
+	"net/http/cookiejar"
 	"net/url"
 	"os"
+	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 )
@@ -16,6 +23,11 @@ const (
 	LogFile = "logs.txt"
 )
 
+const (
+	// Urls kept in the memory by the frontier when it spills to the disk
+	DefaultMaxFrontierEntries = 100000
+)
+
 const (
 	INFO    = iota
 	WARNING = iota
@@ -37,6 +49,8 @@ type Loggers struct {
 	Warning *log.Logger
 	Error   *log.Logger
 	Mutex   sync.Mutex
+	// Log file the loggers write into, closed by Close
+	File *os.File
 }
 
 func (l *Loggers) Log(t int, msg string) {
@@ -64,32 +78,68 @@ func CreateLoggers(fileName string) (*Loggers, error) {
 		Info:    infoLogger,
 		Warning: warningLogger,
 		Error:   errorLogger,
+		File:    file,
 	}, nil
 }
 
+// Close closes the log file, the loggers must not be used afterwards
+func (l *Loggers) Close() error {
+	if l == nil || l.File == nil {
+		return nil
+	}
+	l.Mutex.Lock()
+	defer l.Mutex.Unlock()
+	return l.File.Close()
+}
+
 type Collector struct {
-	Seed       string
-	Depth      int
-	SaveToFile bool
-	FileName   string
-	Scrapper   *Scrapper
-	Loggers    *Loggers
-	Begin      time.Time
-	End        time.Time
+	Seed string
+	// Seeds crawled after the seed into the same results
+	AdditionalSeeds []string
+	Depth           int
+	SaveToFile      bool
+	FileName        string
+	// Links are not followed from nofollow pages and through nofollow links when set
+	RespectRobots bool
+	// Links of the near duplicates of the pages scraped before are not followed when set
+	SkipDuplicateLinks bool
+	// Urls looking like crawler traps are kept out of the crawl, nil disables the heuristics
+	Traps *TrapDetector
+	// Links are followed only to these hosts when set, otherwise to any host
+	AllowedHosts []string
+	// Urls are scraped in the order of their scores when the frontier is set, otherwise depth first
+	Frontier *Frontier
+	// Crawl stops after this many succeeded pages of the frontier, zero means no limit
+	MaxPages    int
+	Concurrency int
+	// Pages, visited urls and the frontier are kept on the disk under this directory when it is set
+	StorageDir   string
+	ExpectedUrls int
+	Scrapper     *Scrapper
+	Loggers      *Loggers
+	// Progress of the crawl is printed to the output besides the log file, nil prints nothing
+	Output io.Writer
+	Begin  time.Time
+	End    time.Time
 }
 
 type ResultData struct {
-	Seed               string                    `json:"seed"`
-	Depth              int                       `json:"depth"`
-	BeginTimestamp     time.Time                 `json:"begin_timestamp"`
-	EndTimestamp       time.Time                 `json:"end_timestamp"`
-	ExecutionInSeconds float64                   `json:"execution_in_seconds"`
-	PageRatePerSec     float64                   `json:"page_rate_per_sec"`
-	TotalPages         int                       `json:"total_pages"`
-	SucceededPages     int                       `json:"succeeded_pages"`
-	FailedPages        int                       `json:"failed_pages"`
-	Succeed            map[string]*SucceededPage `json:"succeed"`
-	Failed             map[string]*FailedPage    `json:"failed"`
+	Seed               string          `json:"seed"`
+	Depth              int             `json:"depth"`
+	BeginTimestamp     time.Time       `json:"begin_timestamp"`
+	EndTimestamp       time.Time       `json:"end_timestamp"`
+	ExecutionInSeconds float64         `json:"execution_in_seconds"`
+	PageRatePerSec     float64         `json:"page_rate_per_sec"`
+	TotalPages         int             `json:"total_pages"`
+	SucceededPages     int             `json:"succeeded_pages"`
+	FailedPages        int             `json:"failed_pages"`
+	SuppressedUrls     int             `json:"suppressed_urls"`
+	SuppressedByReason map[string]int  `json:"suppressed_by_reason,omitempty"`
+	Transport          *TransportStats `json:"transport,omitempty"`
+	// Distance the near duplicates were detected within, nil when the crawl had no deduplication
+	MaxDuplicateDistance *int                      `json:"max_duplicate_distance,omitempty"`
+	Succeed              map[string]*SucceededPage `json:"succeed"`
+	Failed               map[string]*FailedPage    `json:"failed"`
 }
 
 func NewCollector(seed string, depth int, saveToFile bool, fileName string) (*Collector, error) {
@@ -105,25 +155,329 @@ func NewCollector(seed string, depth int, saveToFile bool, fileName string) (*Co
 		fmt.Printf("Error creating loggers: %s\n", err.Error())
 	}
 	c := &Collector{
-		Seed:       seed,
-		Depth:      depth,
-		SaveToFile: saveToFile,
-		FileName:   fileName,
-		Scrapper:   NewScrapper(loggers),
-		Loggers:    loggers,
+		Seed:          seed,
+		Depth:         depth,
+		SaveToFile:    saveToFile,
+		FileName:      fileName,
+		RespectRobots: true,
+		Traps:         NewTrapDetector(),
+		Concurrency:   DefaultConcurrency,
+		Scrapper:      NewScrapper(loggers),
+		Loggers:       loggers,
+		Output:        os.Stdout,
+	}
+	// Every crawl gets its own transport so that the connection metrics in the results are of the crawl
+	c.Scrapper.Transport = NewTransport(DefaultTransportOptions())
+	if strings.HasPrefix(seed, "file:") {
+		root, err := LocalRoot(seed)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("seed is not a local file: %s", err.Error()))
+		}
+		if err := c.EnableLocalSite(root, ""); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// NewLocalCollector creates the collector of a directory whose pages are recorded under the base url, every html
+// file of the directory is a seed so that the pages without any link to them are crawled too
+func NewLocalCollector(dir string, baseURL string, depth int, saveToFile bool, fileName string) (*Collector, error) {
+	site, err := NewLocalSite(dir, baseURL)
+	if err != nil {
+		return nil, err
+	}
+	pages, err := site.Pages()
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("local site could not be walked: %s", err.Error()))
+	}
+	if len(pages) == 0 {
+		return nil, errors.New(fmt.Sprintf("no html file is found in: %s", site.Root))
+	}
+	// The index of the site is the seed when there is one
+	seed := pages[0]
+	if URLExists(pages, site.BaseURL.String()) {
+		seed = site.BaseURL.String()
+	}
+	c, err := NewCollector(seed, depth, saveToFile, fileName)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.EnableLocalSite(dir, baseURL); err != nil {
+		return nil, err
+	}
+	for _, page := range pages {
+		if err := c.AddSeed(page); err != nil {
+			return nil, err
+		}
 	}
 	return c, nil
 }
 
+// AddSeed adds a seed crawled after the seed of the collector
+func (c *Collector) AddSeed(seed string) error {
+	if _, err := url.ParseRequestURI(seed); err != nil {
+		return errors.New(fmt.Sprintf("seed is not valid url: %s", err.Error()))
+	}
+	if c.Scrapper.Transport.Local != nil {
+		seed = c.Scrapper.Transport.Local.PublicUrl(seed)
+	}
+	if seed != c.Seed && !URLExists(c.AdditionalSeeds, seed) {
+		c.AdditionalSeeds = append(c.AdditionalSeeds, seed)
+	}
+	return nil
+}
+
+// ConfigureTransport replaces the transport of the crawl with one of the given timeouts, limits and dns ttls
+func (c *Collector) ConfigureTransport(options TransportOptions) {
+	previous := c.Scrapper.Transport
+	previous.CloseIdleConnections()
+	c.Scrapper.Transport = NewTransport(options)
+	c.Scrapper.Transport.Local = previous.Local
+	c.Scrapper.Transport.Jar = previous.Jar
+	c.Scrapper.Transport.Session = previous.Session
+}
+
+// Close releases the log file, the page store and the idle connections of the crawl, a collector which is created
+// for every run of a long running process has to be closed after the run
+func (c *Collector) Close() error {
+	c.Scrapper.Transport.CloseIdleConnections()
+	var err error
+	if c.Scrapper.Store != nil {
+		err = c.Scrapper.Store.Close()
+	}
+	if c.Frontier != nil && c.Frontier.Spill != nil {
+		if closeErr := c.Frontier.Spill.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if closeErr := c.Loggers.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// EnableLogin makes the crawl log in with the form before scraping and keep the cookies of the session, the session
+// is renewed when the pages are redirected to the login page
+func (c *Collector) EnableLogin(form LoginForm) error {
+	session, err := NewLoginSession(form)
+	if err != nil {
+		return err
+	}
+	jar, err := cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List})
+	if err != nil {
+		return err
+	}
+	c.Scrapper.Transport.Jar = jar
+	c.Scrapper.Transport.Session = session
+	return nil
+}
+
+// EnableLocalSite makes the crawl read the urls under the base url from the files of the directory and follow
+// the links only within it, the file seeds under the directory are mapped to the base url. An empty base url
+// keeps the file urls of the directory.
+func (c *Collector) EnableLocalSite(dir string, baseURL string) error {
+	site, err := NewLocalSite(dir, baseURL)
+	if err != nil {
+		return errors.New(fmt.Sprintf("local site could not be opened: %s", err.Error()))
+	}
+	c.Scrapper.Transport.Local = site
+	c.Seed = site.PublicUrl(c.Seed)
+	for i, seed := range c.AdditionalSeeds {
+		c.AdditionalSeeds[i] = site.PublicUrl(seed)
+	}
+	return nil
+}
+
+// EnableMainTextExtraction makes the crawl extract the main content of the html pages into SucceededPage.MainText,
+// the blocks repeated across the pages of a host are removed from the main texts when the results are saved
+func (c *Collector) EnableMainTextExtraction() {
+	if c.Scrapper.ContentExtractor == nil {
+		c.Scrapper.ContentExtractor = NewContentExtractor()
+	}
+}
+
+// DisableLanguageDetection makes the crawl record the declared languages only, the pages which do not declare
+// their language are left without one instead of being detected from their text
+func (c *Collector) DisableLanguageDetection() {
+	if c.Scrapper.LanguageDetector != nil {
+		c.Scrapper.LanguageDetector.DeclaredOnly = true
+	}
+}
+
+// EnableLinkSources makes the crawl find the links of the html pages in the elements of the sources besides the
+// anchors, such as LinkSourceIframe or LinkSourceRefresh, the links record their sources in PageLink.Source
+func (c *Collector) EnableLinkSources(sources ...string) error {
+	extractor, err := NewLinkExtractor(sources...)
+	if err != nil {
+		return err
+	}
+	c.Scrapper.LinkExtractor = extractor
+	return nil
+}
+
+// EnableAssetInventory makes the crawl record the assets of the html pages into SucceededPage.Assets and their
+// weight into SucceededPage.Weight, the assets are requested for their availability and size if check is set
+func (c *Collector) EnableAssetInventory(check bool) {
+	c.Scrapper.AssetExtractor = NewAssetExtractor(check)
+}
+
+// EnableDeduplication makes the crawl detect the near duplicate pages into SucceededPage.DuplicateOf, the links
+// of the duplicates are not followed if skipLinks is set
+func (c *Collector) EnableDeduplication(maxDistance int, skipLinks bool) error {
+	deduplicator, err := NewDeduplicator(maxDistance)
+	if err != nil {
+		return err
+	}
+	c.Scrapper.Deduplicator = deduplicator
+	c.SkipDuplicateLinks = skipLinks
+	return nil
+}
+
+// EnablePriorityCrawl makes the crawl scrape the urls with the highest scores first until the page budget is spent,
+// a nil score prefers the urls closer to the seed
+func (c *Collector) EnablePriorityCrawl(score ScoreFunc, maxPages int) {
+	c.Frontier = NewFrontier(score)
+	c.MaxPages = maxPages
+	if c.StorageDir != "" {
+		if err := c.spillFrontier(); err != nil {
+			c.Loggers.Log(ERROR, fmt.Sprintf("Frontier could not spill to the disk: %s\n", err.Error()))
+		}
+	}
+}
+
+// EnableDiskStorage keeps the scraped pages, the visited urls and the frontier on the disk under the directory
+// so that the crawl is not limited by the memory, the bloom filters are sized for the expected number of urls
+func (c *Collector) EnableDiskStorage(dir string, expectedUrls int) error {
+	if expectedUrls <= 0 {
+		expectedUrls = DefaultExpectedUrls
+	}
+	store, err := NewDiskPageStore(filepath.Join(dir, "pages"), expectedUrls)
+	if err != nil {
+		return errors.New(fmt.Sprintf("page store could not be opened: %s", err.Error()))
+	}
+	c.Scrapper.Store = store
+	c.StorageDir = dir
+	c.ExpectedUrls = expectedUrls
+	if c.Traps != nil {
+		admitted, err := NewDiskIndex(filepath.Join(dir, "admitted"), expectedUrls)
+		if err != nil {
+			return errors.New(fmt.Sprintf("admitted urls could not be opened: %s", err.Error()))
+		}
+		admitted.Loggers = c.Loggers
+		c.Traps.Admitted = admitted
+		rejected, err := NewDiskIndex(filepath.Join(dir, "suppressed"), expectedUrls)
+		if err != nil {
+			return errors.New(fmt.Sprintf("suppressed urls could not be opened: %s", err.Error()))
+		}
+		rejected.Loggers = c.Loggers
+		c.Traps.Rejected = rejected
+	}
+	if c.Frontier != nil {
+		return c.spillFrontier()
+	}
+	return nil
+}
+
+func (c *Collector) spillFrontier() error {
+	spill, err := NewDiskQueue(filepath.Join(c.StorageDir, "frontier"))
+	if err != nil {
+		return err
+	}
+	seen, err := NewDiskIndex(filepath.Join(c.StorageDir, "frontier_seen"), c.ExpectedUrls)
+	if err != nil {
+		return err
+	}
+	seen.Loggers = c.Loggers
+	c.Frontier.Spill = spill
+	c.Frontier.Seen = seen
+	c.Frontier.MaxEntries = DefaultMaxFrontierEntries
+	return nil
+}
+
+// FlushStorage writes the visited urls kept in the memory to the disk
+func (c *Collector) FlushStorage() error {
+	if c.Scrapper.Store != nil {
+		if err := c.Scrapper.Store.Index.Flush(); err != nil {
+			return err
+		}
+	}
+	if c.Traps != nil {
+		for _, set := range []UrlSet{c.Traps.Admitted, c.Traps.Rejected} {
+			if index, ok := set.(*DiskIndex); ok {
+				if err := index.Flush(); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	if c.Frontier != nil {
+		if seen, ok := c.Frontier.Seen.(*DiskIndex); ok {
+			if err := seen.Flush(); err != nil {
+				return err
+			}
+		}
+		if c.Frontier.Spill != nil {
+			return c.Frontier.Spill.Close()
+		}
+	}
+	return nil
+}
+
+// LoadExtractionRules makes the crawl extract the custom fields declared in the rules file into SucceededPage.Fields
+func (c *Collector) LoadExtractionRules(path string) error {
+	extractor, err := LoadFieldExtractor(path)
+	if err != nil {
+		return errors.New(fmt.Sprintf("extraction rules could not be loaded: %s", err.Error()))
+	}
+	c.Scrapper.FieldExtractor = extractor
+	return nil
+}
+
 func (c *Collector) StartCrawling() (int, error) {
 	message := fmt.Sprintf("Crawling starting for url: %s with depth: %d\n", c.Seed, c.Depth)
-	fmt.Printf(message)
+	if c.Output != nil {
+		fmt.Fprint(c.Output, message)
+	}
 	c.Loggers.Log(INFO, message)
 	c.Begin = time.Now()
+	if session := c.Scrapper.Transport.Session; session != nil {
+		if err := session.Login(NewRequestWithTransport(c.Scrapper.Timeout, c.Scrapper.Transport)); err != nil {
+			c.Loggers.Log(ERROR, fmt.Sprintf("Login failed: %s\n", err.Error()))
+			return 0, errors.New(fmt.Sprintf("login failed: %s", err.Error()))
+		}
+		c.Loggers.Log(INFO, fmt.Sprintf("Logged in with: %s\n", session.Form.Url))
+	}
+	seeds := c.AdmitUrls(append([]string{c.Seed}, c.AdditionalSeeds...))
+	if c.Frontier != nil {
+		for _, seed := range seeds {
+			c.Frontier.Push(seed, 0, "", nil)
+		}
+		c.CrawlFrontier()
+	} else {
+		for _, seed := range seeds {
+			c.CrawlSeed(seed)
+		}
+	}
+	if err := c.FlushStorage(); err != nil {
+		c.Loggers.Log(ERROR, fmt.Sprintf("Error flushing the storage: %s\n", err.Error()))
+	}
+	if c.SaveToFile {
+		c.End = time.Now()
+		_, _ = c.SaveResultsToFile()
+	}
+	return c.Scrapper.NumberOfPagesSucceed(), nil
+}
+
+// CrawlSeed scrapes the seed and crawls its links depth first
+func (c *Collector) CrawlSeed(seed string) {
+	if !c.SpendHostBudget(seed) {
+		return
+	}
 	var wg sync.WaitGroup
 	channel := make(chan ScrapeResult)
 	wg.Add(1)
-	go c.Scrapper.Scrape(c.Seed, channel, &wg)
+	go c.Scrapper.Scrape(seed, channel, &wg)
 
 	scrapeResult := <-channel
 	if scrapeResult.Error != nil {
@@ -133,11 +487,6 @@ func (c *Collector) StartCrawling() (int, error) {
 		c.Crawl(scrapeResult.Page, c.Depth-1)
 	}
 	wg.Wait()
-	if c.SaveToFile {
-		c.End = time.Now()
-		_, _ = c.SaveResultsToFile()
-	}
-	return c.Scrapper.NumberOfPagesSucceed(), nil
 }
 
 func (c *Collector) Crawl(page *SucceededPage, depth int) {
@@ -147,13 +496,19 @@ func (c *Collector) Crawl(page *SucceededPage, depth int) {
 	if depth <= 0 {
 		return
 	}
+	urls := []string{}
+	for _, u := range c.LinksToFollow(page) {
+		if c.SpendHostBudget(u) {
+			urls = append(urls, u)
+		}
+	}
 	var wg sync.WaitGroup
-	wg.Add(len(page.Urls))
+	wg.Add(len(urls))
 	channel := make(chan ScrapeResult)
-	for _, u := range page.Urls {
+	for _, u := range urls {
 		go c.Scrapper.Scrape(u, channel, &wg)
 	}
-	for range page.Urls {
+	for range urls {
 		scrapeResult := <-channel
 		if scrapeResult.Error != nil {
 			c.Loggers.Log(ERROR, fmt.Sprintf("Scrape error: %s\n", scrapeResult.Error.Error()))
@@ -166,6 +521,140 @@ func (c *Collector) Crawl(page *SucceededPage, depth int) {
 	return
 }
 
+// CrawlFrontier scrapes the urls of the frontier in batches of the concurrency, the links of the scraped pages
+// are pushed into the frontier until the depth is reached
+func (c *Collector) CrawlFrontier() {
+	concurrency := c.Concurrency
+	if concurrency <= 0 {
+		concurrency = DefaultConcurrency
+	}
+	succeeded := 0
+	for {
+		batch := map[string]*FrontierEntry{}
+		for len(batch) < concurrency && (c.MaxPages <= 0 || succeeded+len(batch) < c.MaxPages) {
+			entry := c.Frontier.Pop()
+			if entry == nil {
+				break
+			}
+			if !c.SpendHostBudget(entry.Url) {
+				continue
+			}
+			batch[entry.Url] = entry
+		}
+		if len(batch) == 0 {
+			return
+		}
+		var wg sync.WaitGroup
+		wg.Add(len(batch))
+		channel := make(chan ScrapeResult)
+		for u := range batch {
+			go c.Scrapper.Scrape(u, channel, &wg)
+		}
+		for range batch {
+			scrapeResult := <-channel
+			if scrapeResult.Error != nil {
+				c.Loggers.Log(ERROR, fmt.Sprintf("Scrape error: %s\n", scrapeResult.Error.Error()))
+			}
+			if scrapeResult.Page != nil {
+				succeeded++
+				if entry, exists := batch[scrapeResult.Page.Url]; exists && entry.Depth < c.Depth-1 {
+					c.PushLinks(scrapeResult.Page, entry.Depth+1)
+				}
+			}
+		}
+		wg.Wait()
+	}
+}
+
+// PushLinks adds the links of the page to the frontier with their anchor texts
+func (c *Collector) PushLinks(page *SucceededPage, depth int) {
+	anchorTexts := map[string]string{}
+	for _, link := range page.Links {
+		if _, exists := anchorTexts[link.Url]; !exists || anchorTexts[link.Url] == "" {
+			anchorTexts[link.Url] = link.Text
+		}
+	}
+	for _, u := range c.LinksToFollow(page) {
+		if !c.Scrapper.IsVisited(u) && !c.Scrapper.IsFailed(u) {
+			c.Frontier.Push(u, depth, anchorTexts[u], page)
+		}
+	}
+}
+
+// LinksToFollow returns the urls of the page which are allowed by the robots directives, the login session, the local site,
+// the allowed hosts, the duplicate detection and the crawler trap heuristics
+func (c *Collector) LinksToFollow(page *SucceededPage) []string {
+	if c.SkipDuplicateLinks && page.DuplicateOf != "" {
+		c.Loggers.Log(INFO, fmt.Sprintf("Links are not followed on duplicate page: %s of: %s\n", page.Url, page.DuplicateOf))
+		return []string{}
+	}
+	urls := page.Urls
+	if c.RespectRobots {
+		urls = page.FollowableUrls()
+	}
+	if session := c.Scrapper.Transport.Session; session != nil {
+		allowed := make([]string, 0, len(urls))
+		for _, u := range urls {
+			if !session.Excluded(u) {
+				allowed = append(allowed, u)
+			}
+		}
+		urls = allowed
+	}
+	if local := c.Scrapper.Transport.Local; local != nil {
+		inSite := make([]string, 0, len(urls))
+		for _, u := range urls {
+			if local.Contains(u) {
+				inSite = append(inSite, u)
+			}
+		}
+		urls = inSite
+	}
+	if len(c.AllowedHosts) > 0 {
+		inScope := make([]string, 0, len(urls))
+		for _, u := range urls {
+			if URLExists(c.AllowedHosts, HostOf(u)) {
+				inScope = append(inScope, u)
+			}
+		}
+		urls = inScope
+	}
+	return c.AdmitUrls(urls)
+}
+
+// AdmitUrls returns the urls which are not suppressed by the crawler trap heuristics
+func (c *Collector) AdmitUrls(urls []string) []string {
+	if c.Traps == nil {
+		return urls
+	}
+	admitted := make([]string, 0, len(urls))
+	for _, u := range urls {
+		if reason, first := c.Traps.Check(u); reason != "" {
+			if first {
+				c.Loggers.Log(WARNING, fmt.Sprintf("Url suppressed as crawler trap: %s Reason: %s\n", u, reason))
+			}
+			continue
+		}
+		admitted = append(admitted, u)
+	}
+	return admitted
+}
+
+// SpendHostBudget counts the url against the page budget of its host before it is scraped, false is returned
+// when the budget is spent and the url is suppressed. The urls scraped before or being scraped are not counted.
+func (c *Collector) SpendHostBudget(u string) bool {
+	if c.Traps == nil || c.Scrapper.IsVisited(u) || c.Scrapper.IsFailed(u) || !c.Scrapper.IsProcessed(u) {
+		return true
+	}
+	if reason, first := c.Traps.Spend(u); reason != "" {
+		if first {
+			c.Loggers.Log(WARNING, fmt.Sprintf("Url suppressed as crawler trap: %s Reason: %s\n", u, reason))
+		}
+		return false
+	}
+	return true
+}
+
 func (c *Collector) SaveResultsToFile() (bool, error) {
 	c.Loggers.Log(INFO, fmt.Sprintf("Collecting finished %d pages scrapped successfully %d pages failed\n",
 		c.Scrapper.NumberOfPagesSucceed(),
@@ -176,18 +665,58 @@ func (c *Collector) SaveResultsToFile() (bool, error) {
 	failedPages := c.Scrapper.NumberOfPagesFailed()
 	totalPages := succeededPages + failedPages
 	pageRatePerSec := float64(totalPages) / executionInSec
+	suppressedUrls := 0
+	var suppressedByReason map[string]int
+	if c.Traps != nil {
+		suppressedByReason = c.Traps.SuppressedCounts()
+		for _, count := range suppressedByReason {
+			suppressedUrls += count
+		}
+	}
+	var transportStats *TransportStats
+	if c.Scrapper.Transport != nil {
+		stats := c.Scrapper.Transport.Snapshot()
+		transportStats = &stats
+		c.Loggers.Log(INFO, fmt.Sprintf("Transport made %d requests over %d new and %d reused connections\n",
+			stats.Requests, stats.NewConnections, stats.ReusedConnections))
+	}
+	var maxDuplicateDistance *int
+	if c.Scrapper.Deduplicator != nil {
+		distance := c.Scrapper.Deduplicator.MaxDistance
+		maxDuplicateDistance = &distance
+	}
+	blocks := c.CountBlocks()
+	succeeded := c.Scrapper.Succeed
+	if blocks != nil && c.Scrapper.Store == nil {
+		succeeded = make(map[string]*SucceededPage, len(c.Scrapper.Succeed))
+		for url, page := range c.Scrapper.Succeed {
+			succeeded[url] = blocks.Strip(url, page)
+		}
+	}
 	data := &ResultData{
-		Seed:               c.Seed,
-		Depth:              c.Depth,
-		BeginTimestamp:     c.Begin,
-		EndTimestamp:       c.End,
-		ExecutionInSeconds: executionInSec,
-		PageRatePerSec:     pageRatePerSec,
-		TotalPages:         totalPages,
-		SucceededPages:     succeededPages,
-		FailedPages:        failedPages,
-		Succeed:            c.Scrapper.Succeed,
-		Failed:             c.Scrapper.Failed,
+		Seed:                 c.Seed,
+		Depth:                c.Depth,
+		BeginTimestamp:       c.Begin,
+		EndTimestamp:         c.End,
+		ExecutionInSeconds:   executionInSec,
+		PageRatePerSec:       pageRatePerSec,
+		TotalPages:           totalPages,
+		SucceededPages:       succeededPages,
+		FailedPages:          failedPages,
+		SuppressedUrls:       suppressedUrls,
+		SuppressedByReason:   suppressedByReason,
+		Transport:            transportStats,
+		MaxDuplicateDistance: maxDuplicateDistance,
+		Succeed:              succeeded,
+		Failed:               c.Scrapper.Failed,
+	}
+	if c.Scrapper.Store != nil {
+		if err := c.writeStoredResults(data, blocks); err != nil {
+			c.Loggers.Log(ERROR, fmt.Sprintf("Error saving the results into the file: %s\n", err.Error()))
+			return false, err
+		}
+		c.Loggers.Log(INFO, fmt.Sprintf("Results saved successfully into the file :%s\n", c.FileName))
+		return true, nil
 	}
 	file, err := json.MarshalIndent(data, "", "  ")
 	if err != nil {
@@ -202,3 +731,147 @@ func (c *Collector) SaveResultsToFile() (bool, error) {
 	c.Loggers.Log(INFO, fmt.Sprintf("Results saved successfully into the file :%s\n", c.FileName))
 	return true, nil
 }
+
+// CountBlocks counts the blocks of the main texts of the succeeded pages across their hosts, nil is returned
+// when the main content is not extracted
+func (c *Collector) CountBlocks() *BlockCounts {
+	if c.Scrapper.ContentExtractor == nil {
+		return nil
+	}
+	blocks := c.Scrapper.ContentExtractor.NewBlockCounts()
+	err := c.Scrapper.EachSucceeded(func(url string, page *SucceededPage) error {
+		blocks.Add(url, page.MainText)
+		return nil
+	})
+	if err != nil {
+		c.Loggers.Log(ERROR, fmt.Sprintf("Main text blocks could not be counted: %s\n", err.Error()))
+		return nil
+	}
+	return blocks
+}
+
+// writeStoredResults writes the results with the pages read from the disk store one by one, so that the pages
+// do not have to fit into the memory, the repeated blocks are removed from the main texts when blocks is set
+func (c *Collector) writeStoredResults(data *ResultData, blocks *BlockCounts) error {
+	data.Succeed = map[string]*SucceededPage{}
+	data.Failed = map[string]*FailedPage{}
+	header, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	suffix := []byte(`"succeed":{},"failed":{}}`)
+	if !bytes.HasSuffix(header, suffix) {
+		return errors.New("results should end with the pages")
+	}
+	file, err := os.Create(c.FileName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	writer := bufio.NewWriter(file)
+	_, _ = writer.Write(header[:len(header)-len(suffix)])
+	_, _ = writer.WriteString(`"succeed":{`)
+	separator := ""
+	writePage := func(url string, page interface{}) error {
+		key, err := json.Marshal(url)
+		if err != nil {
+			return err
+		}
+		value, err := json.Marshal(page)
+		if err != nil {
+			return err
+		}
+		_, err = writer.WriteString(separator + string(key) + ":" + string(value))
+		separator = ","
+		return err
+	}
+	err = c.Scrapper.EachSucceeded(func(url string, page *SucceededPage) error {
+		if blocks != nil {
+			page = blocks.Strip(url, page)
+		}
+		return writePage(url, page)
+	})
+	if err != nil {
+		return err
+	}
+	_, _ = writer.WriteString(`},"failed":{`)
+	separator = ""
+	err = c.Scrapper.EachFailed(func(url string, page *FailedPage) error {
+		return writePage(url, page)
+	})
+	if err != nil {
+		return err
+	}
+	_, _ = writer.WriteString("}}\n")
+	return writer.Flush()
+}
+
+// LoadResultsFromFile restores the pages of a previous run from the results file so that the following runs
+// append to them, a missing file is not an error
+func (c *Collector) LoadResultsFromFile() error {
+	if _, err := os.Stat(c.FileName); os.IsNotExist(err) {
+		return nil
+	}
+	data, err := LoadResultData(c.FileName)
+	if err != nil {
+		c.Loggers.Log(ERROR, fmt.Sprintf("Error loading the results file: %s\n", err.Error()))
+		return err
+	}
+	if c.Scrapper.Store == nil {
+		c.Scrapper.Mutex.Lock()
+		for u, page := range data.Succeed {
+			c.Scrapper.Succeed[u] = page
+		}
+		for u, page := range data.Failed {
+			c.Scrapper.Failed[u] = page
+		}
+		c.Scrapper.Mutex.Unlock()
+	} else if err := c.storeResults(data); err != nil {
+		c.Loggers.Log(ERROR, fmt.Sprintf("Error storing the results loaded from the file: %s\n", err.Error()))
+		return err
+	}
+	if c.Traps != nil {
+		c.Traps.Mutex.Lock()
+		for reason, count := range data.SuppressedByReason {
+			c.Traps.Suppressed[reason] += count
+		}
+		c.Traps.Mutex.Unlock()
+	}
+	c.Loggers.Log(INFO, fmt.Sprintf("Results loaded from the file: %s\n", c.FileName))
+	return nil
+}
+
+// storeResults puts the pages of the results into the disk store, the store does its own locking
+func (c *Collector) storeResults(data *ResultData) error {
+	for u, page := range data.Succeed {
+		if err := c.Scrapper.Store.PutSucceeded(u, page); err != nil {
+			return err
+		}
+	}
+	for u, page := range data.Failed {
+		if err := c.Scrapper.Store.PutFailed(u, page); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LoadResultData reads a results file saved by a collector
+func LoadResultData(path string) (*ResultData, error) {
+	file, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var data ResultData
+	err = json.Unmarshal(file, &data)
+	if err != nil {
+		return nil, err
+	}
+	if data.Succeed == nil {
+		data.Succeed = map[string]*SucceededPage{}
+	}
+	if data.Failed == nil {
+		data.Failed = map[string]*FailedPage{}
+	}
+	return &data, nil
+}
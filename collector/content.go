@@ -0,0 +1,325 @@
+package collector
+
+import (
+	"container/heap"
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+	"hash/fnv"
+	"regexp"
+	"strings"
+)
+
+const (
+	// Blocks of a host counted at most when looking for the repeated ones
+	DefaultMaxBlockHashes = 100000
+	// The blocks of a main text are separated by a blank line
+	mainTextSeparator   = "\n\n"
+	boilerplateSelector = "nav, footer, aside, header, script, style, noscript, form, iframe, template, " +
+		"[role=navigation], [role=banner], [role=contentinfo], [role=complementary], [aria-hidden=true]"
+	textBlockSelector = "p, pre, blockquote, li, td, dd, dt, h1, h2, h3, h4, h5, h6"
+)
+
+var (
+	negativeClassPattern = regexp.MustCompile(`(?i)(^|[\s_-])(nav|navbar|menu|footer|foot|sidebar|side|aside|` +
+		`cookie|consent|banner|breadcrumbs?|share|social|sponsor|ad|ads|advert|promo|popup|modal|comment|meta|` +
+		`related|widget|masthead|skip)([\s_-]|$)`)
+	positiveClassPattern = regexp.MustCompile(`(?i)(^|[\s_-])(article|body|content|entry|main|page|post|text|` +
+		`blog|story|documentation|docs)([\s_-]|$)`)
+)
+
+type ContentExtractorInterface interface {
+	Extract(doc *goquery.Document) string
+}
+
+type ContentExtractor struct {
+	// Minimum number of characters a text block needs to be taken into account
+	MinTextLength int
+	// Blocks whose link text exceeds this ratio of their whole text are considered navigation
+	MaxLinkDensity float64
+	// Blocks seen on at least this number of pages of the same host are considered boilerplate
+	RepeatThreshold int
+	// Blocks of a host counted at most by the block counts
+	MaxBlockHashes int
+}
+
+// BlockCounts holds the number of pages of each host the blocks of the main texts are found on, they are
+// counted once the crawl is over so that the repeated blocks do not depend on the order the pages are scraped
+// in. A host keeps the counts of its MaxHashes lowest block hashes, which are the same whatever the order
+// the pages are added in.
+type BlockCounts struct {
+	RepeatThreshold int
+	MaxHashes       int
+	Hosts           map[string]map[uint64]int
+	// Counted hashes of each host, the highest first
+	hashes map[string]*blockHashHeap
+}
+
+type textBlock struct {
+	Node        *html.Node
+	Text        string
+	LinkDensity float64
+}
+
+func NewContentExtractor() *ContentExtractor {
+	return &ContentExtractor{
+		MinTextLength:   25,
+		MaxLinkDensity:  0.5,
+		RepeatThreshold: 3,
+		MaxBlockHashes:  DefaultMaxBlockHashes,
+	}
+}
+
+// Extract returns the main text of the document by removing the boilerplate elements and keeping the text
+// blocks of the highest scored container. The document itself is not modified, the blocks repeated across the
+// pages of the host are removed later with BlockCounts.
+func (e *ContentExtractor) Extract(doc *goquery.Document) string {
+	body := doc.Find("body").First()
+	if body.Length() == 0 {
+		return ""
+	}
+	body = body.Clone()
+	body.Find(boilerplateSelector).Remove()
+	body.Find("*").Each(func(i int, s *goquery.Selection) {
+		if e.ClassWeight(s) < 0 && s.Find("article, main").Length() == 0 {
+			s.Remove()
+		}
+	})
+
+	return e.MainText(e.TextBlocks(body))
+}
+
+// MainText scores the containers of the text blocks by their text and link density and joins the blocks
+// of the best container
+func (e *ContentExtractor) MainText(blocks []textBlock) string {
+	scores := map[*html.Node]float64{}
+	candidates := []*html.Node{}
+	for _, block := range blocks {
+		if block.LinkDensity > e.MaxLinkDensity || len(block.Text) < e.MinTextLength {
+			continue
+		}
+		score := 1 + float64(strings.Count(block.Text, ",")) + minFloat(float64(len(block.Text))/100, 3)
+		score *= 1 - block.LinkDensity
+		parent := block.Node.Parent
+		for level := 0; parent != nil && level < 2; level++ {
+			if _, exists := scores[parent]; !exists {
+				scores[parent] = e.ClassWeight(goquery.NewDocumentFromNode(parent).Selection)
+				candidates = append(candidates, parent)
+			}
+			scores[parent] += score / float64(level+1)
+			parent = parent.Parent
+		}
+	}
+
+	var top *html.Node
+	for _, candidate := range candidates {
+		if top == nil || scores[candidate] > scores[top] {
+			top = candidate
+		}
+	}
+
+	// Siblings of the top candidate holding a good amount of content belong to the main content as well
+	containers := []*html.Node{}
+	if top != nil {
+		containers = append(containers, top)
+		if top.Parent != nil {
+			for sibling := top.Parent.FirstChild; sibling != nil; sibling = sibling.NextSibling {
+				if sibling != top && scores[sibling] >= 10 && scores[sibling] >= scores[top]*0.2 {
+					containers = append(containers, sibling)
+				}
+			}
+		}
+	}
+
+	texts := []string{}
+	for _, block := range blocks {
+		if block.LinkDensity > e.MaxLinkDensity {
+			continue
+		}
+		if len(containers) > 0 && !isDescendantOfAny(block.Node, containers) {
+			continue
+		}
+		if len(block.Text) < e.MinTextLength && !isHeading(block.Node) {
+			continue
+		}
+		texts = append(texts, block.Text)
+	}
+	return strings.Join(texts, mainTextSeparator)
+}
+
+// TextBlocks collects the innermost text blocks of the selection in document order
+func (e *ContentExtractor) TextBlocks(root *goquery.Selection) []textBlock {
+	blocks := []textBlock{}
+	root.Find(textBlockSelector).Each(func(i int, s *goquery.Selection) {
+		if s.Find(textBlockSelector).Length() > 0 {
+			return
+		}
+		text := NormalizeSpaces(s.Text())
+		if text == "" {
+			return
+		}
+		linkLength := 0
+		s.Find("a").Each(func(i int, a *goquery.Selection) {
+			linkLength += len(NormalizeSpaces(a.Text()))
+		})
+		blocks = append(blocks, textBlock{
+			Node:        s.Get(0),
+			Text:        text,
+			LinkDensity: float64(linkLength) / float64(len(text)),
+		})
+	})
+	return blocks
+}
+
+// NewBlockCounts returns empty block counts with the thresholds of the extractor
+func (e *ContentExtractor) NewBlockCounts() *BlockCounts {
+	return &BlockCounts{
+		RepeatThreshold: e.RepeatThreshold,
+		MaxHashes:       e.MaxBlockHashes,
+		Hosts:           map[string]map[uint64]int{},
+		hashes:          map[string]*blockHashHeap{},
+	}
+}
+
+// Add counts the blocks of the main text of a page for the host of its url, a block is counted once per page
+func (b *BlockCounts) Add(url string, mainText string) {
+	if mainText == "" {
+		return
+	}
+	host := HostOf(url)
+	counts, exists := b.Hosts[host]
+	if !exists {
+		counts = map[uint64]int{}
+		b.Hosts[host] = counts
+		b.hashes[host] = &blockHashHeap{}
+	}
+	hashes := b.hashes[host]
+	unique := map[uint64]bool{}
+	for _, block := range strings.Split(mainText, mainTextSeparator) {
+		hash := blockHash(block)
+		if unique[hash] {
+			continue
+		}
+		unique[hash] = true
+		if _, exists := counts[hash]; !exists && b.MaxHashes > 0 && len(counts) >= b.MaxHashes {
+			// A dropped hash is above the highest counted one from then on, so it is never counted again
+			if hash >= (*hashes)[0] {
+				continue
+			}
+			delete(counts, heap.Pop(hashes).(uint64))
+		}
+		if counts[hash] == 0 && b.MaxHashes > 0 {
+			heap.Push(hashes, hash)
+		}
+		counts[hash]++
+	}
+}
+
+// RemoveRepeated returns the main text of a page without the blocks found on RepeatThreshold pages of its host,
+// main texts made only of repeated blocks (mirrors, reloads) keep their content
+func (b *BlockCounts) RemoveRepeated(url string, mainText string) string {
+	counts := b.Hosts[HostOf(url)]
+	if mainText == "" || b.RepeatThreshold <= 0 || len(counts) == 0 {
+		return mainText
+	}
+	texts := []string{}
+	for _, block := range strings.Split(mainText, mainTextSeparator) {
+		if counts[blockHash(block)] < b.RepeatThreshold {
+			texts = append(texts, block)
+		}
+	}
+	if len(texts) == 0 {
+		return mainText
+	}
+	return strings.Join(texts, mainTextSeparator)
+}
+
+// Strip returns the page with the repeated blocks removed from its main text, the page is copied when its
+// main text changes so that the scraped page is left as it is
+func (b *BlockCounts) Strip(url string, page *SucceededPage) *SucceededPage {
+	mainText := b.RemoveRepeated(url, page.MainText)
+	if mainText == page.MainText {
+		return page
+	}
+	stripped := *page
+	stripped.MainText = mainText
+	return &stripped
+}
+
+// ClassWeight scores an element by its class and id attributes, negative values indicate boilerplate
+func (e *ContentExtractor) ClassWeight(s *goquery.Selection) float64 {
+	if s.Is("article, main, [role=main]") {
+		return 25
+	}
+	names := []string{s.AttrOr("class", ""), s.AttrOr("id", "")}
+	weight := 0.0
+	for _, name := range names {
+		if name == "" {
+			continue
+		}
+		if negativeClassPattern.MatchString(name) {
+			weight -= 25
+		}
+		if positiveClassPattern.MatchString(name) {
+			weight += 25
+		}
+	}
+	return weight
+}
+
+// blockHash identifies a text block regardless of its case
+func blockHash(text string) uint64 {
+	hash := fnv.New64a()
+	_, _ = hash.Write([]byte(strings.ToLower(text)))
+	return hash.Sum64()
+}
+
+func isDescendantOfAny(node *html.Node, ancestors []*html.Node) bool {
+	for n := node.Parent; n != nil; n = n.Parent {
+		for _, ancestor := range ancestors {
+			if n == ancestor {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func isHeading(node *html.Node) bool {
+	switch node.Data {
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		return true
+	}
+	return false
+}
+
+func minFloat(a float64, b float64) float64 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+type blockHashHeap []uint64
+
+func (h blockHashHeap) Len() int {
+	return len(h)
+}
+
+func (h blockHashHeap) Less(i, j int) bool {
+	return h[i] > h[j]
+}
+
+func (h blockHashHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+}
+
+func (h *blockHashHeap) Push(x interface{}) {
+	*h = append(*h, x.(uint64))
+}
+
+func (h *blockHashHeap) Pop() interface{} {
+	old := *h
+	hash := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return hash
+}
@@ -0,0 +1,215 @@
+package collector
+
+import (
+	"fmt"
+	"github.com/PuerkitoBio/goquery"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func parseDocument(t *testing.T, page string) *goquery.Document {
+	t.Helper()
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(page))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return doc
+}
+
+const articlePage = `<html><body>
+<header><a href="/">Home</a> <a href="/blog">Blog</a></header>
+<nav class="menu"><ul><li><a href="/a">First section of the site</a></li><li><a href="/b">Second section of the site</a></li></ul></nav>
+<div class="sidebar"><p>Subscribe to the newsletter to get the latest posts, offers and news.</p></div>
+<article>
+<h1>Crawling politely</h1>
+<p>A polite crawler waits between the requests to the same host, so that the site is not overloaded.</p>
+<p>It respects the robots rules of the site, and it identifies itself with a clear user agent.</p>
+</article>
+<footer><p>Copyright of the example site, all rights reserved, since the beginning.</p></footer>
+</body></html>`
+
+func TestContentExtractorKeepsTheArticle(t *testing.T) {
+	text := NewContentExtractor().Extract(parseDocument(t, articlePage))
+	for _, want := range []string{"Crawling politely", "A polite crawler waits", "It respects the robots rules"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("main text misses %q: %q", want, text)
+		}
+	}
+	for _, boilerplate := range []string{"Home", "First section", "newsletter", "Copyright"} {
+		if strings.Contains(text, boilerplate) {
+			t.Errorf("main text contains the boilerplate %q: %q", boilerplate, text)
+		}
+	}
+}
+
+func TestContentExtractorSkipsLinkDenseBlocks(t *testing.T) {
+	page := `<html><body><div class="content">
+<p>The crawler keeps the text blocks of the content, the blocks made of links are left out of the main text.</p>
+<p><a href="/1">Related article number one</a> <a href="/2">Related article number two</a></p>
+</div></body></html>`
+	text := NewContentExtractor().Extract(parseDocument(t, page))
+	if !strings.Contains(text, "The crawler keeps the text blocks") {
+		t.Errorf("main text misses the content: %q", text)
+	}
+	if strings.Contains(text, "Related article") {
+		t.Errorf("main text contains the links: %q", text)
+	}
+}
+
+// productPages returns the main texts of the product pages of a shop which all end with the same block
+func productPages(t *testing.T, extractor *ContentExtractor) map[string]string {
+	t.Helper()
+	texts := map[string]string{}
+	for i := 0; i < 2*extractor.RepeatThreshold; i++ {
+		page := fmt.Sprintf(`<html><body><div><p>Page %d describes its own product in a long enough paragraph, with details.</p>
+<p>Our shop ships worldwide, returns are free within thirty days of the purchase.</p></div></body></html>`, i)
+		texts[fmt.Sprintf("https://shop.example.com/%d", i)] = extractor.Extract(parseDocument(t, page))
+	}
+	return texts
+}
+
+func TestBlockCountsRemoveTheBlocksRepeatedAcrossTheHost(t *testing.T) {
+	extractor := NewContentExtractor()
+	texts := productPages(t, extractor)
+	blocks := extractor.NewBlockCounts()
+	for url, text := range texts {
+		blocks.Add(url, text)
+	}
+	for url, text := range texts {
+		if !strings.Contains(text, "ships worldwide") {
+			t.Fatalf("%s: extracted main text misses the shop block: %q", url, text)
+		}
+		stripped := blocks.RemoveRepeated(url, text)
+		if strings.Contains(stripped, "ships worldwide") || !strings.Contains(stripped, "describes its own product") {
+			t.Errorf("%s: got main text %q", url, stripped)
+		}
+	}
+	// The blocks are counted per host
+	other := "Our shop ships worldwide, returns are free within thirty days of the purchase."
+	if text := blocks.RemoveRepeated("https://other.example.com/", other); text != other {
+		t.Errorf("block of another host is dropped: %q", text)
+	}
+}
+
+func TestBlockCountsDoNotDependOnTheScrapeOrder(t *testing.T) {
+	extractor := NewContentExtractor()
+	texts := productPages(t, extractor)
+	urls := make([]string, 0, len(texts))
+	for url := range texts {
+		urls = append(urls, url)
+	}
+	sort.Strings(urls)
+	var want map[string]string
+	for _, order := range [][]int{{0, 1, 2, 3, 4, 5}, {5, 4, 3, 2, 1, 0}, {3, 0, 5, 1, 4, 2}} {
+		blocks := extractor.NewBlockCounts()
+		for _, i := range order {
+			blocks.Add(urls[i], texts[urls[i]])
+		}
+		got := map[string]string{}
+		for url, text := range texts {
+			got[url] = blocks.RemoveRepeated(url, text)
+		}
+		if want == nil {
+			want = got
+		} else if !reflect.DeepEqual(got, want) {
+			t.Errorf("order %v: got main texts %q, want %q", order, got, want)
+		}
+	}
+}
+
+func TestBlockCountsKeepPagesMadeOfRepeatedBlocks(t *testing.T) {
+	extractor := NewContentExtractor()
+	page := `<html><body><div><p>The same page is reloaded again and again, and it keeps its content each time.</p></div></body></html>`
+	text := extractor.Extract(parseDocument(t, page))
+	blocks := extractor.NewBlockCounts()
+	for i := 0; i < extractor.RepeatThreshold+1; i++ {
+		blocks.Add(fmt.Sprintf("https://example.com/?reload=%d", i), text)
+	}
+	if stripped := blocks.RemoveRepeated("https://example.com/", text); stripped != text {
+		t.Errorf("content is dropped: %q", stripped)
+	}
+}
+
+func TestBlockCountsAreBoundedPerHost(t *testing.T) {
+	texts := []string{}
+	for i := 0; i < 5; i++ {
+		texts = append(texts, fmt.Sprintf("first\n\nsecond\n\nblock %d", i))
+	}
+	want := map[uint64]int{blockHash("first"): 5, blockHash("second"): 5}
+	for i := range texts {
+		want[blockHash(texts[i][strings.LastIndex(texts[i], "\n")+1:])] = 1
+	}
+	// The lowest hashes are kept with their counts
+	lowest := []uint64{}
+	for hash := range want {
+		lowest = append(lowest, hash)
+	}
+	sort.Slice(lowest, func(i, j int) bool { return lowest[i] < lowest[j] })
+	for _, hash := range lowest[3:] {
+		delete(want, hash)
+	}
+	for _, reversed := range []bool{false, true} {
+		blocks := NewContentExtractor().NewBlockCounts()
+		blocks.MaxHashes = 3
+		for i := range texts {
+			if reversed {
+				i = len(texts) - 1 - i
+			}
+			blocks.Add("https://example.com/", texts[i])
+		}
+		if counts := blocks.Hosts["example.com"]; !reflect.DeepEqual(counts, want) {
+			t.Errorf("got counts %v, want %v", counts, want)
+		}
+	}
+}
+
+func TestCollectorSavesTheMainTextsWithoutTheRepeatedBlocks(t *testing.T) {
+	for _, disk := range []bool{false, true} {
+		loggers := discardLoggers()
+		c := &Collector{Scrapper: NewScrapper(loggers), Loggers: loggers, FileName: filepath.Join(t.TempDir(), "results.json")}
+		c.EnableMainTextExtraction()
+		if disk {
+			if err := c.EnableDiskStorage(t.TempDir(), 100); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for url, text := range productPages(t, c.Scrapper.ContentExtractor) {
+			c.Scrapper.ScrapeSucceed(url, &SucceededPage{Url: url, MainText: text})
+		}
+		if _, err := c.SaveResultsToFile(); err != nil {
+			t.Fatal(err)
+		}
+		data, err := LoadResultData(c.FileName)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(data.Succeed) != 2*c.Scrapper.ContentExtractor.RepeatThreshold {
+			t.Fatalf("disk %t: got %d pages", disk, len(data.Succeed))
+		}
+		for url, page := range data.Succeed {
+			if strings.Contains(page.MainText, "ships worldwide") || !strings.Contains(page.MainText, "its own product") {
+				t.Errorf("disk %t: %s: got saved main text %q", disk, url, page.MainText)
+			}
+		}
+		// The scraped pages are left as they are
+		_ = c.Scrapper.EachSucceeded(func(url string, page *SucceededPage) error {
+			if !strings.Contains(page.MainText, "ships worldwide") {
+				t.Errorf("disk %t: %s: scraped main text is changed", disk, url)
+			}
+			return nil
+		})
+		if err := c.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestContentExtractorWithoutBody(t *testing.T) {
+	doc := parseDocument(t, `<html><head><title>Empty</title></head></html>`)
+	if text := NewContentExtractor().Extract(doc); text != "" {
+		t.Errorf("got %q, want no text", text)
+	}
+}
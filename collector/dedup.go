@@ -0,0 +1,226 @@
+package collector
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math/bits"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+const (
+	// Number of words of a shingle
+	ShingleSize = 3
+	// Pages with less words than this get no fingerprint, their content is too small to compare
+	MinFingerprintWords = 10
+	// Fingerprints differing in at most this many bits out of 64 are near duplicates
+	DefaultMaxHammingDistance = 3
+	// A fingerprint is split into one band more than the distance, with 64 bands every page would be a candidate
+	MaxHammingDistance = 63
+)
+
+type DeduplicatorInterface interface {
+	Register(url string, fingerprint uint64) string
+}
+
+// Deduplicator keeps the fingerprints of the scraped pages to tell whether a new page is a near duplicate
+// of a page scraped before. The fingerprints are split into bands so that the candidates sharing a band
+// are compared only, any two fingerprints within the distance share at least one band.
+type Deduplicator struct {
+	MaxDistance  int
+	Fingerprints map[string]uint64
+	Order        map[string]int
+	Bands        []map[uint64][]string
+	Mutex        sync.Mutex
+}
+
+func NewDeduplicator(maxDistance int) (*Deduplicator, error) {
+	if maxDistance < 0 || maxDistance > MaxHammingDistance {
+		return nil, errors.New(fmt.Sprintf("max distance should be between 0 and %d: %d", MaxHammingDistance, maxDistance))
+	}
+	bands := make([]map[uint64][]string, maxDistance+1)
+	for i := range bands {
+		bands[i] = map[uint64][]string{}
+	}
+	return &Deduplicator{
+		MaxDistance:  maxDistance,
+		Fingerprints: map[string]uint64{},
+		Order:        map[string]int{},
+		Bands:        bands,
+	}, nil
+}
+
+// Matches returns the registered urls within the distance of the fingerprint in the order of registration
+func (d *Deduplicator) Matches(fingerprint uint64) []string {
+	d.Mutex.Lock()
+	defer d.Mutex.Unlock()
+	return d.matches(fingerprint)
+}
+
+func (d *Deduplicator) matches(fingerprint uint64) []string {
+	seen := map[string]bool{}
+	matches := []string{}
+	for i, band := range d.Bands {
+		for _, candidate := range band[bandOf(fingerprint, i, len(d.Bands))] {
+			if !seen[candidate] && HammingDistance(fingerprint, d.Fingerprints[candidate]) <= d.MaxDistance {
+				seen[candidate] = true
+				matches = append(matches, candidate)
+			}
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		return d.Order[matches[i]] < d.Order[matches[j]]
+	})
+	return matches
+}
+
+// Register adds the fingerprint of a page and returns the url of the first page it duplicates, or empty. A page
+// registered again keeps its first fingerprint and duplicates only the pages registered before it.
+func (d *Deduplicator) Register(url string, fingerprint uint64) string {
+	d.Mutex.Lock()
+	defer d.Mutex.Unlock()
+	if registered, exists := d.Fingerprints[url]; exists {
+		for _, match := range d.matches(registered) {
+			if d.Order[match] < d.Order[url] {
+				return match
+			}
+		}
+		return ""
+	}
+	original := ""
+	if matches := d.matches(fingerprint); len(matches) > 0 {
+		original = matches[0]
+	}
+	d.Fingerprints[url] = fingerprint
+	d.Order[url] = len(d.Order)
+	for i, band := range d.Bands {
+		key := bandOf(fingerprint, i, len(d.Bands))
+		band[key] = append(band[key], url)
+	}
+	return original
+}
+
+func bandOf(fingerprint uint64, band int, bands int) uint64 {
+	width := 64 / bands
+	shift := uint(band * width)
+	if band == bands-1 {
+		width = 64 - band*width
+	}
+	if width >= 64 {
+		return fingerprint
+	}
+	return (fingerprint >> shift) & (1<<uint(width) - 1)
+}
+
+// SimHash computes the 64 bit SimHash of the word shingles of the text, false is returned when the
+// text is too small to be fingerprinted
+func SimHash(text string) (uint64, bool) {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	if len(words) < MinFingerprintWords {
+		return 0, false
+	}
+	var weights [64]int
+	for i := 0; i+ShingleSize <= len(words); i++ {
+		hash := fnv.New64a()
+		_, _ = hash.Write([]byte(strings.Join(words[i:i+ShingleSize], " ")))
+		value := hash.Sum64()
+		for bit := 0; bit < 64; bit++ {
+			if value&(1<<uint(bit)) != 0 {
+				weights[bit]++
+			} else {
+				weights[bit]--
+			}
+		}
+	}
+	var fingerprint uint64
+	for bit := 0; bit < 64; bit++ {
+		if weights[bit] > 0 {
+			fingerprint |= 1 << uint(bit)
+		}
+	}
+	return fingerprint, true
+}
+
+func HammingDistance(a uint64, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// PageFingerprint fingerprints the main text of the page if it is extracted, otherwise its paragraphs
+func PageFingerprint(page *SucceededPage) (uint64, bool) {
+	text := page.MainText
+	if text == "" {
+		text = strings.Join(page.Paragrahps, "\n")
+	}
+	return SimHash(text)
+}
+
+func FormatFingerprint(fingerprint uint64) string {
+	return strconv.FormatUint(fingerprint, 16)
+}
+
+func ParseFingerprint(s string) (uint64, bool) {
+	if s == "" {
+		return 0, false
+	}
+	fingerprint, err := strconv.ParseUint(s, 16, 64)
+	return fingerprint, err == nil
+}
+
+// ClusterDuplicates groups the pages whose fingerprints are within the distance, only the clusters with
+// more than one page are returned. Pages of a cluster and the clusters are ordered by url.
+func ClusterDuplicates(pages map[string]*SucceededPage, maxDistance int) ([][]string, error) {
+	deduplicator, err := NewDeduplicator(maxDistance)
+	if err != nil {
+		return nil, err
+	}
+	urls := make([]string, 0, len(pages))
+	for url := range pages {
+		urls = append(urls, url)
+	}
+	sort.Strings(urls)
+
+	parent := map[string]string{}
+	var find func(url string) string
+	find = func(url string) string {
+		if parent[url] != url {
+			parent[url] = find(parent[url])
+		}
+		return parent[url]
+	}
+	for _, url := range urls {
+		fingerprint, ok := ParseFingerprint(pages[url].Fingerprint)
+		if !ok {
+			continue
+		}
+		parent[url] = url
+		// Every near duplicate registered before is joined into the cluster, not only the first one
+		for _, match := range deduplicator.Matches(fingerprint) {
+			parent[find(match)] = find(url)
+		}
+		deduplicator.Register(url, fingerprint)
+	}
+
+	groups := map[string][]string{}
+	for _, url := range urls {
+		if _, ok := parent[url]; ok {
+			root := find(url)
+			groups[root] = append(groups[root], url)
+		}
+	}
+	clusters := [][]string{}
+	for _, group := range groups {
+		if len(group) > 1 {
+			clusters = append(clusters, group)
+		}
+	}
+	sort.Slice(clusters, func(i, j int) bool {
+		return clusters[i][0] < clusters[j][0]
+	})
+	return clusters, nil
+}
@@ -0,0 +1,97 @@
+package collector
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const dedupText = "The crawler visits the pages of the site one after another, it records the text of every page " +
+	"and the links between them, so that the pages can be searched and their duplicates can be found later on."
+
+func TestSimHashOfNearDuplicates(t *testing.T) {
+	original, ok := SimHash(dedupText)
+	if !ok {
+		t.Fatalf("text is not fingerprinted")
+	}
+	nearDuplicate, _ := SimHash(strings.Replace(dedupText, "later on", "afterwards", 1))
+	different, _ := SimHash("Snowball stemmers reduce the words of many languages to their stems, the search " +
+		"analyzes the queries with the stemmer of every language indexed before returning the best ranked results.")
+	if distance := HammingDistance(original, nearDuplicate); distance > 10 {
+		t.Errorf("got distance %d of the near duplicate", distance)
+	}
+	if distance := HammingDistance(original, different); distance <= 10 {
+		t.Errorf("got distance %d of a different text", distance)
+	}
+	if _, ok := SimHash("Too short to compare"); ok {
+		t.Errorf("short text is fingerprinted")
+	}
+}
+
+func TestDeduplicatorRegister(t *testing.T) {
+	deduplicator, err := NewDeduplicator(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fingerprint := uint64(0xF0F0F0F0F0F0F0F0)
+	if original := deduplicator.Register("https://example.com/a", fingerprint); original != "" {
+		t.Errorf("first page duplicates %q", original)
+	}
+	if original := deduplicator.Register("https://example.com/b", fingerprint^0b101); original != "https://example.com/a" {
+		t.Errorf("got original %q, want the first page", original)
+	}
+	if original := deduplicator.Register("https://example.com/c", ^fingerprint); original != "" {
+		t.Errorf("different page duplicates %q", original)
+	}
+	want := []string{"https://example.com/a", "https://example.com/b"}
+	if matches := deduplicator.Matches(fingerprint); !reflect.DeepEqual(matches, want) {
+		t.Errorf("got matches %q, want %q", matches, want)
+	}
+}
+
+func TestDeduplicatorRegisterAgain(t *testing.T) {
+	deduplicator, err := NewDeduplicator(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fingerprint := uint64(0x123456789ABCDEF0)
+	deduplicator.Register("https://example.com/a", fingerprint)
+	deduplicator.Register("https://example.com/b", fingerprint^1)
+	// A page scraped again is not a duplicate of itself nor of the pages registered after it
+	if original := deduplicator.Register("https://example.com/a", fingerprint); original != "" {
+		t.Errorf("page registered again duplicates %q", original)
+	}
+	if original := deduplicator.Register("https://example.com/b", fingerprint^1); original != "https://example.com/a" {
+		t.Errorf("duplicate registered again got original %q", original)
+	}
+	if got := len(deduplicator.Order); got != 2 {
+		t.Errorf("got %d registered pages, want 2", got)
+	}
+}
+
+func TestNewDeduplicatorRejectsDistancesOutOfRange(t *testing.T) {
+	for _, distance := range []int{-1, MaxHammingDistance + 1} {
+		if _, err := NewDeduplicator(distance); err == nil {
+			t.Errorf("distance %d is accepted", distance)
+		}
+	}
+}
+
+func TestClusterDuplicates(t *testing.T) {
+	fingerprint := uint64(0xAAAAAAAAAAAAAAAA)
+	pages := map[string]*SucceededPage{
+		"https://example.com/a": {Fingerprint: FormatFingerprint(fingerprint)},
+		"https://example.com/b": {Fingerprint: FormatFingerprint(fingerprint ^ 0b11)},
+		"https://example.com/c": {Fingerprint: FormatFingerprint(fingerprint ^ 0b11100)},
+		"https://example.com/d": {Fingerprint: FormatFingerprint(^fingerprint)},
+		"https://example.com/e": {},
+	}
+	clusters, err := ClusterDuplicates(pages, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{{"https://example.com/a", "https://example.com/b", "https://example.com/c"}}
+	if !reflect.DeepEqual(clusters, want) {
+		t.Errorf("got clusters %q, want %q", clusters, want)
+	}
+}
@@ -0,0 +1,178 @@
+package collector
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	// Number of the entries written into a segment file of a disk queue before a new one is started
+	DefaultSegmentSize = 10000
+)
+
+// DiskQueue is a first in first out queue of frontier entries kept in segment files, only the oldest
+// segment is read into the memory at a time
+type DiskQueue struct {
+	Dir         string
+	SegmentSize int
+	// Segment files from the oldest to the one being written
+	Segments []string
+	writer   *os.File
+	written  int
+	buffer   []*FrontierEntry
+	// Segment whose entries are in the buffer, it is removed once they are all popped
+	reading  string
+	length   int
+	sequence int
+	Mutex    sync.Mutex
+}
+
+// NewDiskQueue opens the queue in the directory, the entries of the existing segments are kept
+func NewDiskQueue(dir string) (*DiskQueue, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	queue := &DiskQueue{Dir: dir, SegmentSize: DefaultSegmentSize, Segments: []string{}, buffer: []*FrontierEntry{}}
+	matches, err := filepath.Glob(filepath.Join(dir, "*.queue"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(matches)
+	for _, segment := range matches {
+		entries, err := readSegment(segment)
+		if err != nil {
+			return nil, err
+		}
+		queue.Segments = append(queue.Segments, segment)
+		queue.length += len(entries)
+		fmt.Sscanf(strings.TrimSuffix(filepath.Base(segment), ".queue"), "%d", &queue.sequence)
+	}
+	return queue, nil
+}
+
+func (q *DiskQueue) Push(entry *FrontierEntry) error {
+	q.Mutex.Lock()
+	defer q.Mutex.Unlock()
+	if q.writer == nil || q.written >= q.SegmentSize {
+		if err := q.closeWriter(); err != nil {
+			return err
+		}
+		q.sequence++
+		segment := filepath.Join(q.Dir, fmt.Sprintf("%012d.queue", q.sequence))
+		writer, err := os.OpenFile(segment, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+		if err != nil {
+			return err
+		}
+		q.writer = writer
+		q.written = 0
+		q.Segments = append(q.Segments, segment)
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if _, err := q.writer.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	q.written++
+	q.length++
+	return nil
+}
+
+// Pop returns the oldest entry, nil is returned when the queue is empty
+func (q *DiskQueue) Pop() (*FrontierEntry, error) {
+	q.Mutex.Lock()
+	defer q.Mutex.Unlock()
+	for len(q.buffer) == 0 {
+		if q.reading != "" {
+			if err := os.Remove(q.reading); err != nil {
+				return nil, err
+			}
+			q.reading = ""
+		}
+		if len(q.Segments) == 0 {
+			return nil, nil
+		}
+		segment := q.Segments[0]
+		if len(q.Segments) == 1 {
+			if err := q.closeWriter(); err != nil {
+				return nil, err
+			}
+		}
+		entries, err := readSegment(segment)
+		if err != nil {
+			return nil, err
+		}
+		q.Segments = q.Segments[1:]
+		q.buffer = entries
+		q.reading = segment
+	}
+	entry := q.buffer[0]
+	q.buffer = q.buffer[1:]
+	q.length--
+	return entry, nil
+}
+
+func (q *DiskQueue) Len() int {
+	q.Mutex.Lock()
+	defer q.Mutex.Unlock()
+	return q.length
+}
+
+// Close closes the segment being written and writes the entries of the buffer back into the segment they are
+// read from, so that every entry which is not popped stays on the disk
+func (q *DiskQueue) Close() error {
+	q.Mutex.Lock()
+	defer q.Mutex.Unlock()
+	if err := q.closeWriter(); err != nil {
+		return err
+	}
+	if q.reading == "" {
+		return nil
+	}
+	var lines bytes.Buffer
+	for _, entry := range q.buffer {
+		line, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		lines.Write(append(line, '\n'))
+	}
+	return ioutil.WriteFile(q.reading, lines.Bytes(), 0644)
+}
+
+func (q *DiskQueue) closeWriter() error {
+	if q.writer == nil {
+		return nil
+	}
+	err := q.writer.Close()
+	q.writer = nil
+	return err
+}
+
+func readSegment(path string) ([]*FrontierEntry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	entries := []*FrontierEntry{}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), MaxDocumentSize)
+	for scanner.Scan() {
+		var entry FrontierEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		entries = append(entries, &entry)
+	}
+	return entries, scanner.Err()
+}
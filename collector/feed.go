@@ -0,0 +1,163 @@
+package collector
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"github.com/microcosm-cc/bluemonday"
+	"html"
+	"strings"
+)
+
+type Feed struct {
+	Title       string      `json:"title"`
+	Description string      `json:"description"`
+	Link        string      `json:"link"`
+	Items       []*FeedItem `json:"items"`
+}
+
+type FeedItem struct {
+	Id        string `json:"id"`
+	Title     string `json:"title"`
+	Link      string `json:"link"`
+	Summary   string `json:"summary"`
+	Published int64  `json:"published"`
+}
+
+// ParseFeed reads RSS 2.0, RSS 1.0 (RDF) and Atom feeds, the element namespaces are ignored
+func ParseFeed(body []byte) (*Feed, error) {
+	var root xmlNode
+	decoder := xml.NewDecoder(bytes.NewReader(body))
+	decoder.Strict = false
+	decoder.CharsetReader = charsetReader
+	if err := decoder.Decode(&root); err != nil {
+		return nil, err
+	}
+	feed := &Feed{Items: []*FeedItem{}}
+	switch strings.ToLower(root.XMLName.Local) {
+	case "rss":
+		channel := root.Child("channel")
+		if channel == nil {
+			return nil, errors.New("rss feed without channel")
+		}
+		feed.Title = channel.Text("title")
+		feed.Description = StripTags(channel.Text("description"))
+		feed.Link = channel.Text("link")
+		for _, item := range channel.Children("item") {
+			feed.Items = append(feed.Items, rssItem(item))
+		}
+	case "rdf":
+		if channel := root.Child("channel"); channel != nil {
+			feed.Title = channel.Text("title")
+			feed.Description = StripTags(channel.Text("description"))
+			feed.Link = channel.Text("link")
+		}
+		for _, item := range root.Children("item") {
+			feed.Items = append(feed.Items, rssItem(item))
+		}
+	case "feed":
+		feed.Title = StripTags(root.Text("title"))
+		feed.Description = StripTags(root.Text("subtitle"))
+		feed.Link = atomLink(&root)
+		for _, entry := range root.Children("entry") {
+			item := &FeedItem{
+				Id:      entry.Text("id"),
+				Title:   StripTags(entry.Text("title")),
+				Link:    atomLink(entry),
+				Summary: StripTags(entry.Text("summary")),
+			}
+			if item.Summary == "" {
+				item.Summary = StripTags(entry.Text("content"))
+			}
+			item.Published = ParseDate(entry.Text("published"))
+			if item.Published == 0 {
+				item.Published = ParseDate(entry.Text("updated"))
+			}
+			if item.Id == "" {
+				item.Id = item.Link
+			}
+			feed.Items = append(feed.Items, item)
+		}
+	default:
+		return nil, errors.New("document is not a feed")
+	}
+	return feed, nil
+}
+
+func rssItem(item *xmlNode) *FeedItem {
+	result := &FeedItem{
+		Id:        item.Text("guid"),
+		Title:     StripTags(item.Text("title")),
+		Link:      item.Text("link"),
+		Summary:   StripTags(item.Text("description")),
+		Published: ParseDate(item.Text("pubDate")),
+	}
+	if result.Published == 0 {
+		result.Published = ParseDate(item.Text("date"))
+	}
+	if result.Link == "" {
+		result.Link = item.Attr("about")
+	}
+	if result.Id == "" {
+		result.Id = result.Link
+	}
+	return result
+}
+
+// atomLink prefers the alternate link of an Atom feed or entry
+func atomLink(node *xmlNode) string {
+	link := ""
+	for _, child := range node.Children("link") {
+		rel := child.Attr("rel")
+		if rel == "" || rel == "alternate" {
+			return child.Attr("href")
+		}
+		if link == "" {
+			link = child.Attr("href")
+		}
+	}
+	return link
+}
+
+func (n *xmlNode) Child(name string) *xmlNode {
+	for i := range n.Nodes {
+		if strings.EqualFold(n.Nodes[i].XMLName.Local, name) {
+			return &n.Nodes[i]
+		}
+	}
+	return nil
+}
+
+func (n *xmlNode) Children(name string) []*xmlNode {
+	children := []*xmlNode{}
+	for i := range n.Nodes {
+		if strings.EqualFold(n.Nodes[i].XMLName.Local, name) {
+			children = append(children, &n.Nodes[i])
+		}
+	}
+	return children
+}
+
+// Text returns the trimmed character data of the named child, or empty if there is no such child
+func (n *xmlNode) Text(name string) string {
+	child := n.Child(name)
+	if child == nil {
+		return ""
+	}
+	return strings.TrimSpace(child.CharData)
+}
+
+func (n *xmlNode) Attr(name string) string {
+	for _, attr := range n.Attrs {
+		if strings.EqualFold(attr.Name.Local, name) {
+			return strings.TrimSpace(attr.Value)
+		}
+	}
+	return ""
+}
+
+// StripTags removes the html markup of a text, feeds commonly embed html in their descriptions
+func StripTags(s string) string {
+	s = bluemonday.StrictPolicy().Sanitize(s)
+	return NormalizeSpaces(html.UnescapeString(s))
+}
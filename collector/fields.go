@@ -0,0 +1,157 @@
+package collector
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/PuerkitoBio/goquery"
+	"github.com/andybalholm/cascadia"
+	"io/ioutil"
+	"regexp"
+	"strings"
+)
+
+const (
+	// Special attribute names of the extraction rules, anything else is read as an html attribute
+	TextAttribute = "text"
+	HtmlAttribute = "html"
+)
+
+type ExtractionRule struct {
+	Name       string `json:"name"`
+	Selector   string `json:"selector"`
+	Attribute  string `json:"attribute"`
+	Multiple   bool   `json:"multiple"`
+	Regex      string `json:"regex"`
+	UrlPattern string `json:"url_pattern"`
+
+	selector   cascadia.Selector
+	regex      *regexp.Regexp
+	urlPattern *regexp.Regexp
+}
+
+type FieldExtractorInterface interface {
+	Extract(pageURL string, doc *goquery.Document) map[string]interface{}
+}
+
+type FieldExtractor struct {
+	Rules []*ExtractionRule
+}
+
+func NewFieldExtractor(rules []*ExtractionRule) (*FieldExtractor, error) {
+	names := map[string]bool{}
+	for _, rule := range rules {
+		if rule.Name == "" {
+			return nil, errors.New("extraction rule without a name")
+		}
+		if names[rule.Name] {
+			return nil, errors.New(fmt.Sprintf("extraction rule %s is declared more than once", rule.Name))
+		}
+		names[rule.Name] = true
+		selector, err := cascadia.Compile(rule.Selector)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("extraction rule %s has invalid selector: %s", rule.Name, err.Error()))
+		}
+		rule.selector = selector
+		if rule.Regex != "" {
+			rule.regex, err = regexp.Compile(rule.Regex)
+			if err != nil {
+				return nil, errors.New(fmt.Sprintf("extraction rule %s has invalid regex: %s", rule.Name, err.Error()))
+			}
+		}
+		if rule.UrlPattern != "" {
+			rule.urlPattern, err = regexp.Compile(rule.UrlPattern)
+			if err != nil {
+				return nil, errors.New(fmt.Sprintf("extraction rule %s has invalid url pattern: %s", rule.Name, err.Error()))
+			}
+		}
+		if rule.Attribute == "" {
+			rule.Attribute = TextAttribute
+		}
+	}
+	return &FieldExtractor{Rules: rules}, nil
+}
+
+// LoadFieldExtractor reads the extraction rules from a json file holding an array of rules
+func LoadFieldExtractor(path string) (*FieldExtractor, error) {
+	bytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var rules []*ExtractionRule
+	err = json.Unmarshal(bytes, &rules)
+	if err != nil {
+		return nil, err
+	}
+	return NewFieldExtractor(rules)
+}
+
+// Extract applies the rules matching the page url, the fields are strings or string slices for the rules
+// declared as multiple. Fields without any value are left out.
+func (f *FieldExtractor) Extract(pageURL string, doc *goquery.Document) map[string]interface{} {
+	fields := map[string]interface{}{}
+	base := BaseHref(pageURL, doc)
+	for _, rule := range f.Rules {
+		if rule.urlPattern != nil && !rule.urlPattern.MatchString(pageURL) {
+			continue
+		}
+		values := []string{}
+		doc.FindMatcher(rule.selector).EachWithBreak(func(i int, s *goquery.Selection) bool {
+			value, ok := rule.Value(base, s)
+			if ok {
+				values = append(values, value)
+			}
+			return rule.Multiple || len(values) == 0
+		})
+		if len(values) == 0 {
+			continue
+		}
+		if rule.Multiple {
+			fields[rule.Name] = values
+		} else {
+			fields[rule.Name] = values[0]
+		}
+	}
+	return fields
+}
+
+// Value reads the configured attribute of the element and applies the regex, when the regex has a
+// capturing group the first group is used, otherwise the whole match
+func (r *ExtractionRule) Value(pageURL string, s *goquery.Selection) (string, bool) {
+	var value string
+	switch r.Attribute {
+	case TextAttribute:
+		value = NormalizeSpaces(s.Text())
+	case HtmlAttribute:
+		html, err := s.Html()
+		if err != nil {
+			return "", false
+		}
+		value = strings.TrimSpace(html)
+	default:
+		attr, exists := s.Attr(r.Attribute)
+		if !exists {
+			return "", false
+		}
+		value = strings.TrimSpace(attr)
+		if r.Attribute == "href" || r.Attribute == "src" {
+			if absoluteUrl, err := AbsoluteURL(pageURL, value); err == nil {
+				value = absoluteUrl
+			}
+		}
+	}
+	if r.regex != nil {
+		match := r.regex.FindStringSubmatch(value)
+		if match == nil {
+			return "", false
+		}
+		value = match[0]
+		if len(match) > 1 {
+			value = match[1]
+		}
+	}
+	if value == "" {
+		return "", false
+	}
+	return value, true
+}
@@ -0,0 +1,81 @@
+package collector
+
+import (
+	"reflect"
+	"testing"
+)
+
+const productPage = `<html><head><base href="https://cdn.example.com/files/"></head><body>
+<h1>Crawler 2.4.1 released</h1>
+<span class="author">  Jane   Doe </span>
+<meta itemprop="price" content="12.50">
+<div class="summary"><b>Fast</b> and polite</div>
+<a href="crawler-2.4.1.tar.gz">tarball</a>
+<a href="crawler-2.4.1.zip">zip</a>
+<a href="notes.html">notes</a>
+</body></html>`
+
+func TestFieldExtractorAppliesTheRules(t *testing.T) {
+	extractor, err := LoadFieldExtractor("../data/extraction_rules.example.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields := extractor.Extract("https://example.com/products/crawler", parseDocument(t, productPage))
+	want := map[string]interface{}{
+		"author":  "Jane Doe",
+		"version": "2.4.1",
+		"price":   "12.50",
+		// The links are resolved against the base href of the page
+		"downloads": []string{
+			"https://cdn.example.com/files/crawler-2.4.1.tar.gz",
+			"https://cdn.example.com/files/crawler-2.4.1.zip",
+		},
+	}
+	if !reflect.DeepEqual(fields, want) {
+		t.Errorf("got fields %v, want %v", fields, want)
+	}
+}
+
+func TestFieldExtractorSkipsRulesOfOtherUrls(t *testing.T) {
+	extractor, err := LoadFieldExtractor("../data/extraction_rules.example.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields := extractor.Extract("https://example.com/blog/release", parseDocument(t, productPage))
+	if _, exists := fields["price"]; exists {
+		t.Errorf("price is extracted outside of the product pages: %v", fields)
+	}
+	if fields["author"] != "Jane Doe" {
+		t.Errorf("got author %v", fields["author"])
+	}
+}
+
+func TestFieldExtractorLeavesOutFieldsWithoutValues(t *testing.T) {
+	extractor, err := NewFieldExtractor([]*ExtractionRule{
+		{Name: "summary", Selector: ".summary", Attribute: HtmlAttribute},
+		{Name: "missing", Selector: ".missing"},
+		{Name: "no_match", Selector: "h1", Regex: `v(\d+)`},
+		{Name: "no_attribute", Selector: "h1", Attribute: "title"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields := extractor.Extract("https://example.com/", parseDocument(t, productPage))
+	if want := map[string]interface{}{"summary": "<b>Fast</b> and polite"}; !reflect.DeepEqual(fields, want) {
+		t.Errorf("got fields %v, want %v", fields, want)
+	}
+}
+
+func TestNewFieldExtractorRejectsInvalidRules(t *testing.T) {
+	for name, rules := range map[string][]*ExtractionRule{
+		"no name":          {{Selector: "h1"}},
+		"duplicate name":   {{Name: "a", Selector: "h1"}, {Name: "a", Selector: "h2"}},
+		"invalid selector": {{Name: "a", Selector: "h1["}},
+		"invalid regex":    {{Name: "a", Selector: "h1", Regex: "("}},
+		"invalid pattern":  {{Name: "a", Selector: "h1", UrlPattern: "["}},
+	} {
+		if _, err := NewFieldExtractor(rules); err == nil {
+			t.Errorf("%s: rules are accepted", name)
+		}
+	}
+}
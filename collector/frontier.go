@@ -0,0 +1,217 @@
+package collector
+
+import (
+	"container/heap"
+	"math"
+	"sync"
+)
+
+const (
+	// Number of pages scraped at the same time by the priority crawl
+	DefaultConcurrency = 8
+)
+
+// FrontierEntry is a discovered url waiting to be scraped
+type FrontierEntry struct {
+	Url string `json:"url"`
+	// Shortest number of clicks from the seed the url is discovered with
+	Depth int     `json:"depth"`
+	Score float64 `json:"score"`
+	// Number of the scraped pages linking to the url
+	InLinks     int      `json:"in_links"`
+	AnchorTexts []string `json:"anchor_texts"`
+	sequence    int
+	index       int
+}
+
+// ScoreFunc scores a url when it is discovered on the source page, the source is nil for the seed. Urls with
+// a higher score are scraped first, a url keeps the highest score it gets from its sources.
+type ScoreFunc func(entry *FrontierEntry, source *SucceededPage) float64
+
+type WeightedScore struct {
+	Weight float64
+	Score  ScoreFunc
+}
+
+type FrontierInterface interface {
+	Push(u string, depth int, anchorText string, source *SucceededPage)
+	Pop() *FrontierEntry
+	Len() int
+}
+
+// Frontier is the priority queue of the urls to be scraped, urls of the same score are scraped in the
+// order they are discovered. When the spill queue is set the urls discovered beyond MaxEntries wait on
+// the disk until the queue in the memory is empty, so the order is by score within each of the batches.
+type Frontier struct {
+	Score   ScoreFunc
+	Entries frontierHeap
+	Queued  map[string]*FrontierEntry
+	// Urls which are popped or spilled to the disk
+	Seen       UrlSet
+	MaxEntries int
+	Spill      *DiskQueue
+	sequence   int
+	Mutex      sync.Mutex
+}
+
+func NewFrontier(score ScoreFunc) *Frontier {
+	if score == nil {
+		score = DepthScore
+	}
+	return &Frontier{
+		Score:   score,
+		Entries: frontierHeap{},
+		Queued:  map[string]*FrontierEntry{},
+		Seen:    MemoryUrlSet{},
+	}
+}
+
+// Push adds a url discovered on the source page, a url which is queued already gets the link counted and is
+// rescored. Urls which are popped or spilled before are ignored.
+func (f *Frontier) Push(u string, depth int, anchorText string, source *SucceededPage) {
+	f.Mutex.Lock()
+	defer f.Mutex.Unlock()
+	entry, exists := f.Queued[u]
+	if !exists && f.Seen.Contains(u) {
+		return
+	}
+	if !exists {
+		entry = &FrontierEntry{Url: u, Depth: depth, AnchorTexts: []string{}, sequence: f.sequence}
+		f.sequence++
+	}
+	if depth < entry.Depth {
+		entry.Depth = depth
+	}
+	if source != nil {
+		entry.InLinks++
+	}
+	if anchorText != "" && !URLExists(entry.AnchorTexts, anchorText) {
+		entry.AnchorTexts = append(entry.AnchorTexts, anchorText)
+	}
+	score := f.Score(entry, source)
+	if !exists {
+		entry.Score = score
+		if f.Spill != nil && f.MaxEntries > 0 && len(f.Entries) >= f.MaxEntries {
+			if err := f.Spill.Push(entry); err == nil {
+				f.Seen.Add(u)
+				return
+			}
+		}
+		f.Queued[u] = entry
+		heap.Push(&f.Entries, entry)
+		return
+	}
+	if score > entry.Score {
+		entry.Score = score
+	}
+	heap.Fix(&f.Entries, entry.index)
+}
+
+// Pop removes and returns the url with the highest score, nil is returned when the frontier is empty
+func (f *Frontier) Pop() *FrontierEntry {
+	f.Mutex.Lock()
+	defer f.Mutex.Unlock()
+	if len(f.Entries) == 0 {
+		f.refill()
+	}
+	if len(f.Entries) == 0 {
+		return nil
+	}
+	entry := heap.Pop(&f.Entries).(*FrontierEntry)
+	delete(f.Queued, entry.Url)
+	f.Seen.Add(entry.Url)
+	return entry
+}
+
+// refill moves the spilled urls back into the memory up to MaxEntries
+func (f *Frontier) refill() {
+	if f.Spill == nil {
+		return
+	}
+	for len(f.Entries) < f.MaxEntries {
+		entry, err := f.Spill.Pop()
+		if err != nil || entry == nil {
+			return
+		}
+		entry.sequence = f.sequence
+		f.sequence++
+		f.Queued[entry.Url] = entry
+		heap.Push(&f.Entries, entry)
+	}
+}
+
+func (f *Frontier) Len() int {
+	f.Mutex.Lock()
+	defer f.Mutex.Unlock()
+	length := len(f.Entries)
+	if f.Spill != nil {
+		length += f.Spill.Len()
+	}
+	return length
+}
+
+// DepthScore prefers the urls closer to the seed
+func DepthScore(entry *FrontierEntry, source *SucceededPage) float64 {
+	return 1 / float64(1+entry.Depth)
+}
+
+// InLinkScore prefers the urls linked by more pages, the score grows logarithmically in [0, 1)
+func InLinkScore(entry *FrontierEntry, source *SucceededPage) float64 {
+	return 1 - 1/(1+math.Log1p(float64(entry.InLinks)))
+}
+
+// SitemapScore scores the urls by their sitemap priorities, the urls missing from the sitemap get the
+// default priority
+func SitemapScore(priorities map[string]float64) ScoreFunc {
+	return func(entry *FrontierEntry, source *SucceededPage) float64 {
+		if priority, exists := priorities[entry.Url]; exists {
+			return priority
+		}
+		return DefaultSitemapPriority
+	}
+}
+
+// CombineScores sums the weighted scores
+func CombineScores(scores ...WeightedScore) ScoreFunc {
+	return func(entry *FrontierEntry, source *SucceededPage) float64 {
+		total := 0.0
+		for _, score := range scores {
+			total += score.Weight * score.Score(entry, source)
+		}
+		return total
+	}
+}
+
+type frontierHeap []*FrontierEntry
+
+func (h frontierHeap) Len() int {
+	return len(h)
+}
+
+func (h frontierHeap) Less(i, j int) bool {
+	if h[i].Score == h[j].Score {
+		return h[i].sequence < h[j].sequence
+	}
+	return h[i].Score > h[j].Score
+}
+
+func (h frontierHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *frontierHeap) Push(x interface{}) {
+	entry := x.(*FrontierEntry)
+	entry.index = len(*h)
+	*h = append(*h, entry)
+}
+
+func (h *frontierHeap) Pop() interface{} {
+	old := *h
+	entry := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+	entry.index = -1
+	return entry
+}
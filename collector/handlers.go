@@ -0,0 +1,349 @@
+package collector
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+const (
+	// Bodies of the non html documents are read up to this size
+	MaxDocumentSize = 20 * 1024 * 1024
+)
+
+var (
+	markdownHeading     = regexp.MustCompile(`^\s{0,3}(#{1,6})\s+(.*?)\s*#*\s*$`)
+	markdownSetextLine  = regexp.MustCompile(`^\s{0,3}(=+|-+)\s*$`)
+	markdownLink        = regexp.MustCompile(`!?\[([^\]]*)\]\(\s*<?([^)\s>]+)>?(?:\s+"[^"]*")?\s*\)`)
+	markdownAutoLink    = regexp.MustCompile(`<(https?://[^>\s]+)>`)
+	markdownReference   = regexp.MustCompile(`^\s{0,3}\[[^\]]+\]:\s*<?(\S+?)>?(?:\s+["'(].*["')])?\s*$`)
+	markdownListMarker  = regexp.MustCompile(`^\s*(?:[-*+]|\d+[.)])\s+`)
+	markdownEmphasis    = regexp.MustCompile("(\\*{1,3}|~~|`+)")
+	markdownUnderscore  = regexp.MustCompile(`(^|[\s(])_{1,3}|_{1,3}([\s).,;:!?]|$)`)
+	markdownFence       = regexp.MustCompile("^\\s{0,3}(```|~~~)")
+	markdownHTMLTag     = regexp.MustCompile(`</?[a-zA-Z][^>]*>`)
+	markdownBlockquote  = regexp.MustCompile(`^\s{0,3}>\s?`)
+	markdownHorizontal  = regexp.MustCompile(`^\s{0,3}([-*_]\s*){3,}$`)
+	markdownTableBorder = regexp.MustCompile(`^\s*\|?\s*:?-+:?\s*(\|\s*:?-+:?\s*)*\|?\s*$`)
+)
+
+// Content is the searchable part of a document filled into the SucceededPage by the scraper
+type Content struct {
+	Title       string
+	Description string
+	Paragraphs  []string
+	Urls        []string
+}
+
+type ContentHandler interface {
+	Handle(pageURL string, body []byte) (*Content, error)
+}
+
+// ContentHandlerFunc adapts a function into a ContentHandler
+type ContentHandlerFunc func(pageURL string, body []byte) (*Content, error)
+
+func (f ContentHandlerFunc) Handle(pageURL string, body []byte) (*Content, error) {
+	return f(pageURL, body)
+}
+
+// DefaultContentHandlers returns the handlers of the non html documents keyed by their media type
+func DefaultContentHandlers() map[string]ContentHandler {
+	feed := ContentHandlerFunc(HandleXML)
+	return map[string]ContentHandler{
+		"text/plain":           ContentHandlerFunc(HandlePlainText),
+		"text/markdown":        ContentHandlerFunc(HandleMarkdown),
+		"text/x-markdown":      ContentHandlerFunc(HandleMarkdown),
+		"application/pdf":      ContentHandlerFunc(HandlePDF),
+		"application/rss+xml":  feed,
+		"application/atom+xml": feed,
+		"application/rdf+xml":  feed,
+		"application/xml":      feed,
+		"text/xml":             feed,
+	}
+}
+
+// MediaType returns the media type of a content type header, markdown files are commonly served as plain
+// text so the extension of the url is taken into account for them
+func MediaType(contentType string, pageURL string) string {
+	mediaType := strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+	if mediaType == "text/plain" || mediaType == "application/octet-stream" || mediaType == "" {
+		if u, err := url.Parse(pageURL); err == nil {
+			switch strings.ToLower(path.Ext(u.Path)) {
+			case ".md", ".markdown":
+				return "text/markdown"
+			case ".pdf":
+				if mediaType != "text/plain" {
+					return "application/pdf"
+				}
+			}
+		}
+	}
+	return mediaType
+}
+
+// HandlePlainText splits the text into the paragraphs by the blank lines, the first line is the title
+func HandlePlainText(pageURL string, body []byte) (*Content, error) {
+	text := DecodeText(body)
+	content := &Content{Paragraphs: []string{}, Urls: []string{}}
+	lines := []string{}
+	flush := func() {
+		paragraph := NormalizeSpaces(strings.Join(lines, " "))
+		if paragraph != "" {
+			content.Paragraphs = append(content.Paragraphs, paragraph)
+		}
+		lines = lines[:0]
+	}
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			flush()
+			continue
+		}
+		if content.Title == "" {
+			content.Title = NormalizeSpaces(line)
+		}
+		lines = append(lines, line)
+	}
+	flush()
+	if len(content.Paragraphs) > 0 {
+		content.Description = content.Paragraphs[0]
+	}
+	return content, nil
+}
+
+// HandleMarkdown strips the markdown syntax into plain paragraphs, the first heading is the title and
+// the links are resolved against the page url
+func HandleMarkdown(pageURL string, body []byte) (*Content, error) {
+	text := DecodeText(body)
+	content := &Content{Paragraphs: []string{}, Urls: []string{}}
+	addUrl := func(href string) {
+		absoluteUrl, err := AbsoluteURL(pageURL, href)
+		if err == nil && !URLExists(content.Urls, absoluteUrl) {
+			content.Urls = append(content.Urls, absoluteUrl)
+		}
+	}
+	lines := []string{}
+	flush := func() {
+		paragraph := NormalizeSpaces(strings.Join(lines, " "))
+		if paragraph != "" {
+			content.Paragraphs = append(content.Paragraphs, paragraph)
+		}
+		lines = lines[:0]
+	}
+	inFence := false
+	rawLines := strings.Split(text, "\n")
+	for idx, line := range rawLines {
+		if markdownFence.MatchString(line) {
+			flush()
+			inFence = !inFence
+			continue
+		}
+		if inFence {
+			lines = append(lines, line)
+			continue
+		}
+		if match := markdownReference.FindStringSubmatch(line); match != nil {
+			addUrl(match[1])
+			continue
+		}
+		if strings.TrimSpace(line) == "" || markdownHorizontal.MatchString(line) || markdownTableBorder.MatchString(line) {
+			flush()
+			continue
+		}
+		// Setext headings are underlined on the next line
+		if idx+1 < len(rawLines) && markdownSetextLine.MatchString(rawLines[idx+1]) && len(lines) == 0 {
+			heading := StripMarkdown(line)
+			if content.Title == "" {
+				content.Title = heading
+			}
+			content.Paragraphs = append(content.Paragraphs, heading)
+			continue
+		}
+		if markdownSetextLine.MatchString(line) && len(lines) == 0 {
+			continue
+		}
+		for _, match := range markdownLink.FindAllStringSubmatch(line, -1) {
+			if !strings.HasPrefix(match[0], "!") {
+				addUrl(match[2])
+			}
+		}
+		for _, match := range markdownAutoLink.FindAllStringSubmatch(line, -1) {
+			addUrl(match[1])
+		}
+		if match := markdownHeading.FindStringSubmatch(line); match != nil {
+			flush()
+			heading := StripMarkdown(match[2])
+			if content.Title == "" && len(match[1]) == 1 {
+				content.Title = heading
+			}
+			if heading != "" {
+				content.Paragraphs = append(content.Paragraphs, heading)
+			}
+			continue
+		}
+		line = markdownBlockquote.ReplaceAllString(line, "")
+		line = markdownListMarker.ReplaceAllString(line, "")
+		line = strings.Trim(strings.TrimSpace(line), "|")
+		lines = append(lines, StripMarkdown(strings.ReplaceAll(line, "|", " ")))
+	}
+	flush()
+	if content.Title == "" && len(content.Paragraphs) > 0 {
+		content.Title = content.Paragraphs[0]
+	}
+	for _, paragraph := range content.Paragraphs {
+		if paragraph != content.Title {
+			content.Description = paragraph
+			break
+		}
+	}
+	return content, nil
+}
+
+// StripMarkdown removes the inline markdown syntax keeping the text of the links and images
+func StripMarkdown(s string) string {
+	s = markdownLink.ReplaceAllString(s, "$1")
+	s = markdownAutoLink.ReplaceAllString(s, "$1")
+	s = markdownHTMLTag.ReplaceAllString(s, "")
+	s = markdownEmphasis.ReplaceAllString(s, "")
+	s = markdownUnderscore.ReplaceAllString(s, "$1$2")
+	return NormalizeSpaces(s)
+}
+
+// HandlePDF extracts the text of the pages of a pdf document
+func HandlePDF(pageURL string, body []byte) (*Content, error) {
+	doc, err := ParsePDF(body)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("pdf could not be parsed: %s", err.Error()))
+	}
+	content := &Content{
+		Title:      doc.Title(),
+		Paragraphs: doc.Paragraphs(),
+		Urls:       []string{},
+	}
+	for _, link := range doc.Links() {
+		absoluteUrl, err := AbsoluteURL(pageURL, link)
+		if err == nil && !URLExists(content.Urls, absoluteUrl) {
+			content.Urls = append(content.Urls, absoluteUrl)
+		}
+	}
+	if content.Title == "" && len(content.Paragraphs) > 0 {
+		content.Title = content.Paragraphs[0]
+	}
+	content.Description = doc.Subject()
+	return content, nil
+}
+
+type xmlNode struct {
+	XMLName  xml.Name
+	Attrs    []xml.Attr `xml:",any,attr"`
+	CharData string     `xml:",chardata"`
+	Nodes    []xmlNode  `xml:",any"`
+}
+
+// HandleXML reads the RSS, RDF and Atom feeds into their title, entries and links. Other xml documents
+// are indexed by their text nodes.
+func HandleXML(pageURL string, body []byte) (*Content, error) {
+	feed, err := ParseFeed(body)
+	if err == nil {
+		content := &Content{
+			Title:       feed.Title,
+			Description: feed.Description,
+			Paragraphs:  []string{},
+			Urls:        []string{},
+		}
+		for _, item := range feed.Items {
+			for _, text := range []string{item.Title, item.Summary} {
+				if text != "" {
+					content.Paragraphs = append(content.Paragraphs, text)
+				}
+			}
+			absoluteUrl, err := AbsoluteURL(pageURL, item.Link)
+			if err == nil && !URLExists(content.Urls, absoluteUrl) {
+				content.Urls = append(content.Urls, absoluteUrl)
+			}
+		}
+		return content, nil
+	}
+
+	var root xmlNode
+	decoder := xml.NewDecoder(bytes.NewReader(body))
+	decoder.Strict = false
+	decoder.CharsetReader = charsetReader
+	if err := decoder.Decode(&root); err != nil {
+		return nil, errors.New(fmt.Sprintf("xml could not be parsed: %s", err.Error()))
+	}
+	content := &Content{Paragraphs: []string{}, Urls: []string{}}
+	var walk func(node xmlNode)
+	walk = func(node xmlNode) {
+		text := NormalizeSpaces(node.CharData)
+		if text != "" {
+			if content.Title == "" && strings.EqualFold(node.XMLName.Local, "title") {
+				content.Title = text
+			}
+			content.Paragraphs = append(content.Paragraphs, text)
+		}
+		for _, child := range node.Nodes {
+			walk(child)
+		}
+	}
+	walk(root)
+	return content, nil
+}
+
+// DecodeText converts the body into a valid utf-8 string, utf-16 byte order marks and latin-1 are handled
+func DecodeText(body []byte) string {
+	switch {
+	case bytes.HasPrefix(body, []byte{0xEF, 0xBB, 0xBF}):
+		body = body[3:]
+	case bytes.HasPrefix(body, []byte{0xFE, 0xFF}), bytes.HasPrefix(body, []byte{0xFF, 0xFE}):
+		return decodeUTF16(body)
+	}
+	text := string(body)
+	if !utf8.ValidString(text) {
+		runes := make([]rune, len(body))
+		for i, b := range body {
+			runes[i] = rune(b)
+		}
+		text = string(runes)
+	}
+	return strings.ReplaceAll(text, "\r\n", "\n")
+}
+
+func decodeUTF16(body []byte) string {
+	bigEndian := body[0] == 0xFE
+	body = body[2:]
+	units := make([]uint16, 0, len(body)/2)
+	for i := 0; i+1 < len(body); i += 2 {
+		if bigEndian {
+			units = append(units, uint16(body[i])<<8|uint16(body[i+1]))
+		} else {
+			units = append(units, uint16(body[i+1])<<8|uint16(body[i]))
+		}
+	}
+	return strings.ReplaceAll(string(utf16.Decode(units)), "\r\n", "\n")
+}
+
+// charsetReader lets the xml decoder read the latin-1 documents, the others are read as they are
+func charsetReader(charset string, input io.Reader) (io.Reader, error) {
+	switch strings.ToLower(charset) {
+	case "iso-8859-1", "latin1", "latin-1", "windows-1252", "us-ascii":
+		body, err := io.ReadAll(input)
+		if err != nil {
+			return nil, err
+		}
+		runes := make([]rune, len(body))
+		for i, b := range body {
+			runes[i] = rune(b)
+		}
+		return strings.NewReader(string(runes)), nil
+	}
+	return input, nil
+}
@@ -0,0 +1,305 @@
+package collector
+
+import (
+	"github.com/PuerkitoBio/goquery"
+	"math"
+	"net/http"
+	"strings"
+	"unicode"
+)
+
+const (
+	LanguageFromHTML     = "html"
+	LanguageFromHeader   = "header"
+	LanguageFromDetected = "detected"
+	// Texts with fewer letters than this are too short to be detected
+	DefaultMinDetectionLetters = 40
+	// Only the beginning of the text is read by the detector
+	MaxDetectionChars = 4000
+	// Average log probability margin per trigram the best language needs over the second one, the profiles are
+	// trained on short samples so only a clear lead is trusted
+	DefaultMinDetectionMargin = 0.05
+)
+
+// Sample texts the trigram profiles of the languages are trained with, they are made of the most frequent
+// words of each language so that the profiles are dominated by the function words
+var languageSamples = map[string]string{
+	"en": "The first thing that you should know about this page is that it was written for all of the people " +
+		"who would like to find more information about the world. We have been working with them and they are " +
+		"going to tell us what they think. There is nothing which can be done without their help, but it is " +
+		"also true that we will need to make some changes when the new year comes. This is one of the reasons " +
+		"why our company has decided to offer these services to every customer in the country.",
+	"fr": "La première chose que vous devez savoir sur cette page est qu'elle a été écrite pour toutes les " +
+		"personnes qui voudraient trouver plus d'informations sur le monde. Nous avons travaillé avec eux et " +
+		"ils vont nous dire ce qu'ils pensent. Il n'y a rien qui puisse être fait sans leur aide, mais il est " +
+		"aussi vrai que nous aurons besoin de faire quelques changements quand la nouvelle année arrivera. " +
+		"C'est une des raisons pour lesquelles notre entreprise a décidé d'offrir ces services à chaque client du pays.",
+	"es": "La primera cosa que usted debe saber sobre esta página es que fue escrita para todas las personas " +
+		"que quieren encontrar más información sobre el mundo. Hemos trabajado con ellos y nos van a decir lo " +
+		"que piensan. No hay nada que se pueda hacer sin su ayuda, pero también es cierto que necesitaremos " +
+		"hacer algunos cambios cuando llegue el nuevo año. Esta es una de las razones por las que nuestra " +
+		"empresa ha decidido ofrecer estos servicios a cada cliente del país.",
+	"pt": "A primeira coisa que você deve saber sobre esta página é que ela foi escrita para todas as pessoas " +
+		"que gostariam de encontrar mais informações sobre o mundo. Nós temos trabalhado com eles e eles vão " +
+		"nos dizer o que pensam. Não há nada que possa ser feito sem a sua ajuda, mas também é verdade que " +
+		"vamos precisar fazer algumas mudanças quando o novo ano chegar. Esta é uma das razões pelas quais a " +
+		"nossa empresa decidiu oferecer estes serviços a cada cliente do país.",
+	"it": "La prima cosa che dovete sapere su questa pagina è che è stata scritta per tutte le persone che " +
+		"vorrebbero trovare più informazioni sul mondo. Abbiamo lavorato con loro e ci diranno cosa ne pensano. " +
+		"Non c'è niente che si possa fare senza il loro aiuto, ma è anche vero che avremo bisogno di fare " +
+		"alcuni cambiamenti quando arriverà il nuovo anno. Questa è una delle ragioni per cui la nostra " +
+		"azienda ha deciso di offrire questi servizi a ogni cliente del paese.",
+	"de": "Das erste, was Sie über diese Seite wissen sollten, ist, dass sie für alle Menschen geschrieben " +
+		"wurde, die mehr Informationen über die Welt finden möchten. Wir haben mit ihnen gearbeitet und sie " +
+		"werden uns sagen, was sie denken. Es gibt nichts, was ohne ihre Hilfe getan werden kann, aber es ist " +
+		"auch wahr, dass wir einige Änderungen machen müssen, wenn das neue Jahr kommt. Das ist einer der " +
+		"Gründe, warum unser Unternehmen sich entschieden hat, diese Dienste jedem Kunden im Land anzubieten.",
+	"nl": "Het eerste wat u over deze pagina moet weten is dat hij geschreven is voor alle mensen die meer " +
+		"informatie over de wereld willen vinden. Wij hebben met hen gewerkt en zij gaan ons vertellen wat zij " +
+		"denken. Er is niets dat zonder hun hulp gedaan kan worden, maar het is ook waar dat we een paar " +
+		"veranderingen moeten maken wanneer het nieuwe jaar komt. Dit is een van de redenen waarom ons bedrijf " +
+		"heeft besloten om deze diensten aan elke klant in het land aan te bieden.",
+	"sv": "Det första som du bör veta om den här sidan är att den skrevs för alla de människor som vill hitta " +
+		"mer information om världen. Vi har arbetat med dem och de kommer att berätta för oss vad de tycker. " +
+		"Det finns ingenting som kan göras utan deras hjälp, men det är också sant att vi kommer att behöva " +
+		"göra några förändringar när det nya året kommer. Detta är en av anledningarna till att vårt företag " +
+		"har bestämt sig för att erbjuda dessa tjänster till varje kund i landet.",
+	"da": "Det første, som du skal vide om denne side, er at den blev skrevet for alle de mennesker, der gerne " +
+		"vil finde mere information om verden. Vi har arbejdet sammen med dem, og de vil fortælle os, hvad de " +
+		"synes. Der er intet, som kan gøres uden deres hjælp, men det er også rigtigt, at vi bliver nødt til " +
+		"at lave nogle ændringer, når det nye år kommer. Dette er en af grundene til, at vores virksomhed har " +
+		"besluttet at tilbyde disse tjenester til hver eneste kunde i landet.",
+	"no": "Det første du bør vite om denne siden er at den ble skrevet for alle de menneskene som ønsker å " +
+		"finne mer informasjon om verden. Vi har jobbet sammen med dem, og de skal fortelle oss hva de mener. " +
+		"Det finnes ingenting som kan gjøres uten deres hjelp, men det er også sant at vi må gjøre noen " +
+		"endringer når det nye året kommer. Dette er en av grunnene til at vårt selskap har bestemt seg for å " +
+		"tilby disse tjenestene til hver eneste kunde i landet.",
+	"fi": "Ensimmäinen asia, joka sinun pitää tietää tästä sivusta, on että se kirjoitettiin kaikille " +
+		"ihmisille, jotka haluavat löytää lisää tietoa maailmasta. Olemme tehneet työtä heidän kanssaan ja he " +
+		"kertovat meille, mitä he ajattelevat. Mitään ei voida tehdä ilman heidän apuaan, mutta on myös totta, " +
+		"että meidän täytyy tehdä joitakin muutoksia, kun uusi vuosi alkaa. Tämä on yksi syistä, miksi " +
+		"yrityksemme on päättänyt tarjota näitä palveluja jokaiselle asiakkaalle maassa.",
+	"pl": "Pierwszą rzeczą, którą powinieneś wiedzieć o tej stronie, jest to, że została napisana dla " +
+		"wszystkich ludzi, którzy chcieliby znaleźć więcej informacji o świecie. Pracowaliśmy z nimi i oni " +
+		"powiedzą nam, co myślą. Nie ma niczego, co można zrobić bez ich pomocy, ale prawdą jest także, że " +
+		"będziemy musieli wprowadzić pewne zmiany, kiedy przyjdzie nowy rok. To jest jeden z powodów, dla " +
+		"których nasza firma postanowiła zaoferować te usługi każdemu klientowi w kraju.",
+	"tr": "Bu sayfa hakkında bilmeniz gereken ilk şey, dünya hakkında daha fazla bilgi bulmak isteyen bütün " +
+		"insanlar için yazılmış olmasıdır. Onlarla birlikte çalıştık ve bize ne düşündüklerini söyleyecekler. " +
+		"Onların yardımı olmadan yapılabilecek hiçbir şey yoktur, ama yeni yıl geldiğinde bazı değişiklikler " +
+		"yapmamız gerekeceği de doğrudur. Bu, şirketimizin bu hizmetleri ülkedeki her müşteriye sunmaya karar " +
+		"vermesinin nedenlerinden biridir.",
+	"ru": "Первое, что вы должны знать об этой странице, это то, что она была написана для всех людей, " +
+		"которые хотели бы найти больше информации о мире. Мы работали вместе с ними, и они расскажут нам, " +
+		"что они думают. Нет ничего, что можно сделать без их помощи, но также верно и то, что нам нужно " +
+		"будет сделать некоторые изменения, когда наступит новый год. Это одна из причин, почему наша " +
+		"компания решила предложить эти услуги каждому клиенту в стране.",
+	"uk": "Перше, що ви повинні знати про цю сторінку, це те, що вона була написана для всіх людей, які " +
+		"хотіли б знайти більше інформації про світ. Ми працювали разом з ними, і вони розкажуть нам, що вони " +
+		"думають. Немає нічого, що можна зробити без їхньої допомоги, але також правда й те, що нам потрібно " +
+		"буде зробити деякі зміни, коли настане новий рік. Це одна з причин, чому наша компанія вирішила " +
+		"запропонувати ці послуги кожному клієнту в країні.",
+}
+
+// Languages recognized by their scripts alone, checked in order
+var scriptLanguages = []struct {
+	Language string
+	Script   *unicode.RangeTable
+}{
+	{"ja", unicode.Hiragana},
+	{"ja", unicode.Katakana},
+	{"ko", unicode.Hangul},
+	{"zh", unicode.Han},
+	{"ar", unicode.Arabic},
+	{"he", unicode.Hebrew},
+	{"el", unicode.Greek},
+	{"th", unicode.Thai},
+	{"hi", unicode.Devanagari},
+}
+
+type languageProfile struct {
+	Trigrams map[string]float64
+}
+
+type LanguageDetectorInterface interface {
+	Language(header http.Header, doc *goquery.Document, text string) (string, string)
+	Detect(text string) string
+}
+
+// LanguageDetector determines the language of a page from its declarations, or from its text with the
+// trigram profiles of the sample texts and the scripts of the letters
+type LanguageDetector struct {
+	// The declared language is not trusted when set so that every page is detected from its text
+	IgnoreDeclared bool
+	// The pages without a declared language are left without one when set instead of being detected from their text
+	DeclaredOnly bool
+	MinLetters   int
+	MinMargin    float64
+	Profiles     map[string]*languageProfile
+	// Log probability of the trigrams missing from a profile, it is shared by the profiles so that the profiles
+	// of the samples with fewer distinct trigrams do not win the texts they know little about
+	Unseen float64
+}
+
+func NewLanguageDetector() *LanguageDetector {
+	profiles := make(map[string]*languageProfile, len(languageSamples))
+	unseen := 0.0
+	for language, sample := range languageSamples {
+		counts := map[string]int{}
+		total := 0
+		for _, trigram := range textTrigrams(sample) {
+			counts[trigram]++
+			total++
+		}
+		profile := &languageProfile{Trigrams: make(map[string]float64, len(counts))}
+		// Add one smoothing over the trigrams of the sample and one more bucket for the unseen ones
+		denominator := float64(total + len(counts) + 1)
+		for trigram, count := range counts {
+			profile.Trigrams[trigram] = math.Log(float64(count+1) / denominator)
+		}
+		unseen = math.Min(unseen, math.Log(1/denominator))
+		profiles[language] = profile
+	}
+	return &LanguageDetector{
+		MinLetters: DefaultMinDetectionLetters,
+		MinMargin:  DefaultMinDetectionMargin,
+		Profiles:   profiles,
+		Unseen:     unseen,
+	}
+}
+
+// Language returns the language of a page with the source it is taken from, the lang attribute of the html
+// element comes first, then the Content-Language header and the meta tag, then the text unless DeclaredOnly is
+// set.
+// An empty language is returned when none of them is conclusive.
+func (d *LanguageDetector) Language(header http.Header, doc *goquery.Document, text string) (string, string) {
+	if !d.IgnoreDeclared {
+		if doc != nil {
+			if language := NormalizeLanguage(doc.Find("html").AttrOr("lang", "")); language != "" {
+				return language, LanguageFromHTML
+			}
+		}
+		declared := ""
+		if header != nil {
+			declared = header.Get("Content-Language")
+		}
+		if declared == "" && doc != nil {
+			doc.Find("meta[http-equiv]").Each(func(i int, s *goquery.Selection) {
+				if strings.EqualFold(s.AttrOr("http-equiv", ""), "content-language") {
+					declared = s.AttrOr("content", "")
+				}
+			})
+		}
+		// A list of languages declares the audience of the page rather than its language
+		if !strings.Contains(declared, ",") {
+			if language := NormalizeLanguage(declared); language != "" {
+				return language, LanguageFromHeader
+			}
+		}
+	}
+	if !d.DeclaredOnly {
+		if language := d.Detect(text); language != "" {
+			return language, LanguageFromDetected
+		}
+	}
+	return "", ""
+}
+
+// Detect returns the language of the text, an empty language is returned when the text is too short or the
+// best language is not ahead of the others by the minimum margin
+func (d *LanguageDetector) Detect(text string) string {
+	if len(text) > MaxDetectionChars {
+		text = text[:MaxDetectionChars]
+		// Drop the rune cut in the middle
+		text = strings.ToValidUTF8(text, "")
+	}
+	letters := 0
+	scripts := map[string]int{}
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		letters++
+		for _, script := range scriptLanguages {
+			if unicode.Is(script.Script, r) {
+				scripts[script.Language]++
+				break
+			}
+		}
+	}
+	if letters < d.MinLetters {
+		return ""
+	}
+	// Japanese texts mix the kana with the han characters, so any share of kana makes the text japanese
+	if scripts["ja"]*10 >= letters {
+		return "ja"
+	}
+	for _, script := range scriptLanguages {
+		if scripts[script.Language]*2 > letters {
+			return script.Language
+		}
+	}
+	trigrams := textTrigrams(text)
+	if len(trigrams) == 0 {
+		return ""
+	}
+	best, second := math.Inf(-1), math.Inf(-1)
+	language := ""
+	for name, profile := range d.Profiles {
+		score := 0.0
+		for _, trigram := range trigrams {
+			if p, exists := profile.Trigrams[trigram]; exists {
+				score += p
+			} else {
+				score += d.Unseen
+			}
+		}
+		score /= float64(len(trigrams))
+		if score > best {
+			best, second = score, best
+			language = name
+		} else if score > second {
+			second = score
+		}
+	}
+	if best-second < d.MinMargin {
+		return ""
+	}
+	return language
+}
+
+// NormalizeLanguage returns the primary subtag of a language tag in lowercase, "en-US" is returned as "en"
+func NormalizeLanguage(tag string) string {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	if i := strings.IndexAny(tag, "-_"); i >= 0 {
+		tag = tag[:i]
+	}
+	if len(tag) < 2 || len(tag) > 3 {
+		return ""
+	}
+	for _, r := range tag {
+		if r < 'a' || r > 'z' {
+			return ""
+		}
+	}
+	// Norwegian bokmål and nynorsk share a profile and a stemmer
+	if tag == "nb" || tag == "nn" {
+		return "no"
+	}
+	return tag
+}
+
+// textTrigrams returns the letter trigrams of the words of the text padded with spaces
+func textTrigrams(text string) []string {
+	trigrams := []string{}
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && r != '\''
+	})
+	for _, word := range words {
+		runes := []rune(" " + strings.Trim(word, "'") + " ")
+		for i := 0; i+3 <= len(runes); i++ {
+			trigrams = append(trigrams, string(runes[i:i+3]))
+		}
+	}
+	return trigrams
+}
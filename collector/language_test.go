@@ -0,0 +1,119 @@
+package collector
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func TestDetectLanguage(t *testing.T) {
+	detector := NewLanguageDetector()
+	for want, texts := range map[string][]string{
+		"en": {
+			"To install the package, run the installer and follow the prompts. The configuration file is stored in your home directory.",
+			"The city council voted on Tuesday to expand the bike lane network, citing a sharp rise in commuters who cycle to work.",
+			"Call client.Do(req) with a context; if err != nil return err. The handler writes JSON to the response writer and sets the status code.",
+		},
+		"de": {
+			"Die Bundesregierung hat am Mittwoch neue Regeln für den Ausbau der erneuerbaren Energien beschlossen, die ab dem kommenden Jahr gelten sollen.",
+			"Der Scheduler verteilt die Container auf die Knoten und startet sie neu, wenn ein Knoten ausfällt oder gewartet wird.",
+		},
+		"fr": {"Le planificateur répartit les conteneurs sur les nœuds et les redémarre lorsqu'un nœud tombe en panne."},
+		"es": {"El ayuntamiento anunció ayer que las obras de la nueva línea de metro comenzarán el próximo mes de septiembre."},
+		"nl": {"De gemeente heeft besloten om de parkeertarieven in het centrum volgend jaar te verhogen om het verkeer te verminderen."},
+		"sv": {"Regeringen har lagt fram ett nytt förslag som ska göra det enklare för små företag att anställa fler medarbetare."},
+		"it": {"Il comune ha annunciato che i lavori per la nuova linea della metropolitana inizieranno il prossimo mese di settembre."},
+		"pl": {"Rząd przedstawił w środę nowy projekt ustawy, który ma uprościć procedury administracyjne dla przedsiębiorstw."},
+		"ja": {"このページでは、クローラーの設定方法と検索インデックスの作り方について説明します。設定ファイルはホームディレクトリに保存されます。"},
+		"el": {"Η κυβέρνηση παρουσίασε την Τετάρτη ένα νέο νομοσχέδιο για την απλοποίηση των διαδικασιών."},
+	} {
+		for _, text := range texts {
+			if got := detector.Detect(text); got != want {
+				t.Errorf("%q: got %q, want %q", text, got, want)
+			}
+		}
+	}
+}
+
+// Terse technical texts and pages mixing languages are not conclusive, they may be left without a language but
+// are never given a language they are not written in
+func TestDetectLanguageIsNotConclusive(t *testing.T) {
+	detector := NewLanguageDetector()
+	for text, allowed := range map[string][]string{
+		"Kubernetes clusters schedule containers onto nodes based on resource requests, and the scheduler " +
+			"rebalances pods when a node fails or is drained for maintenance.": {"", "en"},
+		"Release notes: fixed memory leak in the HTTP client, improved startup time, updated dependencies, " +
+			"removed deprecated configuration flags.": {"", "en"},
+		"The conference opened on Monday with a keynote about distributed systems. Die Veranstaltung wurde von " +
+			"der Universität organisiert und dauerte drei Tage. Most talks were recorded and are available online.": {"", "en"},
+		"Die Bundesregierung hat neue Regeln beschlossen. The government has approved new rules for renewable " +
+			"energy.": {"", "en", "de"},
+		"Short text.": {""},
+	} {
+		got := detector.Detect(text)
+		found := false
+		for _, language := range allowed {
+			found = found || got == language
+		}
+		if !found {
+			t.Errorf("%q: got %q, want one of %q", text, got, allowed)
+		}
+	}
+}
+
+func TestLanguageOfPage(t *testing.T) {
+	german := "Die Bundesregierung hat am Mittwoch neue Regeln für den Ausbau der erneuerbaren Energien beschlossen."
+	page := func(html string) *goquery.Document {
+		doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return doc
+	}
+	declaredOnly := NewLanguageDetector()
+	declaredOnly.DeclaredOnly = true
+	for _, test := range []struct {
+		name     string
+		detector *LanguageDetector
+		header   http.Header
+		html     string
+		language string
+		from     string
+	}{
+		{"html lang", NewLanguageDetector(), nil, `<html lang="fr-CA"><body></body></html>`, "fr", LanguageFromHTML},
+		{"header", NewLanguageDetector(), http.Header{"Content-Language": {"de"}}, `<html></html>`, "de", LanguageFromHeader},
+		{"meta", NewLanguageDetector(), nil, `<html><head><meta http-equiv="Content-Language" content="nb"></head></html>`, "no", LanguageFromHeader},
+		{"audience list", declaredOnly, http.Header{"Content-Language": {"en, de"}}, `<html></html>`, "", ""},
+		{"declared only", declaredOnly, nil, `<html></html>`, "", ""},
+		{"detected text", NewLanguageDetector(), http.Header{"Content-Language": {"en, de"}}, `<html></html>`, "de", LanguageFromDetected},
+		{"declared before detected", NewLanguageDetector(), nil, `<html lang="en"></html>`, "en", LanguageFromHTML},
+	} {
+		language, from := test.detector.Language(test.header, page(test.html), german)
+		if language != test.language || from != test.from {
+			t.Errorf("%s: got %q from %q, want %q from %q", test.name, language, from, test.language, test.from)
+		}
+	}
+}
+
+// The pages which do not declare their language are detected from their text unless the detection is turned off
+func TestCollectorDetectsTheLanguageByDefault(t *testing.T) {
+	page := `<html><body><p>Die Bundesregierung hat am Mittwoch neue Regeln für den Ausbau der erneuerbaren Energien beschlossen.</p></body></html>`
+	loggers := discardLoggers()
+	c := &Collector{Scrapper: NewScrapper(loggers), Loggers: loggers}
+	scraped, err := c.Scrapper.ScrapeHTML("https://example.com/", strings.NewReader(page), http.Header{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if scraped.Language != "de" || scraped.LanguageFrom != LanguageFromDetected {
+		t.Errorf("got language %q from %q, want de detected", scraped.Language, scraped.LanguageFrom)
+	}
+	c.DisableLanguageDetection()
+	if scraped, err = c.Scrapper.ScrapeHTML("https://example.com/", strings.NewReader(page), http.Header{}); err != nil {
+		t.Fatal(err)
+	}
+	if scraped.Language != "" {
+		t.Errorf("got language %q with the detection turned off", scraped.Language)
+	}
+}
@@ -0,0 +1,202 @@
+package collector
+
+import (
+	"errors"
+	"fmt"
+	"github.com/PuerkitoBio/goquery"
+	"regexp"
+	"strings"
+)
+
+const (
+	LinkSourceAnchor  = "a"
+	LinkSourceArea    = "area"
+	LinkSourceLink    = "link"
+	LinkSourceIframe  = "iframe"
+	LinkSourceFrame   = "frame"
+	LinkSourceForm    = "form"
+	LinkSourceRefresh = "meta_refresh"
+	LinkSourceSrcset  = "srcset"
+)
+
+// Link sources of the crawl unless they are configured
+var DefaultLinkSources = []string{LinkSourceAnchor}
+
+// Every link source in the order they are documented
+var LinkSources = []string{
+	LinkSourceAnchor,
+	LinkSourceArea,
+	LinkSourceLink,
+	LinkSourceIframe,
+	LinkSourceFrame,
+	LinkSourceForm,
+	LinkSourceRefresh,
+	LinkSourceSrcset,
+}
+
+// Relations of the link elements which point to other pages rather than to the assets of the page
+var pageLinkRelations = map[string]bool{
+	"next":      true,
+	"prev":      true,
+	"previous":  true,
+	"alternate": true,
+}
+
+// Elements of the link sources, the elements of the sources which are not enabled are not selected
+var linkSelectors = map[string]string{
+	LinkSourceAnchor:  "a[href]",
+	LinkSourceArea:    "area[href]",
+	LinkSourceLink:    "link[href][rel]",
+	LinkSourceIframe:  "iframe[src]",
+	LinkSourceFrame:   "frame[src]",
+	LinkSourceForm:    "form",
+	LinkSourceRefresh: "meta[http-equiv][content]",
+	LinkSourceSrcset:  "img[srcset], source[srcset]",
+}
+
+var refreshURLPattern = regexp.MustCompile(`(?i)^\s*\d*(?:\.\d*)?\s*[;,]?\s*url\s*=\s*(.+)$`)
+
+type LinkExtractorInterface interface {
+	Extract(pageURL string, doc *goquery.Document) []*PageLink
+}
+
+// LinkExtractor finds the outbound links of the html pages in the elements of the enabled sources, the relative
+// links are resolved against the base href of the page
+type LinkExtractor struct {
+	Sources  map[string]bool
+	selector string
+}
+
+func NewLinkExtractor(sources ...string) (*LinkExtractor, error) {
+	if len(sources) == 0 {
+		sources = DefaultLinkSources
+	}
+	e := &LinkExtractor{Sources: map[string]bool{}}
+	selectors := []string{}
+	for _, source := range sources {
+		selector, exists := linkSelectors[source]
+		if !exists {
+			return nil, errors.New(fmt.Sprintf("unknown link source: %s", source))
+		}
+		if !e.Sources[source] {
+			e.Sources[source] = true
+			selectors = append(selectors, selector)
+		}
+	}
+	e.selector = strings.Join(selectors, ", ")
+	return e, nil
+}
+
+// Extract returns the links of the document in the order they appear
+func (e *LinkExtractor) Extract(pageURL string, doc *goquery.Document) []*PageLink {
+	base := BaseHref(pageURL, doc)
+	links := []*PageLink{}
+	add := func(s *goquery.Selection, source string, href string, text string) {
+		href = strings.TrimSpace(href)
+		if href == "" {
+			return
+		}
+		absoluteUrl, err := AbsoluteURL(base, href)
+		if err != nil {
+			return
+		}
+		links = append(links, &PageLink{Url: absoluteUrl, Text: text, NoFollow: IsNoFollowLink(s), Source: source})
+	}
+	doc.Find(e.selector).Each(func(i int, s *goquery.Selection) {
+		switch goquery.NodeName(s) {
+		case "a":
+			add(s, LinkSourceAnchor, s.AttrOr("href", ""), NormalizeSpaces(s.Text()))
+		case "area":
+			add(s, LinkSourceArea, s.AttrOr("href", ""), NormalizeSpaces(s.AttrOr("alt", "")))
+		case "link":
+			for _, rel := range strings.Fields(strings.ToLower(s.AttrOr("rel", ""))) {
+				if pageLinkRelations[rel] {
+					add(s, LinkSourceLink, s.AttrOr("href", ""), NormalizeSpaces(s.AttrOr("title", "")))
+					return
+				}
+			}
+		case "iframe", "frame":
+			add(s, goquery.NodeName(s), s.AttrOr("src", ""), NormalizeSpaces(s.AttrOr("title", "")))
+		case "form":
+			// Only the get forms lead to pages, an empty action submits to the page itself
+			if method := strings.ToLower(strings.TrimSpace(s.AttrOr("method", ""))); method != "" && method != "get" {
+				return
+			}
+			action := strings.TrimSpace(s.AttrOr("action", ""))
+			if action == "" {
+				action = pageURL
+			}
+			add(s, LinkSourceForm, action, "")
+		case "meta":
+			if strings.EqualFold(s.AttrOr("http-equiv", ""), "refresh") {
+				add(s, LinkSourceRefresh, RefreshURL(s.AttrOr("content", "")), "")
+			}
+		case "img", "source":
+			text := NormalizeSpaces(s.AttrOr("alt", ""))
+			for _, u := range SrcsetUrls(s.AttrOr("srcset", "")) {
+				add(s, LinkSourceSrcset, u, text)
+			}
+		}
+	})
+	return links
+}
+
+// BaseHref returns the url the relative links of the document are resolved against, the href of the first
+// base element when it is given, otherwise the url of the page
+func BaseHref(pageURL string, doc *goquery.Document) string {
+	href, exists := doc.Find("base[href]").First().Attr("href")
+	if !exists || strings.TrimSpace(href) == "" {
+		return pageURL
+	}
+	base, err := AbsoluteURL(pageURL, strings.TrimSpace(href))
+	if err != nil {
+		return pageURL
+	}
+	return base
+}
+
+// RefreshURL returns the url of the content of a meta refresh such as "5; url=/next", empty when it only reloads
+func RefreshURL(content string) string {
+	matches := refreshURLPattern.FindStringSubmatch(content)
+	if matches == nil {
+		return ""
+	}
+	return strings.Trim(strings.TrimSpace(matches[1]), `'"`)
+}
+
+// SrcsetUrls returns the urls of the candidates of a srcset attribute, the urls may hold commas themselves so
+// a candidate ends at the whitespace after its url or at a trailing comma of the url
+func SrcsetUrls(srcset string) []string {
+	urls := []string{}
+	for position := 0; position < len(srcset); {
+		for position < len(srcset) && (srcset[position] == ',' || isSpace(srcset[position])) {
+			position++
+		}
+		start := position
+		for position < len(srcset) && !isSpace(srcset[position]) {
+			position++
+		}
+		candidate := srcset[start:position]
+		if strings.HasSuffix(candidate, ",") {
+			candidate = strings.TrimRight(candidate, ",")
+		} else {
+			// Skip the descriptors up to the comma ending the candidate
+			depth := 0
+			for ; position < len(srcset) && (depth > 0 || srcset[position] != ','); position++ {
+				if srcset[position] == '(' {
+					depth++
+				} else if srcset[position] == ')' && depth > 0 {
+					depth--
+				}
+			}
+		}
+		if candidate != "" && !strings.HasPrefix(candidate, "data:") {
+			urls = append(urls, candidate)
+		}
+	}
+	return urls
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
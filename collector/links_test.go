@@ -0,0 +1,120 @@
+package collector
+
+import (
+	"reflect"
+	"testing"
+)
+
+const linksPage = `<html><head>
+<base href="https://cdn.example.com/docs/">
+<link rel="next" href="page-2.html" title="Next page">
+<link rel="stylesheet" href="style.css">
+<meta http-equiv="refresh" content="5; url='moved.html'">
+</head><body>
+<a href="intro.html"> Intro  page </a>
+<a href="/about" rel="nofollow">About</a>
+<a href="">empty</a>
+<map><area href="region.html" alt="Region"></map>
+<iframe src="https://video.example.com/embed" title="Video"></iframe>
+<form action="search"><input name="q"></form>
+<form method="post" action="login"></form>
+<img srcset="small.png 1x, large.png 2x, data:image/png;base64,AAAA 3x" alt="Picture">
+</body></html>`
+
+func TestLinkExtractorFindsTheAnchorsByDefault(t *testing.T) {
+	extractor, err := NewLinkExtractor()
+	if err != nil {
+		t.Fatal(err)
+	}
+	links := extractor.Extract("https://example.com/guide/", parseDocument(t, linksPage))
+	// Relative links are resolved against the base href instead of the page url
+	want := []*PageLink{
+		{Url: "https://cdn.example.com/docs/intro.html", Text: "Intro page", Source: LinkSourceAnchor},
+		{Url: "https://cdn.example.com/about", Text: "About", NoFollow: true, Source: LinkSourceAnchor},
+	}
+	if !reflect.DeepEqual(links, want) {
+		t.Errorf("got links %s, want %s", linkUrls(links), linkUrls(want))
+	}
+}
+
+func TestLinkExtractorFindsTheLinksOfEverySource(t *testing.T) {
+	extractor, err := NewLinkExtractor(LinkSources...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	links := extractor.Extract("https://example.com/guide/", parseDocument(t, linksPage))
+	want := map[string]string{
+		"https://cdn.example.com/docs/page-2.html": LinkSourceLink,
+		"https://cdn.example.com/docs/moved.html":  LinkSourceRefresh,
+		"https://cdn.example.com/docs/intro.html":  LinkSourceAnchor,
+		"https://cdn.example.com/about":            LinkSourceAnchor,
+		"https://cdn.example.com/docs/region.html": LinkSourceArea,
+		"https://video.example.com/embed":          LinkSourceIframe,
+		"https://cdn.example.com/docs/search":      LinkSourceForm,
+		"https://cdn.example.com/docs/small.png":   LinkSourceSrcset,
+		"https://cdn.example.com/docs/large.png":   LinkSourceSrcset,
+	}
+	got := map[string]string{}
+	for _, link := range links {
+		got[link.Url] = link.Source
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got links %v, want %v", got, want)
+	}
+}
+
+func TestNewLinkExtractorRejectsUnknownSources(t *testing.T) {
+	if _, err := NewLinkExtractor(LinkSourceAnchor, "script"); err == nil {
+		t.Errorf("unknown source is accepted")
+	}
+}
+
+func TestBaseHref(t *testing.T) {
+	for page, want := range map[string]string{
+		`<html><head><base href="/root/"></head></html>`:                     "https://example.com/root/",
+		`<html><head><base href="https://other.example.com/"></head></html>`: "https://other.example.com/",
+		`<html><head><base target="_blank"></head></html>`:                   "https://example.com/a/b.html",
+		`<html><head><base href="  "></head></html>`:                         "https://example.com/a/b.html",
+		`<html><head></head></html>`:                                         "https://example.com/a/b.html",
+	} {
+		if got := BaseHref("https://example.com/a/b.html", parseDocument(t, page)); got != want {
+			t.Errorf("%s: got %q, want %q", page, got, want)
+		}
+	}
+}
+
+func TestRefreshURL(t *testing.T) {
+	for content, want := range map[string]string{
+		"0;URL=/next":         "/next",
+		"5; url='moved.html'": "moved.html",
+		"3, url = \"/b\"":     "/b",
+		"10":                  "",
+	} {
+		if got := RefreshURL(content); got != want {
+			t.Errorf("RefreshURL(%q) = %q, want %q", content, got, want)
+		}
+	}
+}
+
+func linkUrls(links []*PageLink) []string {
+	urls := make([]string, len(links))
+	for i, link := range links {
+		urls[i] = link.Url
+	}
+	return urls
+}
+
+func TestSrcsetUrls(t *testing.T) {
+	for srcset, want := range map[string][]string{
+		"a.png":                        {"a.png"},
+		"a.png 1x, b.png 2x":           {"a.png", "b.png"},
+		"a.png, b.png":                 {"a.png", "b.png"},
+		" a.png 100w ,  b,c.png 200w ": {"a.png", "b,c.png"},
+		"data:image/png;base64,AAAA 1x, b.png 2x": {"b.png"},
+		"": {},
+	} {
+		if got := SrcsetUrls(srcset); !reflect.DeepEqual(got, want) {
+			t.Errorf("SrcsetUrls(%q) = %q, want %q", srcset, got, want)
+		}
+	}
+}
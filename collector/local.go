@@ -0,0 +1,205 @@
+package collector
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	LocalIndexFile = "index.html"
+)
+
+// LocalSite serves the urls under the base url from the files of a directory, such as the build of a static
+// site, so that it is crawled the same way as when it is deployed. The base url is the file url of the directory
+// unless the public url of the site is given.
+type LocalSite struct {
+	Root    string
+	BaseURL *url.URL
+	Files   http.RoundTripper
+}
+
+func NewLocalSite(dir string, baseURL string) (*LocalSite, error) {
+	root, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, errors.New(fmt.Sprintf("local site is not a directory: %s", root))
+	}
+	if baseURL == "" {
+		baseURL = (&url.URL{Scheme: "file", Path: filepath.ToSlash(root)}).String()
+	}
+	base, err := url.ParseRequestURI(baseURL)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("base url is not valid url: %s", err.Error()))
+	}
+	if !strings.HasSuffix(base.Path, "/") {
+		base.Path += "/"
+	}
+	base.RawPath = ""
+	base.RawQuery = ""
+	base.Fragment = ""
+	return &LocalSite{
+		Root:    root,
+		BaseURL: base,
+		Files:   http.NewFileTransport(localFileSystem{http.Dir(root)}),
+	}, nil
+}
+
+// LocalRoot returns the directory of a file url, the directory itself or the directory of the file
+func LocalRoot(fileURL string) (string, error) {
+	u, err := url.Parse(fileURL)
+	if err != nil || u.Scheme != "file" {
+		return "", errors.New(fmt.Sprintf("not a file url: %s", fileURL))
+	}
+	root := filepath.FromSlash(u.Path)
+	info, err := os.Stat(root)
+	if err != nil {
+		return "", err
+	}
+	if !info.IsDir() {
+		root = filepath.Dir(root)
+	}
+	return root, nil
+}
+
+// Contains returns whether the url is under the base url of the site
+func (s *LocalSite) Contains(pageURL string) bool {
+	u, err := url.Parse(pageURL)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Scheme, s.BaseURL.Scheme) && strings.EqualFold(u.Host, s.BaseURL.Host) &&
+		strings.HasPrefix(u.Path+"/", s.BaseURL.Path)
+}
+
+// UrlOf returns the url of a file of the site, the index files are addressed by their directories
+func (s *LocalSite) UrlOf(file string) (string, bool) {
+	rel, err := filepath.Rel(s.Root, file)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	rel = filepath.ToSlash(rel)
+	if rel == "." {
+		rel = ""
+	}
+	if info, err := os.Stat(file); err == nil && info.IsDir() && rel != "" {
+		rel += "/"
+	}
+	if path.Base(rel) == LocalIndexFile {
+		rel = strings.TrimSuffix(rel, LocalIndexFile)
+	}
+	return s.BaseURL.ResolveReference(&url.URL{Path: rel}).String(), true
+}
+
+// PublicUrl maps a file url under the root to the base url of the site, other urls are returned as they are
+func (s *LocalSite) PublicUrl(pageURL string) string {
+	u, err := url.Parse(pageURL)
+	if err != nil || u.Scheme != "file" {
+		return pageURL
+	}
+	if public, ok := s.UrlOf(filepath.FromSlash(u.Path)); ok {
+		return public
+	}
+	return pageURL
+}
+
+// Pages returns the urls of the html files of the site in the order of their paths
+func (s *LocalSite) Pages() ([]string, error) {
+	var pages []string
+	err := filepath.WalkDir(s.Root, func(file string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			// Hidden directories such as .git are not part of the site
+			if file != s.Root && strings.HasPrefix(entry.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		switch strings.ToLower(filepath.Ext(file)) {
+		case ".html", ".htm":
+			if u, ok := s.UrlOf(file); ok {
+				pages = append(pages, u)
+			}
+		}
+		return nil
+	})
+	return pages, err
+}
+
+// RoundTrip reads the urls of the site from the files, the others are requested with the next transport
+func (s *LocalSite) RoundTrip(request *http.Request, next http.RoundTripper) (*http.Response, error) {
+	if !s.Contains(request.URL.String()) {
+		return next.RoundTrip(request)
+	}
+	local := request.Clone(request.Context())
+	local.URL = &url.URL{Path: "/" + strings.TrimPrefix(request.URL.Path+"/", s.BaseURL.Path)}
+	if !strings.HasSuffix(request.URL.Path, "/") {
+		local.URL.Path = strings.TrimSuffix(local.URL.Path, "/")
+	}
+	// Index files are served as they are instead of redirecting to their directories
+	if path.Base(local.URL.Path) == LocalIndexFile {
+		local.URL.Path = strings.TrimSuffix(local.URL.Path, LocalIndexFile)
+	}
+	response, err := s.Files.RoundTrip(local)
+	if err != nil {
+		return nil, err
+	}
+	response.Request = request
+	if response.ContentLength < 0 {
+		if length, err := strconv.ParseInt(response.Header.Get("Content-Length"), 10, 64); err == nil {
+			response.ContentLength = length
+		}
+	}
+	return response, nil
+}
+
+// localFileSystem hides the directories without an index file so that they are not served as listings
+type localFileSystem struct {
+	Dir http.Dir
+}
+
+func (l localFileSystem) Open(name string) (http.File, error) {
+	file, err := l.Dir.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	if info.IsDir() {
+		index, err := l.Dir.Open(path.Join(name, LocalIndexFile))
+		if err != nil {
+			_ = file.Close()
+			return nil, os.ErrNotExist
+		}
+		_ = index.Close()
+	}
+	return file, nil
+}
+
+// localTransport sends the requests through the local site before the http transport
+type localTransport struct {
+	Site *LocalSite
+	Next http.RoundTripper
+}
+
+func (t *localTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	return t.Site.RoundTrip(request, t.Next)
+}
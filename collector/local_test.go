@@ -0,0 +1,162 @@
+package collector
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// localTree writes the files of a small static site under a temporary directory and returns the directory
+func localTree(t *testing.T, files map[string]string) string {
+	t.Helper()
+	root := t.TempDir()
+	for name, content := range files {
+		file := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(file, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+// recordingTransport answers every request with no content and records the urls it is sent
+type recordingTransport struct {
+	urls []string
+}
+
+func (r *recordingTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	r.urls = append(r.urls, request.URL.String())
+	return &http.Response{StatusCode: http.StatusNoContent, Body: http.NoBody, Request: request}, nil
+}
+
+func TestLocalSiteUrls(t *testing.T) {
+	root := localTree(t, map[string]string{
+		"index.html":      "home",
+		"docs/index.html": "docs",
+		"docs/guide.html": "guide",
+		"assets/logo.txt": "logo",
+		".git/HEAD.html":  "hidden",
+	})
+	base := "https://docs.example.com/site/"
+	site, err := NewLocalSite(root, "https://docs.example.com/site")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if site.BaseURL.String() != base {
+		t.Fatalf("got base url %s, want %s", site.BaseURL, base)
+	}
+
+	for u, want := range map[string]bool{
+		base:                                     true,
+		"https://docs.example.com/site":          true,
+		"https://DOCS.example.com/site/docs/":    true,
+		"https://docs.example.com/sitemap.xml":   false,
+		"http://docs.example.com/site/":          false,
+		"https://other.example.com/site/":        false,
+		"file://" + filepath.ToSlash(root) + "/": false,
+	} {
+		if got := site.Contains(u); got != want {
+			t.Errorf("contains %s: got %v, want %v", u, got, want)
+		}
+	}
+
+	for file, want := range map[string]string{
+		root:                              base,
+		filepath.Join(root, "index.html"): base,
+		filepath.Join(root, "docs"):       base + "docs/",
+		filepath.Join(root, "docs", "index.html"): base + "docs/",
+		filepath.Join(root, "docs", "guide.html"): base + "docs/guide.html",
+		filepath.Dir(root):                        "",
+		filepath.Join(filepath.Dir(root), "x"):    "",
+	} {
+		got, ok := site.UrlOf(file)
+		if got != want || ok != (want != "") {
+			t.Errorf("url of %s: got %q %v, want %q", file, got, ok, want)
+		}
+	}
+
+	fileURL := "file://" + filepath.ToSlash(root)
+	for u, want := range map[string]string{
+		fileURL + "/docs/index.html":                           base + "docs/",
+		fileURL + "/docs/guide.html":                           base + "docs/guide.html",
+		"https://example.com/docs/":                            "https://example.com/docs/",
+		"file://" + filepath.ToSlash(filepath.Dir(root)) + "/": "file://" + filepath.ToSlash(filepath.Dir(root)) + "/",
+	} {
+		if got := site.PublicUrl(u); got != want {
+			t.Errorf("public url of %s: got %s, want %s", u, got, want)
+		}
+	}
+
+	pages, err := site.Pages()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{base + "docs/guide.html", base + "docs/", base}; !reflect.DeepEqual(pages, want) {
+		t.Errorf("pages\n got: %v\nwant: %v", pages, want)
+	}
+}
+
+func TestLocalSiteRoundTrip(t *testing.T) {
+	root := localTree(t, map[string]string{
+		"index.html":      "home",
+		"docs/index.html": "docs",
+		"assets/logo.txt": "logo",
+	})
+	base := "https://docs.example.com/site/"
+	site, err := NewLocalSite(root, base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	next := &recordingTransport{}
+	for _, test := range []struct {
+		url    string
+		status int
+		body   string
+	}{
+		{base, http.StatusOK, "home"},
+		{base + "index.html", http.StatusOK, "home"},
+		{base + "docs/", http.StatusOK, "docs"},
+		// Index files are served as they are, not redirected to their directories
+		{base + "docs/index.html", http.StatusOK, "docs"},
+		{base + "assets/logo.txt", http.StatusOK, "logo"},
+		// Directories without an index file are not listed
+		{base + "assets/", http.StatusNotFound, ""},
+		{base + "missing.html", http.StatusNotFound, ""},
+		{"https://other.example.com/", http.StatusNoContent, ""},
+	} {
+		request, err := http.NewRequest(http.MethodGet, test.url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		response, err := site.RoundTrip(request, next)
+		if err != nil {
+			t.Fatalf("%s: %s", test.url, err)
+		}
+		body, _ := ioutil.ReadAll(response.Body)
+		response.Body.Close()
+		if response.StatusCode != test.status {
+			t.Errorf("%s: got status %d, want %d", test.url, response.StatusCode, test.status)
+			continue
+		}
+		if response.Request != request {
+			t.Errorf("%s: response is not of the request", test.url)
+		}
+		if test.status == http.StatusOK {
+			if string(body) != test.body || response.ContentLength != int64(len(test.body)) {
+				t.Errorf("%s: got body %q of length %d, want %q", test.url, body, response.ContentLength, test.body)
+			}
+		} else if strings.Contains(string(body), "logo.txt") {
+			t.Errorf("%s: directory is listed: %s", test.url, body)
+		}
+	}
+	if want := []string{"https://other.example.com/"}; !reflect.DeepEqual(next.urls, want) {
+		t.Errorf("got next transport urls %v, want %v", next.urls, want)
+	}
+}
@@ -0,0 +1,246 @@
+package collector
+
+import (
+	"errors"
+	"fmt"
+	"github.com/PuerkitoBio/goquery"
+	"github.com/andybalholm/cascadia"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	DefaultLoginFormSelector = "form:has(input[type=password])"
+	// Paths with a segment like /logout or /sign-off, the words merely containing them like /blog-outline are kept
+	DefaultLogoutPattern = `(?i)/(log|sign)[-_]?(out|off)\b`
+	// Expired sessions are not renewed anymore after this many logins without any request succeeding in between
+	DefaultMaxLogins = 3
+)
+
+// LoginForm declares how to log in before the crawl, the form of the login page is submitted with its own
+// fields, the csrf token and the configured fields
+type LoginForm struct {
+	Url string `json:"url"`
+	// Selector of the login form, the form with a password input by default
+	FormSelector string            `json:"form_selector,omitempty"`
+	Fields       map[string]string `json:"fields"`
+	// Selector of the element holding the csrf token, the token is read from the attribute, value by default,
+	// and submitted as the field, the name of the element by default
+	CSRFSelector string `json:"csrf_selector,omitempty"`
+	CSRFAttr     string `json:"csrf_attr,omitempty"`
+	CSRFField    string `json:"csrf_field,omitempty"`
+	// Responses redirected to the urls matching the pattern mean that the session expired, the pattern
+	// matches the login url by default
+	ExpiredPattern string `json:"expired_pattern,omitempty"`
+	// Links matching the pattern are not followed so that the crawl does not end its own session
+	LogoutPattern string `json:"logout_pattern,omitempty"`
+	MaxLogins     int    `json:"max_logins,omitempty"`
+}
+
+type LoginSessionInterface interface {
+	Login(requester *Request) error
+	Renew(requester *Request, generation int) error
+	Expired(response *http.Response) bool
+	Excluded(pageURL string) bool
+}
+
+// LoginSession logs in with the form and renews the session when the responses are redirected to the login
+// page, the cookies of the session are kept by the cookie jar of the transport
+type LoginSession struct {
+	Form         LoginForm
+	formSelector cascadia.Selector
+	expired      *regexp.Regexp
+	logout       *regexp.Regexp
+	// Incremented by every login so that the requests which saw the same expired session log in once
+	Generation int
+	// Logins since the last request which succeeded without the session expiring
+	Failures int32
+	Mutex    sync.Mutex
+}
+
+func NewLoginSession(form LoginForm) (*LoginSession, error) {
+	loginURL, err := url.ParseRequestURI(form.Url)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("login url is not valid url: %s", err.Error()))
+	}
+	if form.FormSelector == "" {
+		form.FormSelector = DefaultLoginFormSelector
+	}
+	if form.CSRFAttr == "" {
+		form.CSRFAttr = "value"
+	}
+	if form.ExpiredPattern == "" {
+		form.ExpiredPattern = "^" + regexp.QuoteMeta(loginURL.Scheme+"://"+loginURL.Host+loginURL.Path) + `(\?|$)`
+	}
+	if form.LogoutPattern == "" {
+		form.LogoutPattern = DefaultLogoutPattern
+	}
+	if form.MaxLogins <= 0 {
+		form.MaxLogins = DefaultMaxLogins
+	}
+	session := &LoginSession{Form: form}
+	if session.formSelector, err = cascadia.Compile(form.FormSelector); err != nil {
+		return nil, errors.New(fmt.Sprintf("login form selector is not valid: %s", err.Error()))
+	}
+	if form.CSRFSelector != "" {
+		if _, err := cascadia.Compile(form.CSRFSelector); err != nil {
+			return nil, errors.New(fmt.Sprintf("csrf selector is not valid: %s", err.Error()))
+		}
+	}
+	if session.expired, err = regexp.Compile(form.ExpiredPattern); err != nil {
+		return nil, errors.New(fmt.Sprintf("expired pattern is not valid: %s", err.Error()))
+	}
+	if session.logout, err = regexp.Compile(form.LogoutPattern); err != nil {
+		return nil, errors.New(fmt.Sprintf("logout pattern is not valid: %s", err.Error()))
+	}
+	return session, nil
+}
+
+// Login fetches the login page and submits its form
+func (s *LoginSession) Login(requester *Request) error {
+	s.Mutex.Lock()
+	defer s.Mutex.Unlock()
+	return s.login(requester)
+}
+
+// Renew logs in again unless another request renewed the session since the generation was read
+func (s *LoginSession) Renew(requester *Request, generation int) error {
+	s.Mutex.Lock()
+	defer s.Mutex.Unlock()
+	if generation != s.Generation {
+		return nil
+	}
+	if int(atomic.LoadInt32(&s.Failures)) >= s.Form.MaxLogins {
+		return errors.New(fmt.Sprintf("session keeps expiring after %d logins", s.Form.MaxLogins))
+	}
+	atomic.AddInt32(&s.Failures, 1)
+	return s.login(requester)
+}
+
+// CurrentGeneration returns the generation of the session, it waits for the login in progress
+func (s *LoginSession) CurrentGeneration() int {
+	s.Mutex.Lock()
+	defer s.Mutex.Unlock()
+	return s.Generation
+}
+
+// Succeeded records a request which was answered within the session
+func (s *LoginSession) Succeeded() {
+	if atomic.LoadInt32(&s.Failures) != 0 {
+		atomic.StoreInt32(&s.Failures, 0)
+	}
+}
+
+// Expired returns whether the response was redirected to the login page
+func (s *LoginSession) Expired(response *http.Response) bool {
+	if response.Request == nil || len(RedirectChain(response)) == 0 {
+		return false
+	}
+	return s.expired.MatchString(response.Request.URL.String())
+}
+
+// Excluded returns whether the url is the login page or logs out, these are not crawled
+func (s *LoginSession) Excluded(pageURL string) bool {
+	return s.expired.MatchString(pageURL) || s.logout.MatchString(pageURL)
+}
+
+func (s *LoginSession) login(requester *Request) error {
+	response, err := requester.send(http.MethodGet, s.Form.Url, nil)
+	if err != nil {
+		return errors.New(fmt.Sprintf("login page could not be fetched: %s", err.Error()))
+	}
+	doc, err := goquery.NewDocumentFromReader(response.Body)
+	response.Body.Close()
+	if err != nil {
+		return err
+	}
+	pageURL := response.Request.URL.String()
+	form := doc.FindMatcher(s.formSelector).First()
+	if form.Length() == 0 {
+		return errors.New(fmt.Sprintf("login form is not found on: %s", pageURL))
+	}
+	values := FormValues(form)
+	if s.Form.CSRFSelector != "" {
+		element := doc.Find(s.Form.CSRFSelector).First()
+		token, exists := element.Attr(s.Form.CSRFAttr)
+		if !exists || token == "" {
+			return errors.New(fmt.Sprintf("csrf token is not found on: %s", pageURL))
+		}
+		field := s.Form.CSRFField
+		if field == "" {
+			field = element.AttrOr("name", "")
+		}
+		if field == "" {
+			return errors.New("csrf field name is not given")
+		}
+		values.Set(field, token)
+	}
+	for name, value := range s.Form.Fields {
+		values.Set(name, value)
+	}
+
+	action := strings.TrimSpace(form.AttrOr("action", ""))
+	if action == "" {
+		action = pageURL
+	}
+	action, err = AbsoluteURL(BaseHref(pageURL, doc), action)
+	if err != nil {
+		return errors.New(fmt.Sprintf("login form action is not valid: %s", err.Error()))
+	}
+	method := strings.ToUpper(strings.TrimSpace(form.AttrOr("method", http.MethodGet)))
+	if method != http.MethodPost {
+		method = http.MethodGet
+	}
+	response, err = requester.send(method, action, values)
+	if err != nil {
+		return errors.New(fmt.Sprintf("login form could not be submitted: %s", err.Error()))
+	}
+	defer response.Body.Close()
+	// The login form is shown again when the login is refused
+	result, err := goquery.NewDocumentFromReader(response.Body)
+	if err != nil {
+		return err
+	}
+	if result.FindMatcher(s.formSelector).Length() > 0 {
+		return errors.New(fmt.Sprintf("login is refused by: %s", response.Request.URL.String()))
+	}
+	s.Generation++
+	return nil
+}
+
+// FormValues returns the values a browser submits with the form before the user fills it
+func FormValues(form *goquery.Selection) url.Values {
+	values := url.Values{}
+	form.Find("input[name], select[name], textarea[name]").Each(func(i int, s *goquery.Selection) {
+		name := s.AttrOr("name", "")
+		switch goquery.NodeName(s) {
+		case "select":
+			option := s.Find("option[selected]").First()
+			if option.Length() == 0 {
+				option = s.Find("option").First()
+			}
+			if option.Length() > 0 {
+				values.Add(name, option.AttrOr("value", strings.TrimSpace(option.Text())))
+			}
+		case "textarea":
+			values.Add(name, s.Text())
+		default:
+			switch strings.ToLower(s.AttrOr("type", "text")) {
+			case "submit", "button", "image", "reset", "file":
+				return
+			case "checkbox", "radio":
+				if _, checked := s.Attr("checked"); !checked {
+					return
+				}
+				values.Add(name, s.AttrOr("value", "on"))
+			default:
+				values.Add(name, s.AttrOr("value", ""))
+			}
+		}
+	})
+	return values
+}
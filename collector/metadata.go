@@ -0,0 +1,273 @@
+package collector
+
+import (
+	"encoding/json"
+	"github.com/PuerkitoBio/goquery"
+	"strings"
+	"time"
+)
+
+var dateLayouts = []string{
+	time.RFC3339Nano,
+	time.RFC3339,
+	"2006-01-02T15:04:05Z0700",
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+	time.RFC1123Z,
+	time.RFC1123,
+	time.RFC850,
+	time.ANSIC,
+	"January 2, 2006",
+	"2 January 2006",
+	"Jan 2, 2006",
+	"2006/01/02",
+}
+
+type PageMetadata struct {
+	Lang         string                   `json:"lang,omitempty"`
+	Keywords     []string                 `json:"keywords,omitempty"`
+	Author       string                   `json:"author,omitempty"`
+	Published    int64                    `json:"published,omitempty"`
+	Modified     int64                    `json:"modified,omitempty"`
+	CanonicalUrl string                   `json:"canonical_url,omitempty"`
+	OpenGraph    map[string][]string      `json:"open_graph,omitempty"`
+	TwitterCard  map[string][]string      `json:"twitter_card,omitempty"`
+	JSONLD       []map[string]interface{} `json:"json_ld,omitempty"`
+	Microdata    []*MicrodataItem         `json:"microdata,omitempty"`
+}
+
+type MicrodataItem struct {
+	Type       []string                 `json:"type,omitempty"`
+	Id         string                   `json:"id,omitempty"`
+	Properties map[string][]interface{} `json:"properties"`
+}
+
+type MetadataExtractorInterface interface {
+	Extract(pageURL string, doc *goquery.Document) *PageMetadata
+}
+
+type MetadataExtractor struct{}
+
+func NewMetadataExtractor() *MetadataExtractor {
+	return &MetadataExtractor{}
+}
+
+func (m *MetadataExtractor) Extract(pageURL string, doc *goquery.Document) *PageMetadata {
+	metadata := &PageMetadata{
+		OpenGraph:   map[string][]string{},
+		TwitterCard: map[string][]string{},
+		JSONLD:      []map[string]interface{}{},
+		Microdata:   []*MicrodataItem{},
+	}
+	metadata.Lang = strings.TrimSpace(doc.Find("html").AttrOr("lang", ""))
+
+	var published, modified string
+	doc.Find("meta").Each(func(i int, s *goquery.Selection) {
+		content, exists := s.Attr("content")
+		if !exists {
+			return
+		}
+		content = strings.TrimSpace(content)
+		// The http-equiv elements have neither a name nor a property
+		if equiv := s.AttrOr("http-equiv", ""); strings.EqualFold(equiv, "content-language") && metadata.Lang == "" {
+			metadata.Lang = content
+		}
+		// OpenGraph uses the property attribute, but name is also common in the wild
+		key := strings.ToLower(strings.TrimSpace(s.AttrOr("property", s.AttrOr("name", ""))))
+		switch {
+		case key == "":
+			return
+		case strings.HasPrefix(key, "og:") || strings.HasPrefix(key, "article:"):
+			metadata.OpenGraph[key] = append(metadata.OpenGraph[key], content)
+		case strings.HasPrefix(key, "twitter:"):
+			metadata.TwitterCard[key] = append(metadata.TwitterCard[key], content)
+		case key == "keywords":
+			for _, keyword := range strings.Split(content, ",") {
+				keyword = strings.TrimSpace(keyword)
+				if keyword != "" {
+					metadata.Keywords = append(metadata.Keywords, keyword)
+				}
+			}
+		case key == "author" || key == "dc.creator":
+			if metadata.Author == "" {
+				metadata.Author = content
+			}
+		case key == "date" || key == "dc.date" || key == "dc.date.issued" || key == "dcterms.created":
+			if published == "" {
+				published = content
+			}
+		case key == "last-modified" || key == "dcterms.modified":
+			if modified == "" {
+				modified = content
+			}
+		}
+	})
+
+	if href, exists := doc.Find("link[rel~=canonical]").First().Attr("href"); exists {
+		if canonical, err := AbsoluteURL(pageURL, strings.TrimSpace(href)); err == nil {
+			metadata.CanonicalUrl = canonical
+		}
+	}
+
+	doc.Find(`script[type="application/ld+json"]`).Each(func(i int, s *goquery.Selection) {
+		metadata.JSONLD = append(metadata.JSONLD, ParseJSONLD(s.Text())...)
+	})
+
+	doc.Find("[itemscope]").Each(func(i int, s *goquery.Selection) {
+		if _, isProperty := s.Attr("itemprop"); !isProperty {
+			metadata.Microdata = append(metadata.Microdata, ParseMicrodataItem(pageURL, s))
+		}
+	})
+
+	// Fallbacks from the OpenGraph, JSON-LD and microdata in the order of reliability
+	if values := metadata.OpenGraph["article:author"]; metadata.Author == "" && len(values) > 0 {
+		metadata.Author = values[0]
+	}
+	if values := metadata.OpenGraph["article:published_time"]; published == "" && len(values) > 0 {
+		published = values[0]
+	}
+	if values := metadata.OpenGraph["article:modified_time"]; modified == "" && len(values) > 0 {
+		modified = values[0]
+	}
+	if values := metadata.OpenGraph["og:updated_time"]; modified == "" && len(values) > 0 {
+		modified = values[0]
+	}
+	for _, object := range metadata.JSONLD {
+		if published == "" {
+			published, _ = object["datePublished"].(string)
+		}
+		if modified == "" {
+			modified, _ = object["dateModified"].(string)
+		}
+		if metadata.Author == "" {
+			metadata.Author = jsonLDName(object["author"])
+		}
+	}
+	doc.Find("[itemprop=datePublished], [itemprop=dateModified]").Each(func(i int, s *goquery.Selection) {
+		value := strings.TrimSpace(s.AttrOr("content", s.AttrOr("datetime", s.Text())))
+		if s.AttrOr("itemprop", "") == "datePublished" && published == "" {
+			published = value
+		} else if s.AttrOr("itemprop", "") == "dateModified" && modified == "" {
+			modified = value
+		}
+	})
+	metadata.Published = ParseDate(published)
+	metadata.Modified = ParseDate(modified)
+	return metadata
+}
+
+// ParseJSONLD decodes a JSON-LD script into its objects, flattening top level arrays and @graph containers
+func ParseJSONLD(s string) []map[string]interface{} {
+	var data interface{}
+	if err := json.Unmarshal([]byte(strings.TrimSpace(s)), &data); err != nil {
+		return nil
+	}
+	objects := []map[string]interface{}{}
+	var flatten func(v interface{})
+	flatten = func(v interface{}) {
+		switch value := v.(type) {
+		case []interface{}:
+			for _, item := range value {
+				flatten(item)
+			}
+		case map[string]interface{}:
+			if graph, exists := value["@graph"]; exists {
+				flatten(graph)
+				return
+			}
+			objects = append(objects, value)
+		}
+	}
+	flatten(data)
+	return objects
+}
+
+// ParseMicrodataItem reads the properties of an itemscope element, nested items are parsed recursively
+func ParseMicrodataItem(pageURL string, item *goquery.Selection) *MicrodataItem {
+	result := &MicrodataItem{
+		Type:       strings.Fields(item.AttrOr("itemtype", "")),
+		Id:         item.AttrOr("itemid", ""),
+		Properties: map[string][]interface{}{},
+	}
+	item.Find("[itemprop]").Each(func(i int, s *goquery.Selection) {
+		// Properties belong to the closest enclosing item only
+		owner := s.Parent().Closest("[itemscope]")
+		if owner.Length() == 0 || owner.Get(0) != item.Get(0) {
+			return
+		}
+		var value interface{}
+		if _, scoped := s.Attr("itemscope"); scoped {
+			value = ParseMicrodataItem(pageURL, s)
+		} else {
+			value = microdataValue(pageURL, s)
+		}
+		for _, name := range strings.Fields(s.AttrOr("itemprop", "")) {
+			result.Properties[name] = append(result.Properties[name], value)
+		}
+	})
+	return result
+}
+
+func microdataValue(pageURL string, s *goquery.Selection) string {
+	var attr string
+	switch goquery.NodeName(s) {
+	case "meta":
+		attr = "content"
+	case "a", "area", "link":
+		attr = "href"
+	case "audio", "embed", "iframe", "img", "source", "track", "video":
+		attr = "src"
+	case "object":
+		attr = "data"
+	case "data", "meter":
+		attr = "value"
+	case "time":
+		attr = "datetime"
+	}
+	if attr == "" {
+		return NormalizeSpaces(s.Text())
+	}
+	value, exists := s.Attr(attr)
+	if !exists {
+		return NormalizeSpaces(s.Text())
+	}
+	if attr == "href" || attr == "src" || attr == "data" {
+		if absoluteUrl, err := AbsoluteURL(pageURL, value); err == nil {
+			return absoluteUrl
+		}
+	}
+	return strings.TrimSpace(value)
+}
+
+func jsonLDName(v interface{}) string {
+	switch value := v.(type) {
+	case string:
+		return value
+	case map[string]interface{}:
+		name, _ := value["name"].(string)
+		return name
+	case []interface{}:
+		if len(value) > 0 {
+			return jsonLDName(value[0])
+		}
+	}
+	return ""
+}
+
+// ParseDate converts the commonly used date formats into unix timestamp, zero is returned when it fails
+func ParseDate(s string) int64 {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0
+	}
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.UTC().Unix()
+		}
+	}
+	return 0
+}
@@ -0,0 +1,153 @@
+package collector
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMetadataExtractorReadsTheMetaElements(t *testing.T) {
+	page := `<html lang="en-GB"><head>
+<meta name="keywords" content="crawler, search ,, index">
+<meta name="author" content="Jane Doe">
+<meta property="og:title" content="Crawling politely">
+<meta property="og:image" content="https://example.com/a.png">
+<meta property="og:image" content="https://example.com/b.png">
+<meta name="twitter:card" content="summary">
+<link rel="canonical" href="/articles/crawling">
+</head><body></body></html>`
+	metadata := NewMetadataExtractor().Extract("https://example.com/articles/crawling?ref=home", parseDocument(t, page))
+	if metadata.Lang != "en-GB" {
+		t.Errorf("got lang %q", metadata.Lang)
+	}
+	if want := []string{"crawler", "search", "index"}; !reflect.DeepEqual(metadata.Keywords, want) {
+		t.Errorf("got keywords %q, want %q", metadata.Keywords, want)
+	}
+	if metadata.Author != "Jane Doe" {
+		t.Errorf("got author %q", metadata.Author)
+	}
+	if want := []string{"https://example.com/a.png", "https://example.com/b.png"}; !reflect.DeepEqual(metadata.OpenGraph["og:image"], want) {
+		t.Errorf("got og:image %q, want %q", metadata.OpenGraph["og:image"], want)
+	}
+	if got := metadata.TwitterCard["twitter:card"]; !reflect.DeepEqual(got, []string{"summary"}) {
+		t.Errorf("got twitter:card %q", got)
+	}
+	if metadata.CanonicalUrl != "https://example.com/articles/crawling" {
+		t.Errorf("got canonical url %q", metadata.CanonicalUrl)
+	}
+}
+
+func TestMetadataExtractorReadsTheContentLanguage(t *testing.T) {
+	page := `<html><head><meta http-equiv="Content-Language" content="fr"></head><body></body></html>`
+	if metadata := NewMetadataExtractor().Extract("https://example.com/", parseDocument(t, page)); metadata.Lang != "fr" {
+		t.Errorf("got lang %q, want fr", metadata.Lang)
+	}
+}
+
+func TestMetadataExtractorReadsJSONLD(t *testing.T) {
+	page := `<html><head><script type="application/ld+json">
+{"@context": "https://schema.org", "@graph": [
+	{"@type": "Article", "headline": "Crawling", "datePublished": "2021-03-04T10:00:00Z",
+	 "dateModified": "2021-03-05", "author": [{"@type": "Person", "name": "John Roe"}]},
+	{"@type": "Organization", "name": "Example"}
+]}
+</script><script type="application/ld+json">not json</script></head><body></body></html>`
+	metadata := NewMetadataExtractor().Extract("https://example.com/", parseDocument(t, page))
+	if len(metadata.JSONLD) != 2 {
+		t.Fatalf("got %d json-ld objects, want 2", len(metadata.JSONLD))
+	}
+	if metadata.JSONLD[1]["name"] != "Example" {
+		t.Errorf("got second object %v", metadata.JSONLD[1])
+	}
+	if metadata.Author != "John Roe" {
+		t.Errorf("got author %q", metadata.Author)
+	}
+	if want := time.Date(2021, 3, 4, 10, 0, 0, 0, time.UTC).Unix(); metadata.Published != want {
+		t.Errorf("got published %d, want %d", metadata.Published, want)
+	}
+	if want := time.Date(2021, 3, 5, 0, 0, 0, 0, time.UTC).Unix(); metadata.Modified != want {
+		t.Errorf("got modified %d, want %d", metadata.Modified, want)
+	}
+}
+
+func TestMetadataExtractorReadsMicrodata(t *testing.T) {
+	page := `<html><body>
+<div itemscope itemtype="https://schema.org/Product" itemid="urn:product:1">
+	<span itemprop="name">Crawler book</span>
+	<a itemprop="url" href="/book">Book</a>
+	<img itemprop="image" src="book.png">
+	<div itemprop="offers" itemscope itemtype="https://schema.org/Offer">
+		<meta itemprop="price" content="12.50">
+		<span itemprop="name">Paperback</span>
+	</div>
+	<time itemprop="datePublished" datetime="2020-01-02">January 2nd</time>
+</div>
+</body></html>`
+	metadata := NewMetadataExtractor().Extract("https://example.com/shop/", parseDocument(t, page))
+	if len(metadata.Microdata) != 1 {
+		t.Fatalf("got %d top level items, want 1", len(metadata.Microdata))
+	}
+	item := metadata.Microdata[0]
+	if !reflect.DeepEqual(item.Type, []string{"https://schema.org/Product"}) || item.Id != "urn:product:1" {
+		t.Errorf("got type %q and id %q", item.Type, item.Id)
+	}
+	for name, want := range map[string]interface{}{
+		"name":  "Crawler book",
+		"url":   "https://example.com/book",
+		"image": "https://example.com/shop/book.png",
+	} {
+		if got := item.Properties[name]; !reflect.DeepEqual(got, []interface{}{want}) {
+			t.Errorf("got %s %v, want %v", name, got, want)
+		}
+	}
+	offers := item.Properties["offers"]
+	if len(offers) != 1 {
+		t.Fatalf("got offers %v", offers)
+	}
+	offer, ok := offers[0].(*MicrodataItem)
+	if !ok {
+		t.Fatalf("offer is not an item: %v", offers[0])
+	}
+	// The name of the offer belongs to the offer only
+	if !reflect.DeepEqual(offer.Properties["name"], []interface{}{"Paperback"}) ||
+		!reflect.DeepEqual(offer.Properties["price"], []interface{}{"12.50"}) {
+		t.Errorf("got offer properties %v", offer.Properties)
+	}
+	if want := time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC).Unix(); metadata.Published != want {
+		t.Errorf("got published %d, want %d", metadata.Published, want)
+	}
+}
+
+func TestMetadataExtractorPrefersTheMetaDates(t *testing.T) {
+	page := `<html><head>
+<meta name="dc.date" content="2019-05-06">
+<meta property="article:published_time" content="2018-01-01T00:00:00Z">
+<meta property="og:updated_time" content="2019-07-08T09:10:11+02:00">
+</head><body></body></html>`
+	metadata := NewMetadataExtractor().Extract("https://example.com/", parseDocument(t, page))
+	if want := time.Date(2019, 5, 6, 0, 0, 0, 0, time.UTC).Unix(); metadata.Published != want {
+		t.Errorf("got published %d, want %d", metadata.Published, want)
+	}
+	if want := time.Date(2019, 7, 8, 7, 10, 11, 0, time.UTC).Unix(); metadata.Modified != want {
+		t.Errorf("got modified %d, want %d", metadata.Modified, want)
+	}
+}
+
+func TestParseDate(t *testing.T) {
+	day := time.Date(2021, 6, 15, 0, 0, 0, 0, time.UTC).Unix()
+	for input, want := range map[string]int64{
+		"2021-06-15":                    day,
+		" 2021/06/15 ":                  day,
+		"June 15, 2021":                 day,
+		"15 June 2021":                  day,
+		"Jun 15, 2021":                  day,
+		"2021-06-15T12:30:00+02:00":     day + 10*3600 + 30*60,
+		"Tue, 15 Jun 2021 08:00:00 GMT": day + 8*3600,
+		"":                              0,
+		"yesterday":                     0,
+	} {
+		if got := ParseDate(input); got != want {
+			t.Errorf("ParseDate(%q) = %d, want %d", input, got, want)
+		}
+	}
+}
@@ -0,0 +1,301 @@
+package collector
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// Number of item ids remembered per feed to tell the new items apart
+	MaxSeenFeedItems = 1000
+	// Polls an item is scraped by before it is given up and marked seen when its page keeps failing
+	MaxFeedItemAttempts = 3
+)
+
+type FeedMonitorInterface interface {
+	AddFeed(feedURL string) error
+	RemoveFeed(feedURL string)
+	Poll() (int, error)
+	RecordFailure(state *FeedState, item *FeedItem) bool
+	MarkSeen(state *FeedState, items []*FeedItem)
+	Run(stop <-chan struct{})
+	LoadState() error
+	SaveState() error
+}
+
+type FeedState struct {
+	Url        string   `json:"url"`
+	LastSeenId string   `json:"last_seen_id"`
+	LastSeen   int64    `json:"last_seen"`
+	LastPolled int64    `json:"last_polled"`
+	SeenIds    []string `json:"seen_ids"`
+	// Failed scrapes of the items which are not seen yet, keyed by the item id
+	Failures map[string]int `json:"failures,omitempty"`
+}
+
+// FeedMonitor polls the registered RSS/Atom feeds and scrapes the pages of their new items with the
+// scrapper of the collector. The new pages are appended to the results file of the collector and handed
+// to OnPages, which is the place to update the search index incrementally.
+type FeedMonitor struct {
+	Collector *Collector
+	Interval  time.Duration
+	StateFile string
+	Feeds     map[string]*FeedState
+	OnPages   func(pages []*SucceededPage)
+	Mutex     sync.Mutex
+}
+
+func NewFeedMonitor(collector *Collector, interval time.Duration, stateFile string) (*FeedMonitor, error) {
+	if interval <= 0 {
+		return nil, errors.New("interval should be a positive duration")
+	}
+	m := &FeedMonitor{
+		Collector: collector,
+		Interval:  interval,
+		StateFile: stateFile,
+		Feeds:     map[string]*FeedState{},
+	}
+	if err := m.LoadState(); err != nil {
+		return nil, err
+	}
+	if err := collector.LoadResultsFromFile(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (m *FeedMonitor) AddFeed(feedURL string) error {
+	if _, err := url.ParseRequestURI(feedURL); err != nil {
+		return errors.New(fmt.Sprintf("feed is not valid url: %s", err.Error()))
+	}
+	m.Mutex.Lock()
+	defer m.Mutex.Unlock()
+	if _, exists := m.Feeds[feedURL]; !exists {
+		m.Feeds[feedURL] = &FeedState{Url: feedURL, SeenIds: []string{}}
+	}
+	return nil
+}
+
+func (m *FeedMonitor) RemoveFeed(feedURL string) {
+	m.Mutex.Lock()
+	delete(m.Feeds, feedURL)
+	m.Mutex.Unlock()
+}
+
+// Run polls the feeds every interval until the stop channel is closed
+func (m *FeedMonitor) Run(stop <-chan struct{}) {
+	ticker := time.NewTicker(m.Interval)
+	defer ticker.Stop()
+	for {
+		if _, err := m.Poll(); err != nil {
+			m.Collector.Loggers.Log(ERROR, fmt.Sprintf("Feed polling failed: %s\n", err.Error()))
+		}
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Poll fetches every feed once and scrapes the pages of the items which are not seen yet, it returns the
+// number of the new pages. An item is seen once its page is scraped, the items whose pages failed are tried
+// again by the next polls until MaxFeedItemAttempts is reached.
+func (m *FeedMonitor) Poll() (int, error) {
+	if m.Collector.Begin.IsZero() {
+		m.Collector.Begin = time.Now()
+	}
+	m.Mutex.Lock()
+	feeds := make([]*FeedState, 0, len(m.Feeds))
+	for _, state := range m.Feeds {
+		feeds = append(feeds, state)
+	}
+	m.Mutex.Unlock()
+	sort.Slice(feeds, func(i, j int) bool {
+		return feeds[i].Url < feeds[j].Url
+	})
+
+	links := []string{}
+	newItems := map[*FeedState][]*FeedItem{}
+	itemLinks := map[*FeedItem]string{}
+	for _, state := range feeds {
+		items, err := m.FetchNewItems(state)
+		if err != nil {
+			m.Collector.Loggers.Log(WARNING, fmt.Sprintf("Feed could not be fetched: %s Reason: %s\n", state.Url, err.Error()))
+			continue
+		}
+		newItems[state] = items
+		for _, item := range items {
+			link, err := AbsoluteURL(state.Url, item.Link)
+			if err != nil {
+				continue
+			}
+			itemLinks[item] = link
+			if !URLExists(links, link) {
+				links = append(links, link)
+			}
+		}
+	}
+
+	pages := []*SucceededPage{}
+	if len(links) > 0 {
+		var wg sync.WaitGroup
+		wg.Add(len(links))
+		channel := make(chan ScrapeResult)
+		for _, link := range links {
+			go m.Collector.Scrapper.Scrape(link, channel, &wg)
+		}
+		for range links {
+			scrapeResult := <-channel
+			if scrapeResult.Error != nil {
+				m.Collector.Loggers.Log(ERROR, fmt.Sprintf("Scrape error: %s\n", scrapeResult.Error.Error()))
+			}
+			if scrapeResult.Page != nil {
+				pages = append(pages, scrapeResult.Page)
+			}
+		}
+		wg.Wait()
+	}
+
+	// Items without a valid link have no page to retry
+	for state, items := range newItems {
+		seen := []*FeedItem{}
+		for _, item := range items {
+			link, valid := itemLinks[item]
+			if !valid || m.Collector.Scrapper.IsVisited(link) || m.RecordFailure(state, item) {
+				seen = append(seen, item)
+			}
+		}
+		m.MarkSeen(state, seen)
+	}
+
+	if len(pages) > 0 {
+		m.Collector.End = time.Now()
+		if _, err := m.Collector.SaveResultsToFile(); err != nil {
+			return len(pages), err
+		}
+		if m.OnPages != nil {
+			m.OnPages(pages)
+		}
+	}
+	m.Collector.Loggers.Log(INFO, fmt.Sprintf("Feeds polled %d new pages scrapped\n", len(pages)))
+	return len(pages), m.SaveState()
+}
+
+// FetchNewItems downloads the feed and returns its items which are not seen before, oldest first
+func (m *FeedMonitor) FetchNewItems(state *FeedState) ([]*FeedItem, error) {
+	requester := NewRequestWithTransport(m.Collector.Scrapper.Timeout, m.Collector.Scrapper.Transport)
+	response, err := requester.GetRequest(state.Url)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	body, err := ioutil.ReadAll(io.LimitReader(response.Body, MaxDocumentSize))
+	if err != nil {
+		return nil, err
+	}
+	feed, err := ParseFeed(body)
+	if err != nil {
+		return nil, err
+	}
+
+	m.Mutex.Lock()
+	defer m.Mutex.Unlock()
+	seen := map[string]bool{}
+	for _, id := range state.SeenIds {
+		seen[id] = true
+	}
+	items := []*FeedItem{}
+	for _, item := range feed.Items {
+		if item.Id == "" || seen[item.Id] {
+			continue
+		}
+		seen[item.Id] = true
+		items = append(items, item)
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].Published < items[j].Published
+	})
+	state.LastPolled = CurrentTimestamp()
+	return items, nil
+}
+
+// RecordFailure counts a failed scrape of the page of the item, it returns true when the item is given up
+func (m *FeedMonitor) RecordFailure(state *FeedState, item *FeedItem) bool {
+	m.Mutex.Lock()
+	defer m.Mutex.Unlock()
+	if state.Failures == nil {
+		state.Failures = map[string]int{}
+	}
+	state.Failures[item.Id]++
+	if state.Failures[item.Id] < MaxFeedItemAttempts {
+		return false
+	}
+	m.Collector.Loggers.Log(WARNING, fmt.Sprintf("Feed item given up after %d failed scrapes: %s\n", state.Failures[item.Id], item.Link))
+	return true
+}
+
+// MarkSeen records the items of the feed as seen so that their pages are not scraped again, oldest first
+func (m *FeedMonitor) MarkSeen(state *FeedState, items []*FeedItem) {
+	m.Mutex.Lock()
+	defer m.Mutex.Unlock()
+	for _, item := range items {
+		delete(state.Failures, item.Id)
+		state.SeenIds = append(state.SeenIds, item.Id)
+		state.LastSeenId = item.Id
+		if item.Published > state.LastSeen {
+			state.LastSeen = item.Published
+		}
+	}
+	if len(state.SeenIds) > MaxSeenFeedItems {
+		state.SeenIds = state.SeenIds[len(state.SeenIds)-MaxSeenFeedItems:]
+	}
+}
+
+func (m *FeedMonitor) LoadState() error {
+	bytes, err := ioutil.ReadFile(m.StateFile)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var feeds []*FeedState
+	if err := json.Unmarshal(bytes, &feeds); err != nil {
+		return errors.New(fmt.Sprintf("feed monitor state could not be parsed: %s", err.Error()))
+	}
+	m.Mutex.Lock()
+	defer m.Mutex.Unlock()
+	for _, state := range feeds {
+		if state.SeenIds == nil {
+			state.SeenIds = []string{}
+		}
+		m.Feeds[state.Url] = state
+	}
+	return nil
+}
+
+func (m *FeedMonitor) SaveState() error {
+	m.Mutex.Lock()
+	feeds := make([]*FeedState, 0, len(m.Feeds))
+	for _, state := range m.Feeds {
+		feeds = append(feeds, state)
+	}
+	sort.Slice(feeds, func(i, j int) bool {
+		return feeds[i].Url < feeds[j].Url
+	})
+	file, err := json.MarshalIndent(feeds, "", "  ")
+	m.Mutex.Unlock()
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(m.StateFile, file, 0644)
+}
@@ -0,0 +1,241 @@
+package collector
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	SucceededLogFile = "succeeded.log"
+	FailedLogFile    = "failed.log"
+	PageIndexDir     = "index"
+)
+
+// Values of the page index are the log of the page and the offset of its record, "s:120" or "f:0". A page
+// which is taken out of the store is left with an empty value.
+const (
+	succeededRecord = "s"
+	failedRecord    = "f"
+)
+
+// DiskPageStore keeps the scraped pages in append only logs on the disk, the index of the urls tells where the
+// record of a page is. It holds the visited set of the crawls which do not fit into the memory.
+type DiskPageStore struct {
+	Dir       string
+	Index     *DiskIndex
+	Succeeded *os.File
+	Failed    *os.File
+	succeeded int
+	failed    int
+	Mutex     sync.Mutex
+}
+
+// NewDiskPageStore opens the store in the directory, the pages of an existing store are kept
+func NewDiskPageStore(dir string, expectedUrls int) (*DiskPageStore, error) {
+	index, err := NewDiskIndex(filepath.Join(dir, PageIndexDir), expectedUrls)
+	if err != nil {
+		return nil, err
+	}
+	store := &DiskPageStore{Dir: dir, Index: index}
+	if store.Succeeded, err = os.OpenFile(filepath.Join(dir, SucceededLogFile), os.O_CREATE|os.O_RDWR, 0644); err != nil {
+		return nil, err
+	}
+	if store.Failed, err = os.OpenFile(filepath.Join(dir, FailedLogFile), os.O_CREATE|os.O_RDWR, 0644); err != nil {
+		return nil, err
+	}
+	err = index.Each(func(key string, value string) error {
+		if strings.HasPrefix(value, succeededRecord+":") {
+			store.succeeded++
+		} else if strings.HasPrefix(value, failedRecord+":") {
+			store.failed++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+func (s *DiskPageStore) PutSucceeded(url string, page *SucceededPage) error {
+	return s.put(url, succeededRecord, page)
+}
+
+func (s *DiskPageStore) PutFailed(url string, page *FailedPage) error {
+	return s.put(url, failedRecord, page)
+}
+
+func (s *DiskPageStore) put(url string, kind string, page interface{}) error {
+	record, err := json.Marshal(page)
+	if err != nil {
+		return err
+	}
+	s.Mutex.Lock()
+	defer s.Mutex.Unlock()
+	file := s.Succeeded
+	if kind == failedRecord {
+		file = s.Failed
+	}
+	offset, err := file.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(record, '\n')); err != nil {
+		return err
+	}
+	previous, _ := s.Index.Get(url)
+	s.count(previous, -1)
+	value := kind + ":" + strconv.FormatInt(offset, 10)
+	s.count(value, 1)
+	return s.Index.Put(url, value)
+}
+
+// Get returns the page of the url, either the succeeded or the failed one is set if the url is stored
+func (s *DiskPageStore) Get(url string) (*SucceededPage, *FailedPage, error) {
+	value, exists := s.Index.Get(url)
+	if !exists || value == "" {
+		return nil, nil, nil
+	}
+	s.Mutex.Lock()
+	defer s.Mutex.Unlock()
+	return s.read(value)
+}
+
+func (s *DiskPageStore) read(value string) (*SucceededPage, *FailedPage, error) {
+	parts := strings.SplitN(value, ":", 2)
+	if len(parts) != 2 {
+		return nil, nil, errors.New(fmt.Sprintf("page index value is not valid: %s", value))
+	}
+	offset, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return nil, nil, err
+	}
+	file := s.Succeeded
+	if parts[0] == failedRecord {
+		file = s.Failed
+	}
+	reader := bufio.NewReader(io.NewSectionReader(file, offset, 1<<62))
+	record, err := reader.ReadBytes('\n')
+	if err != nil && err != io.EOF {
+		return nil, nil, err
+	}
+	if parts[0] == failedRecord {
+		var page FailedPage
+		if err := json.Unmarshal(record, &page); err != nil {
+			return nil, nil, err
+		}
+		return nil, &page, nil
+	}
+	var page SucceededPage
+	if err := json.Unmarshal(record, &page); err != nil {
+		return nil, nil, err
+	}
+	return &page, nil, nil
+}
+
+// IsSucceeded and IsFailed answer from the index without reading the pages
+func (s *DiskPageStore) IsSucceeded(url string) bool {
+	value, _ := s.Index.Get(url)
+	return strings.HasPrefix(value, succeededRecord+":")
+}
+
+func (s *DiskPageStore) IsFailed(url string) bool {
+	value, _ := s.Index.Get(url)
+	return strings.HasPrefix(value, failedRecord+":")
+}
+
+// Take returns the page of the url and takes the url out of the store at once
+func (s *DiskPageStore) Take(url string) (*SucceededPage, *FailedPage, error) {
+	s.Mutex.Lock()
+	defer s.Mutex.Unlock()
+	value, exists := s.Index.Get(url)
+	if !exists || value == "" {
+		return nil, nil, nil
+	}
+	succeeded, failed, err := s.read(value)
+	if err != nil {
+		return nil, nil, err
+	}
+	s.count(value, -1)
+	return succeeded, failed, s.Index.Put(url, "")
+}
+
+// Delete takes the url out of the store, its record stays in the log
+func (s *DiskPageStore) Delete(url string) error {
+	s.Mutex.Lock()
+	defer s.Mutex.Unlock()
+	previous, exists := s.Index.Get(url)
+	if !exists || previous == "" {
+		return nil
+	}
+	s.count(previous, -1)
+	return s.Index.Put(url, "")
+}
+
+func (s *DiskPageStore) count(value string, delta int) {
+	if strings.HasPrefix(value, succeededRecord+":") {
+		s.succeeded += delta
+	} else if strings.HasPrefix(value, failedRecord+":") {
+		s.failed += delta
+	}
+}
+
+func (s *DiskPageStore) NumberOfSucceeded() int {
+	s.Mutex.Lock()
+	defer s.Mutex.Unlock()
+	return s.succeeded
+}
+
+func (s *DiskPageStore) NumberOfFailed() int {
+	s.Mutex.Lock()
+	defer s.Mutex.Unlock()
+	return s.failed
+}
+
+// EachSucceeded calls the function for every succeeded page of the store
+func (s *DiskPageStore) EachSucceeded(fn func(url string, page *SucceededPage) error) error {
+	return s.each(succeededRecord, func(url string, succeeded *SucceededPage, failed *FailedPage) error {
+		return fn(url, succeeded)
+	})
+}
+
+// EachFailed calls the function for every failed page of the store
+func (s *DiskPageStore) EachFailed(fn func(url string, page *FailedPage) error) error {
+	return s.each(failedRecord, func(url string, succeeded *SucceededPage, failed *FailedPage) error {
+		return fn(url, failed)
+	})
+}
+
+func (s *DiskPageStore) each(kind string, fn func(url string, succeeded *SucceededPage, failed *FailedPage) error) error {
+	return s.Index.Each(func(url string, value string) error {
+		if !strings.HasPrefix(value, kind+":") {
+			return nil
+		}
+		s.Mutex.Lock()
+		succeeded, failed, err := s.read(value)
+		s.Mutex.Unlock()
+		if err != nil {
+			return err
+		}
+		return fn(url, succeeded, failed)
+	})
+}
+
+// Close flushes the index and closes the logs
+func (s *DiskPageStore) Close() error {
+	if err := s.Index.Flush(); err != nil {
+		return err
+	}
+	if err := s.Succeeded.Close(); err != nil {
+		return err
+	}
+	return s.Failed.Close()
+}
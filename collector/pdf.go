@@ -0,0 +1,776 @@
+package collector
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+var (
+	pdfObjectHeader = regexp.MustCompile(`(\d+)\s+(\d+)\s+obj\b`)
+	pdfReference    = regexp.MustCompile(`(\d+)\s+\d+\s+R\b`)
+	pdfInfo         = regexp.MustCompile(`/Info\s+(\d+)\s+\d+\s+R\b`)
+	pdfURI          = regexp.MustCompile(`/URI\s*\(`)
+	pdfEncrypt      = regexp.MustCompile(`/Encrypt\s+\d+\s+\d+\s+R\b`)
+)
+
+const (
+	// MaxPDFStreamSize bounds the inflated size of a single stream and MaxPDFDecodedSize the inflated size of
+	// all the streams of a document, a small compressed document could otherwise expand without limit
+	MaxPDFStreamSize  = 4 * 1024 * 1024
+	MaxPDFDecodedSize = 4 * MaxPDFStreamSize
+)
+
+type pdfObject struct {
+	Dict    string
+	Raw     []byte
+	Stream  []byte
+	Decoded bool
+}
+
+// PDFDocument is a minimal pdf reader able to extract the text of the pages. It supports flate compressed
+// content streams, object streams and ToUnicode character maps which is enough for the most documents.
+// The streams are only inflated when the text needs them.
+type PDFDocument struct {
+	Objects map[int]*pdfObject
+	Data    []byte
+	Budget  int
+}
+
+type pdfCMap struct {
+	CodeLength int
+	Chars      map[string]string
+}
+
+type pdfToken struct {
+	Kind  byte
+	Value string
+	Items []pdfToken
+}
+
+const (
+	pdfOperator = 'o'
+	pdfNumber   = 'n'
+	pdfString   = 's'
+	pdfName     = '/'
+	pdfArray    = '['
+)
+
+func ParsePDF(data []byte) (*PDFDocument, error) {
+	if !bytes.HasPrefix(bytes.TrimLeft(data, "\x00\t\r\n "), []byte("%PDF")) {
+		return nil, errors.New("missing pdf header")
+	}
+	doc := &PDFDocument{Objects: map[int]*pdfObject{}, Data: data, Budget: MaxPDFDecodedSize}
+	matches := pdfObjectHeader.FindAllSubmatchIndex(data, -1)
+	for idx, match := range matches {
+		number, _ := strconv.Atoi(string(data[match[2]:match[3]]))
+		end := len(data)
+		if idx+1 < len(matches) {
+			end = matches[idx+1][0]
+		}
+		body := data[match[1]:end]
+		if endobj := bytes.LastIndex(body, []byte("endobj")); endobj >= 0 {
+			body = body[:endobj]
+		}
+		object := &pdfObject{Dict: string(body)}
+		if start := bytes.Index(body, []byte("stream")); start >= 0 && bytes.Contains(body[:start], []byte("<<")) {
+			object.Dict = string(body[:start])
+			stream := body[start+len("stream"):]
+			stream = bytes.TrimPrefix(stream, []byte("\r"))
+			stream = bytes.TrimPrefix(stream, []byte("\n"))
+			if end := bytes.LastIndex(stream, []byte("endstream")); end >= 0 {
+				stream = stream[:end]
+			}
+			if length, err := strconv.Atoi(pdfDictValue(object.Dict, "Length")); err == nil && length >= 0 && length <= len(stream) {
+				stream = stream[:length]
+			}
+			object.Raw = stream
+		}
+		doc.Objects[number] = object
+	}
+	if len(doc.Objects) == 0 {
+		return nil, errors.New("no pdf objects found")
+	}
+	if pdfEncrypt.Match(data) {
+		return nil, errors.New("encrypted pdf documents are not supported")
+	}
+	doc.expandObjectStreams()
+	return doc, nil
+}
+
+// expandObjectStreams registers the objects compressed into the object streams
+func (d *PDFDocument) expandObjectStreams() {
+	for _, number := range d.sortedNumbers() {
+		object := d.Objects[number]
+		if pdfDictValue(object.Dict, "Type") != "/ObjStm" || d.Stream(object) == nil {
+			continue
+		}
+		count, _ := strconv.Atoi(pdfDictValue(object.Dict, "N"))
+		first, _ := strconv.Atoi(pdfDictValue(object.Dict, "First"))
+		if first < 0 || first > len(object.Stream) {
+			continue
+		}
+		header := strings.Fields(string(object.Stream[:first]))
+		for i := 0; i < count && 2*i+1 < len(header); i++ {
+			number, _ := strconv.Atoi(header[2*i])
+			offset, _ := strconv.Atoi(header[2*i+1])
+			end := len(object.Stream) - first
+			if 2*i+3 < len(header) {
+				end, _ = strconv.Atoi(header[2*i+3])
+			}
+			if offset < 0 || end < 0 || first+offset > len(object.Stream) || first+end > len(object.Stream) || offset > end {
+				continue
+			}
+			if _, exists := d.Objects[number]; !exists {
+				d.Objects[number] = &pdfObject{Dict: string(object.Stream[first+offset : first+end])}
+			}
+		}
+	}
+}
+
+// Resolve follows an indirect reference, direct values are returned as they are
+func (d *PDFDocument) Resolve(value string) *pdfObject {
+	if match := pdfReference.FindStringSubmatch(value); match != nil && strings.HasSuffix(strings.TrimSpace(value), "R") {
+		number, _ := strconv.Atoi(match[1])
+		if object, exists := d.Objects[number]; exists {
+			return object
+		}
+		return &pdfObject{}
+	}
+	return &pdfObject{Dict: value}
+}
+
+// Pages returns the page objects in the order of the page tree
+func (d *PDFDocument) Pages() []*pdfObject {
+	pages := []*pdfObject{}
+	visited := map[*pdfObject]bool{}
+	var walk func(node *pdfObject)
+	walk = func(node *pdfObject) {
+		if visited[node] {
+			return
+		}
+		visited[node] = true
+		switch pdfDictValue(node.Dict, "Type") {
+		case "/Pages":
+			for _, kid := range pdfReference.FindAllString(pdfDictValue(node.Dict, "Kids"), -1) {
+				walk(d.Resolve(kid))
+			}
+		case "/Page":
+			pages = append(pages, node)
+		}
+	}
+	for _, number := range d.sortedNumbers() {
+		object := d.Objects[number]
+		if pdfDictValue(object.Dict, "Type") == "/Catalog" {
+			walk(d.Resolve(pdfDictValue(object.Dict, "Pages")))
+			break
+		}
+	}
+	if len(pages) == 0 {
+		for _, number := range d.sortedNumbers() {
+			if object := d.Objects[number]; pdfDictValue(object.Dict, "Type") == "/Page" {
+				pages = append(pages, object)
+			}
+		}
+	}
+	return pages
+}
+
+func (d *PDFDocument) sortedNumbers() []int {
+	numbers := make([]int, 0, len(d.Objects))
+	for number := range d.Objects {
+		numbers = append(numbers, number)
+	}
+	sort.Ints(numbers)
+	return numbers
+}
+
+// Fonts returns the character maps of the fonts of a page keyed by their resource names, the
+// resources are inherited from the parent nodes of the page tree
+func (d *PDFDocument) Fonts(page *pdfObject) map[string]*pdfCMap {
+	fonts := map[string]*pdfCMap{}
+	for node, depth := page, 0; node != nil && depth < 32; depth++ {
+		resources := pdfDictValue(node.Dict, "Resources")
+		if resources != "" {
+			fontDict := d.Resolve(pdfDictValue(d.Resolve(resources).Dict, "Font")).Dict
+			for name, ref := range pdfDictEntries(fontDict) {
+				if _, exists := fonts[name]; exists {
+					continue
+				}
+				font := d.Resolve(ref)
+				toUnicode := pdfDictValue(font.Dict, "ToUnicode")
+				if toUnicode == "" {
+					fonts[name] = nil
+					continue
+				}
+				fonts[name] = parsePDFCMap(d.Stream(d.Resolve(toUnicode)))
+			}
+			break
+		}
+		parent := pdfDictValue(node.Dict, "Parent")
+		if parent == "" {
+			break
+		}
+		node = d.Resolve(parent)
+	}
+	return fonts
+}
+
+// PageText extracts the lines of text of the page content streams
+func (d *PDFDocument) PageText(page *pdfObject) []string {
+	contents := pdfDictValue(page.Dict, "Contents")
+	var stream []byte
+	for _, ref := range pdfReference.FindAllString(contents, -1) {
+		object := d.Resolve(ref)
+		if object.Raw == nil && strings.HasPrefix(strings.TrimSpace(object.Dict), "[") {
+			// Contents array held by an indirect object
+			for _, inner := range pdfReference.FindAllString(object.Dict, -1) {
+				stream = append(stream, d.Stream(d.Resolve(inner))...)
+				stream = append(stream, '\n')
+			}
+			continue
+		}
+		stream = append(stream, d.Stream(object)...)
+		stream = append(stream, '\n')
+	}
+	fonts := d.Fonts(page)
+
+	lines := []string{}
+	var line strings.Builder
+	newLine := func() {
+		text := NormalizeSpaces(line.String())
+		if text != "" {
+			lines = append(lines, text)
+		}
+		line.Reset()
+	}
+	var font *pdfCMap
+	operands := []pdfToken{}
+	lastY := 0.0
+	for _, token := range tokenizePDF(stream) {
+		if token.Kind != pdfOperator {
+			operands = append(operands, token)
+			continue
+		}
+		switch token.Value {
+		case "Tf":
+			if len(operands) >= 2 && operands[0].Kind == pdfName {
+				font = fonts[operands[0].Value]
+			}
+		case "Tj":
+			if len(operands) >= 1 {
+				line.WriteString(decodePDFString(operands[len(operands)-1].Value, font))
+			}
+		case "'", "\"":
+			newLine()
+			if len(operands) >= 1 {
+				line.WriteString(decodePDFString(operands[len(operands)-1].Value, font))
+			}
+		case "TJ":
+			if len(operands) >= 1 {
+				for _, item := range operands[len(operands)-1].Items {
+					if item.Kind == pdfString {
+						line.WriteString(decodePDFString(item.Value, font))
+					} else if adjustment, err := strconv.ParseFloat(item.Value, 64); err == nil && adjustment < -200 {
+						line.WriteString(" ")
+					}
+				}
+			}
+		case "Td", "TD":
+			if len(operands) >= 2 {
+				if y, err := strconv.ParseFloat(operands[1].Value, 64); err == nil && y != 0 {
+					newLine()
+				} else {
+					line.WriteString(" ")
+				}
+			}
+		case "Tm":
+			if len(operands) >= 6 {
+				if y, err := strconv.ParseFloat(operands[5].Value, 64); err == nil && y != lastY {
+					newLine()
+					lastY = y
+				} else {
+					line.WriteString(" ")
+				}
+			}
+		case "T*", "ET":
+			newLine()
+		}
+		operands = operands[:0]
+	}
+	newLine()
+	return lines
+}
+
+// Paragraphs joins the lines of the pages into paragraphs ending with a sentence terminator
+func (d *PDFDocument) Paragraphs() []string {
+	paragraphs := []string{}
+	for _, page := range d.Pages() {
+		current := ""
+		for _, line := range d.PageText(page) {
+			if strings.HasSuffix(current, "-") {
+				current = strings.TrimSuffix(current, "-") + line
+			} else if current != "" {
+				current += " " + line
+			} else {
+				current = line
+			}
+			if strings.HasSuffix(line, ".") || strings.HasSuffix(line, "!") || strings.HasSuffix(line, "?") ||
+				strings.HasSuffix(line, ":") {
+				paragraphs = append(paragraphs, current)
+				current = ""
+			}
+		}
+		if current != "" {
+			paragraphs = append(paragraphs, current)
+		}
+	}
+	return paragraphs
+}
+
+func (d *PDFDocument) Title() string {
+	return d.infoValue("Title")
+}
+
+func (d *PDFDocument) Subject() string {
+	return d.infoValue("Subject")
+}
+
+func (d *PDFDocument) infoValue(key string) string {
+	match := pdfInfo.FindSubmatch(d.Data)
+	if match == nil {
+		return ""
+	}
+	number, _ := strconv.Atoi(string(match[1]))
+	info, exists := d.Objects[number]
+	if !exists {
+		return ""
+	}
+	tokens := tokenizePDF([]byte(pdfDictValue(info.Dict, key)))
+	if len(tokens) == 0 || tokens[0].Kind != pdfString {
+		return ""
+	}
+	return NormalizeSpaces(decodePDFString(tokens[0].Value, nil))
+}
+
+// Links returns the uri actions of the link annotations
+func (d *PDFDocument) Links() []string {
+	links := []string{}
+	for _, number := range d.sortedNumbers() {
+		dict := d.Objects[number].Dict
+		for _, loc := range pdfURI.FindAllStringIndex(dict, -1) {
+			tokens := tokenizePDF([]byte(dict[loc[1]-1:]))
+			if len(tokens) > 0 && tokens[0].Kind == pdfString {
+				links = append(links, strings.TrimSpace(tokens[0].Value))
+			}
+		}
+	}
+	return links
+}
+
+// Stream decodes the stream of an object the first time it is read, the inflated bytes are taken from the
+// budget of the document
+func (d *PDFDocument) Stream(object *pdfObject) []byte {
+	if !object.Decoded {
+		object.Stream = pdfDecodeStream(object.Dict, object.Raw, minInt(MaxPDFStreamSize, d.Budget))
+		object.Decoded = true
+		if pdfDictValue(object.Dict, "Filter") != "" {
+			d.Budget -= len(object.Stream)
+		}
+	}
+	return object.Stream
+}
+
+func pdfDecodeStream(dict string, stream []byte, limit int) []byte {
+	filter := pdfDictValue(dict, "Filter")
+	if filter == "" {
+		return stream
+	}
+	if strings.Trim(filter, "[] ") != "/FlateDecode" || limit <= 0 {
+		// Images and other encodings do not hold any text
+		return nil
+	}
+	reader, err := zlib.NewReader(bytes.NewReader(stream))
+	if err != nil {
+		return nil
+	}
+	defer reader.Close()
+	decoded, err := ioutil.ReadAll(io.LimitReader(reader, int64(limit)))
+	if err != nil && len(decoded) == 0 {
+		return nil
+	}
+	return decoded
+}
+
+// pdfDictValue returns the raw value of a key of the top level dictionary
+func pdfDictValue(dict string, key string) string {
+	return pdfDictEntries(dict)[key]
+}
+
+// pdfDictEntries splits the top level dictionary of an object into its raw key values
+func pdfDictEntries(dict string) map[string]string {
+	entries := map[string]string{}
+	start := strings.Index(dict, "<<")
+	if start < 0 {
+		return entries
+	}
+	s := dict[start+2:]
+	i := 0
+	for i < len(s) {
+		for i < len(s) && isPDFWhitespace(s[i]) {
+			i++
+		}
+		if i >= len(s) || strings.HasPrefix(s[i:], ">>") || s[i] != '/' {
+			break
+		}
+		keyEnd := i + 1
+		for keyEnd < len(s) && !isPDFWhitespace(s[keyEnd]) && !isPDFDelimiter(s[keyEnd]) {
+			keyEnd++
+		}
+		key := s[i+1 : keyEnd]
+		valueEnd := pdfValueEnd(s, keyEnd)
+		entries[key] = strings.TrimSpace(s[keyEnd:valueEnd])
+		i = valueEnd
+	}
+	return entries
+}
+
+// pdfValueEnd finds the end of the value starting at the position, references span three tokens
+func pdfValueEnd(s string, i int) int {
+	for i < len(s) && isPDFWhitespace(s[i]) {
+		i++
+	}
+	if i >= len(s) {
+		return i
+	}
+	switch {
+	case strings.HasPrefix(s[i:], "<<"):
+		depth := 0
+		for j := i; j < len(s)-1; j++ {
+			if s[j] == '<' && s[j+1] == '<' {
+				depth++
+				j++
+			} else if s[j] == '>' && s[j+1] == '>' {
+				depth--
+				j++
+				if depth == 0 {
+					return j + 1
+				}
+			}
+		}
+		return len(s)
+	case s[i] == '[':
+		depth := 0
+		for j := i; j < len(s); j++ {
+			if s[j] == '(' {
+				j = pdfLiteralEnd(s, j) - 1
+			} else if s[j] == '[' {
+				depth++
+			} else if s[j] == ']' {
+				depth--
+				if depth == 0 {
+					return j + 1
+				}
+			}
+		}
+		return len(s)
+	case s[i] == '(':
+		return pdfLiteralEnd(s, i)
+	case s[i] == '<':
+		if end := strings.IndexByte(s[i:], '>'); end >= 0 {
+			return i + end + 1
+		}
+		return len(s)
+	}
+	j := i + 1
+	for j < len(s) && !isPDFWhitespace(s[j]) && !isPDFDelimiter(s[j]) {
+		j++
+	}
+	// An indirect reference is written as "number generation R"
+	rest := strings.Fields(s[j:minInt(len(s), j+24)])
+	if len(rest) >= 2 && rest[1] == "R" || len(rest) >= 2 && strings.HasPrefix(rest[1], "R/") {
+		if _, err := strconv.Atoi(rest[0]); err == nil {
+			return j + strings.Index(s[j:], "R") + 1
+		}
+	}
+	return j
+}
+
+func pdfLiteralEnd(s string, i int) int {
+	depth := 0
+	for j := i; j < len(s); j++ {
+		switch s[j] {
+		case '\\':
+			j++
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return j + 1
+			}
+		}
+	}
+	return len(s)
+}
+
+func isPDFWhitespace(c byte) bool {
+	return c == ' ' || c == '\n' || c == '\r' || c == '\t' || c == '\f' || c == 0
+}
+
+func isPDFDelimiter(c byte) bool {
+	return strings.IndexByte("()<>[]{}/%", c) >= 0
+}
+
+// tokenizePDF splits a content stream into operands and operators, the literal and hex strings are
+// returned as raw bytes
+func tokenizePDF(data []byte) []pdfToken {
+	tokens := []pdfToken{}
+	stack := [][]pdfToken{}
+	emit := func(token pdfToken) {
+		if len(stack) > 0 {
+			stack[len(stack)-1] = append(stack[len(stack)-1], token)
+		} else {
+			tokens = append(tokens, token)
+		}
+	}
+	s := string(data)
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case isPDFWhitespace(c):
+			i++
+		case c == '%':
+			for i < len(s) && s[i] != '\n' && s[i] != '\r' {
+				i++
+			}
+		case c == '(':
+			end := pdfLiteralEnd(s, i)
+			emit(pdfToken{Kind: pdfString, Value: unescapePDFLiteral(s[i+1 : maxInt(i+1, end-1)])})
+			i = end
+		case strings.HasPrefix(s[i:], "<<") || strings.HasPrefix(s[i:], ">>"):
+			i += 2
+		case c == '<':
+			end := strings.IndexByte(s[i:], '>')
+			if end < 0 {
+				end = len(s) - i
+			}
+			emit(pdfToken{Kind: pdfString, Value: decodePDFHex(s[i+1 : i+end])})
+			i += end + 1
+		case c == '[':
+			stack = append(stack, []pdfToken{})
+			i++
+		case c == ']':
+			if len(stack) > 0 {
+				items := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				emit(pdfToken{Kind: pdfArray, Items: items})
+			}
+			i++
+		case c == '/':
+			j := i + 1
+			for j < len(s) && !isPDFWhitespace(s[j]) && !isPDFDelimiter(s[j]) {
+				j++
+			}
+			emit(pdfToken{Kind: pdfName, Value: s[i+1 : j]})
+			i = j
+		case c == '{' || c == '}' || c == ')' || c == '>':
+			i++
+		default:
+			j := i
+			for j < len(s) && !isPDFWhitespace(s[j]) && !isPDFDelimiter(s[j]) {
+				j++
+			}
+			word := s[i:j]
+			i = j
+			if _, err := strconv.ParseFloat(word, 64); err == nil {
+				emit(pdfToken{Kind: pdfNumber, Value: word})
+				continue
+			}
+			if word == "BI" {
+				// Inline images are skipped until their end marker
+				if end := strings.Index(s[i:], "EI"); end >= 0 {
+					i += end + 2
+				} else {
+					i = len(s)
+				}
+				continue
+			}
+			emit(pdfToken{Kind: pdfOperator, Value: word})
+		}
+	}
+	return tokens
+}
+
+func unescapePDFLiteral(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 >= len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 't':
+			b.WriteByte('\t')
+		case 'b':
+			b.WriteByte('\b')
+		case 'f':
+			b.WriteByte('\f')
+		case '\r':
+			if i+1 < len(s) && s[i+1] == '\n' {
+				i++
+			}
+		case '\n':
+		default:
+			if s[i] >= '0' && s[i] <= '7' {
+				j := i
+				for j < len(s) && j < i+3 && s[j] >= '0' && s[j] <= '7' {
+					j++
+				}
+				value, _ := strconv.ParseUint(s[i:j], 8, 8)
+				b.WriteByte(byte(value))
+				i = j - 1
+			} else {
+				b.WriteByte(s[i])
+			}
+		}
+	}
+	return b.String()
+}
+
+func decodePDFHex(s string) string {
+	hex := strings.Map(func(r rune) rune {
+		if isPDFWhitespace(byte(r)) {
+			return -1
+		}
+		return r
+	}, s)
+	if len(hex)%2 == 1 {
+		hex += "0"
+	}
+	result := make([]byte, 0, len(hex)/2)
+	for i := 0; i+1 < len(hex); i += 2 {
+		value, err := strconv.ParseUint(hex[i:i+2], 16, 8)
+		if err != nil {
+			continue
+		}
+		result = append(result, byte(value))
+	}
+	return string(result)
+}
+
+// decodePDFString maps the character codes of a shown string into text with the font character map, the
+// strings without a map are either utf-16 with a byte order mark or a single byte encoding
+func decodePDFString(s string, cmap *pdfCMap) string {
+	if cmap != nil && len(cmap.Chars) > 0 {
+		var b strings.Builder
+		length := maxInt(cmap.CodeLength, 1)
+		for i := 0; i+length <= len(s); i += length {
+			if text, exists := cmap.Chars[s[i:i+length]]; exists {
+				b.WriteString(text)
+			}
+		}
+		return b.String()
+	}
+	if strings.HasPrefix(s, "\xfe\xff") {
+		return decodeUTF16BE(s[2:])
+	}
+	runes := make([]rune, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		runes = append(runes, rune(s[i]))
+	}
+	return string(runes)
+}
+
+func decodeUTF16BE(s string) string {
+	units := make([]uint16, 0, len(s)/2)
+	for i := 0; i+1 < len(s); i += 2 {
+		units = append(units, uint16(s[i])<<8|uint16(s[i+1]))
+	}
+	return string(utf16.Decode(units))
+}
+
+// parsePDFCMap reads the bfchar and bfrange mappings of a ToUnicode character map
+func parsePDFCMap(data []byte) *pdfCMap {
+	cmap := &pdfCMap{CodeLength: 1, Chars: map[string]string{}}
+	tokens := tokenizePDF(data)
+	for i := 0; i < len(tokens); i++ {
+		token := tokens[i]
+		if token.Kind != pdfOperator {
+			continue
+		}
+		switch token.Value {
+		case "begincodespacerange":
+			if i+1 < len(tokens) && tokens[i+1].Kind == pdfString {
+				cmap.CodeLength = maxInt(len(tokens[i+1].Value), 1)
+			}
+		case "beginbfchar":
+			for i+2 < len(tokens) && tokens[i+1].Kind == pdfString && tokens[i+2].Kind == pdfString {
+				cmap.Chars[tokens[i+1].Value] = decodeUTF16BE(tokens[i+2].Value)
+				i += 2
+			}
+		case "beginbfrange":
+			for i+3 < len(tokens) && tokens[i+1].Kind == pdfString && tokens[i+2].Kind == pdfString {
+				low, high := []byte(tokens[i+1].Value), []byte(tokens[i+2].Value)
+				destination := tokens[i+3]
+				for code, n := append([]byte{}, low...), 0; bytes.Compare(code, high) <= 0 && n < 65536; n++ {
+					if destination.Kind == pdfArray {
+						if n < len(destination.Items) {
+							cmap.Chars[string(code)] = decodeUTF16BE(destination.Items[n].Value)
+						}
+					} else {
+						target := append([]byte{}, destination.Value...)
+						if length := len(target); length >= 2 {
+							value := (uint16(target[length-2])<<8 | uint16(target[length-1])) + uint16(n)
+							target[length-2], target[length-1] = byte(value>>8), byte(value)
+						} else if length == 1 {
+							target[0] += byte(n)
+						}
+						cmap.Chars[string(code)] = decodeUTF16BE(string(target))
+					}
+					if !incrementCode(code) {
+						break
+					}
+				}
+				i += 3
+			}
+		}
+	}
+	return cmap
+}
+
+func incrementCode(code []byte) bool {
+	for i := len(code) - 1; i >= 0; i-- {
+		code[i]++
+		if code[i] != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func minInt(a int, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt(a int, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,199 @@
+package collector
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// buildPDF writes the objects numbered from 1 into a document, the parser does not need the cross reference table
+func buildPDF(objects ...string) []byte {
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.4\n")
+	for i, object := range objects {
+		fmt.Fprintf(&b, "%d 0 obj\n%s\nendobj\n", i+1, object)
+	}
+	b.WriteString("trailer\n<< /Root 1 0 R /Info 6 0 R >>\n%%EOF\n")
+	return b.Bytes()
+}
+
+func streamObject(dict string, stream []byte) string {
+	return fmt.Sprintf("<< %s /Length %d >>\nstream\n%s\nendstream", dict, len(stream), stream)
+}
+
+func flate(data string) []byte {
+	var b bytes.Buffer
+	writer := zlib.NewWriter(&b)
+	writer.Write([]byte(data))
+	writer.Close()
+	return b.Bytes()
+}
+
+const pdfContent = "BT /F1 12 Tf 72 720 Td (Hello sandbox) Tj 0 -14 Td (second line.) Tj ET"
+
+func simplePDF(content string) []byte {
+	return buildPDF(
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /Contents 4 0 R /Annots [5 0 R] >>",
+		content,
+		"<< /Type /Annot /Subtype /Link /A << /S /URI /URI (/linked.html) >> >>",
+		"<< /Title (Test document) /Subject (About the tests) >>",
+	)
+}
+
+func TestParsePDF(t *testing.T) {
+	for name, content := range map[string]string{
+		"plain": streamObject("", []byte(pdfContent)),
+		"flate": streamObject("/Filter /FlateDecode", flate(pdfContent)),
+	} {
+		got, err := HandlePDF("http://example.com/docs/test.pdf", simplePDF(content))
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+		want := &Content{
+			Title:       "Test document",
+			Description: "About the tests",
+			Paragraphs:  []string{"Hello sandbox second line."},
+			Urls:        []string{"http://example.com/linked.html"},
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s:\n got: %+v\nwant: %+v", name, got, want)
+		}
+	}
+}
+
+func TestParsePDFObjectStream(t *testing.T) {
+	// The page 5 is only held by the object stream 3
+	header := "5 0 "
+	data := buildPDF(
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [5 0 R] /Count 1 >>",
+		streamObject(fmt.Sprintf("/Type /ObjStm /N 1 /First %d", len(header)),
+			[]byte(header+"<< /Type /Page /Parent 2 0 R /Contents 4 0 R >>")),
+		streamObject("", []byte(pdfContent)),
+	)
+	doc, err := ParsePDF(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := doc.Paragraphs(), []string{"Hello sandbox second line."}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestParsePDFRejectsInvalidDocuments(t *testing.T) {
+	for name, data := range map[string][]byte{
+		"no header":  []byte("<html></html>"),
+		"no objects": []byte("%PDF-1.4\n%%EOF\n"),
+		"encrypted":  []byte("%PDF-1.4\n1 0 obj\n<< /Type /Catalog >>\nendobj\ntrailer << /Encrypt 2 0 R >>\n"),
+	} {
+		if _, err := ParsePDF(data); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+		if _, err := HandlePDF("http://example.com/test.pdf", data); err == nil {
+			t.Errorf("%s: expected the handler to fail", name)
+		}
+	}
+}
+
+// The malformed lengths and offsets are ignored rather than sliced with
+func TestParsePDFMalformedDocuments(t *testing.T) {
+	objectStream := func(first string, header string) []byte {
+		return buildPDF(
+			"<< /Type /Catalog /Pages 2 0 R >>",
+			"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+			fmt.Sprintf("<< /Type /ObjStm /N 2 /First %s /Length 60 >>\nstream\n%s<< /Type /Page >> << /Type /Page >>\nendstream", first, header),
+		)
+	}
+	for name, data := range map[string][]byte{
+		"negative length":        simplePDF("<< /Length -5 >>\nstream\n" + pdfContent + "\nendstream"),
+		"length past the stream": simplePDF("<< /Length 100000 >>\nstream\n" + pdfContent + "\nendstream"),
+		"negative first":         objectStream("-3", "3 0 4 17 "),
+		"first past the stream":  objectStream("100000", "3 0 4 17 "),
+		"negative offset":        objectStream("9", "3 -40 4 17 "),
+		"negative end":           objectStream("9", "3 0 4 -17 "),
+		"offset past the end":    objectStream("9", "3 17 4 0 "),
+		"truncated":              simplePDF(streamObject("", []byte(pdfContent)))[:120],
+		"bad flate":              simplePDF(streamObject("/Filter /FlateDecode", []byte("not compressed"))),
+		"unterminated strings":   simplePDF(streamObject("", []byte("BT (open <4142 [(a) Tj"))),
+	} {
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					t.Errorf("%s: parser panicked: %v", name, r)
+				}
+			}()
+			doc, err := ParsePDF(data)
+			if err != nil {
+				return
+			}
+			doc.Title()
+			doc.Paragraphs()
+			doc.Links()
+		}()
+	}
+}
+
+func TestScrapeFailsMalformedPDF(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Write([]byte("%PDF-1.4\nnot a document\n"))
+	}))
+	defer server.Close()
+	discard := log.New(ioutil.Discard, "", 0)
+	scrapper := NewScrapper(&Loggers{Info: discard, Warning: discard, Error: discard})
+	scrapper.Timeout = 5 * time.Second
+	channel := make(chan ScrapeResult, 1)
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+	scrapper.Scrape(server.URL+"/broken.pdf", channel, wg)
+	if result := <-channel; result.Error == nil {
+		t.Fatal("expected the scrape to fail")
+	}
+	if _, failed := scrapper.Failed[server.URL+"/broken.pdf"]; !failed {
+		t.Fatal("expected a failed page")
+	}
+}
+
+func TestParsePDFBoundsInflatedStreams(t *testing.T) {
+	bomb := flate(strings.Repeat("0", 8*MaxPDFStreamSize))
+	image := streamObject("/Subtype /Image /Filter /FlateDecode", bomb)
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /Contents [4 0 R 5 0 R 6 0 R 7 0 R 8 0 R 9 0 R] /Resources << /XObject << /Im1 10 0 R >> >> >>",
+	}
+	for i := 0; i < 6; i++ {
+		objects = append(objects, streamObject("/Filter /FlateDecode", bomb))
+	}
+	objects = append(objects, image)
+	doc, err := ParsePDF(buildPDF(objects...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc.Paragraphs()
+	decoded := 0
+	for number := 4; number <= 9; number++ {
+		if size := len(doc.Objects[number].Stream); size > MaxPDFStreamSize {
+			t.Fatalf("stream %d inflated into %d bytes", number, size)
+		} else {
+			decoded += size
+		}
+	}
+	if decoded > MaxPDFDecodedSize {
+		t.Fatalf("document inflated into %d bytes", decoded)
+	}
+	if doc.Objects[10].Decoded {
+		t.Fatal("the image stream is decoded")
+	}
+}
@@ -3,7 +3,12 @@ package collector
 import (
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"net/http/httptrace"
+	neturl "net/url"
+	"strings"
+	"sync/atomic"
 	"time"
 )
 
@@ -18,21 +23,60 @@ type Requester interface {
 	Request(url string, method string) (*http.Response, error)
 }
 
+const (
+	MaxRedirects = 10
+)
+
+// StatusError is returned for the responses with a status code other than 200
+type StatusError struct {
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("status code: %d\n", e.StatusCode)
+}
+
+// RedirectError is returned when the redirects loop or exceed MaxRedirects, the chain holds the urls in
+// the order they are visited
+type RedirectError struct {
+	Chain []string
+	Loop  bool
+}
+
+func (e *RedirectError) Error() string {
+	if e.Loop {
+		return fmt.Sprintf("redirect loop: %s\n", strings.Join(e.Chain, " -> "))
+	}
+	return fmt.Sprintf("stopped after %d redirects: %s\n", MaxRedirects, strings.Join(e.Chain, " -> "))
+}
+
 type Request struct {
-	UserAgent  string
-	Client     *http.Client
-	Timeout    time.Duration
+	UserAgent string
+	Client    *http.Client
+	Timeout   time.Duration
+	Transport *Transport
 }
 
+// NewRequest creates a requester over the shared transport
 func NewRequest(timeout time.Duration) *Request {
+	return NewRequestWithTransport(timeout, SharedTransport())
+}
+
+func NewRequestWithTransport(timeout time.Duration, transport *Transport) *Request {
+	client := &http.Client{Timeout: timeout, CheckRedirect: CheckRedirect, Transport: transport.RoundTripper()}
+	// Cookies are sent only by the crawls which keep a session
+	if transport.Jar != nil {
+		client.Jar = transport.Jar
+	}
 	return &Request{
 		UserAgent: userAgent,
-		Client:    &http.Client{Timeout: timeout},
+		Client:    client,
 		Timeout:   timeout,
+		Transport: transport,
 	}
 }
 
-func (r *Request) HeadRequest(url string) (*http.Response, error)  {
+func (r *Request) HeadRequest(url string) (*http.Response, error) {
 	return r.Request(url, "HEAD")
 }
 
@@ -41,18 +85,107 @@ func (r *Request) GetRequest(url string) (*http.Response, error) {
 }
 
 func (r *Request) Request(url string, method string) (*http.Response, error) {
-	request, err := http.NewRequest(method, url, nil)
+	var session *LoginSession
+	generation := 0
+	if r.Transport != nil && r.Transport.Session != nil {
+		session = r.Transport.Session
+		generation = session.CurrentGeneration()
+	}
+	response, err := r.send(method, url, nil)
+	if err != nil || session == nil {
+		return response, err
+	}
+	if !session.Expired(response) {
+		session.Succeeded()
+		return response, nil
+	}
+	// The session expired, the request is sent once more after logging in again
+	response.Body.Close()
+	if err := session.Renew(r, generation); err != nil {
+		return nil, errors.New(fmt.Sprintf("session could not be renewed: %s\n", err.Error()))
+	}
+	response, err = r.send(method, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if session.Expired(response) {
+		response.Body.Close()
+		return nil, errors.New(fmt.Sprintf("session expired right after logging in: %s\n", url))
+	}
+	session.Succeeded()
+	return response, nil
+}
+
+// send sends the request with the form values as the query of the get requests and as the body of the others
+func (r *Request) send(method string, url string, values neturl.Values) (*http.Response, error) {
+	var body io.Reader
+	if values != nil && method != http.MethodGet {
+		body = strings.NewReader(values.Encode())
+	}
+	request, err := http.NewRequest(method, url, body)
 	if err != nil {
 		return nil, errors.New(fmt.Sprintf("new http request failed: %s\n", err.Error()))
 	}
+	if values != nil {
+		if method == http.MethodGet {
+			request.URL.RawQuery = values.Encode()
+		} else {
+			request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+	}
 	request.Header.Set("User-Agent", r.UserAgent)
+	if r.Transport != nil {
+		stats := r.Transport.Stats
+		atomic.AddInt64(&stats.Requests, 1)
+		request = request.WithContext(httptrace.WithClientTrace(request.Context(), &httptrace.ClientTrace{
+			GotConn: func(info httptrace.GotConnInfo) {
+				if info.Reused {
+					atomic.AddInt64(&stats.ReusedConnections, 1)
+				} else {
+					atomic.AddInt64(&stats.NewConnections, 1)
+				}
+			},
+		}))
+	}
 
 	response, err := r.Client.Do(request)
 	if err != nil {
+		var redirectError *RedirectError
+		if errors.As(err, &redirectError) {
+			return nil, redirectError
+		}
 		return nil, errors.New(fmt.Sprintf("http request failed: %s\n", err.Error()))
 	}
 	if response.StatusCode != 200 {
-		return nil, errors.New(fmt.Sprintf("status code: %d\n", response.StatusCode))
+		response.Body.Close()
+		return nil, &StatusError{StatusCode: response.StatusCode}
 	}
 	return response, nil
 }
+
+// CheckRedirect stops following the redirects on loops and after MaxRedirects redirects
+func CheckRedirect(request *http.Request, via []*http.Request) error {
+	chain := make([]string, 0, len(via)+1)
+	for _, previous := range via {
+		chain = append(chain, previous.URL.String())
+	}
+	chain = append(chain, request.URL.String())
+	for _, previous := range via {
+		if previous.URL.String() == request.URL.String() {
+			return &RedirectError{Chain: chain, Loop: true}
+		}
+	}
+	if len(via) >= MaxRedirects {
+		return &RedirectError{Chain: chain}
+	}
+	return nil
+}
+
+// RedirectChain returns the urls redirected until the response, starting with the requested url
+func RedirectChain(response *http.Response) []string {
+	chain := []string{}
+	for r := response.Request.Response; r != nil; r = r.Request.Response {
+		chain = append([]string{r.Request.URL.String()}, chain...)
+	}
+	return chain
+}
@@ -0,0 +1,107 @@
+package collector
+
+import (
+	"github.com/PuerkitoBio/goquery"
+	"net/http"
+	"strings"
+)
+
+var (
+	// Directives which carry a value after the colon, anything else before a colon is a user agent name
+	valuedRobotsDirectives = map[string]bool{
+		"unavailable_after": true,
+		"max-snippet":       true,
+		"max-image-preview": true,
+		"max-video-preview": true,
+	}
+	// Link relations telling crawlers not to follow the link
+	noFollowRelations = map[string]bool{
+		"nofollow":  true,
+		"ugc":       true,
+		"sponsored": true,
+	}
+)
+
+type RobotsDirectives struct {
+	NoIndex    bool     `json:"noindex,omitempty"`
+	NoFollow   bool     `json:"nofollow,omitempty"`
+	Directives []string `json:"directives,omitempty"`
+}
+
+// ParseRobotsMeta reads the robots directives of the meta tags, both the generic robots and the ones
+// naming the given bots are taken into account
+func ParseRobotsMeta(doc *goquery.Document, bots ...string) *RobotsDirectives {
+	names := map[string]bool{"robots": true}
+	for _, bot := range bots {
+		names[strings.ToLower(bot)] = true
+	}
+	directives := &RobotsDirectives{}
+	doc.Find("meta[name]").Each(func(i int, s *goquery.Selection) {
+		if !names[strings.ToLower(strings.TrimSpace(s.AttrOr("name", "")))] {
+			return
+		}
+		directives.Add(s.AttrOr("content", ""))
+	})
+	return directives
+}
+
+// ParseRobotsHeader reads the X-Robots-Tag headers, the headers addressing a specific user agent are ignored
+func ParseRobotsHeader(header http.Header) *RobotsDirectives {
+	directives := &RobotsDirectives{}
+	for _, value := range header.Values("X-Robots-Tag") {
+		if colon := strings.Index(value, ":"); colon > 0 {
+			prefix := strings.ToLower(strings.TrimSpace(value[:colon]))
+			if !valuedRobotsDirectives[prefix] && !strings.ContainsAny(prefix, ", ") {
+				continue
+			}
+		}
+		directives.Add(value)
+	}
+	return directives
+}
+
+// Add parses a comma separated directives list
+func (r *RobotsDirectives) Add(value string) {
+	for _, directive := range strings.Split(value, ",") {
+		directive = strings.ToLower(strings.TrimSpace(directive))
+		if directive == "" {
+			continue
+		}
+		switch directive {
+		case "noindex":
+			r.NoIndex = true
+		case "nofollow":
+			r.NoFollow = true
+		case "none":
+			r.NoIndex = true
+			r.NoFollow = true
+		}
+		if !URLExists(r.Directives, directive) {
+			r.Directives = append(r.Directives, directive)
+		}
+	}
+}
+
+// Merge combines the directives of other sources such as the header and the meta tags
+func (r *RobotsDirectives) Merge(other *RobotsDirectives) *RobotsDirectives {
+	if other == nil {
+		return r
+	}
+	r.Add(strings.Join(other.Directives, ","))
+	return r
+}
+
+// IsEmpty is true when no directive is found, such pages are stored without the robots record
+func (r *RobotsDirectives) IsEmpty() bool {
+	return len(r.Directives) == 0
+}
+
+// IsNoFollowLink checks the rel attribute of a link for nofollow, ugc and sponsored relations
+func IsNoFollowLink(s *goquery.Selection) bool {
+	for _, rel := range strings.Fields(strings.ToLower(s.AttrOr("rel", ""))) {
+		if noFollowRelations[rel] {
+			return true
+		}
+	}
+	return false
+}
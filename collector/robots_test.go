@@ -0,0 +1,124 @@
+package collector
+
+import (
+	"io/ioutil"
+	"log"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func discardLoggers() *Loggers {
+	discard := log.New(ioutil.Discard, "", 0)
+	return &Loggers{Info: discard, Warning: discard, Error: discard}
+}
+
+func TestParseRobotsMeta(t *testing.T) {
+	doc := parseDocument(t, `<html><head>
+<meta name="robots" content="NoIndex, max-snippet:20">
+<meta name="Googlebot" content="nofollow">
+<meta name="otherbot" content="none">
+<meta name="description" content="noindex">
+</head></html>`)
+	robots := ParseRobotsMeta(doc)
+	if !robots.NoIndex || robots.NoFollow {
+		t.Errorf("generic robots: got %+v", robots)
+	}
+	robots = ParseRobotsMeta(doc, "googlebot")
+	if !robots.NoIndex || !robots.NoFollow {
+		t.Errorf("robots of the bot: got %+v", robots)
+	}
+	if want := []string{"noindex", "max-snippet:20", "nofollow"}; !reflect.DeepEqual(robots.Directives, want) {
+		t.Errorf("got directives %q, want %q", robots.Directives, want)
+	}
+}
+
+func TestParseRobotsHeader(t *testing.T) {
+	header := http.Header{}
+	header.Add("X-Robots-Tag", "noindex")
+	header.Add("X-Robots-Tag", "unavailable_after: 25 Jun 2030 15:00:00 PST")
+	// Directives addressing a specific user agent are ignored
+	header.Add("X-Robots-Tag", "otherbot: nofollow")
+	robots := ParseRobotsHeader(header)
+	if !robots.NoIndex || robots.NoFollow {
+		t.Errorf("got %+v", robots)
+	}
+	if want := []string{"noindex", "unavailable_after: 25 jun 2030 15:00:00 pst"}; !reflect.DeepEqual(robots.Directives, want) {
+		t.Errorf("got directives %q, want %q", robots.Directives, want)
+	}
+	if !ParseRobotsHeader(http.Header{}).IsEmpty() {
+		t.Errorf("directives are found without the header")
+	}
+}
+
+func TestRobotsDirectivesMerge(t *testing.T) {
+	header := http.Header{"X-Robots-Tag": {"noindex, nofollow"}}
+	doc := parseDocument(t, `<html><head><meta name="robots" content="nofollow, noarchive"></head></html>`)
+	robots := ParseRobotsHeader(header).Merge(ParseRobotsMeta(doc)).Merge(nil)
+	if want := []string{"noindex", "nofollow", "noarchive"}; !reflect.DeepEqual(robots.Directives, want) {
+		t.Errorf("got directives %q, want %q", robots.Directives, want)
+	}
+}
+
+func TestScrapeHTMLKeepsTheNoFollowLinksApart(t *testing.T) {
+	page := `<html><body>
+<a href="/followed">followed</a>
+<a href="/nofollow" rel="nofollow">nofollow</a>
+<a href="/sponsored" rel="external Sponsored">sponsored</a>
+<a href="/ugc" rel="ugc">ugc</a>
+<a href="/both">followed once</a><a href="/both" rel="nofollow">nofollow once</a>
+</body></html>`
+	s := NewScrapper(discardLoggers())
+	scraped, err := s.ScrapeHTML("https://example.com/", strings.NewReader(page), http.Header{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if scraped.Robots != nil {
+		t.Errorf("got robots %+v on a page without directives", scraped.Robots)
+	}
+	want := []string{"https://example.com/nofollow", "https://example.com/sponsored", "https://example.com/ugc"}
+	if !reflect.DeepEqual(scraped.NoFollowUrls, want) {
+		t.Errorf("got nofollow urls %q, want %q", scraped.NoFollowUrls, want)
+	}
+	// A url linked once without nofollow is followed
+	if want := []string{"https://example.com/followed", "https://example.com/both"}; !reflect.DeepEqual(scraped.FollowableUrls(), want) {
+		t.Errorf("got followable urls %q, want %q", scraped.FollowableUrls(), want)
+	}
+}
+
+func TestScrapeHTMLRecordsTheRobotsDirectives(t *testing.T) {
+	page := `<html><head><meta name="robots" content="nofollow"></head><body><a href="/a">a</a></body></html>`
+	s := NewScrapper(discardLoggers())
+	scraped, err := s.ScrapeHTML("https://example.com/", strings.NewReader(page), http.Header{"X-Robots-Tag": {"noindex"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if scraped.Robots == nil || !scraped.Robots.NoIndex || !scraped.Robots.NoFollow {
+		t.Fatalf("got robots %+v", scraped.Robots)
+	}
+	if urls := scraped.FollowableUrls(); len(urls) != 0 {
+		t.Errorf("links of a nofollow page are followable: %q", urls)
+	}
+}
+
+func TestCollectorFollowsTheLinksAllowedByTheRobots(t *testing.T) {
+	loggers := discardLoggers()
+	c := &Collector{Scrapper: NewScrapper(loggers), Loggers: loggers, RespectRobots: true}
+	page := &SucceededPage{
+		Url:          "https://example.com/",
+		Urls:         []string{"https://example.com/a", "https://example.com/b"},
+		NoFollowUrls: []string{"https://example.com/b"},
+	}
+	if got := c.LinksToFollow(page); !reflect.DeepEqual(got, []string{"https://example.com/a"}) {
+		t.Errorf("got %q", got)
+	}
+	page.Robots = &RobotsDirectives{NoFollow: true, Directives: []string{"nofollow"}}
+	if got := c.LinksToFollow(page); len(got) != 0 {
+		t.Errorf("links of a nofollow page are followed: %q", got)
+	}
+	c.RespectRobots = false
+	if got := c.LinksToFollow(page); !reflect.DeepEqual(got, page.Urls) {
+		t.Errorf("got %q without respecting the robots", got)
+	}
+}
@@ -4,6 +4,9 @@ import (
 	"errors"
 	"fmt"
 	"github.com/PuerkitoBio/goquery"
+	"io"
+	"io/ioutil"
+	"net/http"
 	"runtime"
 	"strconv"
 	"strings"
@@ -12,20 +15,94 @@ import (
 )
 
 type SucceededPage struct {
-	Url           string   `json:"url"`
-	Title         string   `json:"title"`
-	Description   string   `json:"description"`
-	ContentType   string   `json:"content_type"`
-	ContentLength int64    `json:"content_length"`
-	Timestamp     int64    `json:"timestamp"`
-	Urls          []string `json:"urls"`
-	Paragrahps    []string `json:"paragrahps"`
+	Url           string                 `json:"url"`
+	Title         string                 `json:"title"`
+	Description   string                 `json:"description"`
+	ContentType   string                 `json:"content_type"`
+	ContentLength int64                  `json:"content_length"`
+	StatusCode    int                    `json:"status_code,omitempty"`
+	Timestamp     int64                  `json:"timestamp"`
+	LastModified  int64                  `json:"last_modified,omitempty"`
+	Urls          []string               `json:"urls"`
+	Paragrahps    []string               `json:"paragrahps"`
+	MainText      string                 `json:"main_text,omitempty"`
+	Language      string                 `json:"language,omitempty"`
+	LanguageFrom  string                 `json:"language_from,omitempty"`
+	Metadata      *PageMetadata          `json:"metadata,omitempty"`
+	Fields        map[string]interface{} `json:"fields,omitempty"`
+	Robots        *RobotsDirectives      `json:"robots,omitempty"`
+	NoFollowUrls  []string               `json:"nofollow_urls,omitempty"`
+	Links         []*PageLink            `json:"links,omitempty"`
+	Assets        []*PageAsset           `json:"assets,omitempty"`
+	Weight        *PageWeight            `json:"weight,omitempty"`
+	FinalUrl      string                 `json:"final_url,omitempty"`
+	Redirects     []string               `json:"redirects,omitempty"`
+	ResponseTime  int64                  `json:"response_time_ms,omitempty"`
+	Fingerprint   string                 `json:"fingerprint,omitempty"`
+	DuplicateOf   string                 `json:"duplicate_of,omitempty"`
+}
+
+// PageLink is a link of a page with its anchor text
+type PageLink struct {
+	Url      string `json:"url"`
+	Text     string `json:"text"`
+	NoFollow bool   `json:"nofollow,omitempty"`
+	Source   string `json:"source,omitempty"`
+}
+
+// FollowableUrls returns the urls of the page which are allowed to be followed by the robots directives
+func (p *SucceededPage) FollowableUrls() []string {
+	if p.Robots != nil && p.Robots.NoFollow {
+		return []string{}
+	}
+	if len(p.NoFollowUrls) == 0 {
+		return p.Urls
+	}
+	urls := make([]string, 0, len(p.Urls))
+	for _, u := range p.Urls {
+		if !URLExists(p.NoFollowUrls, u) {
+			urls = append(urls, u)
+		}
+	}
+	return urls
+}
+
+// SetResponse records the status code, the redirects, the last modification time and the response time of the page
+func (p *SucceededPage) SetResponse(response *http.Response, elapsed time.Duration) {
+	p.StatusCode = response.StatusCode
+	if modified := ParseDate(response.Header.Get("Last-Modified")); modified > 0 {
+		p.LastModified = modified
+	}
+	if chain := RedirectChain(response); len(chain) > 0 {
+		p.Redirects = chain
+		p.FinalUrl = response.Request.URL.String()
+	}
+	p.ResponseTime = elapsed.Milliseconds()
 }
 
 type FailedPage struct {
-	Url        string `json:"url"`
-	FailReason string `json:"fail_reason"`
-	Timestamp  int64  `json:"timestamp"`
+	Url          string   `json:"url"`
+	FailReason   string   `json:"fail_reason"`
+	Timestamp    int64    `json:"timestamp"`
+	StatusCode   int      `json:"status_code,omitempty"`
+	Redirects    []string `json:"redirects,omitempty"`
+	RedirectLoop bool     `json:"redirect_loop,omitempty"`
+}
+
+// NewFailedPage records the failure of a page, the status code, the redirects and whether they loop are
+// kept apart when the error carries them
+func NewFailedPage(url string, err error) *FailedPage {
+	page := &FailedPage{Url: url, FailReason: err.Error(), Timestamp: CurrentTimestamp()}
+	var statusError *StatusError
+	if errors.As(err, &statusError) {
+		page.StatusCode = statusError.StatusCode
+	}
+	var redirectError *RedirectError
+	if errors.As(err, &redirectError) {
+		page.Redirects = redirectError.Chain
+		page.RedirectLoop = redirectError.Loop
+	}
+	return page
 }
 
 type ScrapeResult struct {
@@ -44,6 +121,7 @@ type ScraperInterface interface {
 	NumberOfPagesSucceed() int
 	NumberOfPagesFailed() int
 	NumberOfPagesBeingProcessed() int
+	TakeResult(url string) (*SucceededPage, *FailedPage)
 	Scrape(url string, channel chan ScrapeResult, wg *sync.WaitGroup)
 }
 
@@ -53,15 +131,42 @@ type Scrapper struct {
 	InProcess map[string]int
 	Loggers   *Loggers
 	Mutex     sync.Mutex
+	// Main content extraction is disabled when the extractor is nil
+	ContentExtractor  *ContentExtractor
+	MetadataExtractor *MetadataExtractor
+	// Links are found in the anchors only when the extractor is nil
+	LinkExtractor *LinkExtractor
+	// Pages are left without a language when the detector is nil
+	LanguageDetector *LanguageDetector
+	// Custom fields are extracted only when the rules are set
+	FieldExtractor *FieldExtractor
+	// Handlers of the non html documents keyed by their media type
+	ContentHandlers map[string]ContentHandler
+	// Assets of the html pages are inventoried only when the extractor is set
+	AssetExtractor *AssetExtractor
+	// Near duplicate pages are detected only when the deduplicator is set
+	Deduplicator *Deduplicator
+	// Pages are kept on the disk instead of the Succeed and Failed maps when the store is set
+	Store *DiskPageStore
+	// Requests share the transport so that the connections are reused
+	Transport *Transport
+	Timeout   time.Duration
 }
 
 func NewScrapper(loggers *Loggers) *Scrapper {
+	linkExtractor, _ := NewLinkExtractor()
 	return &Scrapper{
-		Succeed:   map[string]*SucceededPage{},
-		Failed:    map[string]*FailedPage{},
-		InProcess: map[string]int{},
-		Loggers:   loggers,
-		Mutex:     sync.Mutex{},
+		Succeed:           map[string]*SucceededPage{},
+		Failed:            map[string]*FailedPage{},
+		InProcess:         map[string]int{},
+		Loggers:           loggers,
+		Mutex:             sync.Mutex{},
+		MetadataExtractor: NewMetadataExtractor(),
+		LinkExtractor:     linkExtractor,
+		LanguageDetector:  NewLanguageDetector(),
+		ContentHandlers:   DefaultContentHandlers(),
+		Transport:         SharedTransport(),
+		Timeout:           DefaultRequestTimeout,
 	}
 }
 
@@ -79,20 +184,38 @@ func (s *Scrapper) InitiateScrape(url string) {
 	s.Mutex.Unlock()
 }
 
+// ScrapeSucceed records the page of the url, the store does its own locking so the page is written to the disk
+// without holding the mutex of the scrapper
 func (s *Scrapper) ScrapeSucceed(url string, page *SucceededPage) {
+	if s.Store != nil {
+		if err := s.Store.PutSucceeded(url, page); err != nil {
+			s.Loggers.Log(ERROR, fmt.Sprintf("Page could not be stored: %s Reason: %s\n", url, err.Error()))
+		}
+	}
 	s.Mutex.Lock()
-	s.Succeed[url] = page
+	if s.Store == nil {
+		s.Succeed[url] = page
+		// A page retried after failing is not failed anymore
+		delete(s.Failed, url)
+	}
 	delete(s.InProcess, url)
-	s.Loggers.Log(INFO, fmt.Sprintf("Scrape succeded on page :%s\n", page.Url))
 	s.Mutex.Unlock()
+	s.Loggers.Log(INFO, fmt.Sprintf("Scrape succeded on page :%s\n", page.Url))
 }
 
 func (s *Scrapper) ScrapeFailed(url string, page *FailedPage) {
+	if s.Store != nil {
+		if err := s.Store.PutFailed(url, page); err != nil {
+			s.Loggers.Log(ERROR, fmt.Sprintf("Page could not be stored: %s Reason: %s\n", url, err.Error()))
+		}
+	}
 	s.Mutex.Lock()
-	s.Failed[url] = page
+	if s.Store == nil {
+		s.Failed[url] = page
+	}
 	delete(s.InProcess, url)
-	s.Loggers.Log(INFO, fmt.Sprintf("Scrape failed on page: %s Reason: %s\n", page.Url, page.FailReason))
 	s.Mutex.Unlock()
+	s.Loggers.Log(INFO, fmt.Sprintf("Scrape failed on page: %s Reason: %s\n", page.Url, page.FailReason))
 }
 
 func (s *Scrapper) IsProcessed(url string) bool {
@@ -106,9 +229,12 @@ func (s *Scrapper) IsProcessed(url string) bool {
 }
 
 func (s *Scrapper) IsVisited(url string) bool {
+	if s.Store != nil {
+		return s.Store.IsSucceeded(url)
+	}
 	s.Mutex.Lock()
-	_, ok := s.Succeed[url]
 	defer s.Mutex.Unlock()
+	_, ok := s.Succeed[url]
 	if ok {
 		return true
 	}
@@ -116,9 +242,12 @@ func (s *Scrapper) IsVisited(url string) bool {
 }
 
 func (s *Scrapper) IsFailed(url string) bool {
+	if s.Store != nil {
+		return s.Store.IsFailed(url)
+	}
 	s.Mutex.Lock()
-	_, ok := s.Failed[url]
 	defer s.Mutex.Unlock()
+	_, ok := s.Failed[url]
 	if ok {
 		return true
 	}
@@ -126,12 +255,18 @@ func (s *Scrapper) IsFailed(url string) bool {
 }
 
 func (s *Scrapper) NumberOfPagesSucceed() int {
+	if s.Store != nil {
+		return s.Store.NumberOfSucceeded()
+	}
 	s.Mutex.Lock()
 	defer s.Mutex.Unlock()
 	return len(s.Succeed)
 }
 
 func (s *Scrapper) NumberOfPagesFailed() int {
+	if s.Store != nil {
+		return s.Store.NumberOfFailed()
+	}
 	s.Mutex.Lock()
 	defer s.Mutex.Unlock()
 	return len(s.Failed)
@@ -143,6 +278,61 @@ func (s *Scrapper) NumberOfPagesBeingProcessed() int {
 	return len(s.InProcess)
 }
 
+// TakeResult returns the scraped page of the url and forgets it, so that it can be scraped again
+func (s *Scrapper) TakeResult(url string) (*SucceededPage, *FailedPage) {
+	if s.Store != nil {
+		succeeded, failed, err := s.Store.Take(url)
+		if err != nil {
+			s.Loggers.Log(ERROR, fmt.Sprintf("Page could not be taken from the store: %s Reason: %s\n", url, err.Error()))
+		}
+		return succeeded, failed
+	}
+	s.Mutex.Lock()
+	defer s.Mutex.Unlock()
+	succeeded, failed := s.Succeed[url], s.Failed[url]
+	delete(s.Succeed, url)
+	delete(s.Failed, url)
+	return succeeded, failed
+}
+
+// EachSucceeded calls the function for every succeeded page, from the store if the pages are kept on the disk
+func (s *Scrapper) EachSucceeded(fn func(url string, page *SucceededPage) error) error {
+	if s.Store != nil {
+		return s.Store.EachSucceeded(fn)
+	}
+	s.Mutex.Lock()
+	pages := make(map[string]*SucceededPage, len(s.Succeed))
+	for url, page := range s.Succeed {
+		pages[url] = page
+	}
+	s.Mutex.Unlock()
+	for url, page := range pages {
+		if err := fn(url, page); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// EachFailed calls the function for every failed page, from the store if the pages are kept on the disk
+func (s *Scrapper) EachFailed(fn func(url string, page *FailedPage) error) error {
+	if s.Store != nil {
+		return s.Store.EachFailed(fn)
+	}
+	s.Mutex.Lock()
+	pages := make(map[string]*FailedPage, len(s.Failed))
+	for url, page := range s.Failed {
+		pages[url] = page
+	}
+	s.Mutex.Unlock()
+	for url, page := range pages {
+		if err := fn(url, page); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (s *Scrapper) Scrape(url string, channel chan ScrapeResult, wg *sync.WaitGroup) {
 	defer wg.Done()
 
@@ -163,11 +353,12 @@ func (s *Scrapper) Scrape(url string, channel chan ScrapeResult, wg *sync.WaitGr
 
 	s.InitiateScrape(url)
 
-	requester := NewRequest(30 * time.Second)
+	requester := NewRequestWithTransport(s.Timeout, s.Transport)
 
+	begin := time.Now()
 	headResponse, headError := requester.HeadRequest(url)
 	if headError != nil {
-		s.ScrapeFailed(url, &FailedPage{Url: url, FailReason: headError.Error(), Timestamp: CurrentTimestamp()})
+		s.ScrapeFailed(url, NewFailedPage(url, headError))
 		channel <- ScrapeResult{Page: nil, Error: headError}
 		return
 	}
@@ -176,7 +367,10 @@ func (s *Scrapper) Scrape(url string, channel chan ScrapeResult, wg *sync.WaitGr
 	contentLength := headResponse.ContentLength
 	contentType := strings.ToLower(headResponse.Header.Get("Content-Type"))
 
-	if !strings.Contains(contentType, "text/html") {
+	handler, handled := s.ContentHandlers[MediaType(contentType, url)]
+	isHTML := strings.Contains(contentType, "text/html")
+
+	if !isHTML && !handled {
 		page := &SucceededPage{
 			Url:           url,
 			Title:         "",
@@ -187,29 +381,93 @@ func (s *Scrapper) Scrape(url string, channel chan ScrapeResult, wg *sync.WaitGr
 			Urls:          []string{},
 			Paragrahps:    []string{},
 		}
+		if robots := ParseRobotsHeader(headResponse.Header); !robots.IsEmpty() {
+			page.Robots = robots
+		}
+		page.SetResponse(headResponse, time.Since(begin))
 		s.ScrapeSucceed(url, page)
 		channel <- ScrapeResult{Page: page, Error: nil}
 		return
 	}
 
+	begin = time.Now()
 	getResponse, getError := requester.GetRequest(url)
 	if getError != nil {
-		s.ScrapeFailed(url, &FailedPage{Url: url, FailReason: getError.Error(), Timestamp: CurrentTimestamp()})
+		s.ScrapeFailed(url, NewFailedPage(url, getError))
 		channel <- ScrapeResult{Page: nil, Error: getError}
 		return
 	}
 	defer getResponse.Body.Close()
 
-	var title, description string
+	var page *SucceededPage
+	if isHTML {
+		var err error
+		body := &CountingReader{Reader: getResponse.Body}
+		page, err = s.ScrapeHTML(url, body, getResponse.Header)
+		if err != nil {
+			s.ScrapeFailed(url, NewFailedPage(url, err))
+			channel <- ScrapeResult{Page: nil, Error: err}
+			return
+		}
+		if contentLength < 0 {
+			contentLength = body.Count
+		}
+	} else {
+		var err error
+		page, err = s.ScrapeDocument(url, getResponse, handler)
+		if err != nil {
+			s.ScrapeFailed(url, NewFailedPage(url, err))
+			channel <- ScrapeResult{Page: nil, Error: err}
+			return
+		}
+		if contentLength < 0 {
+			contentLength = getResponse.ContentLength
+		}
+	}
+	page.ContentType = contentType
+	page.ContentLength = contentLength
+	page.SetResponse(getResponse, time.Since(begin))
+	s.InventoryAssets(page, requester)
+	s.Fingerprint(page)
+	s.ScrapeSucceed(url, page)
+	channel <- ScrapeResult{Page: page, Error: nil}
+}
+
+// InventoryAssets checks the assets of the page if the availability checks are enabled and sums the page weight
+func (s *Scrapper) InventoryAssets(page *SucceededPage, requester *Request) {
+	if s.AssetExtractor == nil || page.Assets == nil {
+		return
+	}
+	if s.AssetExtractor.CheckAvailability {
+		s.AssetExtractor.Check(page.Assets, requester)
+	}
+	page.Weight = AssetWeight(page)
+}
+
+// Fingerprint sets the content fingerprint of the page and the page it duplicates if deduplication is enabled
+func (s *Scrapper) Fingerprint(page *SucceededPage) {
+	fingerprint, ok := PageFingerprint(page)
+	if !ok {
+		return
+	}
+	page.Fingerprint = FormatFingerprint(fingerprint)
+	if s.Deduplicator != nil {
+		page.DuplicateOf = s.Deduplicator.Register(page.Url, fingerprint)
+	}
+}
+
+// ScrapeHTML extracts the page of an html document, the url is the base of the relative links
+func (s *Scrapper) ScrapeHTML(url string, body io.Reader, header http.Header) (*SucceededPage, error) {
+	var title, description, mainText string
 	var urls = []string{}
+	var links = []*PageLink{}
+	var noFollowUrls = []string{}
 	var paragraphs = []string{}
 
 	// Load the HTML document
-	doc, err := goquery.NewDocumentFromReader(getResponse.Body)
+	doc, err := goquery.NewDocumentFromReader(body)
 	if err != nil {
-		s.ScrapeFailed(url, &FailedPage{Url: url, FailReason: err.Error(), Timestamp: CurrentTimestamp()})
-		channel <- ScrapeResult{Page: nil, Error: err}
-		return
+		return nil, err
 	}
 
 	// Find page title
@@ -238,30 +496,126 @@ func (s *Scrapper) Scrape(url string, channel chan ScrapeResult, wg *sync.WaitGr
 		}
 	})
 
-	// Find the urls within the page
-	doc.Find("a").Each(func(i int, s *goquery.Selection) {
-		href, exists := s.Attr("href")
-		if exists {
-			absoluteUrl, err := AbsoluteURL(url, href)
-			if err != nil {
-				return
-			}
-			if !URLExists(urls, absoluteUrl) {
-				urls = append(urls, absoluteUrl)
-			}
+	// Find the links of the enabled link sources, keeping the urls linked only with rel=nofollow/ugc/sponsored apart
+	linkExtractor := s.LinkExtractor
+	if linkExtractor == nil {
+		linkExtractor, _ = NewLinkExtractor()
+	}
+	links = linkExtractor.Extract(url, doc)
+	followedUrls := map[string]bool{}
+	for _, link := range links {
+		if !URLExists(urls, link.Url) {
+			urls = append(urls, link.Url)
 		}
-	})
+		if !link.NoFollow {
+			followedUrls[link.Url] = true
+		}
+	}
+	for _, u := range urls {
+		if !followedUrls[u] {
+			noFollowUrls = append(noFollowUrls, u)
+		}
+	}
+
+	// Relative urls of the metadata and the assets are resolved against the base href as well
+	base := BaseHref(url, doc)
+
+	// Find the robots directives of the header and the meta tags
+	var robots *RobotsDirectives
+	if directives := ParseRobotsHeader(header).Merge(ParseRobotsMeta(doc)); !directives.IsEmpty() {
+		robots = directives
+	}
+
+	// Find the structured metadata of the page
+	var metadata *PageMetadata
+	if s.MetadataExtractor != nil {
+		metadata = s.MetadataExtractor.Extract(base, doc)
+	}
+
+	// Find the main content of the page without the boilerplate
+	if s.ContentExtractor != nil {
+		mainText = s.ContentExtractor.Extract(doc)
+	}
+
+	// Find the language the page is written in
+	var language, languageSource string
+	if s.LanguageDetector != nil {
+		text := mainText
+		if text == "" {
+			text = strings.Join(paragraphs, "\n")
+		}
+		if text == "" {
+			text = title + "\n" + description
+		}
+		language, languageSource = s.LanguageDetector.Language(header, doc, text)
+	}
+
+	// Find the images, scripts, stylesheets, frames and media the page loads
+	var assets []*PageAsset
+	if s.AssetExtractor != nil {
+		assets = s.AssetExtractor.Extract(base, doc)
+	}
+
+	// Find the custom fields declared by the extraction rules
+	var fields map[string]interface{}
+	if s.FieldExtractor != nil {
+		fields = s.FieldExtractor.Extract(url, doc)
+	}
 
 	page := &SucceededPage{
-		Url:           url,
-		Title:         title,
-		ContentType:   contentType,
-		ContentLength: contentLength,
-		Description:   description,
-		Timestamp:     CurrentTimestamp(),
-		Urls:          urls,
-		Paragrahps:    paragraphs,
+		Url:          url,
+		Title:        title,
+		Description:  description,
+		Timestamp:    CurrentTimestamp(),
+		Urls:         urls,
+		Paragrahps:   paragraphs,
+		MainText:     mainText,
+		Language:     language,
+		LanguageFrom: languageSource,
+		Metadata:     metadata,
+		Fields:       fields,
+		Robots:       robots,
+		NoFollowUrls: noFollowUrls,
+		Links:        links,
+		Assets:       assets,
 	}
-	s.ScrapeSucceed(url, page)
-	channel <- ScrapeResult{Page: page, Error: nil}
+	return page, nil
+}
+
+// ScrapeDocument reads a non html document with the handler of its content type. Documents which could not
+// be read or handled fail.
+func (s *Scrapper) ScrapeDocument(url string, response *http.Response, handler ContentHandler) (*SucceededPage, error) {
+	page := &SucceededPage{
+		Url:         url,
+		ContentType: strings.ToLower(response.Header.Get("Content-Type")),
+		Timestamp:   CurrentTimestamp(),
+		Urls:        []string{},
+		Paragrahps:  []string{},
+	}
+	if robots := ParseRobotsHeader(response.Header); !robots.IsEmpty() {
+		page.Robots = robots
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(response.Body, MaxDocumentSize))
+	if err != nil {
+		s.Loggers.Log(WARNING, fmt.Sprintf("Reading document failed on page: %s Reason: %s\n", url, err.Error()))
+		return nil, err
+	}
+	content, err := handler.Handle(url, body)
+	if err != nil {
+		s.Loggers.Log(WARNING, fmt.Sprintf("Handling document failed on page: %s Reason: %s\n", url, err.Error()))
+		return nil, err
+	}
+	page.Title = content.Title
+	page.Description = content.Description
+	if content.Paragraphs != nil {
+		page.Paragrahps = content.Paragraphs
+	}
+	if content.Urls != nil {
+		page.Urls = content.Urls
+	}
+	if s.LanguageDetector != nil {
+		page.Language, page.LanguageFrom = s.LanguageDetector.Language(response.Header, nil,
+			strings.Join(page.Paragrahps, "\n"))
+	}
+	return page, nil
 }
@@ -0,0 +1,124 @@
+package collector
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+)
+
+const (
+	// Priority of the sitemap urls without one, as defined by the sitemap protocol
+	DefaultSitemapPriority = 0.5
+	// Nested sitemaps of a sitemap index are fetched up to this many
+	MaxNestedSitemaps = 50
+)
+
+type Sitemap struct {
+	Urls []*SitemapUrl
+	// Sitemaps listed by a sitemap index
+	Sitemaps []string
+}
+
+type SitemapUrl struct {
+	Loc        string  `json:"loc"`
+	LastMod    int64   `json:"lastmod"`
+	ChangeFreq string  `json:"changefreq"`
+	Priority   float64 `json:"priority"`
+}
+
+// ParseSitemap reads a sitemap urlset or a sitemap index
+func ParseSitemap(body []byte) (*Sitemap, error) {
+	var root xmlNode
+	decoder := xml.NewDecoder(bytes.NewReader(body))
+	decoder.Strict = false
+	decoder.CharsetReader = charsetReader
+	if err := decoder.Decode(&root); err != nil {
+		return nil, err
+	}
+	sitemap := &Sitemap{Urls: []*SitemapUrl{}, Sitemaps: []string{}}
+	switch strings.ToLower(root.XMLName.Local) {
+	case "urlset":
+		for _, node := range root.Children("url") {
+			loc := node.Text("loc")
+			if loc == "" {
+				continue
+			}
+			priority, err := strconv.ParseFloat(node.Text("priority"), 64)
+			if err != nil || priority < 0 || priority > 1 {
+				priority = DefaultSitemapPriority
+			}
+			sitemap.Urls = append(sitemap.Urls, &SitemapUrl{
+				Loc:        loc,
+				LastMod:    ParseDate(node.Text("lastmod")),
+				ChangeFreq: node.Text("changefreq"),
+				Priority:   priority,
+			})
+		}
+	case "sitemapindex":
+		for _, node := range root.Children("sitemap") {
+			if loc := node.Text("loc"); loc != "" {
+				sitemap.Sitemaps = append(sitemap.Sitemaps, loc)
+			}
+		}
+	default:
+		return nil, errors.New(fmt.Sprintf("unknown sitemap root element: %s", root.XMLName.Local))
+	}
+	return sitemap, nil
+}
+
+// FetchSitemap downloads a sitemap over the shared transport, the urls of the nested sitemaps of a sitemap index
+// are merged into it
+func FetchSitemap(sitemapURL string) (*Sitemap, error) {
+	return fetchSitemaps(NewRequest(DefaultRequestTimeout), sitemapURL)
+}
+
+// FetchSitemap downloads a sitemap with the transport of the scrapper so that the sitemap is requested with the
+// options and the session of the crawl
+func (c *Collector) FetchSitemap(sitemapURL string) (*Sitemap, error) {
+	return fetchSitemaps(NewRequestWithTransport(c.Scrapper.Timeout, c.Scrapper.Transport), sitemapURL)
+}
+
+func fetchSitemaps(requester *Request, sitemapURL string) (*Sitemap, error) {
+	sitemap, err := fetchSitemap(requester, sitemapURL)
+	if err != nil {
+		return nil, err
+	}
+	for i, nested := range sitemap.Sitemaps {
+		if i >= MaxNestedSitemaps {
+			break
+		}
+		child, err := fetchSitemap(requester, nested)
+		if err != nil {
+			continue
+		}
+		sitemap.Urls = append(sitemap.Urls, child.Urls...)
+	}
+	return sitemap, nil
+}
+
+func fetchSitemap(requester *Request, sitemapURL string) (*Sitemap, error) {
+	response, err := requester.GetRequest(sitemapURL)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	body, err := ioutil.ReadAll(io.LimitReader(response.Body, MaxDocumentSize))
+	if err != nil {
+		return nil, err
+	}
+	return ParseSitemap(body)
+}
+
+// Priorities returns the priorities of the sitemap urls keyed by url
+func (s *Sitemap) Priorities() map[string]float64 {
+	priorities := make(map[string]float64, len(s.Urls))
+	for _, u := range s.Urls {
+		priorities[u.Loc] = u.Priority
+	}
+	return priorities
+}
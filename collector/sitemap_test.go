@@ -0,0 +1,39 @@
+package collector
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestCollectorFetchesSitemapsWithItsTransport(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/xml")
+		switch r.URL.Path {
+		case "/sitemap.xml":
+			fmt.Fprintf(w, `<sitemapindex><sitemap><loc>%s/pages.xml</loc></sitemap></sitemapindex>`, server.URL)
+		case "/pages.xml":
+			fmt.Fprintf(w, `<urlset><url><loc>%s/a</loc><priority>0.8</priority></url></urlset>`, server.URL)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	loggers := discardLoggers()
+	c := &Collector{Scrapper: NewScrapper(loggers), Loggers: loggers}
+	c.Scrapper.Transport = NewTransport(DefaultTransportOptions())
+
+	sitemap, err := c.FetchSitemap(server.URL + "/sitemap.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]float64{server.URL + "/a": 0.8}; !reflect.DeepEqual(sitemap.Priorities(), want) {
+		t.Errorf("got priorities %v, want %v", sitemap.Priorities(), want)
+	}
+	if got := c.Scrapper.Transport.Snapshot().Requests; got != 2 {
+		t.Errorf("got %d requests over the transport of the collector, want 2", got)
+	}
+}
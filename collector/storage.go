@@ -0,0 +1,444 @@
+package collector
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+const (
+	// Number of the keys a disk index is sized for when it is not told otherwise
+	DefaultExpectedUrls = 10000000
+	// False positive rate of the bloom filter in front of a disk index
+	DefaultFalsePositiveRate = 0.01
+	// Keys of a disk index are spread over this many bucket files
+	DiskIndexBuckets = 1024
+	// Recent writes are kept in memory up to this many keys before they are appended to the buckets
+	DefaultMaxHotKeys = 100000
+)
+
+// UrlSet is a set of urls which may not fit into the memory
+type UrlSet interface {
+	Add(u string)
+	Contains(u string) bool
+}
+
+type MemoryUrlSet map[string]bool
+
+func (s MemoryUrlSet) Add(u string) {
+	s[u] = true
+}
+
+func (s MemoryUrlSet) Contains(u string) bool {
+	return s[u]
+}
+
+// DiskIndex is a string key value index kept in append only bucket files, the last value written for a key
+// wins. A bloom filter in front of it answers the lookups of the missing keys without touching the disk, the
+// other lookups search the sorted offsets file of their bucket and read a single line of the bucket. Only the
+// bloom filter and the recent writes are kept in memory.
+type DiskIndex struct {
+	Dir   string
+	Bloom *BloomFilter
+	// Writes which are not appended to the buckets yet
+	Hot        map[string]string
+	MaxHotKeys int
+	// Loggers the write errors of Add are logged to, the keys which could not be written stay hot so that the
+	// next flush writes them again or returns the error
+	Loggers *Loggers
+	keys    int
+	Mutex   sync.Mutex
+}
+
+// offsetRecord locates the last line written for a key of the bucket by the 64 bit hash of the key. The offsets
+// file of a bucket starts with the length of the bucket it indexes, followed by the records sorted by hash, a
+// hash shared by several keys has a record for each of them.
+type offsetRecord struct {
+	Hash   uint64
+	Offset int64
+}
+
+const offsetRecordSize = 16
+
+// NewDiskIndex opens the index in the directory. The saved bloom filter of an existing index is loaded, and the
+// lines appended to a bucket after its offsets were written are indexed.
+func NewDiskIndex(dir string, expectedKeys int) (*DiskIndex, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	index := &DiskIndex{
+		Dir:        dir,
+		Bloom:      NewBloomFilter(expectedKeys, DefaultFalsePositiveRate),
+		Hot:        map[string]string{},
+		MaxHotKeys: DefaultMaxHotKeys,
+	}
+	// A filter saved with another size is rebuilt from the hashes of the offsets files
+	saved, err := LoadBloomFilter(index.bloomPath())
+	rebuild := err != nil || saved.Size != index.Bloom.Size || saved.Hashes != index.Bloom.Hashes
+	if !rebuild {
+		index.Bloom = saved
+	}
+	for bucket := 0; bucket < DiskIndexBuckets; bucket++ {
+		if err := index.indexBucket(bucket); err != nil {
+			return nil, err
+		}
+		if rebuild {
+			records, _, err := index.readOffsets(bucket)
+			if err != nil {
+				return nil, err
+			}
+			for _, record := range records {
+				index.Bloom.AddHash(record.Hash)
+			}
+		}
+		info, err := os.Stat(index.offsetsPath(bucket))
+		if err == nil {
+			index.keys += int((info.Size() - 8) / offsetRecordSize)
+		} else if !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+	return index, nil
+}
+
+func (d *DiskIndex) Put(key string, value string) error {
+	d.Mutex.Lock()
+	defer d.Mutex.Unlock()
+	if _, exists := d.get(key); !exists {
+		d.keys++
+	}
+	d.Bloom.Add(key)
+	d.Hot[key] = value
+	if len(d.Hot) >= d.MaxHotKeys {
+		return d.flush()
+	}
+	return nil
+}
+
+func (d *DiskIndex) Get(key string) (string, bool) {
+	d.Mutex.Lock()
+	defer d.Mutex.Unlock()
+	return d.get(key)
+}
+
+func (d *DiskIndex) get(key string) (string, bool) {
+	if value, exists := d.Hot[key]; exists {
+		return value, true
+	}
+	if !d.Bloom.MayContain(key) {
+		return "", false
+	}
+	hash := keyHash(key)
+	offsets, err := d.lookupOffsets(bucketOf(hash), hash)
+	if err != nil {
+		return "", false
+	}
+	for _, offset := range offsets {
+		if stored, value, err := d.readLine(bucketOf(hash), offset); err == nil && stored == key {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+// Add and Contains make the index an UrlSet
+func (d *DiskIndex) Add(u string) {
+	if err := d.Put(u, ""); err != nil && d.Loggers != nil {
+		d.Loggers.Log(ERROR, fmt.Sprintf("Disk index %s could not be written: %s\n", d.Dir, err.Error()))
+	}
+}
+
+func (d *DiskIndex) Contains(u string) bool {
+	_, exists := d.Get(u)
+	return exists
+}
+
+// Len returns the number of the keys of the index
+func (d *DiskIndex) Len() int {
+	d.Mutex.Lock()
+	defer d.Mutex.Unlock()
+	return d.keys
+}
+
+// Each calls the function for every key of the index in the order of the buckets
+func (d *DiskIndex) Each(fn func(key string, value string) error) error {
+	d.Mutex.Lock()
+	defer d.Mutex.Unlock()
+	if err := d.flush(); err != nil {
+		return err
+	}
+	for bucket := 0; bucket < DiskIndexBuckets; bucket++ {
+		values := map[string]string{}
+		err := d.scanBucket(bucket, 0, func(key string, value string, offset int64) {
+			values[key] = value
+		})
+		if err != nil {
+			return err
+		}
+		keys := make([]string, 0, len(values))
+		for key := range values {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if err := fn(key, values[key]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Flush appends the writes kept in memory to their buckets
+func (d *DiskIndex) Flush() error {
+	d.Mutex.Lock()
+	defer d.Mutex.Unlock()
+	return d.flush()
+}
+
+// flush saves the bloom filter before the buckets, so that the saved filter holds every key of the buckets
+func (d *DiskIndex) flush() error {
+	if len(d.Hot) == 0 {
+		return nil
+	}
+	if err := d.Bloom.Save(d.bloomPath()); err != nil {
+		return err
+	}
+	keys := map[int][]string{}
+	for key := range d.Hot {
+		bucket := bucketOf(keyHash(key))
+		keys[bucket] = append(keys[bucket], key)
+	}
+	for bucket, bucketKeys := range keys {
+		if err := d.appendBucket(bucket, bucketKeys); err != nil {
+			return err
+		}
+		for _, key := range bucketKeys {
+			delete(d.Hot, key)
+		}
+	}
+	return nil
+}
+
+// appendBucket writes the hot values of the keys at the end of the bucket and indexes their new lines
+func (d *DiskIndex) appendBucket(bucket int, keys []string) error {
+	file, err := os.OpenFile(d.bucketPath(bucket), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	var lines bytes.Buffer
+	for _, key := range keys {
+		line, err := json.Marshal([]string{key, d.Hot[key]})
+		if err != nil {
+			return err
+		}
+		lines.Write(append(line, '\n'))
+	}
+	if _, err := file.Write(lines.Bytes()); err != nil {
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return d.indexBucket(bucket)
+}
+
+// indexBucket adds the lines of the bucket which are not indexed yet to its offsets file, the record of a key
+// written again is moved to its last line
+func (d *DiskIndex) indexBucket(bucket int) error {
+	info, err := os.Stat(d.bucketPath(bucket))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	records, indexed, err := d.readOffsets(bucket)
+	if err != nil {
+		return err
+	}
+	if indexed == info.Size() {
+		return nil
+	}
+	lines := map[string]int64{}
+	err = d.scanBucket(bucket, indexed, func(key string, value string, offset int64) {
+		lines[key] = offset
+	})
+	if err != nil {
+		return err
+	}
+	sorted := len(records)
+	for key, offset := range lines {
+		hash := keyHash(key)
+		located := false
+		for i := sort.Search(sorted, func(i int) bool { return records[i].Hash >= hash }); i < sorted && records[i].Hash == hash; i++ {
+			if stored, _, err := d.readLine(bucket, records[i].Offset); err == nil && stored == key {
+				records[i].Offset = offset
+				located = true
+				break
+			}
+		}
+		if !located {
+			records = append(records, offsetRecord{Hash: hash, Offset: offset})
+		}
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].Hash < records[j].Hash || records[i].Hash == records[j].Hash && records[i].Offset < records[j].Offset
+	})
+	return d.writeOffsets(bucket, records, info.Size())
+}
+
+// readOffsets reads the records of the bucket and the length of the bucket they index
+func (d *DiskIndex) readOffsets(bucket int) ([]offsetRecord, int64, error) {
+	content, err := ioutil.ReadFile(d.offsetsPath(bucket))
+	if os.IsNotExist(err) {
+		return []offsetRecord{}, 0, nil
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(content) < 8 || (len(content)-8)%offsetRecordSize != 0 {
+		return nil, 0, errors.New(fmt.Sprintf("offsets file %s is not valid", d.offsetsPath(bucket)))
+	}
+	records := make([]offsetRecord, (len(content)-8)/offsetRecordSize)
+	for i := range records {
+		record := content[8+i*offsetRecordSize:]
+		records[i] = offsetRecord{
+			Hash:   binary.BigEndian.Uint64(record),
+			Offset: int64(binary.BigEndian.Uint64(record[8:])),
+		}
+	}
+	return records, int64(binary.BigEndian.Uint64(content)), nil
+}
+
+// writeOffsets replaces the offsets file of the bucket once the records are written
+func (d *DiskIndex) writeOffsets(bucket int, records []offsetRecord, indexed int64) error {
+	content := make([]byte, 8+len(records)*offsetRecordSize)
+	binary.BigEndian.PutUint64(content, uint64(indexed))
+	for i, record := range records {
+		binary.BigEndian.PutUint64(content[8+i*offsetRecordSize:], record.Hash)
+		binary.BigEndian.PutUint64(content[16+i*offsetRecordSize:], uint64(record.Offset))
+	}
+	path := d.offsetsPath(bucket)
+	if err := ioutil.WriteFile(path+".tmp", content, 0644); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// lookupOffsets binary searches the offsets file of the bucket for the lines of the keys of the hash
+func (d *DiskIndex) lookupOffsets(bucket int, hash uint64) ([]int64, error) {
+	file, err := os.Open(d.offsetsPath(bucket))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	record := make([]byte, offsetRecordSize)
+	var readErr error
+	read := func(i int) offsetRecord {
+		if _, err := file.ReadAt(record, 8+int64(i)*offsetRecordSize); err != nil && readErr == nil {
+			readErr = err
+		}
+		return offsetRecord{Hash: binary.BigEndian.Uint64(record), Offset: int64(binary.BigEndian.Uint64(record[8:]))}
+	}
+	count := int((info.Size() - 8) / offsetRecordSize)
+	offsets := []int64{}
+	for i := sort.Search(count, func(i int) bool { return read(i).Hash >= hash }); i < count; i++ {
+		found := read(i)
+		if found.Hash != hash {
+			break
+		}
+		offsets = append(offsets, found.Offset)
+	}
+	return offsets, readErr
+}
+
+// readLine reads the key and the value written at the offset of the bucket
+func (d *DiskIndex) readLine(bucket int, offset int64) (string, string, error) {
+	file, err := os.Open(d.bucketPath(bucket))
+	if err != nil {
+		return "", "", err
+	}
+	defer file.Close()
+	reader := bufio.NewReader(io.NewSectionReader(file, offset, 1<<62))
+	line, err := reader.ReadBytes('\n')
+	if err != nil && err != io.EOF {
+		return "", "", err
+	}
+	var pair []string
+	if err := json.Unmarshal(line, &pair); err != nil {
+		return "", "", err
+	}
+	if len(pair) != 2 {
+		return "", "", errors.New(fmt.Sprintf("disk index line is not valid: %s", line))
+	}
+	return pair[0], pair[1], nil
+}
+
+// scanBucket calls the function for every line of the bucket from the offset on with the offset of the line
+func (d *DiskIndex) scanBucket(bucket int, from int64, fn func(key string, value string, offset int64)) error {
+	file, err := os.Open(d.bucketPath(bucket))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if _, err := file.Seek(from, io.SeekStart); err != nil {
+		return err
+	}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), MaxDocumentSize)
+	offset := from
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		var pair []string
+		if err := json.Unmarshal(line, &pair); err == nil && len(pair) == 2 {
+			fn(pair[0], pair[1], offset)
+		}
+		offset += int64(len(line)) + 1
+	}
+	return scanner.Err()
+}
+
+func (d *DiskIndex) bucketPath(bucket int) string {
+	return filepath.Join(d.Dir, fmt.Sprintf("%04d.idx", bucket))
+}
+
+func (d *DiskIndex) offsetsPath(bucket int) string {
+	return filepath.Join(d.Dir, fmt.Sprintf("%04d.off", bucket))
+}
+
+func (d *DiskIndex) bloomPath() string {
+	return filepath.Join(d.Dir, "bloom")
+}
+
+// keyHash locates the keys in the offsets files and spreads them over the buckets
+func keyHash(key string) uint64 {
+	hash := fnv.New64a()
+	_, _ = hash.Write([]byte(key))
+	return hash.Sum64()
+}
+
+func bucketOf(hash uint64) int {
+	return int(hash % DiskIndexBuckets)
+}
@@ -0,0 +1,432 @@
+package collector
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCollectorCloseReleasesTheStore(t *testing.T) {
+	loggers := discardLoggers()
+	c := &Collector{Scrapper: NewScrapper(loggers), Loggers: loggers}
+	c.Scrapper.Transport = NewTransport(DefaultTransportOptions())
+	if err := c.EnableDiskStorage(t.TempDir(), 1000); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Scrapper.Store.PutSucceeded("http://example.com/", &SucceededPage{Url: "http://example.com/"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range []*os.File{c.Scrapper.Store.Succeeded, c.Scrapper.Store.Failed} {
+		if _, err := file.Write([]byte("\n")); !errors.Is(err, os.ErrClosed) {
+			t.Errorf("%s is not closed: %v", file.Name(), err)
+		}
+	}
+}
+
+func TestDiskIndexRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	index, err := NewDiskIndex(dir, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	index.MaxHotKeys = 10
+	want := map[string]string{}
+	for i := 0; i < 95; i++ {
+		key := fmt.Sprintf("http://example.com/%d", i)
+		want[key] = fmt.Sprintf("value %d", i)
+		if err := index.Put(key, want[key]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Rewritten keys keep their last value, some of them are still hot
+	for i := 0; i < 95; i += 7 {
+		key := fmt.Sprintf("http://example.com/%d", i)
+		want[key] = "rewritten"
+		if err := index.Put(key, want[key]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	check := func(index *DiskIndex) {
+		t.Helper()
+		if got := index.Len(); got != len(want) {
+			t.Fatalf("len: got %d, want %d", got, len(want))
+		}
+		for key, value := range want {
+			if got, exists := index.Get(key); !exists || got != value {
+				t.Fatalf("%s: got %q %v, want %q", key, got, exists, value)
+			}
+		}
+		if _, exists := index.Get("http://example.com/missing"); exists {
+			t.Fatal("a missing key is found")
+		}
+		if !index.Contains("http://example.com/3") || index.Contains("http://example.com/95") {
+			t.Fatal("the url set does not match the keys")
+		}
+		got := map[string]string{}
+		err := index.Each(func(key string, value string) error {
+			got[key] = value
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("each:\n got: %v\nwant: %v", got, want)
+		}
+	}
+	check(index)
+	if err := index.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if len(index.Hot) != 0 {
+		t.Fatalf("%d keys are still hot after the flush", len(index.Hot))
+	}
+	check(index)
+
+	reopened, err := NewDiskIndex(dir, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check(reopened)
+}
+
+// The lines appended to a bucket after its offsets were written are indexed when the index is opened again, and
+// the bloom filter is rebuilt from the offsets files when it is not saved
+func TestDiskIndexRecoversTheUnindexedLines(t *testing.T) {
+	dir := t.TempDir()
+	index, err := NewDiskIndex(dir, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 50; i++ {
+		if err := index.Put(fmt.Sprintf("http://example.com/%d", i), "indexed"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := index.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	key := "http://example.com/3"
+	bucket := bucketOf(keyHash(key))
+	file, err := os.OpenFile(index.bucketPath(bucket), os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := file.Write([]byte(`["http://example.com/3","rewritten"]` + "\n")); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+	if err := os.Remove(index.bloomPath()); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := NewDiskIndex(dir, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := reopened.Len(); got != 50 {
+		t.Errorf("len: got %d, want 50", got)
+	}
+	if got, exists := reopened.Get(key); !exists || got != "rewritten" {
+		t.Errorf("%s: got %q %v, want the appended value", key, got, exists)
+	}
+	for i := 0; i < 50; i++ {
+		if !reopened.Contains(fmt.Sprintf("http://example.com/%d", i)) {
+			t.Fatalf("key %d is missing from the rebuilt bloom filter", i)
+		}
+	}
+	records, indexed, err := reopened.readOffsets(bucket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Stat(reopened.bucketPath(bucket)); err != nil || indexed != info.Size() {
+		t.Errorf("offsets index %d bytes of the bucket: %v", indexed, err)
+	}
+	for i := 1; i < len(records); i++ {
+		if records[i-1].Hash > records[i].Hash {
+			t.Fatalf("offsets are not sorted: %v", records)
+		}
+	}
+}
+
+// A write error of the url set is logged and the url stays in the memory until a flush writes it
+func TestDiskIndexAddLogsTheWriteErrors(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "index")
+	index, err := NewDiskIndex(dir, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var logged bytes.Buffer
+	index.Loggers = discardLoggers()
+	index.Loggers.Error = log.New(&logged, "", 0)
+	index.MaxHotKeys = 1
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatal(err)
+	}
+	index.Add("http://example.com/")
+	if !strings.Contains(logged.String(), "could not be written") {
+		t.Errorf("write error is not logged: %q", logged.String())
+	}
+	if !index.Contains("http://example.com/") {
+		t.Error("url which could not be written is lost")
+	}
+	if err := index.Flush(); err == nil {
+		t.Error("flush does not return the write error")
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := index.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	reopened, err := NewDiskIndex(dir, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reopened.Contains("http://example.com/") {
+		t.Error("url is not written by the flush")
+	}
+}
+
+func TestDiskQueueRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	queue, err := NewDiskQueue(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	queue.SegmentSize = 4
+	for i := 0; i < 10; i++ {
+		if err := queue.Push(&FrontierEntry{Url: fmt.Sprintf("http://example.com/%d", i), Depth: i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := len(queue.Segments); got != 3 {
+		t.Fatalf("segments: got %d, want 3", got)
+	}
+	pop := func(queue *DiskQueue, want int) {
+		t.Helper()
+		entry, err := queue.Pop()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if entry == nil || entry.Url != fmt.Sprintf("http://example.com/%d", want) || entry.Depth != want {
+			t.Fatalf("pop: got %+v, want entry %d", entry, want)
+		}
+	}
+	pop(queue, 0)
+	pop(queue, 1)
+	if err := queue.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The entries read into the memory before the close are not lost
+	reopened, err := NewDiskQueue(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reopened.SegmentSize = 4
+	if got := reopened.Len(); got != 8 {
+		t.Fatalf("len after reopening: got %d, want 8", got)
+	}
+	if err := reopened.Push(&FrontierEntry{Url: "http://example.com/10", Depth: 10}); err != nil {
+		t.Fatal(err)
+	}
+	for i := 2; i <= 10; i++ {
+		pop(reopened, i)
+	}
+	if entry, err := reopened.Pop(); entry != nil || err != nil {
+		t.Fatalf("pop of the empty queue: got %+v %v", entry, err)
+	}
+	if reopened.Len() != 0 {
+		t.Fatalf("len of the empty queue: %d", reopened.Len())
+	}
+}
+
+func TestDiskPageStoreRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewDiskPageStore(dir, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	put := func(url string, failed bool) {
+		t.Helper()
+		if failed {
+			err = store.PutFailed(url, &FailedPage{Url: url, FailReason: "not found", StatusCode: 404})
+		} else {
+			err = store.PutSucceeded(url, &SucceededPage{Url: url, Title: "Title of " + url})
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	put("http://example.com/a", false)
+	put("http://example.com/b", false)
+	put("http://example.com/c", true)
+	// A failed page which succeeds on the retry moves to the succeeded pages
+	put("http://example.com/d", true)
+	put("http://example.com/d", false)
+	put("http://example.com/e", false)
+	if err := store.Delete("http://example.com/e"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Delete("http://example.com/missing"); err != nil {
+		t.Fatal(err)
+	}
+	check := func(store *DiskPageStore) {
+		t.Helper()
+		if got := store.NumberOfSucceeded(); got != 3 {
+			t.Fatalf("succeeded: got %d, want 3", got)
+		}
+		if got := store.NumberOfFailed(); got != 1 {
+			t.Fatalf("failed: got %d, want 1", got)
+		}
+		succeeded, failed, err := store.Get("http://example.com/d")
+		if err != nil || failed != nil || succeeded == nil || succeeded.Title != "Title of http://example.com/d" {
+			t.Fatalf("get d: %+v %+v %v", succeeded, failed, err)
+		}
+		succeeded, failed, err = store.Get("http://example.com/c")
+		if err != nil || succeeded != nil || failed == nil || failed.StatusCode != 404 {
+			t.Fatalf("get c: %+v %+v %v", succeeded, failed, err)
+		}
+		if succeeded, failed, err := store.Get("http://example.com/e"); succeeded != nil || failed != nil || err != nil {
+			t.Fatalf("get of a deleted page: %+v %+v %v", succeeded, failed, err)
+		}
+		if !store.IsSucceeded("http://example.com/a") || store.IsFailed("http://example.com/a") || !store.IsFailed("http://example.com/c") {
+			t.Fatal("the index does not tell the kind of the pages")
+		}
+		urls := []string{}
+		err = store.EachSucceeded(func(url string, page *SucceededPage) error {
+			if page.Url != url {
+				t.Errorf("page of %s is %s", url, page.Url)
+			}
+			urls = append(urls, url)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		sort.Strings(urls)
+		if want := []string{"http://example.com/a", "http://example.com/b", "http://example.com/d"}; !reflect.DeepEqual(urls, want) {
+			t.Fatalf("each succeeded: got %v, want %v", urls, want)
+		}
+		urls = []string{}
+		err = store.EachFailed(func(url string, page *FailedPage) error {
+			urls = append(urls, url)
+			return nil
+		})
+		if err != nil || !reflect.DeepEqual(urls, []string{"http://example.com/c"}) {
+			t.Fatalf("each failed: got %v %v", urls, err)
+		}
+	}
+	check(store)
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+	reopened, err := NewDiskPageStore(dir, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	check(reopened)
+}
+
+func TestDiskPageStoreTake(t *testing.T) {
+	store, err := NewDiskPageStore(t.TempDir(), 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	if err := store.PutSucceeded("http://example.com/a", &SucceededPage{Url: "http://example.com/a"}); err != nil {
+		t.Fatal(err)
+	}
+	succeeded, failed, err := store.Take("http://example.com/a")
+	if err != nil || failed != nil || succeeded == nil || succeeded.Url != "http://example.com/a" {
+		t.Fatalf("take: %+v %+v %v", succeeded, failed, err)
+	}
+	if store.IsSucceeded("http://example.com/a") || store.NumberOfSucceeded() != 0 {
+		t.Error("taken page is still stored")
+	}
+	if succeeded, failed, err := store.Take("http://example.com/a"); succeeded != nil || failed != nil || err != nil {
+		t.Errorf("take of a taken page: %+v %+v %v", succeeded, failed, err)
+	}
+}
+
+// The pages are written to the store without holding the mutex of the scrapper, so that a slow disk does not
+// hold up the workers checking the urls being processed
+func TestScrapperDoesNotHoldItsMutexDuringTheStoreWrites(t *testing.T) {
+	loggers := discardLoggers()
+	c := &Collector{Scrapper: NewScrapper(loggers), Loggers: loggers}
+	if err := c.EnableDiskStorage(t.TempDir(), 1000); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	s := c.Scrapper
+	s.InitiateScrape("http://example.com/a")
+	s.Store.Mutex.Lock()
+	stored := make(chan struct{})
+	go func() {
+		s.ScrapeSucceed("http://example.com/a", &SucceededPage{Url: "http://example.com/a"})
+		close(stored)
+	}()
+	checked := make(chan bool)
+	go func() {
+		checked <- s.IsProcessed("http://example.com/b") && s.NumberOfPagesBeingProcessed() == 1
+	}()
+	select {
+	case ok := <-checked:
+		if !ok {
+			t.Error("processed urls do not match")
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("scrapper is locked while the store is written")
+	}
+	s.Store.Mutex.Unlock()
+	<-stored
+	if !s.IsVisited("http://example.com/a") || s.NumberOfPagesBeingProcessed() != 0 {
+		t.Error("page is not stored")
+	}
+}
+
+func TestBloomFilterFalsePositiveRate(t *testing.T) {
+	for _, rate := range []float64{0.01, 0.001} {
+		keys := 20000
+		bloom := NewBloomFilter(keys, rate)
+		// m = -n ln(p) / ln(2)^2 bits and k = m/n ln(2) hashes
+		wantBits := -float64(keys) * math.Log(rate) / (math.Ln2 * math.Ln2)
+		if float64(bloom.Size) < wantBits || float64(bloom.Size) > wantBits+64 {
+			t.Errorf("rate %g: %d bits, want %.0f", rate, bloom.Size, wantBits)
+		}
+		if want := int(math.Round(float64(bloom.Size) / float64(keys) * math.Ln2)); bloom.Hashes != want {
+			t.Errorf("rate %g: %d hashes, want %d", rate, bloom.Hashes, want)
+		}
+		for i := 0; i < keys; i++ {
+			bloom.Add(fmt.Sprintf("http://example.com/added/%d", i))
+		}
+		for i := 0; i < keys; i++ {
+			if !bloom.MayContain(fmt.Sprintf("http://example.com/added/%d", i)) {
+				t.Fatalf("rate %g: an added key is missing", rate)
+			}
+		}
+		positives := 0
+		for i := 0; i < 10*keys; i++ {
+			if bloom.MayContain(fmt.Sprintf("http://example.com/other/%d", i)) {
+				positives++
+			}
+		}
+		if got := float64(positives) / float64(10*keys); got > 2*rate {
+			t.Errorf("rate %g: %g false positives", rate, got)
+		}
+	}
+}
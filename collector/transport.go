@@ -0,0 +1,284 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// Overall timeout of a request including reading the body
+	DefaultRequestTimeout = 30 * time.Second
+)
+
+type TransportOptions struct {
+	DialTimeout           time.Duration
+	KeepAlive             time.Duration
+	TLSHandshakeTimeout   time.Duration
+	ResponseHeaderTimeout time.Duration
+	IdleConnTimeout       time.Duration
+	MaxIdleConns          int
+	MaxIdleConnsPerHost   int
+	// Zero means no limit
+	MaxConnsPerHost int
+	DNSCacheTTL     time.Duration
+	// Failed lookups are remembered this long so that the links to a dead host fail fast
+	DNSNegativeTTL time.Duration
+}
+
+func DefaultTransportOptions() TransportOptions {
+	return TransportOptions{
+		DialTimeout:           10 * time.Second,
+		KeepAlive:             30 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: 15 * time.Second,
+		IdleConnTimeout:       90 * time.Second,
+		MaxIdleConns:          200,
+		MaxIdleConnsPerHost:   8,
+		MaxConnsPerHost:       16,
+		DNSCacheTTL:           5 * time.Minute,
+		DNSNegativeTTL:        30 * time.Second,
+	}
+}
+
+// TransportStats are the counters of the requests, the connections and the dns lookups of a transport
+type TransportStats struct {
+	Requests          int64 `json:"requests"`
+	NewConnections    int64 `json:"new_connections"`
+	ReusedConnections int64 `json:"reused_connections"`
+	DNSHits           int64 `json:"dns_hits"`
+	DNSMisses         int64 `json:"dns_misses"`
+	DNSNegativeHits   int64 `json:"dns_negative_hits"`
+}
+
+// ReuseRatio returns the share of the requests served over a reused connection
+func (s TransportStats) ReuseRatio() float64 {
+	total := s.NewConnections + s.ReusedConnections
+	if total == 0 {
+		return 0
+	}
+	return float64(s.ReusedConnections) / float64(total)
+}
+
+type dnsEntry struct {
+	Addresses []string
+	Err       error
+	Expires   time.Time
+}
+
+// dnsLookup is a lookup in progress, the concurrent lookups of its host wait for it instead of resolving again
+type dnsLookup struct {
+	Done      chan struct{}
+	Addresses []string
+	Err       error
+	// Lookups cancelled by the caller say nothing about the host, the waiters resolve it again
+	Cancelled bool
+}
+
+// HostResolver resolves the addresses of a host, net.Resolver is the resolver of the crawls
+type HostResolver interface {
+	LookupHost(ctx context.Context, host string) ([]string, error)
+}
+
+// DNSCache resolves the host names with the resolver and keeps the addresses for the ttl, failed lookups
+// are kept for the negative ttl. The expired entries are pruned at most once per ttl.
+type DNSCache struct {
+	Resolver    HostResolver
+	TTL         time.Duration
+	NegativeTTL time.Duration
+	Entries     map[string]*dnsEntry
+	Pending     map[string]*dnsLookup
+	Stats       *TransportStats
+	Mutex       sync.Mutex
+	nextPrune   time.Time
+}
+
+func NewDNSCache(ttl time.Duration, negativeTTL time.Duration, stats *TransportStats) *DNSCache {
+	return &DNSCache{
+		Resolver:    net.DefaultResolver,
+		TTL:         ttl,
+		NegativeTTL: negativeTTL,
+		Entries:     map[string]*dnsEntry{},
+		Pending:     map[string]*dnsLookup{},
+		Stats:       stats,
+	}
+}
+
+func (d *DNSCache) LookupHost(ctx context.Context, host string) ([]string, error) {
+	for {
+		now := time.Now()
+		d.Mutex.Lock()
+		if entry, exists := d.Entries[host]; exists && now.Before(entry.Expires) {
+			d.Mutex.Unlock()
+			return d.hit(entry.Addresses, entry.Err)
+		}
+		lookup, pending := d.Pending[host]
+		if !pending {
+			lookup = &dnsLookup{Done: make(chan struct{})}
+			d.Pending[host] = lookup
+			d.Mutex.Unlock()
+			return d.resolve(ctx, host, lookup)
+		}
+		d.Mutex.Unlock()
+		select {
+		case <-lookup.Done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		if !lookup.Cancelled {
+			return d.hit(lookup.Addresses, lookup.Err)
+		}
+	}
+}
+
+func (d *DNSCache) hit(addresses []string, err error) ([]string, error) {
+	if err != nil {
+		atomic.AddInt64(&d.Stats.DNSNegativeHits, 1)
+		return nil, err
+	}
+	atomic.AddInt64(&d.Stats.DNSHits, 1)
+	return addresses, nil
+}
+
+// resolve looks the host up for the waiters of the lookup and caches the result
+func (d *DNSCache) resolve(ctx context.Context, host string, lookup *dnsLookup) ([]string, error) {
+	atomic.AddInt64(&d.Stats.DNSMisses, 1)
+	addresses, err := d.Resolver.LookupHost(ctx, host)
+	if err == nil && len(addresses) == 0 {
+		err = errors.New(fmt.Sprintf("no addresses found for host: %s", host))
+	}
+	lookup.Addresses, lookup.Err, lookup.Cancelled = addresses, err, ctx.Err() != nil
+	now := time.Now()
+	d.Mutex.Lock()
+	delete(d.Pending, host)
+	if !lookup.Cancelled {
+		entry := &dnsEntry{Addresses: addresses, Err: err, Expires: now.Add(d.TTL)}
+		if err != nil {
+			entry.Expires = now.Add(d.NegativeTTL)
+		}
+		d.prune(now)
+		d.Entries[host] = entry
+	}
+	d.Mutex.Unlock()
+	close(lookup.Done)
+	return addresses, err
+}
+
+// prune removes the expired entries so that the cache of a long crawl does not keep every host it ever resolved
+func (d *DNSCache) prune(now time.Time) {
+	if now.Before(d.nextPrune) {
+		return
+	}
+	for host, entry := range d.Entries {
+		if !now.Before(entry.Expires) {
+			delete(d.Entries, host)
+		}
+	}
+	d.nextPrune = now.Add(d.TTL)
+}
+
+// Transport is the http transport shared by the requests of a crawl so that the connections are reused and
+// the host names are resolved once per ttl
+type Transport struct {
+	Options   TransportOptions
+	DNS       *DNSCache
+	Transport *http.Transport
+	Stats     *TransportStats
+	// Urls of the local site are read from its directory when it is set
+	Local *LocalSite
+	// Cookies are kept only when the jar is set, the login session renews the cookies when they expire
+	Jar     http.CookieJar
+	Session *LoginSession
+}
+
+func NewTransport(options TransportOptions) *Transport {
+	stats := &TransportStats{}
+	t := &Transport{
+		Options: options,
+		DNS:     NewDNSCache(options.DNSCacheTTL, options.DNSNegativeTTL, stats),
+		Stats:   stats,
+	}
+	t.Transport = &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           t.DialContext,
+		TLSHandshakeTimeout:   options.TLSHandshakeTimeout,
+		ResponseHeaderTimeout: options.ResponseHeaderTimeout,
+		IdleConnTimeout:       options.IdleConnTimeout,
+		MaxIdleConns:          options.MaxIdleConns,
+		MaxIdleConnsPerHost:   options.MaxIdleConnsPerHost,
+		MaxConnsPerHost:       options.MaxConnsPerHost,
+		ForceAttemptHTTP2:     true,
+		ExpectContinueTimeout: time.Second,
+	}
+	return t
+}
+
+var (
+	sharedTransport     *Transport
+	sharedTransportOnce sync.Once
+)
+
+// SharedTransport returns the transport with the default options used by the requests which are not given one
+func SharedTransport() *Transport {
+	sharedTransportOnce.Do(func() {
+		sharedTransport = NewTransport(DefaultTransportOptions())
+	})
+	return sharedTransport
+}
+
+// DialContext dials the addresses of the host from the dns cache in turn until one of them connects
+func (t *Transport) DialContext(ctx context.Context, network string, address string) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: t.Options.DialTimeout, KeepAlive: t.Options.KeepAlive}
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	if net.ParseIP(host) != nil {
+		return dialer.DialContext(ctx, network, address)
+	}
+	addresses, err := t.DNS.LookupHost(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	var dialError error
+	for _, ip := range addresses {
+		conn, err := dialer.DialContext(ctx, network, net.JoinHostPort(ip, port))
+		if err == nil {
+			return conn, nil
+		}
+		dialError = err
+	}
+	return nil, dialError
+}
+
+// RoundTripper returns the transport the requests are sent through
+func (t *Transport) RoundTripper() http.RoundTripper {
+	if t.Local != nil {
+		return &localTransport{Site: t.Local, Next: t.Transport}
+	}
+	return t.Transport
+}
+
+// CloseIdleConnections closes the connections kept open for the next requests
+func (t *Transport) CloseIdleConnections() {
+	if t != nil && t.Transport != nil {
+		t.Transport.CloseIdleConnections()
+	}
+}
+
+// Snapshot returns a copy of the counters
+func (t *Transport) Snapshot() TransportStats {
+	return TransportStats{
+		Requests:          atomic.LoadInt64(&t.Stats.Requests),
+		NewConnections:    atomic.LoadInt64(&t.Stats.NewConnections),
+		ReusedConnections: atomic.LoadInt64(&t.Stats.ReusedConnections),
+		DNSHits:           atomic.LoadInt64(&t.Stats.DNSHits),
+		DNSMisses:         atomic.LoadInt64(&t.Stats.DNSMisses),
+		DNSNegativeHits:   atomic.LoadInt64(&t.Stats.DNSNegativeHits),
+	}
+}
@@ -0,0 +1,99 @@
+package collector
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// blockingResolver resolves every host to the same address once it is released, a cancelled lookup fails
+type blockingResolver struct {
+	release chan struct{}
+	calls   int32
+}
+
+func (r *blockingResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	atomic.AddInt32(&r.calls, 1)
+	select {
+	case <-r.release:
+		return []string{"192.0.2.1"}, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func TestDNSCacheCollapsesConcurrentLookups(t *testing.T) {
+	resolver := &blockingResolver{release: make(chan struct{})}
+	cache := NewDNSCache(time.Minute, time.Minute, &TransportStats{})
+	cache.Resolver = resolver
+
+	// The waiters of a cancelled lookup resolve the host again
+	ctx, cancel := context.WithCancel(context.Background())
+	cancelled := make(chan error)
+	go func() {
+		_, err := cache.LookupHost(ctx, "example.com")
+		cancelled <- err
+	}()
+	for atomic.LoadInt32(&resolver.calls) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	var wg sync.WaitGroup
+	results := make([][]string, 8)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			addresses, err := cache.LookupHost(context.Background(), "example.com")
+			if err != nil {
+				t.Error(err)
+			}
+			results[i] = addresses
+		}(i)
+	}
+	cancel()
+	if err := <-cancelled; err == nil {
+		t.Fatal("cancelled lookup succeeded")
+	}
+	for atomic.LoadInt32(&resolver.calls) < 2 {
+		time.Sleep(time.Millisecond)
+	}
+	close(resolver.release)
+	wg.Wait()
+	for _, addresses := range results {
+		if !reflect.DeepEqual(addresses, []string{"192.0.2.1"}) {
+			t.Errorf("got addresses %v", addresses)
+		}
+	}
+	if calls := atomic.LoadInt32(&resolver.calls); calls != 2 {
+		t.Errorf("got %d lookups, want the cancelled one and one for the waiters", calls)
+	}
+	if len(cache.Pending) > 0 {
+		t.Errorf("lookups left pending: %v", cache.Pending)
+	}
+}
+
+func TestDNSCachePrunesExpiredEntries(t *testing.T) {
+	resolver := &blockingResolver{release: make(chan struct{})}
+	close(resolver.release)
+	ttl := 20 * time.Millisecond
+	cache := NewDNSCache(ttl, ttl, &TransportStats{})
+	cache.Resolver = resolver
+	for _, host := range []string{"a.example.com", "b.example.com", "a.example.com"} {
+		if _, err := cache.LookupHost(context.Background(), host); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if calls := atomic.LoadInt32(&resolver.calls); calls != 2 {
+		t.Errorf("got %d lookups, want 2", calls)
+	}
+	time.Sleep(2 * ttl)
+	if _, err := cache.LookupHost(context.Background(), "c.example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if len(cache.Entries) != 1 || cache.Entries["c.example.com"] == nil {
+		t.Errorf("expired entries are kept: %v", cache.Entries)
+	}
+}
@@ -0,0 +1,177 @@
+package collector
+
+import (
+	"net/url"
+	"strings"
+	"sync"
+)
+
+const (
+	DefaultMaxUrlLength = 2048
+	DefaultMaxPathDepth = 16
+	// Times a single path segment may occur in a path, /a/b/a/b/a/b/a is a trap
+	DefaultMaxSegmentRepeats = 3
+	// Distinct query strings allowed for the same path, faceted navigation multiplies them endlessly
+	DefaultMaxQueryVariants = 100
+)
+
+// Reasons of the suppressed urls
+const (
+	TrapUrlLength       = "url_length"
+	TrapPathDepth       = "path_depth"
+	TrapRepeatedSegment = "repeated_segment"
+	TrapQueryVariants   = "query_variants"
+	TrapHostBudget      = "host_budget"
+)
+
+type TrapDetectorInterface interface {
+	Check(u string) (string, bool)
+	SuppressedCounts() map[string]int
+}
+
+// TrapDetector keeps the urls which look like crawler traps out of the frontier. A limit of zero disables
+// the heuristic, every admitted url counts once against the limits of its path and every scraped url against
+// the page budget of its host.
+type TrapDetector struct {
+	MaxUrlLength      int
+	MaxPathDepth      int
+	MaxSegmentRepeats int
+	MaxQueryVariants  int
+	MaxPagesPerHost   int
+	Admitted          UrlSet
+	Variants          map[string]map[string]bool
+	// Pages scraped per host, counted by Spend
+	HostPages map[string]int
+	// Distinct suppressed urls and their number by reason, a url found again is not counted again
+	Rejected   UrlSet
+	Suppressed map[string]int
+	Mutex      sync.Mutex
+}
+
+func NewTrapDetector() *TrapDetector {
+	return &TrapDetector{
+		MaxUrlLength:      DefaultMaxUrlLength,
+		MaxPathDepth:      DefaultMaxPathDepth,
+		MaxSegmentRepeats: DefaultMaxSegmentRepeats,
+		MaxQueryVariants:  DefaultMaxQueryVariants,
+		Admitted:          MemoryUrlSet{},
+		Variants:          map[string]map[string]bool{},
+		HostPages:         map[string]int{},
+		Rejected:          MemoryUrlSet{},
+		Suppressed:        map[string]int{},
+	}
+}
+
+// Check admits the url into the frontier and returns empty, or returns the reason it is suppressed for and
+// whether it is suppressed for the first time. The limits only grow tighter so a suppressed url stays suppressed.
+func (t *TrapDetector) Check(u string) (string, bool) {
+	t.Mutex.Lock()
+	defer t.Mutex.Unlock()
+	if t.Admitted.Contains(u) {
+		return "", false
+	}
+	reason := t.reason(u)
+	if reason != "" {
+		if t.Rejected.Contains(u) {
+			return reason, false
+		}
+		t.Rejected.Add(u)
+		t.Suppressed[reason]++
+		return reason, true
+	}
+	parsed, err := url.Parse(u)
+	if err == nil {
+		if parsed.RawQuery != "" {
+			key := variantKey(parsed)
+			if t.Variants[key] == nil {
+				t.Variants[key] = map[string]bool{}
+			}
+			t.Variants[key][parsed.Query().Encode()] = true
+		}
+	}
+	t.Admitted.Add(u)
+	return "", false
+}
+
+func (t *TrapDetector) reason(u string) string {
+	if t.MaxUrlLength > 0 && len(u) > t.MaxUrlLength {
+		return TrapUrlLength
+	}
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return ""
+	}
+	segments := []string{}
+	for _, segment := range strings.Split(parsed.Path, "/") {
+		if segment != "" {
+			segments = append(segments, segment)
+		}
+	}
+	if t.MaxPathDepth > 0 && len(segments) > t.MaxPathDepth {
+		return TrapPathDepth
+	}
+	if t.MaxSegmentRepeats > 0 {
+		repeats := map[string]int{}
+		for _, segment := range segments {
+			repeats[segment]++
+			if repeats[segment] > t.MaxSegmentRepeats {
+				return TrapRepeatedSegment
+			}
+		}
+	}
+	if t.MaxQueryVariants > 0 && parsed.RawQuery != "" {
+		variants := t.Variants[variantKey(parsed)]
+		if len(variants) >= t.MaxQueryVariants && !variants[parsed.Query().Encode()] {
+			return TrapQueryVariants
+		}
+	}
+	if t.MaxPagesPerHost > 0 && t.HostPages[strings.ToLower(parsed.Host)] >= t.MaxPagesPerHost {
+		return TrapHostBudget
+	}
+	return ""
+}
+
+// Spend counts the url against the page budget of its host when it is about to be scraped and returns empty, or
+// returns the host budget reason and whether the url is suppressed for the first time when the budget is spent
+func (t *TrapDetector) Spend(u string) (string, bool) {
+	t.Mutex.Lock()
+	defer t.Mutex.Unlock()
+	host := HostOf(u)
+	if t.MaxPagesPerHost <= 0 || t.HostPages[host] < t.MaxPagesPerHost {
+		t.HostPages[host]++
+		return "", false
+	}
+	if t.Rejected.Contains(u) {
+		return TrapHostBudget, false
+	}
+	t.Rejected.Add(u)
+	t.Suppressed[TrapHostBudget]++
+	return TrapHostBudget, true
+}
+
+// Refund gives the page of a url spent but not scraped back to the budget of its host
+func (t *TrapDetector) Refund(u string) {
+	t.Mutex.Lock()
+	defer t.Mutex.Unlock()
+	host := HostOf(u)
+	if t.HostPages[host] > 0 {
+		t.HostPages[host]--
+	}
+}
+
+// SuppressedCounts returns a copy of the number of the suppressed urls by reason
+func (t *TrapDetector) SuppressedCounts() map[string]int {
+	t.Mutex.Lock()
+	defer t.Mutex.Unlock()
+	counts := make(map[string]int, len(t.Suppressed))
+	for reason, count := range t.Suppressed {
+		counts[reason] = count
+	}
+	return counts
+}
+
+// variantKey is the url without the query and the fragment, the query variants of a key are kept in their
+// encoded form which sorts the parameters so that reordering them does not make a new variant
+func variantKey(u *url.URL) string {
+	return strings.ToLower(u.Scheme) + "://" + strings.ToLower(u.Host) + u.Path
+}
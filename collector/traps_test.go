@@ -0,0 +1,164 @@
+package collector
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestTrapDetectorCountsSuppressedUrlsOnce(t *testing.T) {
+	traps := NewTrapDetector()
+	traps.MaxQueryVariants = 2
+	for i := 0; i < 2; i++ {
+		if reason, _ := traps.Check(fmt.Sprintf("https://example.com/search?q=%d", i)); reason != "" {
+			t.Fatalf("variant %d suppressed: %s", i, reason)
+		}
+	}
+	trap := "https://example.com/search?q=2"
+	for i := 0; i < 3; i++ {
+		reason, first := traps.Check(trap)
+		if reason != TrapQueryVariants {
+			t.Fatalf("check %d: got reason %q, want %q", i, reason, TrapQueryVariants)
+		}
+		if first != (i == 0) {
+			t.Errorf("check %d: got first %v", i, first)
+		}
+	}
+	if reason, first := traps.Check("https://example.com/search?q=3"); reason != TrapQueryVariants || !first {
+		t.Errorf("another variant: got %q %v", reason, first)
+	}
+	if reason, _ := traps.Check("https://example.com/search?q=0"); reason != "" {
+		t.Errorf("admitted url suppressed: %s", reason)
+	}
+	if got := traps.SuppressedCounts()[TrapQueryVariants]; got != 2 {
+		t.Errorf("got %d suppressed, want 2", got)
+	}
+}
+
+func TestTrapDetectorSuppressesCalendarPages(t *testing.T) {
+	traps := NewTrapDetector()
+	traps.MaxQueryVariants = 12
+	// A calendar links every month to the next one forever
+	suppressed := 0
+	for month := 0; month < 24; month++ {
+		u := fmt.Sprintf("https://example.com/calendar?year=%d&month=%d", 2020+month/12, month%12+1)
+		if reason, _ := traps.Check(u); reason == TrapQueryVariants {
+			suppressed++
+		} else if reason != "" {
+			t.Fatalf("month %d: got reason %q", month, reason)
+		}
+	}
+	if suppressed != 12 {
+		t.Errorf("got %d months suppressed, want 12", suppressed)
+	}
+	// The same parameters in another order are the same variant
+	if reason, _ := traps.Check("https://example.com/calendar?month=1&year=2020"); reason != "" {
+		t.Errorf("reordered parameters are suppressed: %s", reason)
+	}
+	// Calendars paging in the path run into the path depth
+	traps.MaxPathDepth = 4
+	if reason, _ := traps.Check("https://example.com/calendar/2021/03/04/day"); reason != TrapPathDepth {
+		t.Errorf("got reason %q of a deep calendar path", reason)
+	}
+}
+
+func TestTrapDetectorSuppressesSessionIds(t *testing.T) {
+	traps := NewTrapDetector()
+	traps.MaxQueryVariants = 3
+	reasons := []string{}
+	for i := 0; i < 5; i++ {
+		reason, _ := traps.Check(fmt.Sprintf("https://example.com/products?sessionid=%x", 1000+i))
+		reasons = append(reasons, reason)
+	}
+	want := []string{"", "", "", TrapQueryVariants, TrapQueryVariants}
+	if fmt.Sprint(reasons) != fmt.Sprint(want) {
+		t.Errorf("got reasons %q, want %q", reasons, want)
+	}
+	// Another path has variants of its own
+	if reason, _ := traps.Check("https://example.com/cart?sessionid=1"); reason != "" {
+		t.Errorf("another path is suppressed: %s", reason)
+	}
+}
+
+func TestTrapDetectorSuppressesRepeatingPaths(t *testing.T) {
+	traps := NewTrapDetector()
+	for u, want := range map[string]string{
+		"https://example.com/a/b/a/b/a/b":                                 "",
+		"https://example.com/a/b/a/b/a/b/a":                               TrapRepeatedSegment,
+		"https://example.com/docs/docs/docs/docs/index":                   TrapRepeatedSegment,
+		"https://example.com/" + strings.Repeat("x", DefaultMaxUrlLength): TrapUrlLength,
+	} {
+		if reason, _ := traps.Check(u); reason != want {
+			t.Errorf("%.60s: got reason %q, want %q", u, reason, want)
+		}
+	}
+}
+
+func TestTrapDetectorSpendsTheHostBudgetOnScrapedPages(t *testing.T) {
+	traps := NewTrapDetector()
+	traps.MaxPagesPerHost = 2
+	// Admitting the urls into the frontier does not spend the budget
+	for i := 0; i < 4; i++ {
+		if reason, _ := traps.Check(fmt.Sprintf("https://example.com/%d", i)); reason != "" {
+			t.Fatalf("url %d suppressed when admitted: %s", i, reason)
+		}
+	}
+	for i := 0; i < 2; i++ {
+		if reason, _ := traps.Spend(fmt.Sprintf("https://example.com/%d", i)); reason != "" {
+			t.Fatalf("url %d suppressed within the budget: %s", i, reason)
+		}
+	}
+	if reason, first := traps.Spend("https://example.com/2"); reason != TrapHostBudget || !first {
+		t.Errorf("got %q %v over the budget", reason, first)
+	}
+	if reason, first := traps.Spend("https://example.com/2"); reason != TrapHostBudget || first {
+		t.Errorf("got %q %v spending again", reason, first)
+	}
+	// Urls of a spent host are not admitted anymore, other hosts have budgets of their own
+	if reason, _ := traps.Check("https://example.com/new"); reason != TrapHostBudget {
+		t.Errorf("got reason %q of a new url of the spent host", reason)
+	}
+	if reason, _ := traps.Spend("https://other.example.com/"); reason != "" {
+		t.Errorf("other host suppressed: %s", reason)
+	}
+	// A refunded page can be spent again
+	traps.Refund("https://example.com/0")
+	if reason, _ := traps.Spend("https://example.com/3"); reason != "" {
+		t.Errorf("refunded budget is not spent: %s", reason)
+	}
+	if got := traps.SuppressedCounts()[TrapHostBudget]; got != 2 {
+		t.Errorf("got %d suppressed by the host budget, want 2", got)
+	}
+}
+
+func TestCollectorScrapesTheHostBudget(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, "<html><body>")
+		for i := 0; i < 10; i++ {
+			fmt.Fprintf(w, `<a href="/%d">page %d</a>`, i, i)
+		}
+		fmt.Fprint(w, "</body></html>")
+	}))
+	defer server.Close()
+	for _, priority := range []bool{false, true} {
+		loggers := discardLoggers()
+		c := &Collector{Seed: server.URL + "/", Depth: 3, Scrapper: NewScrapper(loggers), Loggers: loggers, Traps: NewTrapDetector()}
+		c.Scrapper.Transport = NewTransport(DefaultTransportOptions())
+		c.Traps.MaxPagesPerHost = 4
+		if priority {
+			c.EnablePriorityCrawl(nil, 0)
+		}
+		if _, err := c.StartCrawling(); err != nil {
+			t.Fatal(err)
+		}
+		if got := c.Scrapper.NumberOfPagesSucceed() + c.Scrapper.NumberOfPagesFailed(); got != 4 {
+			t.Errorf("priority %v: got %d pages scraped, want the budget of 4", priority, got)
+		}
+		if got := c.Traps.SuppressedCounts()[TrapHostBudget]; got != 7 {
+			t.Errorf("priority %v: got %d urls suppressed by the budget, want 7", priority, got)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package collector
 import (
 	"errors"
 	"github.com/microcosm-cc/bluemonday"
+	"io"
 	"net/url"
 	"regexp"
 	"strings"
@@ -25,12 +26,22 @@ func AbsoluteURL(pageURL string, href string) (string, error) {
 	if absURL.Scheme == "//" {
 		absURL.Scheme = baseURL.Scheme
 	}
-	if absURL.Scheme != "http" && absURL.Scheme != "https" {
+	// Pages read from the disk link to the files next to them
+	isFile := absURL.Scheme == "file" && baseURL.Scheme == "file"
+	if absURL.Scheme != "http" && absURL.Scheme != "https" && !isFile {
 		return "", errors.New("unknown scheme")
 	}
 	return absURL.String(), nil
 }
 
+func HostOf(pageURL string) string {
+	u, err := url.Parse(pageURL)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Host)
+}
+
 func TrimAndSanitize(s string) string {
 	p := bluemonday.StrictPolicy()
 	s = p.Sanitize(s)
@@ -53,3 +64,19 @@ func URLExists(urls []string, url string) bool {
 func CurrentTimestamp() int64 {
 	return time.Now().UTC().Unix()
 }
+
+func NormalizeSpaces(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// CountingReader counts the bytes read through it
+type CountingReader struct {
+	Reader io.Reader
+	Count  int64
+}
+
+func (r *CountingReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	r.Count += int64(n)
+	return n, err
+}
@@ -0,0 +1,388 @@
+package distributed
+
+import (
+	"context"
+	"crawler/collector"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// Urls of a lease which are not reported before it expires are handed out again
+	DefaultLeaseDuration = 2 * time.Minute
+	// Number of urls of a lease at most
+	DefaultLeaseSize = 10
+	// Minimum time between the requests of a lease and between the leases of the same host
+	DefaultHostDelay = time.Second
+	// Time the coordinator keeps serving after the crawl is done so that the workers learn it is done
+	DoneGracePeriod = 5 * time.Second
+)
+
+// Lease is a batch of urls of a single host handed out to a worker, a host is leased to one worker at a
+// time which keeps the crawl polite per host
+type Lease struct {
+	Id       string
+	WorkerId string
+	Host     string
+	Urls     map[string]int
+	Expires  time.Time
+}
+
+// Coordinator owns the frontier and the visited set of a distributed crawl. The workers lease the urls over
+// http, scrape them and report the pages back, whose links are queued by the rules of the collector.
+type Coordinator struct {
+	Collector     *collector.Collector
+	LeaseDuration time.Duration
+	LeaseSize     int
+	HostDelay     time.Duration
+	// Pending urls of every host in the order they are discovered
+	Queues map[string][]string
+	// Depths of every url ever queued, which is the visited set of the frontier
+	Depths map[string]int
+	Leases map[string]*Lease
+	// Expired leases with their urls which are not reported yet, their workers may still report them until the
+	// urls are all scraped through the leases handed out again
+	Expired       map[string]*Lease
+	LeasedHosts   map[string]string
+	HostReady     map[string]time.Time
+	Workers       map[string]time.Time
+	ExpiredLeases int
+	Done          chan struct{}
+	done          bool
+	sequence      int
+	Mutex         sync.Mutex
+}
+
+// NewCoordinator creates the coordinator of the crawl of the collector with its seeds queued
+func NewCoordinator(c *collector.Collector) *Coordinator {
+	coordinator := &Coordinator{
+		Collector:     c,
+		LeaseDuration: DefaultLeaseDuration,
+		LeaseSize:     DefaultLeaseSize,
+		HostDelay:     DefaultHostDelay,
+		Queues:        map[string][]string{},
+		Depths:        map[string]int{},
+		Leases:        map[string]*Lease{},
+		Expired:       map[string]*Lease{},
+		LeasedHosts:   map[string]string{},
+		HostReady:     map[string]time.Time{},
+		Workers:       map[string]time.Time{},
+		Done:          make(chan struct{}),
+	}
+	c.Begin = time.Now()
+	for _, seed := range c.AdmitUrls(append([]string{c.Seed}, c.AdditionalSeeds...)) {
+		coordinator.enqueue(seed, 0)
+	}
+	return coordinator
+}
+
+func (c *Coordinator) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(RegisterPath, c.HandleRegister)
+	mux.HandleFunc(LeasePath, c.HandleLease)
+	mux.HandleFunc(ReportPath, c.HandleReport)
+	mux.HandleFunc(StatusPath, c.HandleStatus)
+	return mux
+}
+
+// ListenAndServe serves the workers until the crawl is done and the grace period is over
+func (c *Coordinator) ListenAndServe(addr string) error {
+	server := &http.Server{Addr: addr, Handler: c.Handler()}
+	errs := make(chan error, 1)
+	go func() {
+		errs <- server.ListenAndServe()
+	}()
+	select {
+	case err := <-errs:
+		return err
+	case <-c.Done:
+	}
+	time.Sleep(DoneGracePeriod)
+	return server.Shutdown(context.Background())
+}
+
+func (c *Coordinator) HandleRegister(w http.ResponseWriter, r *http.Request) {
+	var request struct{}
+	if err := readJSON(r, &request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	c.Mutex.Lock()
+	c.sequence++
+	id := fmt.Sprintf("worker-%d", c.sequence)
+	c.Workers[id] = time.Now()
+	c.Mutex.Unlock()
+	c.Collector.Loggers.Log(collector.INFO, fmt.Sprintf("Worker registered: %s\n", id))
+	writeJSON(w, RegisterResponse{WorkerId: id})
+}
+
+func (c *Coordinator) HandleLease(w http.ResponseWriter, r *http.Request) {
+	var request LeaseRequest
+	if err := readJSON(r, &request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, c.Lease(request.WorkerId, request.MaxUrls))
+}
+
+func (c *Coordinator) HandleReport(w http.ResponseWriter, r *http.Request) {
+	var request ReportRequest
+	if err := readJSON(r, &request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := c.Report(request.WorkerId, request.LeaseId, request.Results); err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	writeJSON(w, struct{}{})
+}
+
+func (c *Coordinator) HandleStatus(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, c.Status())
+}
+
+// Lease hands out the pending urls of a host which is neither leased nor waiting for its delay
+func (c *Coordinator) Lease(workerId string, maxUrls int) LeaseResponse {
+	c.Mutex.Lock()
+	defer c.Mutex.Unlock()
+	now := time.Now()
+	c.Workers[workerId] = now
+	c.expireLeases(now)
+	if c.done {
+		return LeaseResponse{Urls: []LeasedUrl{}, Done: true}
+	}
+	if maxUrls <= 0 || maxUrls > c.LeaseSize {
+		maxUrls = c.LeaseSize
+	}
+	if budget := c.remainingBudget(); budget >= 0 && budget < maxUrls {
+		maxUrls = budget
+	}
+	if maxUrls == 0 {
+		return LeaseResponse{Urls: []LeasedUrl{}}
+	}
+	for _, host := range c.sortedHosts() {
+		if _, leased := c.LeasedHosts[host]; leased || now.Before(c.HostReady[host]) {
+			continue
+		}
+		lease := &Lease{WorkerId: workerId, Host: host, Urls: map[string]int{}, Expires: now.Add(c.LeaseDuration)}
+		urls := []LeasedUrl{}
+		queue := c.Queues[host]
+		for len(queue) > 0 && len(urls) < maxUrls {
+			u := queue[0]
+			queue = queue[1:]
+			if c.Collector.Scrapper.IsVisited(u) || c.Collector.Scrapper.IsFailed(u) {
+				continue
+			}
+			if !c.Collector.SpendHostBudget(u) {
+				continue
+			}
+			lease.Urls[u] = c.Depths[u]
+			urls = append(urls, LeasedUrl{Url: u, Depth: c.Depths[u]})
+		}
+		c.setQueue(host, queue)
+		if len(urls) == 0 {
+			continue
+		}
+		c.sequence++
+		lease.Id = fmt.Sprintf("lease-%d", c.sequence)
+		c.Leases[lease.Id] = lease
+		c.LeasedHosts[host] = lease.Id
+		return LeaseResponse{LeaseId: lease.Id, Urls: urls, Expires: lease.Expires, HostDelay: c.HostDelay}
+	}
+	c.checkDone()
+	return LeaseResponse{Urls: []LeasedUrl{}, Done: c.done}
+}
+
+// Report records the scraped pages of the lease and queues their links, the results of the urls which are not
+// leased to the worker are rejected. Results of an expired lease are still accepted for the urls which are not
+// scraped by another worker in the meantime.
+func (c *Coordinator) Report(workerId string, leaseId string, results []UrlResult) error {
+	c.Mutex.Lock()
+	defer c.Mutex.Unlock()
+	c.Workers[workerId] = time.Now()
+	lease, active := c.Leases[leaseId]
+	if !active {
+		lease = c.Expired[leaseId]
+	}
+	if lease == nil || lease.WorkerId != workerId {
+		c.Collector.Loggers.Log(collector.WARNING, fmt.Sprintf("Report rejected: %s of worker: %s is not leased\n", leaseId, workerId))
+		return errors.New(fmt.Sprintf("lease %s is not leased to worker %s", leaseId, workerId))
+	}
+	for _, result := range results {
+		if _, leased := lease.Urls[result.Url]; !leased {
+			c.Collector.Loggers.Log(collector.WARNING, fmt.Sprintf("Result rejected: %s is not leased by: %s\n", result.Url, leaseId))
+			continue
+		}
+		delete(lease.Urls, result.Url)
+		depth := c.Depths[result.Url]
+		scrapper := c.Collector.Scrapper
+		if scrapper.IsVisited(result.Url) || scrapper.IsFailed(result.Url) {
+			continue
+		}
+		switch {
+		case result.Page != nil:
+			scrapper.ScrapeSucceed(result.Url, result.Page)
+			if depth < c.Collector.Depth-1 {
+				for _, u := range c.Collector.LinksToFollow(result.Page) {
+					c.enqueue(u, depth+1)
+				}
+			}
+		case result.Failed != nil:
+			scrapper.ScrapeFailed(result.Url, result.Failed)
+		default:
+			scrapper.ScrapeFailed(result.Url, &collector.FailedPage{
+				Url:        result.Url,
+				FailReason: "worker reported no result",
+				Timestamp:  collector.CurrentTimestamp(),
+			})
+		}
+	}
+	if len(lease.Urls) == 0 {
+		if active {
+			c.releaseLease(lease, time.Now())
+		} else {
+			delete(c.Expired, lease.Id)
+		}
+	}
+	c.checkDone()
+	return nil
+}
+
+func (c *Coordinator) Status() StatusResponse {
+	c.Mutex.Lock()
+	defer c.Mutex.Unlock()
+	c.expireLeases(time.Now())
+	status := StatusResponse{
+		Seed:           c.Collector.Seed,
+		Workers:        len(c.Workers),
+		SucceededPages: c.Collector.Scrapper.NumberOfPagesSucceed(),
+		FailedPages:    c.Collector.Scrapper.NumberOfPagesFailed(),
+		ExpiredLeases:  c.ExpiredLeases,
+		Done:           c.done,
+	}
+	for _, queue := range c.Queues {
+		status.QueuedUrls += len(queue)
+	}
+	for _, lease := range c.Leases {
+		status.LeasedUrls += len(lease.Urls)
+	}
+	return status
+}
+
+func (c *Coordinator) enqueue(u string, depth int) {
+	if _, known := c.Depths[u]; known {
+		return
+	}
+	c.Depths[u] = depth
+	host := collector.HostOf(u)
+	c.Queues[host] = append(c.Queues[host], u)
+}
+
+func (c *Coordinator) setQueue(host string, queue []string) {
+	if len(queue) == 0 {
+		delete(c.Queues, host)
+		return
+	}
+	c.Queues[host] = queue
+}
+
+// expireLeases puts the urls of the expired leases back to the front of their host queues
+func (c *Coordinator) expireLeases(now time.Time) {
+	for _, lease := range c.Leases {
+		if now.Before(lease.Expires) {
+			continue
+		}
+		urls := make([]string, 0, len(lease.Urls))
+		for u := range lease.Urls {
+			urls = append(urls, u)
+			// The urls are spent again when they are leased again
+			if c.Collector.Traps != nil {
+				c.Collector.Traps.Refund(u)
+			}
+		}
+		sort.Strings(urls)
+		c.setQueue(lease.Host, append(urls, c.Queues[lease.Host]...))
+		c.ExpiredLeases++
+		if len(lease.Urls) > 0 {
+			c.Expired[lease.Id] = lease
+		}
+		c.Collector.Loggers.Log(collector.WARNING, fmt.Sprintf("Lease expired: %s of worker: %s with %d urls\n",
+			lease.Id, lease.WorkerId, len(urls)))
+		c.releaseLease(lease, now)
+	}
+	c.pruneExpired()
+}
+
+// pruneExpired drops the expired leases whose urls are all scraped through another lease, so that the leases of
+// the workers which died are not kept until the end of the crawl
+func (c *Coordinator) pruneExpired() {
+	scrapper := c.Collector.Scrapper
+	for id, lease := range c.Expired {
+		pending := false
+		for u := range lease.Urls {
+			if !scrapper.IsVisited(u) && !scrapper.IsFailed(u) {
+				pending = true
+				break
+			}
+		}
+		if !pending {
+			delete(c.Expired, id)
+		}
+	}
+}
+
+func (c *Coordinator) releaseLease(lease *Lease, now time.Time) {
+	delete(c.Leases, lease.Id)
+	if c.LeasedHosts[lease.Host] == lease.Id {
+		delete(c.LeasedHosts, lease.Host)
+		c.HostReady[lease.Host] = now.Add(c.HostDelay)
+	}
+}
+
+// remainingBudget returns the number of the pages left to lease in the page budget, or -1 without a budget
+func (c *Coordinator) remainingBudget() int {
+	if c.Collector.MaxPages <= 0 {
+		return -1
+	}
+	remaining := c.Collector.MaxPages - c.Collector.Scrapper.NumberOfPagesSucceed()
+	for _, lease := range c.Leases {
+		remaining -= len(lease.Urls)
+	}
+	if remaining < 0 {
+		return 0
+	}
+	return remaining
+}
+
+// checkDone finishes the crawl when nothing is queued or leased, or the page budget is spent
+func (c *Coordinator) checkDone() {
+	if c.done || len(c.Leases) > 0 {
+		return
+	}
+	budgetSpent := c.Collector.MaxPages > 0 && c.Collector.Scrapper.NumberOfPagesSucceed() >= c.Collector.MaxPages
+	if len(c.Queues) > 0 && !budgetSpent {
+		return
+	}
+	c.done = true
+	c.Collector.End = time.Now()
+	if c.Collector.SaveToFile {
+		_, _ = c.Collector.SaveResultsToFile()
+	}
+	c.Collector.Loggers.Log(collector.INFO, fmt.Sprintf("Distributed crawl finished %d pages scrapped\n",
+		c.Collector.Scrapper.NumberOfPagesSucceed()))
+	close(c.Done)
+}
+
+func (c *Coordinator) sortedHosts() []string {
+	hosts := make([]string, 0, len(c.Queues))
+	for host := range c.Queues {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+	return hosts
+}
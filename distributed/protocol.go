@@ -0,0 +1,122 @@
+package distributed
+
+import (
+	"bytes"
+	"crawler/collector"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+// Paths of the coordinator api, every request and response body is json
+const (
+	RegisterPath = "/register"
+	LeasePath    = "/lease"
+	ReportPath   = "/report"
+	StatusPath   = "/status"
+)
+
+type RegisterResponse struct {
+	WorkerId string `json:"worker_id"`
+}
+
+type LeaseRequest struct {
+	WorkerId string `json:"worker_id"`
+	MaxUrls  int    `json:"max_urls"`
+}
+
+type LeasedUrl struct {
+	Url   string `json:"url"`
+	Depth int    `json:"depth"`
+}
+
+// LeaseResponse holds the urls the worker should scrape before the lease expires, an empty lease means there
+// is nothing to scrape for now and done means the crawl is finished
+type LeaseResponse struct {
+	LeaseId string      `json:"lease_id"`
+	Urls    []LeasedUrl `json:"urls"`
+	Expires time.Time   `json:"expires"`
+	// Time the worker waits between the requests of the lease, which are all of the same host
+	HostDelay time.Duration `json:"host_delay,omitempty"`
+	Done      bool          `json:"done"`
+}
+
+type UrlResult struct {
+	Url    string                   `json:"url"`
+	Page   *collector.SucceededPage `json:"page,omitempty"`
+	Failed *collector.FailedPage    `json:"failed,omitempty"`
+}
+
+type ReportRequest struct {
+	WorkerId string      `json:"worker_id"`
+	LeaseId  string      `json:"lease_id"`
+	Results  []UrlResult `json:"results"`
+}
+
+type StatusResponse struct {
+	Seed           string `json:"seed"`
+	Workers        int    `json:"workers"`
+	QueuedUrls     int    `json:"queued_urls"`
+	LeasedUrls     int    `json:"leased_urls"`
+	SucceededPages int    `json:"succeeded_pages"`
+	FailedPages    int    `json:"failed_pages"`
+	ExpiredLeases  int    `json:"expired_leases"`
+	Done           bool   `json:"done"`
+}
+
+// StatusError is the error of a request the coordinator responded to with another status code than 200
+type StatusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("coordinator responded with status code: %d %s", e.StatusCode, e.Body)
+}
+
+// Temporary tells whether the request may succeed when it is sent again, the coordinator rejects the
+// requests which are not valid with a client error status code
+func (e *StatusError) Temporary() bool {
+	return e.StatusCode >= http.StatusInternalServerError
+}
+
+// postJSON sends the request as json and decodes the response into the given value unless it is nil
+func postJSON(client *http.Client, url string, request interface{}, response interface{}) error {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	bytes, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return &StatusError{StatusCode: resp.StatusCode, Body: string(bytes)}
+	}
+	if response == nil {
+		return nil
+	}
+	return json.Unmarshal(bytes, response)
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func readJSON(r *http.Request, v interface{}) error {
+	if r.Method != http.MethodPost {
+		return errors.New("method should be POST")
+	}
+	return json.NewDecoder(r.Body).Decode(v)
+}
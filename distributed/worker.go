@@ -0,0 +1,206 @@
+package distributed
+
+import (
+	"crawler/collector"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// Time the worker waits before asking for a lease again when there is nothing to scrape
+	DefaultPollInterval = time.Second
+	// Number of times a failed request to the coordinator is sent again before the worker gives up
+	DefaultMaxRetries = 5
+	// Delay before the first retry of a request, the delay doubles with every retry
+	DefaultRetryDelay = 500 * time.Millisecond
+)
+
+// Worker leases urls from the coordinator, scrapes them with its scrapper and reports the pages back. A lease
+// holds the urls of a single host which are scraped one after another, the worker scrapes up to its concurrency
+// leases of different hosts at the same time.
+type Worker struct {
+	CoordinatorUrl string
+	Id             string
+	Scrapper       *collector.Scrapper
+	Concurrency    int
+	PollInterval   time.Duration
+	MaxRetries     int
+	RetryDelay     time.Duration
+	Client         *http.Client
+	Loggers        *collector.Loggers
+}
+
+func NewWorker(coordinatorUrl string) (*Worker, error) {
+	if coordinatorUrl == "" {
+		return nil, errors.New("coordinator url should not be empty")
+	}
+	loggers, err := collector.CreateLoggers(collector.LogFile)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("loggers could not be created: %s", err.Error()))
+	}
+	return newWorker(coordinatorUrl, collector.NewScrapper(loggers), loggers), nil
+}
+
+// NewCollectorWorker creates a worker scraping with the scrapper of the collector, so that the workers of a crawl
+// created from the config of the coordinator share its transport, login and extraction options
+func NewCollectorWorker(coordinatorUrl string, c *collector.Collector) (*Worker, error) {
+	if coordinatorUrl == "" {
+		return nil, errors.New("coordinator url should not be empty")
+	}
+	return newWorker(coordinatorUrl, c.Scrapper, c.Loggers), nil
+}
+
+func newWorker(coordinatorUrl string, scrapper *collector.Scrapper, loggers *collector.Loggers) *Worker {
+	return &Worker{
+		CoordinatorUrl: strings.TrimSuffix(coordinatorUrl, "/"),
+		Scrapper:       scrapper,
+		Concurrency:    collector.DefaultConcurrency,
+		PollInterval:   DefaultPollInterval,
+		MaxRetries:     DefaultMaxRetries,
+		RetryDelay:     DefaultRetryDelay,
+		Client:         &http.Client{Timeout: 30 * time.Second},
+		Loggers:        loggers,
+	}
+}
+
+func (w *Worker) Register() error {
+	var response RegisterResponse
+	if err := postJSON(w.Client, w.CoordinatorUrl+RegisterPath, struct{}{}, &response); err != nil {
+		return errors.New(fmt.Sprintf("worker could not register: %s", err.Error()))
+	}
+	w.Id = response.WorkerId
+	w.Loggers.Log(collector.INFO, fmt.Sprintf("Worker registered as: %s\n", w.Id))
+	return nil
+}
+
+// Run scrapes the leased urls until the crawl is done or the stop channel is closed, the first error of the
+// leases stops the worker. The worker logs in first when its scrapper keeps a login session.
+func (w *Worker) Run(stop <-chan struct{}) error {
+	if w.Id == "" {
+		if err := w.Register(); err != nil {
+			return err
+		}
+	}
+	if session := w.Scrapper.Transport.Session; session != nil {
+		if err := session.Login(collector.NewRequestWithTransport(w.Scrapper.Timeout, w.Scrapper.Transport)); err != nil {
+			return errors.New(fmt.Sprintf("worker could not log in: %s", err.Error()))
+		}
+		w.Loggers.Log(collector.INFO, fmt.Sprintf("Worker %s logged in with: %s\n", w.Id, session.Form.Url))
+	}
+	concurrency := w.Concurrency
+	if concurrency <= 0 {
+		concurrency = collector.DefaultConcurrency
+	}
+	quit := make(chan struct{})
+	var once sync.Once
+	var runError error
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := w.runLeases(stop, quit)
+			once.Do(func() {
+				runError = err
+				close(quit)
+			})
+		}()
+	}
+	wg.Wait()
+	return runError
+}
+
+// runLeases leases and scrapes the urls of one host after another until the crawl is done, the stop channel is
+// closed or another loop of the worker quits
+func (w *Worker) runLeases(stop <-chan struct{}, quit <-chan struct{}) error {
+	for {
+		select {
+		case <-stop:
+			return nil
+		case <-quit:
+			return nil
+		default:
+		}
+		var lease LeaseResponse
+		request := LeaseRequest{WorkerId: w.Id, MaxUrls: DefaultLeaseSize}
+		if stopped, err := w.post(stop, quit, LeasePath, request, &lease); stopped {
+			return nil
+		} else if err != nil {
+			return errors.New(fmt.Sprintf("lease request failed: %s", err.Error()))
+		}
+		if lease.Done {
+			w.Loggers.Log(collector.INFO, fmt.Sprintf("Worker %s finished, the crawl is done\n", w.Id))
+			return nil
+		}
+		if len(lease.Urls) == 0 {
+			select {
+			case <-stop:
+				return nil
+			case <-quit:
+				return nil
+			case <-time.After(w.PollInterval):
+			}
+			continue
+		}
+		report := ReportRequest{WorkerId: w.Id, LeaseId: lease.LeaseId, Results: w.ScrapeUrls(lease.Urls, lease.HostDelay)}
+		if stopped, err := w.post(stop, quit, ReportPath, report, nil); stopped {
+			return nil
+		} else if err != nil {
+			return errors.New(fmt.Sprintf("report failed: %s", err.Error()))
+		}
+	}
+}
+
+// post sends the request to the path of the coordinator, the requests which fail to reach the coordinator or
+// fail on its side are sent again after a delay doubling with every retry. Stopped is true when the stop channel
+// is closed or another loop of the worker quits while the worker waits to retry.
+func (w *Worker) post(stop <-chan struct{}, quit <-chan struct{}, path string, request interface{}, response interface{}) (stopped bool, err error) {
+	delay := w.RetryDelay
+	for retry := 0; ; retry++ {
+		err = postJSON(w.Client, w.CoordinatorUrl+path, request, response)
+		if statusError, ok := err.(*StatusError); err == nil || retry >= w.MaxRetries || ok && !statusError.Temporary() {
+			return false, err
+		}
+		w.Loggers.Log(collector.WARNING, fmt.Sprintf("Request %s of worker %s failed, retrying in %s: %s\n", path, w.Id, delay, err.Error()))
+		select {
+		case <-stop:
+			return true, nil
+		case <-quit:
+			return true, nil
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+// ScrapeUrls scrapes the urls of a lease one after another waiting the host delay between them, the urls of a
+// lease are of the same host so that the host is never requested concurrently
+func (w *Worker) ScrapeUrls(urls []LeasedUrl, hostDelay time.Duration) []UrlResult {
+	results := make([]UrlResult, 0, len(urls))
+	for i, leased := range urls {
+		if i > 0 && hostDelay > 0 {
+			time.Sleep(hostDelay)
+		}
+		var wg sync.WaitGroup
+		channel := make(chan collector.ScrapeResult, 1)
+		wg.Add(1)
+		w.Scrapper.Scrape(leased.Url, channel, &wg)
+		if scrapeResult := <-channel; scrapeResult.Error != nil {
+			w.Loggers.Log(collector.ERROR, fmt.Sprintf("Scrape error: %s\n", scrapeResult.Error.Error()))
+		}
+		page, failed := w.Scrapper.TakeResult(leased.Url)
+		if page == nil && failed == nil {
+			failed = &collector.FailedPage{
+				Url:        leased.Url,
+				FailReason: "page could not be scraped",
+				Timestamp:  collector.CurrentTimestamp(),
+			}
+		}
+		results = append(results, UrlResult{Url: leased.Url, Page: page, Failed: failed})
+	}
+	return results
+}
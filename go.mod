@@ -4,13 +4,14 @@ go 1.17
 
 require (
 	github.com/PuerkitoBio/goquery v1.7.1
+	github.com/andybalholm/cascadia v1.2.0
 	github.com/kljensen/snowball v0.6.0
 	github.com/microcosm-cc/bluemonday v1.0.15
+	golang.org/x/net v0.0.0-20210614182718-04defd469f4e
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
-	github.com/andybalholm/cascadia v1.2.0 // indirect
 	github.com/aymerick/douceur v0.2.0 // indirect
 	github.com/gorilla/css v1.0.0 // indirect
-	golang.org/x/net v0.0.0-20210614182718-04defd469f4e // indirect
 )
@@ -0,0 +1,147 @@
+package graph
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+type ExporterInterface interface {
+	WriteGraphML(w io.Writer) error
+	WriteDOT(w io.Writer) error
+	WriteCSV(w io.Writer) error
+}
+
+// WriteGraphML writes the graph with the node attributes in GraphML format
+func (g *LinkGraph) WriteGraphML(w io.Writer) error {
+	b := bufio.NewWriter(w)
+	b.WriteString(xml.Header)
+	b.WriteString(`<graphml xmlns="http://graphml.graphdrawing.org/xmlns">` + "\n")
+	keys := [][]string{
+		{"url", "string"},
+		{"title", "string"},
+		{"status", "string"},
+		{"in_degree", "int"},
+		{"out_degree", "int"},
+		{"depth", "int"},
+		{"component", "int"},
+	}
+	for _, key := range keys {
+		fmt.Fprintf(b, `  <key id="%s" for="node" attr.name="%s" attr.type="%s"/>`+"\n", key[0], key[0], key[1])
+	}
+	b.WriteString(`  <graph id="crawl" edgedefault="directed">` + "\n")
+	ids := map[string]string{}
+	for idx, url := range g.SortedUrls() {
+		node := g.Nodes[url]
+		id := "n" + strconv.Itoa(idx)
+		ids[url] = id
+		fmt.Fprintf(b, `    <node id="%s">`+"\n", id)
+		values := []string{
+			node.Url,
+			node.Title,
+			node.Status,
+			strconv.Itoa(node.InDegree),
+			strconv.Itoa(node.OutDegree),
+			strconv.Itoa(node.Depth),
+			strconv.Itoa(node.Component),
+		}
+		for i, value := range values {
+			fmt.Fprintf(b, `      <data key="%s">%s</data>`+"\n", keys[i][0], escapeXML(value))
+		}
+		b.WriteString("    </node>\n")
+	}
+	for idx, edge := range g.Edges {
+		fmt.Fprintf(b, `    <edge id="e%d" source="%s" target="%s"/>`+"\n", idx, ids[edge.From], ids[edge.To])
+	}
+	b.WriteString("  </graph>\n</graphml>\n")
+	return b.Flush()
+}
+
+// WriteDOT writes the graph in Graphviz DOT format, the failed and unvisited nodes are styled apart
+func (g *LinkGraph) WriteDOT(w io.Writer) error {
+	b := bufio.NewWriter(w)
+	b.WriteString("digraph crawl {\n")
+	b.WriteString("  node [shape=box];\n")
+	for _, url := range g.SortedUrls() {
+		node := g.Nodes[url]
+		label := node.Title
+		if label == "" {
+			label = node.Url
+		}
+		attrs := fmt.Sprintf("label=%s, tooltip=%s", quoteDOT(label), quoteDOT(node.Url))
+		switch node.Status {
+		case StatusFailed:
+			attrs += ", color=red"
+		case StatusUnvisited:
+			attrs += ", style=dashed"
+		}
+		if url == g.Seed {
+			attrs += ", peripheries=2"
+		}
+		fmt.Fprintf(b, "  %s [%s];\n", quoteDOT(url), attrs)
+	}
+	for _, edge := range g.Edges {
+		fmt.Fprintf(b, "  %s -> %s;\n", quoteDOT(edge.From), quoteDOT(edge.To))
+	}
+	b.WriteString("}\n")
+	return b.Flush()
+}
+
+// WriteCSV writes the edge list with a source,target header
+func (g *LinkGraph) WriteCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write([]string{"source", "target"}); err != nil {
+		return err
+	}
+	for _, edge := range g.Edges {
+		if err := writer.Write([]string{edge.From, edge.To}); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// WriteNodesCSV writes the nodes with their metrics, it complements the edge list for the tools
+// importing the nodes and the edges separately
+func (g *LinkGraph) WriteNodesCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	header := []string{"url", "title", "status", "in_degree", "out_degree", "depth", "component"}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+	for _, url := range g.SortedUrls() {
+		node := g.Nodes[url]
+		err := writer.Write([]string{
+			node.Url,
+			node.Title,
+			node.Status,
+			strconv.Itoa(node.InDegree),
+			strconv.Itoa(node.OutDegree),
+			strconv.Itoa(node.Depth),
+			strconv.Itoa(node.Component),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func escapeXML(s string) string {
+	var b strings.Builder
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+func quoteDOT(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	s = strings.ReplaceAll(s, "\n", " ")
+	return `"` + s + `"`
+}
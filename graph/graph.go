@@ -0,0 +1,256 @@
+package graph
+
+import (
+	"crawler/collector"
+	"sort"
+)
+
+const (
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+	StatusUnvisited = "unvisited"
+)
+
+type LinkGraphInterface interface {
+	AddEdge(from string, to string)
+	ComputeDepths()
+	ComputeComponents() int
+	Orphans() []string
+	Stats() GraphStats
+}
+
+type Node struct {
+	Url       string `json:"url"`
+	Title     string `json:"title"`
+	Status    string `json:"status"`
+	InDegree  int    `json:"in_degree"`
+	OutDegree int    `json:"out_degree"`
+	// Shortest number of clicks from the seed, -1 when it is not reachable
+	Depth     int `json:"depth"`
+	Component int `json:"component"`
+}
+
+type Edge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+type GraphStats struct {
+	Nodes           int     `json:"nodes"`
+	Edges           int     `json:"edges"`
+	Components      int     `json:"components"`
+	Orphans         int     `json:"orphans"`
+	Unreachable     int     `json:"unreachable"`
+	MaxDepth        int     `json:"max_depth"`
+	AverageDegree   float64 `json:"average_degree"`
+	MaxInDegreeUrl  string  `json:"max_in_degree_url"`
+	MaxOutDegreeUrl string  `json:"max_out_degree_url"`
+}
+
+// LinkGraph is the directed graph of the links between the pages of a crawl. Linked urls which are not
+// crawled are part of the graph as unvisited nodes.
+type LinkGraph struct {
+	Seed     string
+	Nodes    map[string]*Node
+	Edges    []Edge
+	Outbound map[string][]string
+	Inbound  map[string][]string
+	edgeSet  map[Edge]bool
+}
+
+func NewLinkGraph(seed string) *LinkGraph {
+	return &LinkGraph{
+		Seed:     seed,
+		Nodes:    map[string]*Node{},
+		Edges:    []Edge{},
+		Outbound: map[string][]string{},
+		Inbound:  map[string][]string{},
+		edgeSet:  map[Edge]bool{},
+	}
+}
+
+// BuildLinkGraph creates the graph of a crawl result with the depths and components computed
+func BuildLinkGraph(data *collector.ResultData) *LinkGraph {
+	g := NewLinkGraph(data.Seed)
+	for url := range data.Failed {
+		g.Node(url).Status = StatusFailed
+	}
+	for url, page := range data.Succeed {
+		node := g.Node(url)
+		node.Status = StatusSucceeded
+		node.Title = page.Title
+	}
+	for _, url := range sortedKeys(data.Succeed) {
+		for _, link := range data.Succeed[url].Urls {
+			g.AddEdge(url, link)
+		}
+	}
+	g.ComputeDepths()
+	g.ComputeComponents()
+	return g
+}
+
+// LoadLinkGraph builds the graph of a results file saved by a collector
+func LoadLinkGraph(path string) (*LinkGraph, error) {
+	data, err := collector.LoadResultData(path)
+	if err != nil {
+		return nil, err
+	}
+	return BuildLinkGraph(data), nil
+}
+
+// Node returns the node of the url, creating it as unvisited if it does not exist
+func (g *LinkGraph) Node(url string) *Node {
+	node, exists := g.Nodes[url]
+	if !exists {
+		node = &Node{Url: url, Status: StatusUnvisited, Depth: -1, Component: -1}
+		g.Nodes[url] = node
+	}
+	return node
+}
+
+// AddEdge adds a link between two pages, self links and duplicated links are ignored
+func (g *LinkGraph) AddEdge(from string, to string) {
+	edge := Edge{From: from, To: to}
+	if from == to || g.edgeSet[edge] {
+		return
+	}
+	g.edgeSet[edge] = true
+	g.Node(from).OutDegree++
+	g.Node(to).InDegree++
+	g.Outbound[from] = append(g.Outbound[from], to)
+	g.Inbound[to] = append(g.Inbound[to], from)
+	g.Edges = append(g.Edges, edge)
+}
+
+// SortedUrls returns the urls of the nodes in lexical order
+func (g *LinkGraph) SortedUrls() []string {
+	urls := make([]string, 0, len(g.Nodes))
+	for url := range g.Nodes {
+		urls = append(urls, url)
+	}
+	sort.Strings(urls)
+	return urls
+}
+
+// ComputeDepths sets the shortest click depth of every node from the seed with a breadth first search
+func (g *LinkGraph) ComputeDepths() {
+	for _, node := range g.Nodes {
+		node.Depth = -1
+	}
+	seed, exists := g.Nodes[g.Seed]
+	if !exists {
+		return
+	}
+	seed.Depth = 0
+	queue := []string{g.Seed}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for _, next := range g.Outbound[current] {
+			node := g.Nodes[next]
+			if node.Depth < 0 {
+				node.Depth = g.Nodes[current].Depth + 1
+				queue = append(queue, next)
+			}
+		}
+	}
+}
+
+// ComputeComponents labels the weakly connected components of the graph and returns their number,
+// components are numbered in the lexical order of their smallest url
+func (g *LinkGraph) ComputeComponents() int {
+	for _, node := range g.Nodes {
+		node.Component = -1
+	}
+	count := 0
+	for _, url := range g.SortedUrls() {
+		if g.Nodes[url].Component >= 0 {
+			continue
+		}
+		stack := []string{url}
+		g.Nodes[url].Component = count
+		for len(stack) > 0 {
+			current := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			neighbours := append(append([]string{}, g.Outbound[current]...), g.Inbound[current]...)
+			for _, next := range neighbours {
+				if g.Nodes[next].Component < 0 {
+					g.Nodes[next].Component = count
+					stack = append(stack, next)
+				}
+			}
+		}
+		count++
+	}
+	return count
+}
+
+// Components returns the urls of each component ordered by the component number
+func (g *LinkGraph) Components() [][]string {
+	components := [][]string{}
+	for _, url := range g.SortedUrls() {
+		component := g.Nodes[url].Component
+		for len(components) <= component {
+			components = append(components, []string{})
+		}
+		if component >= 0 {
+			components[component] = append(components[component], url)
+		}
+	}
+	return components
+}
+
+// Orphans returns the crawled pages without any inbound link, the seed is not an orphan
+func (g *LinkGraph) Orphans() []string {
+	orphans := []string{}
+	for _, url := range g.SortedUrls() {
+		node := g.Nodes[url]
+		if node.Status == StatusSucceeded && node.InDegree == 0 && url != g.Seed {
+			orphans = append(orphans, url)
+		}
+	}
+	return orphans
+}
+
+func (g *LinkGraph) Stats() GraphStats {
+	stats := GraphStats{
+		Nodes:   len(g.Nodes),
+		Edges:   len(g.Edges),
+		Orphans: len(g.Orphans()),
+	}
+	components := map[int]bool{}
+	var maxIn, maxOut *Node
+	for _, url := range g.SortedUrls() {
+		node := g.Nodes[url]
+		components[node.Component] = true
+		if node.Depth < 0 {
+			stats.Unreachable++
+		}
+		if node.Depth > stats.MaxDepth {
+			stats.MaxDepth = node.Depth
+		}
+		if maxIn == nil || node.InDegree > maxIn.InDegree {
+			maxIn = node
+		}
+		if maxOut == nil || node.OutDegree > maxOut.OutDegree {
+			maxOut = node
+		}
+	}
+	stats.Components = len(components)
+	if len(g.Nodes) > 0 {
+		stats.AverageDegree = float64(len(g.Edges)) / float64(len(g.Nodes))
+		stats.MaxInDegreeUrl = maxIn.Url
+		stats.MaxOutDegreeUrl = maxOut.Url
+	}
+	return stats
+}
+
+func sortedKeys(pages map[string]*collector.SucceededPage) []string {
+	keys := make([]string, 0, len(pages))
+	for key := range pages {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
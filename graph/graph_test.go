@@ -0,0 +1,155 @@
+package graph
+
+import (
+	"bytes"
+	"crawler/collector"
+	"encoding/xml"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testCrawl is a seed linking to a and b, a linking to c which is not crawled, b failing and an orphan page
+// linking to d, the orphan and d are a component of their own
+func testCrawl() *collector.ResultData {
+	return &collector.ResultData{
+		Seed: "https://example.com/",
+		Succeed: map[string]*collector.SucceededPage{
+			"https://example.com/": {Title: "Home", Urls: []string{
+				"https://example.com/a", "https://example.com/b", "https://example.com/a", "https://example.com/",
+			}},
+			"https://example.com/a":      {Title: "A", Urls: []string{"https://example.com/c", "https://example.com/"}},
+			"https://example.com/orphan": {Title: "Orphan", Urls: []string{"https://example.com/d"}},
+		},
+		Failed: map[string]*collector.FailedPage{
+			"https://example.com/b": {FailReason: "not found"},
+		},
+	}
+}
+
+func TestBuildLinkGraph(t *testing.T) {
+	g := BuildLinkGraph(testCrawl())
+	if len(g.Nodes) != 6 {
+		t.Errorf("got %d nodes, want 6", len(g.Nodes))
+	}
+	// The duplicated link and the self link are not edges
+	if len(g.Edges) != 5 {
+		t.Errorf("got %d edges, want 5: %v", len(g.Edges), g.Edges)
+	}
+	for url, want := range map[string]string{
+		"https://example.com/":  StatusSucceeded,
+		"https://example.com/b": StatusFailed,
+		"https://example.com/c": StatusUnvisited,
+	} {
+		if got := g.Nodes[url].Status; got != want {
+			t.Errorf("%s: got status %q, want %q", url, got, want)
+		}
+	}
+	home := g.Nodes["https://example.com/"]
+	if home.InDegree != 1 || home.OutDegree != 2 {
+		t.Errorf("got degrees in %d out %d of the seed", home.InDegree, home.OutDegree)
+	}
+}
+
+func TestLinkGraphDepths(t *testing.T) {
+	g := BuildLinkGraph(testCrawl())
+	for url, want := range map[string]int{
+		"https://example.com/":       0,
+		"https://example.com/a":      1,
+		"https://example.com/b":      1,
+		"https://example.com/c":      2,
+		"https://example.com/orphan": -1,
+		"https://example.com/d":      -1,
+	} {
+		if got := g.Nodes[url].Depth; got != want {
+			t.Errorf("%s: got depth %d, want %d", url, got, want)
+		}
+	}
+}
+
+func TestLinkGraphDepthsWithoutTheSeed(t *testing.T) {
+	g := NewLinkGraph("https://example.com/")
+	g.AddEdge("https://example.com/a", "https://example.com/b")
+	g.ComputeDepths()
+	for url, node := range g.Nodes {
+		if node.Depth != -1 {
+			t.Errorf("%s: got depth %d without the seed", url, node.Depth)
+		}
+	}
+}
+
+func TestLinkGraphComponents(t *testing.T) {
+	g := BuildLinkGraph(testCrawl())
+	want := [][]string{
+		{"https://example.com/", "https://example.com/a", "https://example.com/b", "https://example.com/c"},
+		{"https://example.com/d", "https://example.com/orphan"},
+	}
+	if got := g.Components(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got components %q, want %q", got, want)
+	}
+	if got := g.ComputeComponents(); got != 2 {
+		t.Errorf("got %d components, want 2", got)
+	}
+}
+
+func TestLinkGraphOrphansAndStats(t *testing.T) {
+	g := BuildLinkGraph(testCrawl())
+	if got := g.Orphans(); !reflect.DeepEqual(got, []string{"https://example.com/orphan"}) {
+		t.Errorf("got orphans %q", got)
+	}
+	want := GraphStats{
+		Nodes:           6,
+		Edges:           5,
+		Components:      2,
+		Orphans:         1,
+		Unreachable:     2,
+		MaxDepth:        2,
+		AverageDegree:   5.0 / 6.0,
+		MaxInDegreeUrl:  "https://example.com/",
+		MaxOutDegreeUrl: "https://example.com/",
+	}
+	if got := g.Stats(); got != want {
+		t.Errorf("got stats %+v, want %+v", got, want)
+	}
+}
+
+func TestLinkGraphExports(t *testing.T) {
+	g := BuildLinkGraph(testCrawl())
+	var graphML bytes.Buffer
+	if err := g.WriteGraphML(&graphML); err != nil {
+		t.Fatal(err)
+	}
+	var document struct {
+		Nodes []struct{} `xml:"graph>node"`
+		Edges []struct{} `xml:"graph>edge"`
+	}
+	if err := xml.Unmarshal(graphML.Bytes(), &document); err != nil {
+		t.Fatalf("graphml is not valid xml: %s", err)
+	}
+	if len(document.Nodes) != 6 || len(document.Edges) != 5 {
+		t.Errorf("got %d nodes and %d edges in the graphml", len(document.Nodes), len(document.Edges))
+	}
+
+	var dot bytes.Buffer
+	if err := g.WriteDOT(&dot); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`"https://example.com/" -> "https://example.com/a";`,
+		`"https://example.com/b" [label="https://example.com/b", tooltip="https://example.com/b", color=red];`,
+		`peripheries=2`,
+	} {
+		if !strings.Contains(dot.String(), want) {
+			t.Errorf("dot misses %s:\n%s", want, dot.String())
+		}
+	}
+
+	var edges bytes.Buffer
+	if err := g.WriteCSV(&edges); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(edges.String()), "\n")
+	if len(lines) != 6 || lines[0] != "source,target" {
+		t.Errorf("got edge list:\n%s", edges.String())
+	}
+}
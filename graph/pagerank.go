@@ -0,0 +1,74 @@
+package graph
+
+import "math"
+
+const (
+	DefaultDamping    = 0.85
+	DefaultIterations = 100
+	DefaultTolerance  = 1e-9
+)
+
+// PageRank computes the rank of every node with the power iteration. The rank of the dangling nodes, the
+// ones without outbound links, is spread evenly over all nodes so the ranks always sum up to one.
+func (g *LinkGraph) PageRank(damping float64, iterations int, tolerance float64) map[string]float64 {
+	ranks := map[string]float64{}
+	urls := g.SortedUrls()
+	n := len(urls)
+	if n == 0 {
+		return ranks
+	}
+	index := make(map[string]int, n)
+	for i, url := range urls {
+		index[url] = i
+	}
+	rank := make([]float64, n)
+	for i := range rank {
+		rank[i] = 1 / float64(n)
+	}
+	next := make([]float64, n)
+	for iteration := 0; iteration < iterations; iteration++ {
+		dangling := 0.0
+		for i, url := range urls {
+			if g.Nodes[url].OutDegree == 0 {
+				dangling += rank[i]
+			}
+		}
+		base := (1-damping)/float64(n) + damping*dangling/float64(n)
+		for i := range next {
+			next[i] = base
+		}
+		for _, edge := range g.Edges {
+			from := index[edge.From]
+			next[index[edge.To]] += damping * rank[from] / float64(g.Nodes[edge.From].OutDegree)
+		}
+		diff := 0.0
+		for i := range rank {
+			diff += math.Abs(next[i] - rank[i])
+		}
+		rank, next = next, rank
+		if diff < tolerance {
+			break
+		}
+	}
+	for i, url := range urls {
+		ranks[url] = rank[i]
+	}
+	return ranks
+}
+
+// NormalizedPageRank returns the page ranks with the defaults scaled into [0, 1] by the highest rank
+func (g *LinkGraph) NormalizedPageRank() map[string]float64 {
+	ranks := g.PageRank(DefaultDamping, DefaultIterations, DefaultTolerance)
+	max := 0.0
+	for _, rank := range ranks {
+		if rank > max {
+			max = rank
+		}
+	}
+	if max > 0 {
+		for url, rank := range ranks {
+			ranks[url] = rank / max
+		}
+	}
+	return ranks
+}
@@ -0,0 +1,70 @@
+package graph
+
+import (
+	"math"
+	"testing"
+)
+
+func TestPageRankConverges(t *testing.T) {
+	// The page linked by every other page ranks highest, the dangling page spreads its rank over all pages
+	g := NewLinkGraph("a")
+	g.AddEdge("a", "hub")
+	g.AddEdge("b", "hub")
+	g.AddEdge("c", "hub")
+	g.AddEdge("hub", "a")
+	g.AddEdge("c", "dangling")
+	ranks := g.PageRank(DefaultDamping, DefaultIterations, DefaultTolerance)
+	sum := 0.0
+	for _, rank := range ranks {
+		sum += rank
+	}
+	if math.Abs(sum-1) > 1e-6 {
+		t.Errorf("ranks sum up to %f, want 1", sum)
+	}
+	for _, url := range []string{"a", "b", "c", "dangling"} {
+		if ranks[url] >= ranks["hub"] {
+			t.Errorf("%s ranks %f, not below the hub %f", url, ranks[url], ranks["hub"])
+		}
+	}
+	if ranks["a"] <= ranks["b"] {
+		t.Errorf("page linked by the hub ranks %f, not above %f", ranks["a"], ranks["b"])
+	}
+
+	// More iterations do not change the converged ranks
+	more := g.PageRank(DefaultDamping, DefaultIterations*10, DefaultTolerance)
+	for url, rank := range ranks {
+		if math.Abs(more[url]-rank) > 1e-6 {
+			t.Errorf("%s: got %f after more iterations, want %f", url, more[url], rank)
+		}
+	}
+}
+
+func TestPageRankOfACycleIsUniform(t *testing.T) {
+	g := NewLinkGraph("a")
+	g.AddEdge("a", "b")
+	g.AddEdge("b", "c")
+	g.AddEdge("c", "a")
+	for url, rank := range g.PageRank(DefaultDamping, DefaultIterations, DefaultTolerance) {
+		if math.Abs(rank-1.0/3) > 1e-9 {
+			t.Errorf("%s: got rank %f, want 1/3", url, rank)
+		}
+	}
+}
+
+func TestNormalizedPageRank(t *testing.T) {
+	g := NewLinkGraph("a")
+	g.AddEdge("a", "b")
+	g.AddEdge("c", "b")
+	ranks := g.NormalizedPageRank()
+	if ranks["b"] != 1 {
+		t.Errorf("got highest rank %f, want 1", ranks["b"])
+	}
+	for _, url := range []string{"a", "c"} {
+		if ranks[url] <= 0 || ranks[url] >= 1 {
+			t.Errorf("%s: got rank %f, want within (0, 1)", url, ranks[url])
+		}
+	}
+	if ranks := NewLinkGraph("a").NormalizedPageRank(); len(ranks) != 0 {
+		t.Errorf("got ranks %v of an empty graph", ranks)
+	}
+}
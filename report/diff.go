@@ -0,0 +1,287 @@
+package report
+
+import (
+	"crawler/collector"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	DiffAdded   = "+"
+	DiffRemoved = "-"
+	// Texts with more line pairs than this are diffed as removed and added entirely
+	MaxDiffCells = 1000000
+)
+
+const (
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+)
+
+type DiffLine struct {
+	Op   string `json:"op"`
+	Text string `json:"text"`
+}
+
+type FieldChange struct {
+	Field string     `json:"field"`
+	Diff  []DiffLine `json:"diff"`
+}
+
+type PageChange struct {
+	Url     string         `json:"url"`
+	Changes []*FieldChange `json:"changes"`
+}
+
+type StatusChange struct {
+	Url           string `json:"url"`
+	OldStatus     string `json:"old_status"`
+	NewStatus     string `json:"new_status"`
+	OldStatusCode int    `json:"old_status_code,omitempty"`
+	NewStatusCode int    `json:"new_status_code,omitempty"`
+	// Urls the page is redirected through up to its final url
+	OldRedirects []string `json:"old_redirects,omitempty"`
+	NewRedirects []string `json:"new_redirects,omitempty"`
+}
+
+// CrawlDiff is what changed on a site between two crawls of it
+type CrawlDiff struct {
+	OldSeed      string    `json:"old_seed"`
+	NewSeed      string    `json:"new_seed"`
+	OldTimestamp time.Time `json:"old_timestamp"`
+	NewTimestamp time.Time `json:"new_timestamp"`
+	GeneratedAt  time.Time `json:"generated_at"`
+	// Urls crawled only by the new crawl and only by the old crawl
+	AddedPages   []string      `json:"added_pages"`
+	RemovedPages []string      `json:"removed_pages"`
+	ChangedPages []*PageChange `json:"changed_pages"`
+	// Links failing in the new crawl which did not fail in the old one, with the pages linking them
+	NewlyBrokenLinks []*BrokenLink   `json:"newly_broken_links"`
+	StatusChanges    []*StatusChange `json:"status_changes"`
+}
+
+// LoadCrawlDiff diffs two results files saved by a collector
+func LoadCrawlDiff(oldPath string, newPath string) (*CrawlDiff, error) {
+	oldData, err := collector.LoadResultData(oldPath)
+	if err != nil {
+		return nil, err
+	}
+	newData, err := collector.LoadResultData(newPath)
+	if err != nil {
+		return nil, err
+	}
+	return DiffCrawls(oldData, newData), nil
+}
+
+func DiffCrawls(oldData *collector.ResultData, newData *collector.ResultData) *CrawlDiff {
+	diff := &CrawlDiff{
+		OldSeed:          oldData.Seed,
+		NewSeed:          newData.Seed,
+		OldTimestamp:     oldData.BeginTimestamp,
+		NewTimestamp:     newData.BeginTimestamp,
+		GeneratedAt:      time.Now().UTC(),
+		AddedPages:       []string{},
+		RemovedPages:     []string{},
+		ChangedPages:     []*PageChange{},
+		NewlyBrokenLinks: []*BrokenLink{},
+		StatusChanges:    []*StatusChange{},
+	}
+	oldUrls := crawledUrls(oldData)
+	newUrls := crawledUrls(newData)
+
+	for _, u := range sortedUrls(newUrls) {
+		if !oldUrls[u] {
+			diff.AddedPages = append(diff.AddedPages, u)
+		}
+	}
+	for _, u := range sortedUrls(oldUrls) {
+		if !newUrls[u] {
+			diff.RemovedPages = append(diff.RemovedPages, u)
+		}
+	}
+
+	broken := map[string]*BrokenLink{}
+	for _, u := range sortedUrls(newUrls) {
+		if !oldUrls[u] {
+			if failed, exists := newData.Failed[u]; exists {
+				link := &BrokenLink{Url: u, Reason: strings.TrimSpace(failed.FailReason), StatusCode: failed.StatusCode, Sources: []*LinkSource{}}
+				broken[u] = link
+				diff.NewlyBrokenLinks = append(diff.NewlyBrokenLinks, link)
+			}
+			continue
+		}
+		oldStatus, oldCode, oldRedirects := pageStatus(oldData, u)
+		newStatus, newCode, newRedirects := pageStatus(newData, u)
+		// Succeeded pages of the results saved without their status codes match any status code
+		sameCode := oldCode == newCode || (oldStatus == StatusSucceeded && newStatus == StatusSucceeded && (oldCode == 0 || newCode == 0))
+		if oldStatus != newStatus || !sameCode || !sameStrings(oldRedirects, newRedirects) {
+			diff.StatusChanges = append(diff.StatusChanges, &StatusChange{
+				Url:           u,
+				OldStatus:     oldStatus,
+				NewStatus:     newStatus,
+				OldStatusCode: oldCode,
+				NewStatusCode: newCode,
+				OldRedirects:  oldRedirects,
+				NewRedirects:  newRedirects,
+			})
+			if newStatus == StatusFailed && oldStatus == StatusSucceeded {
+				failed := newData.Failed[u]
+				link := &BrokenLink{Url: u, Reason: strings.TrimSpace(failed.FailReason), StatusCode: failed.StatusCode, Sources: []*LinkSource{}}
+				broken[u] = link
+				diff.NewlyBrokenLinks = append(diff.NewlyBrokenLinks, link)
+			}
+		}
+		oldPage, oldExists := oldData.Succeed[u]
+		newPage, newExists := newData.Succeed[u]
+		if oldExists && newExists {
+			if changes := DiffPages(oldPage, newPage); len(changes) > 0 {
+				diff.ChangedPages = append(diff.ChangedPages, &PageChange{Url: u, Changes: changes})
+			}
+		}
+	}
+	for _, u := range sortedUrls(newUrls) {
+		page, exists := newData.Succeed[u]
+		if !exists {
+			continue
+		}
+		for _, target := range LinkSources(page) {
+			if link, exists := broken[target.Url]; exists {
+				link.Sources = append(link.Sources, &LinkSource{Url: u, Text: target.Text})
+			}
+		}
+	}
+	return diff
+}
+
+// DiffPages returns the changes of the title, the description and the content of a page, the content is the
+// main text when both of the crawls extracted it, otherwise the paragraphs
+func DiffPages(oldPage *collector.SucceededPage, newPage *collector.SucceededPage) []*FieldChange {
+	changes := []*FieldChange{}
+	if oldPage.Title != newPage.Title {
+		changes = append(changes, &FieldChange{Field: "title", Diff: DiffLines([]string{oldPage.Title}, []string{newPage.Title})})
+	}
+	if oldPage.Description != newPage.Description {
+		changes = append(changes, &FieldChange{Field: "description", Diff: DiffLines([]string{oldPage.Description}, []string{newPage.Description})})
+	}
+	if oldPage.MainText != "" && newPage.MainText != "" {
+		if oldPage.MainText != newPage.MainText {
+			changes = append(changes, &FieldChange{Field: "main_text", Diff: DiffLines(textLines(oldPage.MainText), textLines(newPage.MainText))})
+		}
+	} else if strings.Join(oldPage.Paragrahps, "\n") != strings.Join(newPage.Paragrahps, "\n") {
+		changes = append(changes, &FieldChange{Field: "paragraphs", Diff: DiffLines(oldPage.Paragrahps, newPage.Paragrahps)})
+	}
+	return changes
+}
+
+// DiffLines diffs two texts line by line with their longest common subsequence, only the changed lines are
+// returned
+func DiffLines(oldLines []string, newLines []string) []DiffLine {
+	diff := []DiffLine{}
+	if len(oldLines)*len(newLines) > MaxDiffCells {
+		for _, line := range oldLines {
+			diff = append(diff, DiffLine{Op: DiffRemoved, Text: line})
+		}
+		for _, line := range newLines {
+			diff = append(diff, DiffLine{Op: DiffAdded, Text: line})
+		}
+		return diff
+	}
+	// lengths[i][j] is the length of the common subsequence of oldLines[i:] and newLines[j:]
+	lengths := make([][]int, len(oldLines)+1)
+	for i := range lengths {
+		lengths[i] = make([]int, len(newLines)+1)
+	}
+	for i := len(oldLines) - 1; i >= 0; i-- {
+		for j := len(newLines) - 1; j >= 0; j-- {
+			if oldLines[i] == newLines[j] {
+				lengths[i][j] = lengths[i+1][j+1] + 1
+			} else if lengths[i+1][j] >= lengths[i][j+1] {
+				lengths[i][j] = lengths[i+1][j]
+			} else {
+				lengths[i][j] = lengths[i][j+1]
+			}
+		}
+	}
+	i, j := 0, 0
+	for i < len(oldLines) && j < len(newLines) {
+		switch {
+		case oldLines[i] == newLines[j]:
+			i++
+			j++
+		case lengths[i+1][j] >= lengths[i][j+1]:
+			diff = append(diff, DiffLine{Op: DiffRemoved, Text: oldLines[i]})
+			i++
+		default:
+			diff = append(diff, DiffLine{Op: DiffAdded, Text: newLines[j]})
+			j++
+		}
+	}
+	for ; i < len(oldLines); i++ {
+		diff = append(diff, DiffLine{Op: DiffRemoved, Text: oldLines[i]})
+	}
+	for ; j < len(newLines); j++ {
+		diff = append(diff, DiffLine{Op: DiffAdded, Text: newLines[j]})
+	}
+	return diff
+}
+
+// HasChanges is true when anything changed between the crawls
+func (d *CrawlDiff) HasChanges() bool {
+	return len(d.AddedPages) > 0 || len(d.RemovedPages) > 0 || len(d.ChangedPages) > 0 ||
+		len(d.NewlyBrokenLinks) > 0 || len(d.StatusChanges) > 0
+}
+
+// pageStatus returns the status of a crawled page with its status code and the urls it is redirected through
+func pageStatus(data *collector.ResultData, u string) (string, int, []string) {
+	if failed, exists := data.Failed[u]; exists {
+		return StatusFailed, failed.StatusCode, failed.Redirects
+	}
+	page := data.Succeed[u]
+	if len(page.Redirects) == 0 {
+		return StatusSucceeded, page.StatusCode, nil
+	}
+	return StatusSucceeded, page.StatusCode, append(append([]string{}, page.Redirects...), page.FinalUrl)
+}
+
+func sameStrings(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func crawledUrls(data *collector.ResultData) map[string]bool {
+	urls := make(map[string]bool, len(data.Succeed)+len(data.Failed))
+	for u := range data.Succeed {
+		urls[u] = true
+	}
+	for u := range data.Failed {
+		urls[u] = true
+	}
+	return urls
+}
+
+func sortedUrls(urls map[string]bool) []string {
+	sorted := make([]string, 0, len(urls))
+	for u := range urls {
+		sorted = append(sorted, u)
+	}
+	sort.Strings(sorted)
+	return sorted
+}
+
+func textLines(text string) []string {
+	lines := []string{}
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
@@ -0,0 +1,205 @@
+package report
+
+import (
+	"bytes"
+	"crawler/collector"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func diffCrawls() (*collector.ResultData, *collector.ResultData) {
+	oldData := &collector.ResultData{
+		Seed: "https://example.com/",
+		Succeed: map[string]*collector.SucceededPage{
+			"https://example.com/":        {Title: "Home", Paragrahps: []string{"Welcome", "News"}},
+			"https://example.com/about":   {Title: "About", MainText: "We crawl.\nPolitely."},
+			"https://example.com/old":     {Title: "Old"},
+			"https://example.com/pricing": {Title: "Pricing"},
+		},
+		Failed: map[string]*collector.FailedPage{
+			"https://example.com/flaky": {FailReason: "timeout"},
+		},
+	}
+	newData := &collector.ResultData{
+		Seed: "https://example.com/",
+		Succeed: map[string]*collector.SucceededPage{
+			"https://example.com/": {
+				Title: "Home", Paragrahps: []string{"Welcome", "Events", "News"},
+				Links: []*collector.PageLink{
+					{Url: "https://example.com/pricing", Text: "Pricing"},
+					{Url: "https://example.com/gone", Text: "Gone"},
+				},
+			},
+			"https://example.com/about": {Title: "About us", MainText: "We crawl.\nQuickly."},
+			"https://example.com/flaky": {Title: "Flaky"},
+			"https://example.com/new":   {Title: "New", Urls: []string{"https://example.com/gone"}},
+		},
+		Failed: map[string]*collector.FailedPage{
+			"https://example.com/pricing": {FailReason: "status code 500 ", StatusCode: 500},
+			"https://example.com/gone":    {FailReason: "status code 404", StatusCode: 404},
+		},
+	}
+	return oldData, newData
+}
+
+func TestDiffCrawls(t *testing.T) {
+	diff := DiffCrawls(diffCrawls())
+	if want := []string{"https://example.com/gone", "https://example.com/new"}; !reflect.DeepEqual(diff.AddedPages, want) {
+		t.Errorf("got added pages %q, want %q", diff.AddedPages, want)
+	}
+	if want := []string{"https://example.com/old"}; !reflect.DeepEqual(diff.RemovedPages, want) {
+		t.Errorf("got removed pages %q, want %q", diff.RemovedPages, want)
+	}
+	wantStatus := []*StatusChange{
+		{Url: "https://example.com/flaky", OldStatus: StatusFailed, NewStatus: StatusSucceeded},
+		{Url: "https://example.com/pricing", OldStatus: StatusSucceeded, NewStatus: StatusFailed, NewStatusCode: 500},
+	}
+	if !reflect.DeepEqual(diff.StatusChanges, wantStatus) {
+		t.Errorf("got status changes %+v %+v", diff.StatusChanges[0], diff.StatusChanges[1])
+	}
+	// New failing pages and the pages failing since the old crawl are newly broken, with the pages linking them
+	wantBroken := []*BrokenLink{
+		{Url: "https://example.com/gone", Reason: "status code 404", StatusCode: 404, Sources: []*LinkSource{
+			{Url: "https://example.com/", Text: "Gone"}, {Url: "https://example.com/new"},
+		}},
+		{Url: "https://example.com/pricing", Reason: "status code 500", StatusCode: 500, Sources: []*LinkSource{
+			{Url: "https://example.com/", Text: "Pricing"},
+		}},
+	}
+	if !reflect.DeepEqual(diff.NewlyBrokenLinks, wantBroken) {
+		for _, link := range diff.NewlyBrokenLinks {
+			t.Logf("%+v %+v", link, link.Sources)
+		}
+		t.Errorf("newly broken links do not match")
+	}
+	if len(diff.ChangedPages) != 2 {
+		t.Fatalf("got %d changed pages, want 2", len(diff.ChangedPages))
+	}
+	home := diff.ChangedPages[0]
+	if home.Url != "https://example.com/" || len(home.Changes) != 1 || home.Changes[0].Field != "paragraphs" ||
+		!reflect.DeepEqual(home.Changes[0].Diff, []DiffLine{{Op: DiffAdded, Text: "Events"}}) {
+		t.Errorf("got home changes %+v", home.Changes)
+	}
+	about := diff.ChangedPages[1]
+	if len(about.Changes) != 2 || about.Changes[0].Field != "title" || about.Changes[1].Field != "main_text" {
+		t.Fatalf("got about changes %+v", about.Changes)
+	}
+	if want := []DiffLine{{Op: DiffRemoved, Text: "Politely."}, {Op: DiffAdded, Text: "Quickly."}}; !reflect.DeepEqual(about.Changes[1].Diff, want) {
+		t.Errorf("got main text diff %v, want %v", about.Changes[1].Diff, want)
+	}
+	if !diff.HasChanges() {
+		t.Error("diff has no changes")
+	}
+}
+
+func TestDiffCrawlsOfTheSameCrawl(t *testing.T) {
+	_, newData := diffCrawls()
+	if diff := DiffCrawls(newData, newData); diff.HasChanges() {
+		t.Errorf("got changes between the same crawl: %+v", diff)
+	}
+}
+
+func TestDiffCrawlsOfSucceededPages(t *testing.T) {
+	oldData := &collector.ResultData{Succeed: map[string]*collector.SucceededPage{
+		"https://example.com/moved":   {StatusCode: 200},
+		"https://example.com/partial": {StatusCode: 200},
+		"https://example.com/legacy":  {},
+	}}
+	newData := &collector.ResultData{Succeed: map[string]*collector.SucceededPage{
+		"https://example.com/moved": {
+			StatusCode: 200, Redirects: []string{"https://example.com/moved"}, FinalUrl: "https://example.com/new",
+		},
+		"https://example.com/partial": {StatusCode: 203},
+		"https://example.com/legacy":  {StatusCode: 200},
+	}}
+	diff := DiffCrawls(oldData, newData)
+	want := []*StatusChange{
+		{
+			Url: "https://example.com/moved", OldStatus: StatusSucceeded, NewStatus: StatusSucceeded,
+			OldStatusCode: 200, NewStatusCode: 200, NewRedirects: []string{"https://example.com/moved", "https://example.com/new"},
+		},
+		{
+			Url: "https://example.com/partial", OldStatus: StatusSucceeded, NewStatus: StatusSucceeded,
+			OldStatusCode: 200, NewStatusCode: 203,
+		},
+	}
+	if !reflect.DeepEqual(diff.StatusChanges, want) {
+		for _, change := range diff.StatusChanges {
+			t.Logf("%+v", change)
+		}
+		t.Fatalf("status changes do not match")
+	}
+	var b bytes.Buffer
+	if err := diff.Write(&b, ReportFormatMarkdown); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"| https://example.com/moved | succeeded (200) | succeeded (200) via https://example.com/moved → https://example.com/new |",
+		"| https://example.com/partial | succeeded (200) | succeeded (203) |",
+	} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("markdown misses %q:\n%s", want, b.String())
+		}
+	}
+}
+
+func TestDiffPagesFallsBackToTheParagraphs(t *testing.T) {
+	oldPage := &collector.SucceededPage{MainText: "Main text", Paragrahps: []string{"a"}}
+	newPage := &collector.SucceededPage{Paragrahps: []string{"b"}}
+	changes := DiffPages(oldPage, newPage)
+	if len(changes) != 1 || changes[0].Field != "paragraphs" {
+		t.Errorf("got changes %+v", changes)
+	}
+	newPage.Paragrahps = []string{"a"}
+	if changes := DiffPages(oldPage, newPage); len(changes) != 0 {
+		t.Errorf("got changes %+v of the same paragraphs", changes)
+	}
+}
+
+func TestDiffLines(t *testing.T) {
+	for _, test := range []struct {
+		old  []string
+		new  []string
+		want []DiffLine
+	}{
+		{[]string{"a", "b", "c"}, []string{"a", "b", "c"}, []DiffLine{}},
+		{[]string{}, []string{"a"}, []DiffLine{{DiffAdded, "a"}}},
+		{[]string{"a", "b", "c", "d"}, []string{"a", "c", "x", "d"}, []DiffLine{{DiffRemoved, "b"}, {DiffAdded, "x"}}},
+		{[]string{"a", "b"}, []string{"b", "a"}, []DiffLine{{DiffRemoved, "a"}, {DiffAdded, "a"}}},
+	} {
+		if got := DiffLines(test.old, test.new); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("DiffLines(%q, %q) = %v, want %v", test.old, test.new, got, test.want)
+		}
+	}
+}
+
+func TestDiffLinesOfLongTexts(t *testing.T) {
+	lines := make([]string, 1001)
+	for i := range lines {
+		lines[i] = fmt.Sprintf("line %d", i)
+	}
+	// Texts over the cells limit are replaced entirely instead of diffed
+	diff := DiffLines(lines, append([]string{"first"}, lines...))
+	if len(diff) != 2*len(lines)+1 || diff[0].Op != DiffRemoved || diff[len(diff)-1].Op != DiffAdded {
+		t.Errorf("got %d diff lines", len(diff))
+	}
+}
+
+func TestCrawlDiffWritesMarkdown(t *testing.T) {
+	var b bytes.Buffer
+	if err := DiffCrawls(diffCrawls()).Write(&b, ReportFormatMarkdown); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"## Added pages (2)",
+		"| https://example.com/pricing | status code 500 | https://example.com/ \"Pricing\" |",
+		"| https://example.com/pricing | succeeded | failed (500) |",
+		"```diff\n- Politely.\n+ Quickly.\n```",
+	} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("markdown misses %q:\n%s", want, b.String())
+		}
+	}
+}
@@ -0,0 +1,283 @@
+package report
+
+import (
+	"crawler/collector"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultOversizeBytes  = 1024 * 1024
+	DefaultSlowResponseMs = 3000
+	DefaultHeavyPageBytes = 3 * 1024 * 1024
+)
+
+type HealthOptions struct {
+	// Html pages larger than this are reported as oversized
+	OversizeBytes int64 `json:"oversize_bytes"`
+	// Pages responding slower than this are reported as slow
+	SlowResponseMs int64 `json:"slow_response_ms"`
+	// Pages whose html and assets weigh more than this are reported as heavy
+	HeavyPageBytes int64 `json:"heavy_page_bytes"`
+}
+
+type LinkSource struct {
+	Url  string `json:"url"`
+	Text string `json:"text"`
+}
+
+type BrokenLink struct {
+	Url        string        `json:"url"`
+	Reason     string        `json:"reason"`
+	StatusCode int           `json:"status_code,omitempty"`
+	Sources    []*LinkSource `json:"sources"`
+}
+
+type Redirect struct {
+	Url   string   `json:"url"`
+	Chain []string `json:"chain"`
+	Final string   `json:"final,omitempty"`
+	Loop  bool     `json:"loop"`
+}
+
+type DuplicateTitle struct {
+	Title string   `json:"title"`
+	Urls  []string `json:"urls"`
+}
+
+type PageMeasure struct {
+	Url   string `json:"url"`
+	Value int64  `json:"value"`
+}
+
+type MixedContent struct {
+	Url      string   `json:"url"`
+	Insecure []string `json:"insecure"`
+}
+
+type MissingAltText struct {
+	Url    string   `json:"url"`
+	Images []string `json:"images"`
+}
+
+type HealthReport struct {
+	Seed                string            `json:"seed"`
+	GeneratedAt         time.Time         `json:"generated_at"`
+	Options             HealthOptions     `json:"options"`
+	TotalPages          int               `json:"total_pages"`
+	SucceededPages      int               `json:"succeeded_pages"`
+	FailedPages         int               `json:"failed_pages"`
+	BrokenLinks         []*BrokenLink     `json:"broken_links"`
+	Redirects           []*Redirect       `json:"redirects"`
+	MissingTitles       []string          `json:"missing_titles"`
+	MissingDescriptions []string          `json:"missing_descriptions"`
+	DuplicateTitles     []*DuplicateTitle `json:"duplicate_titles"`
+	OversizedPages      []*PageMeasure    `json:"oversized_pages"`
+	SlowPages           []*PageMeasure    `json:"slow_pages"`
+	// The mixed content, the alt texts, the broken assets and the heavy pages are found in the asset inventory,
+	// they are not collected when the pages were crawled without it
+	AssetsCollected bool              `json:"assets_collected"`
+	MixedContent    []*MixedContent   `json:"mixed_content"`
+	MissingAltText  []*MissingAltText `json:"missing_alt_text"`
+	// Assets failing the availability checks with the pages referencing them
+	BrokenAssets []*BrokenLink  `json:"broken_assets"`
+	HeavyPages   []*PageMeasure `json:"heavy_pages"`
+}
+
+func DefaultHealthOptions() HealthOptions {
+	return HealthOptions{
+		OversizeBytes:  DefaultOversizeBytes,
+		SlowResponseMs: DefaultSlowResponseMs,
+		HeavyPageBytes: DefaultHeavyPageBytes,
+	}
+}
+
+// LoadHealthReport builds the report of a results file saved by a collector
+func LoadHealthReport(path string, options HealthOptions) (*HealthReport, error) {
+	data, err := collector.LoadResultData(path)
+	if err != nil {
+		return nil, err
+	}
+	return BuildHealthReport(data, options), nil
+}
+
+func BuildHealthReport(data *collector.ResultData, options HealthOptions) *HealthReport {
+	report := &HealthReport{
+		Seed:                data.Seed,
+		GeneratedAt:         time.Now().UTC(),
+		Options:             options,
+		SucceededPages:      len(data.Succeed),
+		FailedPages:         len(data.Failed),
+		TotalPages:          len(data.Succeed) + len(data.Failed),
+		BrokenLinks:         []*BrokenLink{},
+		Redirects:           []*Redirect{},
+		MissingTitles:       []string{},
+		MissingDescriptions: []string{},
+		DuplicateTitles:     []*DuplicateTitle{},
+		OversizedPages:      []*PageMeasure{},
+		SlowPages:           []*PageMeasure{},
+		MixedContent:        []*MixedContent{},
+		MissingAltText:      []*MissingAltText{},
+		BrokenAssets:        []*BrokenLink{},
+		HeavyPages:          []*PageMeasure{},
+	}
+	pageUrls := make([]string, 0, len(data.Succeed))
+	for u := range data.Succeed {
+		pageUrls = append(pageUrls, u)
+	}
+	sort.Strings(pageUrls)
+	failedUrls := make([]string, 0, len(data.Failed))
+	for u := range data.Failed {
+		failedUrls = append(failedUrls, u)
+	}
+	sort.Strings(failedUrls)
+
+	// Broken links with every page linking them
+	broken := map[string]*BrokenLink{}
+	for _, u := range failedUrls {
+		failed := data.Failed[u]
+		link := &BrokenLink{Url: u, Reason: strings.TrimSpace(failed.FailReason), StatusCode: failed.StatusCode, Sources: []*LinkSource{}}
+		broken[u] = link
+		report.BrokenLinks = append(report.BrokenLinks, link)
+		if len(failed.Redirects) > 0 {
+			report.Redirects = append(report.Redirects, &Redirect{
+				Url:   u,
+				Chain: failed.Redirects,
+				Loop:  failed.RedirectLoop,
+			})
+		}
+	}
+	for _, u := range pageUrls {
+		for _, source := range LinkSources(data.Succeed[u]) {
+			if link, exists := broken[source.Url]; exists {
+				link.Sources = append(link.Sources, &LinkSource{Url: u, Text: source.Text})
+			}
+		}
+	}
+
+	titles := map[string][]string{}
+	brokenAssets := map[string]*BrokenLink{}
+	for _, u := range pageUrls {
+		page := data.Succeed[u]
+		if len(page.Redirects) > 0 {
+			report.Redirects = append(report.Redirects, &Redirect{Url: u, Chain: page.Redirects, Final: page.FinalUrl})
+		}
+		if options.SlowResponseMs > 0 && page.ResponseTime > options.SlowResponseMs {
+			report.SlowPages = append(report.SlowPages, &PageMeasure{Url: u, Value: page.ResponseTime})
+		}
+		if !strings.Contains(page.ContentType, "text/html") {
+			continue
+		}
+		if strings.TrimSpace(page.Title) == "" {
+			report.MissingTitles = append(report.MissingTitles, u)
+		} else {
+			titles[page.Title] = append(titles[page.Title], u)
+		}
+		if strings.TrimSpace(page.Description) == "" {
+			report.MissingDescriptions = append(report.MissingDescriptions, u)
+		}
+		if options.OversizeBytes > 0 && page.ContentLength > options.OversizeBytes {
+			report.OversizedPages = append(report.OversizedPages, &PageMeasure{Url: u, Value: page.ContentLength})
+		}
+		if page.Weight != nil || len(page.Assets) > 0 {
+			report.AssetsCollected = true
+		}
+		if insecure := InsecureUrls(u, page); len(insecure) > 0 {
+			report.MixedContent = append(report.MixedContent, &MixedContent{Url: u, Insecure: insecure})
+		}
+		if options.HeavyPageBytes > 0 && page.Weight != nil && page.Weight.TotalBytes > options.HeavyPageBytes {
+			report.HeavyPages = append(report.HeavyPages, &PageMeasure{Url: u, Value: page.Weight.TotalBytes})
+		}
+		missingAlt := []string{}
+		for _, asset := range page.Assets {
+			if asset.MissingAlt && !collector.URLExists(missingAlt, asset.Url) {
+				missingAlt = append(missingAlt, asset.Url)
+			}
+			if !asset.Broken() {
+				continue
+			}
+			link, exists := brokenAssets[asset.Url]
+			if !exists {
+				link = &BrokenLink{Url: asset.Url, Reason: asset.Error, StatusCode: asset.StatusCode, Sources: []*LinkSource{}}
+				brokenAssets[asset.Url] = link
+				report.BrokenAssets = append(report.BrokenAssets, link)
+			}
+			if len(link.Sources) == 0 || link.Sources[len(link.Sources)-1].Url != u {
+				link.Sources = append(link.Sources, &LinkSource{Url: u})
+			}
+		}
+		if len(missingAlt) > 0 {
+			report.MissingAltText = append(report.MissingAltText, &MissingAltText{Url: u, Images: missingAlt})
+		}
+	}
+	for title, urls := range titles {
+		if len(urls) > 1 {
+			report.DuplicateTitles = append(report.DuplicateTitles, &DuplicateTitle{Title: title, Urls: urls})
+		}
+	}
+	sort.Slice(report.DuplicateTitles, func(i, j int) bool {
+		return report.DuplicateTitles[i].Title < report.DuplicateTitles[j].Title
+	})
+	sort.SliceStable(report.SlowPages, func(i, j int) bool {
+		return report.SlowPages[i].Value > report.SlowPages[j].Value
+	})
+	sort.SliceStable(report.OversizedPages, func(i, j int) bool {
+		return report.OversizedPages[i].Value > report.OversizedPages[j].Value
+	})
+	sort.SliceStable(report.HeavyPages, func(i, j int) bool {
+		return report.HeavyPages[i].Value > report.HeavyPages[j].Value
+	})
+	sort.Slice(report.BrokenAssets, func(i, j int) bool {
+		return report.BrokenAssets[i].Url < report.BrokenAssets[j].Url
+	})
+	return report
+}
+
+// LinkSources returns the links of a page with their anchor texts, the pages saved without the link
+// records fall back to their urls
+func LinkSources(page *collector.SucceededPage) []*LinkSource {
+	sources := []*LinkSource{}
+	if len(page.Links) == 0 {
+		for _, u := range page.Urls {
+			sources = append(sources, &LinkSource{Url: u})
+		}
+		return sources
+	}
+	seen := map[LinkSource]bool{}
+	for _, link := range page.Links {
+		source := LinkSource{Url: link.Url, Text: link.Text}
+		if !seen[source] {
+			seen[source] = true
+			sources = append(sources, &source)
+		}
+	}
+	return sources
+}
+
+// InsecureUrls returns the plain http assets loaded by a https page, the links to other pages are not mixed
+// content. The pages crawled without the asset inventory have no assets to check.
+func InsecureUrls(pageURL string, page *collector.SucceededPage) []string {
+	insecure := []string{}
+	u, err := url.Parse(pageURL)
+	if err != nil || u.Scheme != "https" {
+		return insecure
+	}
+	seen := map[string]bool{}
+	for _, asset := range page.Assets {
+		if strings.HasPrefix(strings.ToLower(asset.Url), "http://") && !seen[asset.Url] {
+			seen[asset.Url] = true
+			insecure = append(insecure, asset.Url)
+		}
+	}
+	return insecure
+}
+
+// HasIssues is true when the report holds any problem
+func (r *HealthReport) HasIssues() bool {
+	return len(r.BrokenLinks) > 0 || len(r.Redirects) > 0 || len(r.MissingTitles) > 0 ||
+		len(r.MissingDescriptions) > 0 || len(r.DuplicateTitles) > 0 || len(r.OversizedPages) > 0 ||
+		len(r.SlowPages) > 0 || len(r.MixedContent) > 0 || len(r.MissingAltText) > 0 || len(r.BrokenAssets) > 0 ||
+		len(r.HeavyPages) > 0
+}
@@ -0,0 +1,187 @@
+package report
+
+import (
+	"bytes"
+	"crawler/collector"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func healthCrawl() *collector.ResultData {
+	return &collector.ResultData{
+		Seed: "https://example.com/",
+		Succeed: map[string]*collector.SucceededPage{
+			"https://example.com/": {
+				Title: "Home", Description: "Home page", ContentType: "text/html; charset=utf-8",
+				Urls: []string{"https://example.com/missing", "http://example.com/plain"},
+				Links: []*collector.PageLink{
+					{Url: "https://example.com/missing", Text: "Missing page"},
+					{Url: "https://example.com/missing", Text: "Missing page"},
+					{Url: "http://example.com/plain", Text: "Plain link"},
+				},
+			},
+			"https://example.com/a": {
+				Title: "Home", ContentType: "text/html", ContentLength: 2048, ResponseTime: 5000,
+				Urls:      []string{"https://example.com/missing"},
+				Redirects: []string{"https://example.com/old-a"}, FinalUrl: "https://example.com/a",
+			},
+			"https://example.com/doc.pdf": {ContentType: "application/pdf"},
+		},
+		Failed: map[string]*collector.FailedPage{
+			"https://example.com/missing": {FailReason: "status code 404 ", StatusCode: 404},
+			"https://example.com/loop": {
+				FailReason:   "redirect loop detected",
+				Redirects:    []string{"https://example.com/loop", "https://example.com/loop2"},
+				RedirectLoop: true,
+			},
+		},
+	}
+}
+
+func TestBuildHealthReport(t *testing.T) {
+	options := HealthOptions{OversizeBytes: 1024, SlowResponseMs: 3000, HeavyPageBytes: 4096}
+	report := BuildHealthReport(healthCrawl(), options)
+	if report.TotalPages != 5 || report.SucceededPages != 3 || report.FailedPages != 2 {
+		t.Errorf("got %d pages, %d succeeded, %d failed", report.TotalPages, report.SucceededPages, report.FailedPages)
+	}
+	if len(report.BrokenLinks) != 2 {
+		t.Fatalf("got %d broken links, want 2", len(report.BrokenLinks))
+	}
+	missing := report.BrokenLinks[1]
+	if missing.Url != "https://example.com/missing" || missing.StatusCode != 404 || missing.Reason != "status code 404" {
+		t.Errorf("got broken link %+v", missing)
+	}
+	// The anchor text is recorded once per page, the pages without links fall back to their urls
+	wantSources := []*LinkSource{{Url: "https://example.com/", Text: "Missing page"}, {Url: "https://example.com/a"}}
+	if !reflect.DeepEqual(missing.Sources, wantSources) {
+		t.Errorf("got sources %v", missing.Sources)
+	}
+	if len(report.Redirects) != 2 || !report.Redirects[0].Loop || report.Redirects[1].Final != "https://example.com/a" {
+		t.Errorf("got redirects %+v %+v", report.Redirects[0], report.Redirects[1])
+	}
+	if !reflect.DeepEqual(report.MissingDescriptions, []string{"https://example.com/a"}) {
+		t.Errorf("got missing descriptions %q", report.MissingDescriptions)
+	}
+	if len(report.MissingTitles) != 0 {
+		t.Errorf("got missing titles %q of the html pages", report.MissingTitles)
+	}
+	if len(report.DuplicateTitles) != 1 || report.DuplicateTitles[0].Title != "Home" {
+		t.Errorf("got duplicate titles %v", report.DuplicateTitles)
+	}
+	if len(report.OversizedPages) != 1 || len(report.SlowPages) != 1 {
+		t.Errorf("got oversized pages %v and slow pages %v", report.OversizedPages, report.SlowPages)
+	}
+	if !report.HasIssues() {
+		t.Errorf("report has no issues")
+	}
+}
+
+func TestHealthReportMarksTheRedirectLoopsOfTheFailedPages(t *testing.T) {
+	chain := []string{"https://example.com/a", "https://example.com/b", "https://example.com/a"}
+	data := &collector.ResultData{
+		Seed:    "https://example.com/",
+		Succeed: map[string]*collector.SucceededPage{},
+		Failed: map[string]*collector.FailedPage{
+			// The loop is kept from the wrapped redirect error whatever the fail reason reads
+			"https://example.com/a": collector.NewFailedPage("https://example.com/a",
+				fmt.Errorf("request failed: %w", &collector.RedirectError{Chain: chain, Loop: true})),
+			"https://example.com/c": collector.NewFailedPage("https://example.com/c",
+				&collector.RedirectError{Chain: []string{"https://example.com/c", "https://example.com/d"}}),
+		},
+	}
+	report := BuildHealthReport(data, HealthOptions{})
+	if len(report.Redirects) != 2 {
+		t.Fatalf("got %d redirects, want 2", len(report.Redirects))
+	}
+	if loop := report.Redirects[0]; !loop.Loop || !reflect.DeepEqual(loop.Chain, chain) {
+		t.Errorf("got redirect %+v, want a loop", loop)
+	}
+	if report.Redirects[1].Loop {
+		t.Errorf("got redirect %+v, want no loop", report.Redirects[1])
+	}
+}
+
+func TestHealthReportMarksTheAssetSectionsNotCollected(t *testing.T) {
+	report := BuildHealthReport(healthCrawl(), DefaultHealthOptions())
+	if report.AssetsCollected {
+		t.Fatalf("assets are collected without the inventory")
+	}
+	// The plain http link is a link to another page, not mixed content
+	if len(report.MixedContent) != 0 {
+		t.Errorf("got mixed content %v", report.MixedContent)
+	}
+	for _, format := range []string{ReportFormatMarkdown, ReportFormatHTML} {
+		var b bytes.Buffer
+		if err := report.Write(&b, format); err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.Count(b.String(), "Not collected"); got != 4 {
+			t.Errorf("%s: got %d sections not collected, want 4:\n%s", format, got, b.String())
+		}
+		if strings.Contains(b.String(), "Mixed content (0)") {
+			t.Errorf("%s: mixed content looks clean without the inventory", format)
+		}
+	}
+}
+
+func TestHealthReportOfTheAssetInventory(t *testing.T) {
+	data := healthCrawl()
+	home := data.Succeed["https://example.com/"]
+	home.Assets = []*collector.PageAsset{
+		{Type: collector.AssetScript, Url: "http://cdn.example.com/app.js"},
+		{Type: collector.AssetImage, Url: "https://example.com/logo.png", MissingAlt: true},
+		{Type: collector.AssetImage, Url: "https://example.com/logo.png", MissingAlt: true},
+		{Type: collector.AssetImage, Url: "https://example.com/gone.png", Checked: true, StatusCode: 404, Error: "status code 404"},
+	}
+	home.Weight = &collector.PageWeight{TotalBytes: 8192}
+	data.Succeed["https://example.com/a"].Weight = &collector.PageWeight{TotalBytes: 100}
+	report := BuildHealthReport(data, DefaultHealthOptions())
+	if !report.AssetsCollected {
+		t.Fatalf("assets are not collected")
+	}
+	if want := []*MixedContent{{Url: "https://example.com/", Insecure: []string{"http://cdn.example.com/app.js"}}}; !reflect.DeepEqual(report.MixedContent, want) {
+		t.Errorf("got mixed content %v", report.MixedContent)
+	}
+	if want := []*MissingAltText{{Url: "https://example.com/", Images: []string{"https://example.com/logo.png"}}}; !reflect.DeepEqual(report.MissingAltText, want) {
+		t.Errorf("got missing alt texts %v", report.MissingAltText)
+	}
+	if len(report.BrokenAssets) != 1 || report.BrokenAssets[0].Url != "https://example.com/gone.png" ||
+		len(report.BrokenAssets[0].Sources) != 1 {
+		t.Errorf("got broken assets %v", report.BrokenAssets)
+	}
+	if len(report.HeavyPages) != 0 {
+		t.Errorf("got heavy pages %v under the default limit", report.HeavyPages)
+	}
+	report = BuildHealthReport(data, HealthOptions{HeavyPageBytes: 4096})
+	if len(report.HeavyPages) != 1 || report.HeavyPages[0].Value != 8192 {
+		t.Errorf("got heavy pages %v", report.HeavyPages)
+	}
+
+	var b bytes.Buffer
+	if err := report.Write(&b, ReportFormatMarkdown); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(b.String(), "Not collected") || !strings.Contains(b.String(), "## Mixed content (1)") {
+		t.Errorf("got markdown:\n%s", b.String())
+	}
+}
+
+func TestHealthReportWritesJSON(t *testing.T) {
+	var b bytes.Buffer
+	if err := BuildHealthReport(healthCrawl(), DefaultHealthOptions()).Write(&b, ReportFormatJSON); err != nil {
+		t.Fatal(err)
+	}
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(b.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded["assets_collected"] != false || decoded["seed"] != "https://example.com/" {
+		t.Errorf("got json %v", decoded)
+	}
+	if err := BuildHealthReport(healthCrawl(), DefaultHealthOptions()).Write(&b, "pdf"); err == nil {
+		t.Errorf("unknown format is written")
+	}
+}
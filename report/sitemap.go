@@ -0,0 +1,277 @@
+package report
+
+import (
+	"bytes"
+	"crawler/collector"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// Limits of a single sitemap file defined by the sitemap protocol
+	MaxSitemapUrls  = 50000
+	MaxSitemapBytes = 50 * 1024 * 1024
+	SitemapFile     = "sitemap.xml"
+	SitemapXMLNS    = "http://www.sitemaps.org/schemas/sitemap/0.9"
+)
+
+type sitemapUrlSet struct {
+	XMLName xml.Name          `xml:"urlset"`
+	XMLNS   string            `xml:"xmlns,attr"`
+	Urls    []*sitemapUrlNode `xml:"url"`
+}
+
+type sitemapUrlNode struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+type sitemapIndex struct {
+	XMLName  xml.Name            `xml:"sitemapindex"`
+	XMLNS    string              `xml:"xmlns,attr"`
+	Sitemaps []*sitemapIndexNode `xml:"sitemap"`
+}
+
+type sitemapIndexNode struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+// SitemapUrls returns the urls of a crawl to be published in a sitemap sorted by url. Failed, noindex and
+// non html pages are left out, pages are listed with their canonical urls on the same host and the last
+// modification time is taken from the Last-Modified header, the page metadata or the crawl time in order.
+func SitemapUrls(data *collector.ResultData) []*collector.SitemapUrl {
+	excluded := map[string]bool{}
+	for u := range data.Failed {
+		excluded[u] = true
+	}
+	for u, page := range data.Succeed {
+		if !isSitemapPage(page) {
+			excluded[u] = true
+		}
+	}
+	urls := map[string]*collector.SitemapUrl{}
+	for u, page := range data.Succeed {
+		if excluded[u] {
+			continue
+		}
+		loc := CanonicalUrl(u, page)
+		if excluded[loc] {
+			continue
+		}
+		lastMod := page.LastModified
+		if lastMod == 0 && page.Metadata != nil {
+			lastMod = page.Metadata.Modified
+		}
+		if lastMod == 0 {
+			lastMod = page.Timestamp
+		}
+		// Pages sharing a canonical url are listed once with the latest modification
+		if existing, exists := urls[loc]; exists {
+			if lastMod > existing.LastMod {
+				existing.LastMod = lastMod
+			}
+			continue
+		}
+		urls[loc] = &collector.SitemapUrl{Loc: loc, LastMod: lastMod, Priority: collector.DefaultSitemapPriority}
+	}
+	sitemapUrls := make([]*collector.SitemapUrl, 0, len(urls))
+	for _, u := range urls {
+		sitemapUrls = append(sitemapUrls, u)
+	}
+	sort.Slice(sitemapUrls, func(i, j int) bool {
+		return sitemapUrls[i].Loc < sitemapUrls[j].Loc
+	})
+	return sitemapUrls
+}
+
+// CanonicalUrl returns the canonical url of a page, the final url of its redirects or the page url. Canonical
+// urls on another host are ignored since a sitemap may only list the urls of its own host.
+func CanonicalUrl(pageURL string, page *collector.SucceededPage) string {
+	loc := pageURL
+	if page.FinalUrl != "" {
+		loc = page.FinalUrl
+	}
+	if page.Metadata == nil || page.Metadata.CanonicalUrl == "" {
+		return loc
+	}
+	canonical, err := url.Parse(page.Metadata.CanonicalUrl)
+	if err != nil {
+		return loc
+	}
+	current, err := url.Parse(loc)
+	if err != nil || !strings.EqualFold(canonical.Host, current.Host) {
+		return loc
+	}
+	return page.Metadata.CanonicalUrl
+}
+
+func isSitemapPage(page *collector.SucceededPage) bool {
+	if page.Robots != nil && page.Robots.NoIndex {
+		return false
+	}
+	mediaType, _, err := mime.ParseMediaType(page.ContentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == "text/html" || mediaType == "application/xhtml+xml"
+}
+
+// WriteSitemap writes the urls as a sitemap urlset
+func WriteSitemap(w io.Writer, urls []*collector.SitemapUrl) error {
+	set := &sitemapUrlSet{XMLNS: SitemapXMLNS, Urls: make([]*sitemapUrlNode, 0, len(urls))}
+	for _, u := range urls {
+		set.Urls = append(set.Urls, &sitemapUrlNode{Loc: u.Loc, LastMod: sitemapDate(u.LastMod)})
+	}
+	return writeSitemapXML(w, set)
+}
+
+// WriteSitemapIndex writes the sitemap index listing the sitemaps at the given urls
+func WriteSitemapIndex(w io.Writer, sitemaps []string, lastMod int64) error {
+	index := &sitemapIndex{XMLNS: SitemapXMLNS, Sitemaps: make([]*sitemapIndexNode, 0, len(sitemaps))}
+	for _, loc := range sitemaps {
+		index.Sitemaps = append(index.Sitemaps, &sitemapIndexNode{Loc: loc, LastMod: sitemapDate(lastMod)})
+	}
+	return writeSitemapXML(w, index)
+}
+
+// ExportSitemaps writes the sitemap of a crawl into the directory and returns the paths of the written
+// files. A crawl exceeding the limits of a single sitemap is split into sitemap-1.xml, sitemap-2.xml and so
+// on, which are listed by the sitemap index in sitemap.xml under the base url they are published at. The
+// numbered sitemaps left in the directory by an earlier larger export are removed.
+func ExportSitemaps(data *collector.ResultData, dir string, baseURL string) ([]string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	chunks := splitSitemapUrls(SitemapUrls(data))
+	if len(chunks) <= 1 {
+		urls := []*collector.SitemapUrl{}
+		if len(chunks) == 1 {
+			urls = chunks[0]
+		}
+		path := filepath.Join(dir, SitemapFile)
+		if err := writeSitemapFile(path, urls); err != nil {
+			return nil, err
+		}
+		if err := removeStaleSitemaps(dir, 0); err != nil {
+			return nil, err
+		}
+		return []string{path}, nil
+	}
+	if baseURL == "" {
+		return nil, errors.New(fmt.Sprintf("base url is required to index %d sitemaps", len(chunks)))
+	}
+	paths := []string{}
+	locs := []string{}
+	var lastMod int64
+	for i, chunk := range chunks {
+		name := fmt.Sprintf("sitemap-%d.xml", i+1)
+		path := filepath.Join(dir, name)
+		if err := writeSitemapFile(path, chunk); err != nil {
+			return nil, err
+		}
+		paths = append(paths, path)
+		locs = append(locs, strings.TrimRight(baseURL, "/")+"/"+name)
+		for _, u := range chunk {
+			if u.LastMod > lastMod {
+				lastMod = u.LastMod
+			}
+		}
+	}
+	var index bytes.Buffer
+	if err := WriteSitemapIndex(&index, locs, lastMod); err != nil {
+		return nil, err
+	}
+	path := filepath.Join(dir, SitemapFile)
+	if err := ioutil.WriteFile(path, index.Bytes(), 0644); err != nil {
+		return nil, err
+	}
+	if err := removeStaleSitemaps(dir, len(chunks)); err != nil {
+		return nil, err
+	}
+	return append([]string{path}, paths...), nil
+}
+
+// removeStaleSitemaps removes the sitemap files numbered above the count, left by an earlier larger export
+func removeStaleSitemaps(dir string, count int) error {
+	paths, err := filepath.Glob(filepath.Join(dir, "sitemap-*.xml"))
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		name := filepath.Base(path)
+		number, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(name, "sitemap-"), ".xml"))
+		if err != nil || name != fmt.Sprintf("sitemap-%d.xml", number) || number <= count {
+			continue
+		}
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// splitSitemapUrls splits the urls into the chunks fitting into a sitemap file
+func splitSitemapUrls(urls []*collector.SitemapUrl) [][]*collector.SitemapUrl {
+	chunks := [][]*collector.SitemapUrl{}
+	// Room for the xml declaration and the urlset element
+	overhead := len(xml.Header) + len(SitemapXMLNS) + 64
+	size := overhead
+	var chunk []*collector.SitemapUrl
+	for _, u := range urls {
+		var escaped bytes.Buffer
+		xml.EscapeText(&escaped, []byte(u.Loc))
+		entrySize := escaped.Len() + 96
+		if len(chunk) >= MaxSitemapUrls || (len(chunk) > 0 && size+entrySize > MaxSitemapBytes) {
+			chunks = append(chunks, chunk)
+			chunk = nil
+			size = overhead
+		}
+		chunk = append(chunk, u)
+		size += entrySize
+	}
+	if len(chunk) > 0 {
+		chunks = append(chunks, chunk)
+	}
+	return chunks
+}
+
+func writeSitemapFile(path string, urls []*collector.SitemapUrl) error {
+	var buffer bytes.Buffer
+	if err := WriteSitemap(&buffer, urls); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, buffer.Bytes(), 0644)
+}
+
+func writeSitemapXML(w io.Writer, v interface{}) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(v); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// sitemapDate formats a unix timestamp in the w3c datetime format of the sitemaps
+func sitemapDate(timestamp int64) string {
+	if timestamp <= 0 {
+		return ""
+	}
+	return time.Unix(timestamp, 0).UTC().Format(time.RFC3339)
+}
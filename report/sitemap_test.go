@@ -0,0 +1,174 @@
+package report
+
+import (
+	"crawler/collector"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func sitemapCrawl() *collector.ResultData {
+	day := time.Date(2021, 6, 15, 0, 0, 0, 0, time.UTC).Unix()
+	return &collector.ResultData{
+		Seed: "https://example.com/",
+		Succeed: map[string]*collector.SucceededPage{
+			"https://example.com/": {ContentType: "text/html", Timestamp: day + 3, LastModified: day},
+			"https://example.com/a": {
+				ContentType: "text/html", Timestamp: day + 3,
+				Metadata: &collector.PageMetadata{Modified: day + 1},
+			},
+			// Pages of the same canonical url are listed once with the latest modification
+			"https://example.com/a?ref=home": {
+				ContentType: "text/html", Timestamp: day + 3, LastModified: day + 2,
+				Metadata: &collector.PageMetadata{CanonicalUrl: "https://example.com/a"},
+			},
+			// Media types are case insensitive
+			"https://example.com/mirror": {
+				ContentType: "Text/HTML; charset=UTF-8", Timestamp: day + 3,
+				Metadata: &collector.PageMetadata{CanonicalUrl: "https://other.example.com/mirror"},
+			},
+			"https://example.com/moved": {ContentType: "text/html", Timestamp: day + 3, FinalUrl: "https://example.com/b"},
+			"https://example.com/hidden": {
+				ContentType: "text/html", Timestamp: day + 3,
+				Robots: &collector.RobotsDirectives{NoIndex: true, Directives: []string{"noindex"}},
+			},
+			"https://example.com/doc.pdf": {ContentType: "application/pdf", Timestamp: day + 3},
+		},
+		Failed: map[string]*collector.FailedPage{
+			"https://example.com/missing": {FailReason: "status code 404", StatusCode: 404},
+		},
+	}
+}
+
+func TestSitemapUrls(t *testing.T) {
+	day := time.Date(2021, 6, 15, 0, 0, 0, 0, time.UTC).Unix()
+	want := []*collector.SitemapUrl{
+		{Loc: "https://example.com/", LastMod: day},
+		{Loc: "https://example.com/a", LastMod: day + 2},
+		{Loc: "https://example.com/b", LastMod: day + 3},
+		// A canonical url on another host is ignored
+		{Loc: "https://example.com/mirror", LastMod: day + 3},
+	}
+	for _, u := range want {
+		u.Priority = collector.DefaultSitemapPriority
+	}
+	got := SitemapUrls(sitemapCrawl())
+	if !reflect.DeepEqual(got, want) {
+		for _, u := range got {
+			t.Logf("%+v", u)
+		}
+		t.Errorf("sitemap urls do not match")
+	}
+}
+
+func TestExportSitemapsWritesASingleSitemap(t *testing.T) {
+	dir := t.TempDir()
+	paths, err := ExportSitemaps(sitemapCrawl(), dir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{filepath.Join(dir, SitemapFile)}; !reflect.DeepEqual(paths, want) {
+		t.Fatalf("got paths %q, want %q", paths, want)
+	}
+	sitemap := readSitemap(t, paths[0])
+	if len(sitemap.Urls) != 4 || sitemap.Urls[0].Loc != "https://example.com/" {
+		t.Fatalf("got %d urls", len(sitemap.Urls))
+	}
+	// The last modification is written in the w3c datetime format and read back
+	if want := time.Date(2021, 6, 15, 0, 0, 0, 0, time.UTC).Unix(); sitemap.Urls[0].LastMod != want {
+		t.Errorf("got lastmod %d, want %d", sitemap.Urls[0].LastMod, want)
+	}
+}
+
+func TestExportSitemapsSplitsLargeCrawls(t *testing.T) {
+	data := &collector.ResultData{Succeed: map[string]*collector.SucceededPage{}}
+	pages := MaxSitemapUrls + 10
+	for i := 0; i < pages; i++ {
+		data.Succeed[fmt.Sprintf("https://example.com/%06d", i)] = &collector.SucceededPage{ContentType: "text/html", Timestamp: int64(i + 1)}
+	}
+	if _, err := ExportSitemaps(data, t.TempDir(), ""); err == nil {
+		t.Fatal("sitemaps are indexed without a base url")
+	}
+	dir := t.TempDir()
+	paths, err := ExportSitemaps(data, dir, "https://example.com/sitemaps/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{filepath.Join(dir, SitemapFile), filepath.Join(dir, "sitemap-1.xml"), filepath.Join(dir, "sitemap-2.xml")}
+	if !reflect.DeepEqual(paths, want) {
+		t.Fatalf("got paths %q, want %q", paths, want)
+	}
+	index := readSitemap(t, paths[0])
+	if want := []string{"https://example.com/sitemaps/sitemap-1.xml", "https://example.com/sitemaps/sitemap-2.xml"}; !reflect.DeepEqual(index.Sitemaps, want) {
+		t.Errorf("got indexed sitemaps %q, want %q", index.Sitemaps, want)
+	}
+	first, second := readSitemap(t, paths[1]), readSitemap(t, paths[2])
+	if len(first.Urls) != MaxSitemapUrls || len(second.Urls) != 10 {
+		t.Fatalf("got %d and %d urls, want %d and 10", len(first.Urls), len(second.Urls), MaxSitemapUrls)
+	}
+	if last := second.Urls[9]; last.Loc != fmt.Sprintf("https://example.com/%06d", pages-1) || last.LastMod != int64(pages) {
+		t.Errorf("got last url %+v", last)
+	}
+	content, err := ioutil.ReadFile(paths[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The index is modified with its latest sitemap
+	if !strings.Contains(string(content), "<lastmod>"+sitemapDate(int64(pages))+"</lastmod>") {
+		t.Errorf("got index:\n%s", content)
+	}
+}
+
+func TestExportSitemapsRemovesTheStaleSitemaps(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"sitemap-1.xml", "sitemap-2.xml", "sitemap-3.xml", "sitemap-notes.xml"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte("<urlset/>"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := ExportSitemaps(sitemapCrawl(), dir, ""); err != nil {
+		t.Fatal(err)
+	}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []string{}
+	for _, file := range files {
+		names = append(names, file.Name())
+	}
+	// Only the numbered sitemaps of the earlier export are removed
+	if want := []string{"sitemap-notes.xml", SitemapFile}; !reflect.DeepEqual(names, want) {
+		t.Errorf("got files %q, want %q", names, want)
+	}
+}
+
+func TestSplitSitemapUrlsKeepsTheFilesUnderTheSizeLimit(t *testing.T) {
+	loc := "https://example.com/" + strings.Repeat("a&", MaxSitemapBytes/8)
+	urls := []*collector.SitemapUrl{{Loc: loc}, {Loc: loc}, {Loc: "https://example.com/"}}
+	// The escaped urls take more room than the urls
+	chunks := splitSitemapUrls(urls)
+	if len(chunks) != 2 || len(chunks[0]) != 1 || len(chunks[1]) != 2 {
+		t.Errorf("got chunks of %d urls", len(chunks))
+	}
+	if chunks := splitSitemapUrls([]*collector.SitemapUrl{}); len(chunks) != 0 {
+		t.Errorf("got %d chunks without urls", len(chunks))
+	}
+}
+
+func readSitemap(t *testing.T, path string) *collector.Sitemap {
+	t.Helper()
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sitemap, err := collector.ParseSitemap(content)
+	if err != nil {
+		t.Fatalf("%s: %s", path, err)
+	}
+	return sitemap
+}
@@ -0,0 +1,398 @@
+package report
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+)
+
+const (
+	ReportFormatJSON     = "json"
+	ReportFormatMarkdown = "markdown"
+	ReportFormatHTML     = "html"
+)
+
+var healthTemplate = template.Must(template.New("health").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Site health report: {{.Seed}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; vertical-align: top; }
+th { background: #f0f0f0; }
+</style>
+</head>
+<body>
+<h1>Site health report</h1>
+<p>Seed: <a href="{{.Seed}}">{{.Seed}}</a><br>Generated at: {{.GeneratedAt.Format "2006-01-02 15:04:05 MST"}}<br>
+Pages: {{.TotalPages}} ({{.SucceededPages}} succeeded, {{.FailedPages}} failed)</p>
+
+<h2>Broken links ({{len .BrokenLinks}})</h2>
+{{if .BrokenLinks}}<table>
+<tr><th>Url</th><th>Reason</th><th>Linked from</th></tr>
+{{range .BrokenLinks}}<tr><td>{{.Url}}</td><td>{{.Reason}}</td><td>{{range .Sources}}<a href="{{.Url}}">{{.Url}}</a>{{if .Text}} &ldquo;{{.Text}}&rdquo;{{end}}<br>{{end}}</td></tr>
+{{end}}</table>{{end}}
+
+<h2>Redirects ({{len .Redirects}})</h2>
+{{if .Redirects}}<table>
+<tr><th>Url</th><th>Chain</th><th>Loop</th></tr>
+{{range .Redirects}}<tr><td>{{.Url}}</td><td>{{range .Chain}}{{.}} &rarr; {{end}}{{.Final}}</td><td>{{if .Loop}}yes{{else}}no{{end}}</td></tr>
+{{end}}</table>{{end}}
+
+<h2>Pages without title ({{len .MissingTitles}})</h2>
+{{if .MissingTitles}}<ul>{{range .MissingTitles}}<li><a href="{{.}}">{{.}}</a></li>{{end}}</ul>{{end}}
+
+<h2>Pages without description ({{len .MissingDescriptions}})</h2>
+{{if .MissingDescriptions}}<ul>{{range .MissingDescriptions}}<li><a href="{{.}}">{{.}}</a></li>{{end}}</ul>{{end}}
+
+<h2>Duplicate titles ({{len .DuplicateTitles}})</h2>
+{{if .DuplicateTitles}}<table>
+<tr><th>Title</th><th>Pages</th></tr>
+{{range .DuplicateTitles}}<tr><td>{{.Title}}</td><td>{{range .Urls}}<a href="{{.}}">{{.}}</a><br>{{end}}</td></tr>
+{{end}}</table>{{end}}
+
+<h2>Oversized pages ({{len .OversizedPages}})</h2>
+{{if .OversizedPages}}<table>
+<tr><th>Url</th><th>Bytes</th></tr>
+{{range .OversizedPages}}<tr><td><a href="{{.Url}}">{{.Url}}</a></td><td>{{.Value}}</td></tr>
+{{end}}</table>{{end}}
+
+<h2>Slow responses ({{len .SlowPages}})</h2>
+{{if .SlowPages}}<table>
+<tr><th>Url</th><th>Milliseconds</th></tr>
+{{range .SlowPages}}<tr><td><a href="{{.Url}}">{{.Url}}</a></td><td>{{.Value}}</td></tr>
+{{end}}</table>{{end}}
+
+{{if .AssetsCollected}}<h2>Mixed content ({{len .MixedContent}})</h2>
+{{if .MixedContent}}<table>
+<tr><th>Page</th><th>Insecure urls</th></tr>
+{{range .MixedContent}}<tr><td><a href="{{.Url}}">{{.Url}}</a></td><td>{{range .Insecure}}{{.}}<br>{{end}}</td></tr>
+{{end}}</table>{{end}}{{else}}<h2>Mixed content</h2>
+<p>Not collected, the pages were crawled without the asset inventory.</p>{{end}}
+
+{{if .AssetsCollected}}<h2>Images without alt text ({{len .MissingAltText}})</h2>
+{{if .MissingAltText}}<table>
+<tr><th>Page</th><th>Images</th></tr>
+{{range .MissingAltText}}<tr><td><a href="{{.Url}}">{{.Url}}</a></td><td>{{range .Images}}{{.}}<br>{{end}}</td></tr>
+{{end}}</table>{{end}}{{else}}<h2>Images without alt text</h2>
+<p>Not collected, the pages were crawled without the asset inventory.</p>{{end}}
+
+{{if .AssetsCollected}}<h2>Broken assets ({{len .BrokenAssets}})</h2>
+{{if .BrokenAssets}}<table>
+<tr><th>Url</th><th>Reason</th><th>Used by</th></tr>
+{{range .BrokenAssets}}<tr><td>{{.Url}}</td><td>{{.Reason}}</td><td>{{range .Sources}}<a href="{{.Url}}">{{.Url}}</a><br>{{end}}</td></tr>
+{{end}}</table>{{end}}{{else}}<h2>Broken assets</h2>
+<p>Not collected, the pages were crawled without the asset inventory.</p>{{end}}
+
+{{if .AssetsCollected}}<h2>Heavy pages ({{len .HeavyPages}})</h2>
+{{if .HeavyPages}}<table>
+<tr><th>Url</th><th>Bytes</th></tr>
+{{range .HeavyPages}}<tr><td><a href="{{.Url}}">{{.Url}}</a></td><td>{{.Value}}</td></tr>
+{{end}}</table>{{end}}{{else}}<h2>Heavy pages</h2>
+<p>Not collected, the pages were crawled without the asset inventory.</p>{{end}}
+</body>
+</html>
+`))
+
+var diffTemplate = template.Must(template.New("diff").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Crawl diff: {{.NewSeed}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; vertical-align: top; }
+th { background: #f0f0f0; }
+.added { background: #e6ffec; }
+.removed { background: #ffebe9; }
+pre { margin: 0; white-space: pre-wrap; }
+</style>
+</head>
+<body>
+<h1>Crawl diff</h1>
+<p>Old crawl: <a href="{{.OldSeed}}">{{.OldSeed}}</a> at {{.OldTimestamp.Format "2006-01-02 15:04:05 MST"}}<br>
+New crawl: <a href="{{.NewSeed}}">{{.NewSeed}}</a> at {{.NewTimestamp.Format "2006-01-02 15:04:05 MST"}}<br>
+Generated at: {{.GeneratedAt.Format "2006-01-02 15:04:05 MST"}}</p>
+
+<h2>Added pages ({{len .AddedPages}})</h2>
+{{if .AddedPages}}<ul>{{range .AddedPages}}<li><a href="{{.}}">{{.}}</a></li>{{end}}</ul>{{end}}
+
+<h2>Removed pages ({{len .RemovedPages}})</h2>
+{{if .RemovedPages}}<ul>{{range .RemovedPages}}<li>{{.}}</li>{{end}}</ul>{{end}}
+
+<h2>Newly broken links ({{len .NewlyBrokenLinks}})</h2>
+{{if .NewlyBrokenLinks}}<table>
+<tr><th>Url</th><th>Reason</th><th>Linked from</th></tr>
+{{range .NewlyBrokenLinks}}<tr><td>{{.Url}}</td><td>{{.Reason}}</td><td>{{range .Sources}}<a href="{{.Url}}">{{.Url}}</a>{{if .Text}} &ldquo;{{.Text}}&rdquo;{{end}}<br>{{end}}</td></tr>
+{{end}}</table>{{end}}
+
+<h2>Status changes ({{len .StatusChanges}})</h2>
+{{if .StatusChanges}}<table>
+<tr><th>Url</th><th>Old status</th><th>New status</th></tr>
+{{range .StatusChanges}}<tr><td><a href="{{.Url}}">{{.Url}}</a></td><td>{{.OldStatus}}{{if .OldStatusCode}} ({{.OldStatusCode}}){{end}}{{if .OldRedirects}} via {{range $i, $u := .OldRedirects}}{{if $i}} &rarr; {{end}}{{$u}}{{end}}{{end}}</td><td>{{.NewStatus}}{{if .NewStatusCode}} ({{.NewStatusCode}}){{end}}{{if .NewRedirects}} via {{range $i, $u := .NewRedirects}}{{if $i}} &rarr; {{end}}{{$u}}{{end}}{{end}}</td></tr>
+{{end}}</table>{{end}}
+
+<h2>Changed pages ({{len .ChangedPages}})</h2>
+{{range .ChangedPages}}<h3><a href="{{.Url}}">{{.Url}}</a></h3>
+<table>
+<tr><th>Field</th><th>Diff</th></tr>
+{{range .Changes}}<tr><td>{{.Field}}</td><td>{{range .Diff}}<pre class="{{if eq .Op "+"}}added{{else}}removed{{end}}">{{.Op}} {{.Text}}</pre>{{end}}</td></tr>
+{{end}}</table>
+{{end}}
+</body>
+</html>
+`))
+
+type ReportWriterInterface interface {
+	WriteJSON(w io.Writer) error
+	WriteMarkdown(w io.Writer) error
+	WriteHTML(w io.Writer) error
+}
+
+// Write writes the report in one of the json, markdown and html formats
+func (r *HealthReport) Write(w io.Writer, format string) error {
+	switch format {
+	case ReportFormatJSON:
+		return r.WriteJSON(w)
+	case ReportFormatMarkdown:
+		return r.WriteMarkdown(w)
+	case ReportFormatHTML:
+		return r.WriteHTML(w)
+	}
+	return errors.New(fmt.Sprintf("unknown report format: %s", format))
+}
+
+func (r *HealthReport) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
+
+func (r *HealthReport) WriteHTML(w io.Writer) error {
+	return healthTemplate.Execute(w, r)
+}
+
+func (r *HealthReport) WriteMarkdown(w io.Writer) error {
+	b := bufio.NewWriter(w)
+	fmt.Fprintf(b, "# Site health report\n\n")
+	fmt.Fprintf(b, "- Seed: %s\n", r.Seed)
+	fmt.Fprintf(b, "- Generated at: %s\n", r.GeneratedAt.Format("2006-01-02 15:04:05 MST"))
+	fmt.Fprintf(b, "- Pages: %d (%d succeeded, %d failed)\n\n", r.TotalPages, r.SucceededPages, r.FailedPages)
+
+	fmt.Fprintf(b, "## Broken links (%d)\n\n", len(r.BrokenLinks))
+	if len(r.BrokenLinks) > 0 {
+		b.WriteString("| Url | Reason | Linked from |\n|---|---|---|\n")
+		for _, link := range r.BrokenLinks {
+			sources := make([]string, 0, len(link.Sources))
+			for _, source := range link.Sources {
+				if source.Text != "" {
+					sources = append(sources, fmt.Sprintf("%s \"%s\"", source.Url, source.Text))
+				} else {
+					sources = append(sources, source.Url)
+				}
+			}
+			fmt.Fprintf(b, "| %s | %s | %s |\n", markdownCell(link.Url), markdownCell(link.Reason),
+				markdownCell(strings.Join(sources, "<br>")))
+		}
+		b.WriteString("\n")
+	}
+
+	fmt.Fprintf(b, "## Redirects (%d)\n\n", len(r.Redirects))
+	if len(r.Redirects) > 0 {
+		b.WriteString("| Url | Chain | Loop |\n|---|---|---|\n")
+		for _, redirect := range r.Redirects {
+			chain := append(append([]string{}, redirect.Chain...), redirect.Final)
+			if redirect.Final == "" {
+				chain = redirect.Chain
+			}
+			loop := "no"
+			if redirect.Loop {
+				loop = "yes"
+			}
+			fmt.Fprintf(b, "| %s | %s | %s |\n", markdownCell(redirect.Url), markdownCell(strings.Join(chain, " → ")), loop)
+		}
+		b.WriteString("\n")
+	}
+
+	writeMarkdownList(b, "Pages without title", r.MissingTitles)
+	writeMarkdownList(b, "Pages without description", r.MissingDescriptions)
+
+	fmt.Fprintf(b, "## Duplicate titles (%d)\n\n", len(r.DuplicateTitles))
+	if len(r.DuplicateTitles) > 0 {
+		b.WriteString("| Title | Pages |\n|---|---|\n")
+		for _, duplicate := range r.DuplicateTitles {
+			fmt.Fprintf(b, "| %s | %s |\n", markdownCell(duplicate.Title), markdownCell(strings.Join(duplicate.Urls, "<br>")))
+		}
+		b.WriteString("\n")
+	}
+
+	writeMarkdownMeasures(b, "Oversized pages", "Bytes", r.OversizedPages)
+	writeMarkdownMeasures(b, "Slow responses", "Milliseconds", r.SlowPages)
+
+	if !r.AssetsCollected {
+		for _, title := range []string{"Mixed content", "Images without alt text", "Broken assets", "Heavy pages"} {
+			fmt.Fprintf(b, "## %s\n\nNot collected, the pages were crawled without the asset inventory.\n\n", title)
+		}
+		return b.Flush()
+	}
+
+	fmt.Fprintf(b, "## Mixed content (%d)\n\n", len(r.MixedContent))
+	if len(r.MixedContent) > 0 {
+		b.WriteString("| Page | Insecure urls |\n|---|---|\n")
+		for _, mixed := range r.MixedContent {
+			fmt.Fprintf(b, "| %s | %s |\n", markdownCell(mixed.Url), markdownCell(strings.Join(mixed.Insecure, "<br>")))
+		}
+		b.WriteString("\n")
+	}
+
+	fmt.Fprintf(b, "## Images without alt text (%d)\n\n", len(r.MissingAltText))
+	if len(r.MissingAltText) > 0 {
+		b.WriteString("| Page | Images |\n|---|---|\n")
+		for _, missing := range r.MissingAltText {
+			fmt.Fprintf(b, "| %s | %s |\n", markdownCell(missing.Url), markdownCell(strings.Join(missing.Images, "<br>")))
+		}
+		b.WriteString("\n")
+	}
+
+	fmt.Fprintf(b, "## Broken assets (%d)\n\n", len(r.BrokenAssets))
+	if len(r.BrokenAssets) > 0 {
+		b.WriteString("| Url | Reason | Used by |\n|---|---|---|\n")
+		for _, asset := range r.BrokenAssets {
+			pages := make([]string, 0, len(asset.Sources))
+			for _, source := range asset.Sources {
+				pages = append(pages, source.Url)
+			}
+			fmt.Fprintf(b, "| %s | %s | %s |\n", markdownCell(asset.Url), markdownCell(asset.Reason),
+				markdownCell(strings.Join(pages, "<br>")))
+		}
+		b.WriteString("\n")
+	}
+
+	writeMarkdownMeasures(b, "Heavy pages", "Bytes", r.HeavyPages)
+	return b.Flush()
+}
+
+// Write writes the diff in one of the json, markdown and html formats
+func (d *CrawlDiff) Write(w io.Writer, format string) error {
+	switch format {
+	case ReportFormatJSON:
+		return d.WriteJSON(w)
+	case ReportFormatMarkdown:
+		return d.WriteMarkdown(w)
+	case ReportFormatHTML:
+		return d.WriteHTML(w)
+	}
+	return errors.New(fmt.Sprintf("unknown report format: %s", format))
+}
+
+func (d *CrawlDiff) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(d)
+}
+
+func (d *CrawlDiff) WriteHTML(w io.Writer) error {
+	return diffTemplate.Execute(w, d)
+}
+
+func (d *CrawlDiff) WriteMarkdown(w io.Writer) error {
+	b := bufio.NewWriter(w)
+	fmt.Fprintf(b, "# Crawl diff\n\n")
+	fmt.Fprintf(b, "- Old crawl: %s at %s\n", d.OldSeed, d.OldTimestamp.Format("2006-01-02 15:04:05 MST"))
+	fmt.Fprintf(b, "- New crawl: %s at %s\n", d.NewSeed, d.NewTimestamp.Format("2006-01-02 15:04:05 MST"))
+	fmt.Fprintf(b, "- Generated at: %s\n\n", d.GeneratedAt.Format("2006-01-02 15:04:05 MST"))
+
+	writeMarkdownList(b, "Added pages", d.AddedPages)
+	writeMarkdownList(b, "Removed pages", d.RemovedPages)
+
+	fmt.Fprintf(b, "## Newly broken links (%d)\n\n", len(d.NewlyBrokenLinks))
+	if len(d.NewlyBrokenLinks) > 0 {
+		b.WriteString("| Url | Reason | Linked from |\n|---|---|---|\n")
+		for _, link := range d.NewlyBrokenLinks {
+			sources := make([]string, 0, len(link.Sources))
+			for _, source := range link.Sources {
+				if source.Text != "" {
+					sources = append(sources, fmt.Sprintf("%s \"%s\"", source.Url, source.Text))
+				} else {
+					sources = append(sources, source.Url)
+				}
+			}
+			fmt.Fprintf(b, "| %s | %s | %s |\n", markdownCell(link.Url), markdownCell(link.Reason),
+				markdownCell(strings.Join(sources, "<br>")))
+		}
+		b.WriteString("\n")
+	}
+
+	fmt.Fprintf(b, "## Status changes (%d)\n\n", len(d.StatusChanges))
+	if len(d.StatusChanges) > 0 {
+		b.WriteString("| Url | Old status | New status |\n|---|---|---|\n")
+		for _, change := range d.StatusChanges {
+			fmt.Fprintf(b, "| %s | %s | %s |\n", markdownCell(change.Url),
+				markdownCell(redirectedStatusText(change.OldStatus, change.OldStatusCode, change.OldRedirects)),
+				markdownCell(redirectedStatusText(change.NewStatus, change.NewStatusCode, change.NewRedirects)))
+		}
+		b.WriteString("\n")
+	}
+
+	fmt.Fprintf(b, "## Changed pages (%d)\n\n", len(d.ChangedPages))
+	for _, page := range d.ChangedPages {
+		fmt.Fprintf(b, "### %s\n\n", page.Url)
+		for _, change := range page.Changes {
+			fmt.Fprintf(b, "%s:\n\n```diff\n", change.Field)
+			for _, line := range change.Diff {
+				fmt.Fprintf(b, "%s %s\n", line.Op, strings.ReplaceAll(line.Text, "\n", " "))
+			}
+			b.WriteString("```\n\n")
+		}
+	}
+	return b.Flush()
+}
+
+func statusText(status string, statusCode int) string {
+	if statusCode == 0 {
+		return status
+	}
+	return fmt.Sprintf("%s (%d)", status, statusCode)
+}
+
+// redirectedStatusText is the status text followed by the urls the page is redirected through
+func redirectedStatusText(status string, statusCode int, redirects []string) string {
+	if len(redirects) == 0 {
+		return statusText(status, statusCode)
+	}
+	return statusText(status, statusCode) + " via " + strings.Join(redirects, " → ")
+}
+
+func writeMarkdownList(b *bufio.Writer, title string, urls []string) {
+	fmt.Fprintf(b, "## %s (%d)\n\n", title, len(urls))
+	for _, u := range urls {
+		fmt.Fprintf(b, "- %s\n", u)
+	}
+	if len(urls) > 0 {
+		b.WriteString("\n")
+	}
+}
+
+func writeMarkdownMeasures(b *bufio.Writer, title string, unit string, measures []*PageMeasure) {
+	fmt.Fprintf(b, "## %s (%d)\n\n", title, len(measures))
+	if len(measures) == 0 {
+		return
+	}
+	fmt.Fprintf(b, "| Url | %s |\n|---|---|\n", unit)
+	for _, measure := range measures {
+		fmt.Fprintf(b, "| %s | %d |\n", markdownCell(measure.Url), measure.Value)
+	}
+	b.WriteString("\n")
+}
+
+func markdownCell(s string) string {
+	s = strings.ReplaceAll(s, "|", "\\|")
+	return strings.ReplaceAll(s, "\n", " ")
+}
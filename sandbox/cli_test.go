@@ -0,0 +1,219 @@
+package sandbox
+
+import (
+	"bytes"
+	"crawler/cli"
+	"crawler/scheduler"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// The command line and the config errors exit with the usage code, the commands which cannot do their work
+// with the failure code
+func TestRunExitCodes(t *testing.T) {
+	dir := t.TempDir()
+	invalid := filepath.Join(dir, "invalid.yaml")
+	if err := ioutil.WriteFile(invalid, []byte("crawl:\n  depth: [1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		args   []string
+		code   int
+		stderr string
+	}{
+		{[]string{}, cli.ExitUsage, "Usage: crawler <command>"},
+		{[]string{"help"}, cli.ExitOK, "Usage: crawler <command>"},
+		{[]string{"fetch"}, cli.ExitUsage, "unknown command: fetch"},
+		{[]string{"crawl", "-h"}, cli.ExitOK, "Usage: crawler crawl"},
+		{[]string{"crawl", "-depht", "2"}, cli.ExitUsage, "flag provided but not defined: -depht"},
+		{[]string{"crawl", "-config", filepath.Join(dir, "missing.yaml")}, cli.ExitUsage, "missing.yaml"},
+		{[]string{"crawl", "-config", invalid}, cli.ExitUsage, "invalid.yaml"},
+		{[]string{"crawl", "-depth", "0", "http://127.0.0.1/"}, cli.ExitUsage, "depth"},
+		{[]string{"crawl", "-priority", "random", "http://127.0.0.1/"}, cli.ExitUsage, "priority"},
+		{[]string{"stats", filepath.Join(dir, "missing.json")}, cli.ExitFailure, "could not be loaded"},
+		{[]string{"search", "-index", filepath.Join(dir, "missing.json"), "token"}, cli.ExitFailure, "could not be loaded"},
+		{[]string{"export", "health", "-strict", filepath.Join(dir, "missing.json")}, cli.ExitFailure, "missing.json"},
+		{[]string{"schedule", filepath.Join(dir, "missing.json")}, cli.ExitUsage, "missing.json"},
+		{[]string{"schedule", "first.json", "second.json"}, cli.ExitUsage, "one jobs file"},
+	} {
+		var stdout, stderr bytes.Buffer
+		if code := cli.Run(test.args, &stdout, &stderr); code != test.code {
+			t.Errorf("%v: got exit code %d, want %d: %s", test.args, code, test.code, stderr.String())
+		}
+		if !strings.Contains(stderr.String(), test.stderr) {
+			t.Errorf("%v: stderr %q does not mention %q", test.args, stderr.String(), test.stderr)
+		}
+		if test.code != cli.ExitOK && stdout.Len() > 0 {
+			t.Errorf("%v: stdout of a failed command: %q", test.args, stdout.String())
+		}
+	}
+}
+
+// The flags override the config file, the options the flags leave out are taken from the file. The -json
+// summaries are the only output on stdout.
+func TestRunFlagsOverrideConfig(t *testing.T) {
+	site := NewSite(DefaultSiteOptions())
+	server := site.Serve()
+	defer server.Close()
+	dir := t.TempDir()
+	config := filepath.Join(dir, "crawler.yaml")
+	configured := filepath.Join(dir, "configured.json")
+	content := "crawl:\n  seeds:\n    - " + server.URL + PagePath(0) + "\n  depth: 1\n  results_file: " + configured + "\n"
+	if err := ioutil.WriteFile(config, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	run := func(v interface{}, args ...string) {
+		t.Helper()
+		var stdout, stderr bytes.Buffer
+		if code := cli.Run(args, &stdout, &stderr); code != cli.ExitOK {
+			t.Fatalf("%v: got exit code %d: %s", args, code, stderr.String())
+		}
+		if err := json.Unmarshal(stdout.Bytes(), v); err != nil {
+			t.Fatalf("%v: stdout %q is not json: %s", args, stdout.String(), err)
+		}
+	}
+
+	for _, test := range []struct {
+		args  []string
+		file  string
+		depth int
+	}{
+		{[]string{}, configured, 1},
+		{[]string{"-depth", "2"}, configured, 2},
+		{[]string{"-out", filepath.Join(dir, "flagged.json"), "-depth", "3"}, filepath.Join(dir, "flagged.json"), 3},
+	} {
+		var summary cli.CrawlSummary
+		run(&summary, append([]string{"crawl", "-json", "-config", config}, test.args...)...)
+		if summary.ResultsFile != test.file {
+			t.Errorf("%v: got results file %s, want %s", test.args, summary.ResultsFile, test.file)
+		}
+		want := site.Expected(server.URL, test.depth)
+		if summary.SucceededPages != len(want.Succeed) || summary.FailedPages != len(want.Failed) {
+			t.Errorf("%v: got %d succeeded and %d failed pages, want %d and %d", test.args,
+				summary.SucceededPages, summary.FailedPages, len(want.Succeed), len(want.Failed))
+		}
+
+		var stats cli.CrawlStats
+		run(&stats, "stats", "-json", test.file)
+		if stats.Depth != test.depth {
+			t.Errorf("%v: got depth %d, want %d", test.args, stats.Depth, test.depth)
+		}
+		// Every status code is the one the page was served with
+		statusCodes := map[int]int{}
+		for range want.Succeed {
+			statusCodes[200]++
+		}
+		for _, page := range want.Failed {
+			statusCodes[page.StatusCode]++
+		}
+		if !reflect.DeepEqual(stats.StatusCodes, statusCodes) {
+			t.Errorf("%v: got status codes %v, want %v", test.args, stats.StatusCodes, statusCodes)
+		}
+	}
+}
+
+// syncBuffer is the stderr of a command running in another goroutine
+type syncBuffer struct {
+	buffer bytes.Buffer
+	mutex  sync.Mutex
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buffer.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buffer.String()
+}
+
+func writeJobs(t *testing.T, dir string, seed string) string {
+	t.Helper()
+	jobs := filepath.Join(dir, "jobs.json")
+	content := fmt.Sprintf(`[{"name": "site", "seeds": [%q], "depth": 2, "schedule": "@daily", "results_file": %q}]`,
+		seed, filepath.Join(dir, "site.json"))
+	if err := ioutil.WriteFile(jobs, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return jobs
+}
+
+// A job run by -run is crawled, indexed and recorded into the history right away
+func TestRunScheduleRunsAJob(t *testing.T) {
+	site := NewSite(DefaultSiteOptions())
+	server := site.Serve()
+	defer server.Close()
+	dir := t.TempDir()
+	jobs := writeJobs(t, dir, server.URL+PagePath(0))
+	history := filepath.Join(dir, "history.json")
+	index := filepath.Join(dir, "indexes.json")
+
+	var stdout, stderr bytes.Buffer
+	args := []string{"schedule", "-history", history, "-index", index, "-run", "site", "-json", jobs}
+	if code := cli.Run(args, &stdout, &stderr); code != cli.ExitOK {
+		t.Fatalf("got exit code %d: %s", code, stderr.String())
+	}
+	var record scheduler.RunRecord
+	if err := json.Unmarshal(stdout.Bytes(), &record); err != nil {
+		t.Fatalf("stdout %q is not json: %s", stdout.String(), err)
+	}
+	want := site.Expected(server.URL, 2)
+	if record.Status != scheduler.RunSucceeded || record.Summary == nil || record.Summary.SucceededPages != len(want.Succeed) {
+		t.Errorf("got record %+v, want %d succeeded pages", record, len(want.Succeed))
+	}
+	for _, file := range []string{history, index, filepath.Join(dir, "site.json")} {
+		if _, err := os.Stat(file); err != nil {
+			t.Errorf("run did not write %s: %s", file, err)
+		}
+	}
+
+	stdout.Reset()
+	stderr.Reset()
+	args = []string{"schedule", "-history", history, "-index", index, "-run", "other", jobs}
+	if code := cli.Run(args, &stdout, &stderr); code != cli.ExitUsage || !strings.Contains(stderr.String(), "other") {
+		t.Errorf("unknown job: got exit code %d: %s", code, stderr.String())
+	}
+}
+
+// Without -run the jobs are scheduled until the command is interrupted
+func TestRunScheduleStopsOnInterrupt(t *testing.T) {
+	dir := t.TempDir()
+	jobs := writeJobs(t, dir, "http://127.0.0.1/")
+	var stdout bytes.Buffer
+	var stderr syncBuffer
+	done := make(chan int)
+	go func() {
+		done <- cli.Run([]string{"schedule", "-history", filepath.Join(dir, "history.json"),
+			"-index", filepath.Join(dir, "indexes.json"), jobs}, &stdout, &stderr)
+	}()
+	deadline := time.Now().Add(10 * time.Second)
+	for !strings.Contains(stderr.String(), "Scheduling 1 jobs") {
+		if time.Now().After(deadline) {
+			t.Fatalf("jobs are not scheduled: %s", stderr.String())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := syscall.Kill(os.Getpid(), syscall.SIGINT); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case code := <-done:
+		if code != cli.ExitOK {
+			t.Errorf("got exit code %d: %s", code, stderr.String())
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("schedule did not stop on the interrupt")
+	}
+}
@@ -0,0 +1,349 @@
+package sandbox
+
+import (
+	"crawler/cli"
+	"crawler/collector"
+	"crawler/distributed"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// politeServer serves the site and records the most requests it served at the same time
+type politeServer struct {
+	*httptest.Server
+	mutex    sync.Mutex
+	inFlight int
+	peak     int
+}
+
+func servePolitely(site *Site) *politeServer {
+	s := &politeServer{}
+	handler := site.Handler()
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mutex.Lock()
+		s.inFlight++
+		if s.inFlight > s.peak {
+			s.peak = s.inFlight
+		}
+		s.mutex.Unlock()
+		// Overlapping requests would show up while the page is served
+		time.Sleep(2 * time.Millisecond)
+		handler.ServeHTTP(w, r)
+		s.mutex.Lock()
+		s.inFlight--
+		s.mutex.Unlock()
+	}))
+	return s
+}
+
+// The worker scrapes several hosts at the same time but never requests one host concurrently
+func TestDistributedCrawlIsPolitePerHost(t *testing.T) {
+	depth := 3
+	sites := []*Site{}
+	servers := []*politeServer{}
+	for i := 0; i < 2; i++ {
+		options := DefaultSiteOptions()
+		options.Seed = int64(i + 1)
+		site := NewSite(options)
+		server := servePolitely(site)
+		defer server.Close()
+		sites = append(sites, site)
+		servers = append(servers, server)
+	}
+	c, err := collector.NewCollector(servers[0].URL+PagePath(0), depth, false, filepath.Join(t.TempDir(), "results.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if err := c.AddSeed(servers[1].URL + PagePath(0)); err != nil {
+		t.Fatal(err)
+	}
+	coordinator := distributed.NewCoordinator(c)
+	coordinator.HostDelay = 5 * time.Millisecond
+	coordinatorServer := httptest.NewServer(coordinator.Handler())
+	defer coordinatorServer.Close()
+
+	worker, err := distributed.NewWorker(coordinatorServer.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	worker.Concurrency = 4
+	worker.PollInterval = 5 * time.Millisecond
+	if err := worker.Run(make(chan struct{})); err != nil {
+		t.Fatalf("worker failed: %s", err)
+	}
+
+	got := []string{}
+	for u := range c.Scrapper.Succeed {
+		got = append(got, u)
+	}
+	for u := range c.Scrapper.Failed {
+		got = append(got, u)
+	}
+	sort.Strings(got)
+	want := []string{}
+	for i, site := range sites {
+		for _, path := range site.Crawled(depth) {
+			want = append(want, servers[i].URL+path)
+		}
+	}
+	sort.Strings(want)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("crawled pages\n got: %v\nwant: %v", got, want)
+	}
+	for i, server := range servers {
+		if server.peak != 1 {
+			t.Errorf("site %d served %d requests at the same time", i, server.peak)
+		}
+	}
+}
+
+// The coordinator records the results of the urls leased to the reporting worker only, an expired lease is still
+// reported by its worker
+func TestCoordinatorRejectsUnleasedResults(t *testing.T) {
+	site := NewSite(DefaultSiteOptions())
+	server := site.Serve()
+	defer server.Close()
+	seed := server.URL + PagePath(0)
+	c, err := collector.NewCollector(seed, 2, false, filepath.Join(t.TempDir(), "results.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	coordinator := distributed.NewCoordinator(c)
+	coordinator.LeaseDuration = 10 * time.Millisecond
+	lease := coordinator.Lease("worker-1", 1)
+	if len(lease.Urls) != 1 || lease.Urls[0].Url != seed {
+		t.Fatalf("got lease %v, want the seed", lease.Urls)
+	}
+	page := func(u string) []distributed.UrlResult {
+		return []distributed.UrlResult{{Url: u, Page: &collector.SucceededPage{Url: u, Urls: []string{}}}}
+	}
+
+	if err := coordinator.Report("worker-2", lease.LeaseId, page(seed)); err == nil {
+		t.Error("report of another worker is accepted")
+	}
+	if err := coordinator.Report("worker-1", "lease-99", page(seed)); err == nil {
+		t.Error("report of an unknown lease is accepted")
+	}
+	if err := coordinator.Report("worker-1", lease.LeaseId, page(server.URL+PagePath(1))); err != nil {
+		t.Fatal(err)
+	}
+	if got := c.Scrapper.NumberOfPagesSucceed(); got != 0 {
+		t.Fatalf("got %d pages recorded from the rejected results", got)
+	}
+
+	time.Sleep(2 * coordinator.LeaseDuration)
+	if status := coordinator.Status(); status.ExpiredLeases != 1 {
+		t.Fatalf("got %d expired leases, want 1", status.ExpiredLeases)
+	}
+	if err := coordinator.Report("worker-1", lease.LeaseId, page(seed)); err != nil {
+		t.Fatalf("report of the expired lease is rejected: %s", err)
+	}
+	if !c.Scrapper.IsVisited(seed) {
+		t.Error("seed of the expired lease is not recorded")
+	}
+	if err := coordinator.Report("worker-1", lease.LeaseId, page(seed)); err == nil {
+		t.Error("expired lease is reported twice")
+	}
+}
+
+// An expired lease is forgotten once its urls are scraped through the lease handed out again, so that the
+// leases of the workers which died do not pile up
+func TestCoordinatorDropsTheExpiredLeasesOfDeadWorkers(t *testing.T) {
+	site := NewSite(DefaultSiteOptions())
+	server := site.Serve()
+	defer server.Close()
+	seed := server.URL + PagePath(0)
+	c, err := collector.NewCollector(seed, 1, false, filepath.Join(t.TempDir(), "results.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	coordinator := distributed.NewCoordinator(c)
+	coordinator.LeaseDuration = 10 * time.Millisecond
+	coordinator.HostDelay = 0
+	dead := coordinator.Lease("worker-1", 1)
+	if len(dead.Urls) != 1 {
+		t.Fatalf("got lease %v", dead.Urls)
+	}
+	time.Sleep(2 * coordinator.LeaseDuration)
+	coordinator.LeaseDuration = time.Minute
+	lease := coordinator.Lease("worker-2", 1)
+	if len(lease.Urls) != 1 || lease.Urls[0].Url != seed {
+		t.Fatalf("got lease %v, want the seed leased again", lease.Urls)
+	}
+	if len(coordinator.Expired) != 1 {
+		t.Fatalf("got %d expired leases, want 1", len(coordinator.Expired))
+	}
+	results := []distributed.UrlResult{{Url: seed, Page: &collector.SucceededPage{Url: seed, Urls: []string{}}}}
+	if err := coordinator.Report("worker-2", lease.LeaseId, results); err != nil {
+		t.Fatal(err)
+	}
+	coordinator.Status()
+	if len(coordinator.Expired) != 0 {
+		t.Errorf("got %d expired leases after their urls are scraped", len(coordinator.Expired))
+	}
+	if err := coordinator.Report("worker-1", dead.LeaseId, results); err == nil {
+		t.Error("dropped lease is reported")
+	}
+}
+
+func TestCoordinatorRegistersWithPostOnly(t *testing.T) {
+	c, err := collector.NewCollector("http://example.com/", 1, false, filepath.Join(t.TempDir(), "results.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	coordinator := distributed.NewCoordinator(c)
+	server := httptest.NewServer(coordinator.Handler())
+	defer server.Close()
+	response, err := http.Get(server.URL + distributed.RegisterPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusBadRequest {
+		t.Errorf("got status %d of a GET register", response.StatusCode)
+	}
+	if status := coordinator.Status(); status.Workers != 0 {
+		t.Errorf("got %d workers", status.Workers)
+	}
+}
+
+// The workers created from the crawl config of the coordinator log in and scrape within the session
+func TestDistributedWorkerUsesTheCrawlConfig(t *testing.T) {
+	server := serveLogin(100)
+	defer server.Close()
+	dir := t.TempDir()
+	config := filepath.Join(dir, "crawler.yaml")
+	content := "crawl:\n  seeds:\n    - " + server.URL + "/\n  depth: 2\n  results_file: " + filepath.Join(dir, "results.json") +
+		"\n  login:\n    url: " + server.URL + "/login\n    csrf_selector: input[name=csrf]\n" +
+		"    fields:\n      user: crawler\n      password: secret\n"
+	if err := ioutil.WriteFile(config, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	newCollector := func() *collector.Collector {
+		t.Helper()
+		loaded, err := cli.LoadConfig(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c, err := loaded.Crawl.NewCollector()
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { c.Close() })
+		return c
+	}
+	c := newCollector()
+	coordinator := distributed.NewCoordinator(c)
+	coordinator.HostDelay = 0
+	coordinatorServer := httptest.NewServer(coordinator.Handler())
+	defer coordinatorServer.Close()
+
+	worker, err := distributed.NewCollectorWorker(coordinatorServer.URL, newCollector())
+	if err != nil {
+		t.Fatal(err)
+	}
+	worker.PollInterval = 5 * time.Millisecond
+	if err := worker.Run(make(chan struct{})); err != nil {
+		t.Fatalf("worker failed: %s", err)
+	}
+	want := []string{}
+	for _, path := range []string{"/", "/a", "/b", "/blog-outline"} {
+		want = append(want, server.URL+path)
+	}
+	if got := sortedKeys(c.Scrapper.Succeed); !reflect.DeepEqual(got, want) {
+		t.Errorf("succeeded pages\n got: %v\nwant: %v", got, want)
+	}
+	if len(c.Scrapper.Failed) > 0 {
+		t.Errorf("failed pages: %v", sortedKeys(c.Scrapper.Failed))
+	}
+	if server.logins != 1 {
+		t.Errorf("got %d logins, want 1", server.logins)
+	}
+}
+
+// flakyHandler fails the first requests of every path of the coordinator with the status code
+func flakyHandler(handler http.Handler, failures int, statusCode int) (http.Handler, map[string]int) {
+	var mutex sync.Mutex
+	requests := map[string]int{}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		requests[r.URL.Path]++
+		failed := requests[r.URL.Path] <= failures
+		mutex.Unlock()
+		if failed {
+			http.Error(w, "coordinator is not available", statusCode)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	}), requests
+}
+
+// The worker retries the leases and the reports the coordinator fails on, the rejected requests are not retried
+func TestDistributedWorkerRetriesTheFailedRequests(t *testing.T) {
+	depth := 2
+	site := NewSite(DefaultSiteOptions())
+	server := site.Serve()
+	defer server.Close()
+	c, err := collector.NewCollector(server.URL+PagePath(0), depth, false, filepath.Join(t.TempDir(), "results.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	coordinator := distributed.NewCoordinator(c)
+	coordinator.HostDelay = 0
+	handler, _ := flakyHandler(coordinator.Handler(), 2, http.StatusServiceUnavailable)
+	coordinatorServer := httptest.NewServer(handler)
+	defer coordinatorServer.Close()
+
+	worker, err := distributed.NewWorker(coordinatorServer.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	worker.Id = "worker-1"
+	worker.Concurrency = 1
+	worker.PollInterval = 5 * time.Millisecond
+	worker.RetryDelay = time.Millisecond
+	if err := worker.Run(make(chan struct{})); err != nil {
+		t.Fatalf("worker failed: %s", err)
+	}
+	got := append(sortedKeys(c.Scrapper.Succeed), sortedKeys(c.Scrapper.Failed)...)
+	sort.Strings(got)
+	want := []string{}
+	for _, path := range site.Crawled(depth) {
+		want = append(want, server.URL+path)
+	}
+	sort.Strings(want)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("crawled pages\n got: %v\nwant: %v", got, want)
+	}
+
+	for _, test := range []struct {
+		statusCode int
+		requests   int
+	}{
+		{http.StatusServiceUnavailable, 1 + worker.MaxRetries},
+		{http.StatusConflict, 1},
+	} {
+		handler, requests := flakyHandler(http.NotFoundHandler(), 100, test.statusCode)
+		failing := httptest.NewServer(handler)
+		worker.CoordinatorUrl = failing.URL
+		if err := worker.Run(make(chan struct{})); err == nil {
+			t.Errorf("%d: worker did not give up", test.statusCode)
+		}
+		failing.Close()
+		if requests[distributed.LeasePath] != test.requests {
+			t.Errorf("%d: got %d lease requests, want %d", test.statusCode, requests[distributed.LeasePath], test.requests)
+		}
+	}
+}
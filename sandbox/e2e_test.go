@@ -0,0 +1,341 @@
+package sandbox
+
+import (
+	"crawler/collector"
+	"crawler/searcher"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// The collector logs and the indexer reads its stop words relative to the working directory, the tests run in
+// a temporary one holding a copy of the stop words
+func TestMain(m *testing.M) {
+	stopWords, err := ioutil.ReadFile(filepath.Join("..", "data", "stop_words.json"))
+	if err != nil {
+		panic(err)
+	}
+	dir, err := ioutil.TempDir("", "sandbox")
+	if err != nil {
+		panic(err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "data"), 0755); err != nil {
+		panic(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "data", "stop_words.json"), stopWords, 0644); err != nil {
+		panic(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		panic(err)
+	}
+	code := m.Run()
+	_ = os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// crawl crawls the site from its home page and returns the results saved by the collector
+func crawl(t *testing.T, site *Site, depth int) (string, *collector.ResultData) {
+	t.Helper()
+	server := site.Serve()
+	t.Cleanup(server.Close)
+	file := filepath.Join(t.TempDir(), "results.json")
+	c, err := collector.NewCollector(server.URL+PagePath(0), depth, true, file)
+	if err != nil {
+		t.Fatalf("collector could not be created: %s", err)
+	}
+	t.Cleanup(func() { c.Close() })
+	if _, err := c.StartCrawling(); err != nil {
+		t.Fatalf("crawling failed: %s", err)
+	}
+	data, err := collector.LoadResultData(file)
+	if err != nil {
+		t.Fatalf("results could not be loaded: %s", err)
+	}
+	return server.URL, data
+}
+
+// normalize clears the timestamps, the response times and the transport statistics and passes the results
+// through json the way they are saved
+func normalize(t *testing.T, data *collector.ResultData) *collector.ResultData {
+	t.Helper()
+	data.BeginTimestamp = time.Time{}
+	data.EndTimestamp = time.Time{}
+	data.ExecutionInSeconds = 0
+	data.PageRatePerSec = 0
+	data.Transport = nil
+	for _, page := range data.Succeed {
+		page.Timestamp = 0
+		page.ResponseTime = 0
+		page.LastModified = 0
+	}
+	for _, page := range data.Failed {
+		page.Timestamp = 0
+	}
+	content, err := json.Marshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	normalized := &collector.ResultData{}
+	if err := json.Unmarshal(content, normalized); err != nil {
+		t.Fatal(err)
+	}
+	return normalized
+}
+
+func sortedKeys(pages interface{}) []string {
+	keys := []string{}
+	for _, key := range reflect.ValueOf(pages).MapKeys() {
+		keys = append(keys, key.String())
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func TestSiteIsDeterministic(t *testing.T) {
+	options := DefaultSiteOptions()
+	if !reflect.DeepEqual(NewSite(options), NewSite(options)) {
+		t.Fatal("sites generated with the same options differ")
+	}
+	other := options
+	other.Seed++
+	if reflect.DeepEqual(NewSite(options).Pages, NewSite(other).Pages) {
+		t.Fatal("sites generated with different seeds are the same")
+	}
+	site := NewSite(options)
+	if got := len(site.PagePaths()); got != options.Pages {
+		t.Fatalf("pages: got %d, want %d", got, options.Pages)
+	}
+	if got := len(site.PathsWithKind(KindMissing)); got != options.BrokenLinks {
+		t.Fatalf("broken links: got %d, want %d", got, options.BrokenLinks)
+	}
+}
+
+func TestCrawlProducesExpectedResults(t *testing.T) {
+	site := NewSite(DefaultSiteOptions())
+	for _, depth := range []int{1, 2, 3, 6} {
+		base, data := crawl(t, site, depth)
+		got := normalize(t, data)
+		want := normalize(t, site.Expected(base, depth))
+		if !reflect.DeepEqual(sortedKeys(got.Succeed), sortedKeys(want.Succeed)) {
+			t.Errorf("depth %d: succeeded pages\n got: %v\nwant: %v", depth, sortedKeys(got.Succeed), sortedKeys(want.Succeed))
+		}
+		if !reflect.DeepEqual(sortedKeys(got.Failed), sortedKeys(want.Failed)) {
+			t.Errorf("depth %d: failed pages\n got: %v\nwant: %v", depth, sortedKeys(got.Failed), sortedKeys(want.Failed))
+		}
+		for u, page := range want.Succeed {
+			if gotPage, exists := got.Succeed[u]; exists && !reflect.DeepEqual(gotPage, page) {
+				gotJSON, _ := json.MarshalIndent(gotPage, "", "  ")
+				wantJSON, _ := json.MarshalIndent(page, "", "  ")
+				t.Errorf("depth %d: page %s\n got: %s\nwant: %s", depth, u, gotJSON, wantJSON)
+			}
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("depth %d: results differ from the expected results", depth)
+		}
+	}
+}
+
+func TestSlowPagesAreTimed(t *testing.T) {
+	options := DefaultSiteOptions()
+	options.SlowPages = 5
+	site := NewSite(options)
+	base, data := crawl(t, site, 10)
+	slow := 0
+	for _, path := range site.Crawled(10) {
+		page := site.Pages[path]
+		if !page.Slow {
+			continue
+		}
+		slow++
+		if got := data.Succeed[base+path].ResponseTime; got < options.SlowDelay.Milliseconds() {
+			t.Errorf("page %s: response time %dms, want at least %dms", path, got, options.SlowDelay.Milliseconds())
+		}
+	}
+	if slow == 0 {
+		t.Fatal("no slow page is crawled")
+	}
+}
+
+func TestRobotsTxtPointsToSitemap(t *testing.T) {
+	site := NewSite(DefaultSiteOptions())
+	server := site.Serve()
+	defer server.Close()
+	response, err := http.Get(server.URL + "/robots.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := ioutil.ReadAll(response.Body)
+	response.Body.Close()
+	want := "User-agent: *\nDisallow: /private/\nSitemap: " + server.URL + "/sitemap.xml\n"
+	if string(body) != want {
+		t.Fatalf("robots.txt\n got: %q\nwant: %q", body, want)
+	}
+	sitemap, err := collector.FetchSitemap(server.URL + "/sitemap.xml")
+	if err != nil {
+		t.Fatalf("sitemap could not be fetched: %s", err)
+	}
+	got := []string{}
+	for _, u := range sitemap.Urls {
+		got = append(got, strings.TrimPrefix(u.Loc, server.URL))
+	}
+	if !reflect.DeepEqual(got, site.PagePaths()) {
+		t.Fatalf("sitemap urls\n got: %v\nwant: %v", got, site.PagePaths())
+	}
+}
+
+// The collector follows the robots meta tags but does not read the robots.txt, the linked pages under the
+// disallowed path are crawled like the other pages
+func TestDisallowedPagesAreCrawled(t *testing.T) {
+	site := NewSite(DefaultSiteOptions())
+	depth := 6
+	base, data := crawl(t, site, depth)
+	private := []string{}
+	for _, path := range site.Paths {
+		if site.Pages[path].Kind == KindPrivate {
+			if !strings.HasPrefix(path, site.Options.Disallow[0]) {
+				t.Errorf("private page %s is not under %s", path, site.Options.Disallow[0])
+			}
+			private = append(private, path)
+		}
+	}
+	if len(private) != site.Options.PrivatePages {
+		t.Fatalf("got %d private pages, want %d", len(private), site.Options.PrivatePages)
+	}
+	want := normalize(t, site.Expected(base, depth))
+	got := normalize(t, data)
+	for _, path := range private {
+		page, crawled := got.Succeed[base+path]
+		if !crawled {
+			t.Errorf("private page %s is not crawled", path)
+			continue
+		}
+		if !reflect.DeepEqual(page, want.Succeed[base+path]) {
+			t.Errorf("private page %s differs from the expected page", path)
+		}
+	}
+}
+
+func TestSearchFindsCrawledPages(t *testing.T) {
+	site := NewSite(DefaultSiteOptions())
+	depth := 10
+	server := site.Serve()
+	defer server.Close()
+	base := server.URL
+	file := filepath.Join(t.TempDir(), "results.json")
+	c, err := collector.NewCollector(base+PagePath(0), depth, true, file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if _, err := c.StartCrawling(); err != nil {
+		t.Fatal(err)
+	}
+	indexer, err := searcher.NewIndexer()
+	if err != nil {
+		t.Fatalf("indexer could not be created: %s", err)
+	}
+	indexer.CollapseDuplicates = false
+	if err := indexer.LoadCollectorDocument(file, false); err != nil {
+		t.Fatalf("results could not be indexed: %s", err)
+	}
+
+	search := func(query string) []string {
+		urls := []string{}
+		for _, result := range indexer.Search(query) {
+			urls = append(urls, result.Url)
+		}
+		return urls
+	}
+	topics := map[string][]string{}
+	for _, path := range site.Crawled(depth) {
+		page := site.Pages[path]
+		var query string
+		switch page.Kind {
+		case KindPage:
+			if page.Index == 0 {
+				continue
+			}
+			query = Token(page.Index)
+		case KindRedirect:
+			query = MovedToken(site.Pages[page.Target].Index)
+		case KindText:
+			query = TextToken(page.Index)
+		case KindPrivate:
+			query = PrivateToken(page.Index)
+		default:
+			continue
+		}
+		want := []string{base + path}
+		if page.NoIndex {
+			want = []string{}
+		} else if page.Topic != "" {
+			topics[page.Topic] = append(topics[page.Topic], base+path)
+		}
+		if got := search(query); !reflect.DeepEqual(got, want) {
+			t.Errorf("search %q: got %v, want %v", query, got, want)
+		}
+	}
+	if len(topics) == 0 {
+		t.Fatal("no topic page is crawled")
+	}
+	for topic, want := range topics {
+		got := search(strings.ToUpper(topic))
+		sort.Strings(got)
+		sort.Strings(want)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("search %q:\n got: %v\nwant: %v", topic, got, want)
+		}
+	}
+}
+
+func TestDuplicatesUseTheCrawlDistance(t *testing.T) {
+	site := NewSite(DefaultSiteOptions())
+	server := site.Serve()
+	defer server.Close()
+	file := filepath.Join(t.TempDir(), "results.json")
+	c, err := collector.NewCollector(server.URL+PagePath(0), 2, true, file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if err := c.EnableDeduplication(5, false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.StartCrawling(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := collector.LoadResultData(file)
+	if err != nil {
+		t.Fatalf("results could not be loaded: %s", err)
+	}
+	if data.MaxDuplicateDistance == nil || *data.MaxDuplicateDistance != 5 {
+		t.Fatalf("got max duplicate distance %v, want 5", data.MaxDuplicateDistance)
+	}
+
+	// The fingerprints differ in 5 bits, more than the default distance
+	pages := map[string]*collector.SucceededPage{
+		"https://example.com/a": {Url: "https://example.com/a", Fingerprint: collector.FormatFingerprint(0)},
+		"https://example.com/b": {Url: "https://example.com/b", Fingerprint: collector.FormatFingerprint(0x1f)},
+	}
+	for _, distance := range []*int{nil, data.MaxDuplicateDistance} {
+		indexer, err := searcher.NewIndexer()
+		if err != nil {
+			t.Fatalf("indexer could not be created: %s", err)
+		}
+		indexer.ComputeDuplicates(&collector.ResultData{Succeed: pages, MaxDuplicateDistance: distance})
+		want := map[string]string{}
+		if distance != nil {
+			want["https://example.com/b"] = "https://example.com/a"
+		}
+		if !reflect.DeepEqual(indexer.Duplicates, want) {
+			t.Errorf("distance %v: got duplicates %v, want %v", distance, indexer.Duplicates, want)
+		}
+	}
+}
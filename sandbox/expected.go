@@ -0,0 +1,130 @@
+package sandbox
+
+import (
+	"crawler/collector"
+	"net/http"
+	"strings"
+)
+
+// Crawled returns the paths a crawl from the home page with the depth scrapes, a resource is scraped when the page
+// linking it is scraped and followable and the resource is within the depth
+func (s *Site) Crawled(depth int) []string {
+	crawled := []string{PagePath(0)}
+	queue := []*SitePage{s.Pages[PagePath(0)]}
+	for len(queue) > 0 {
+		page := queue[0]
+		queue = queue[1:]
+		if page.NoFollow {
+			continue
+		}
+		for _, link := range page.Links {
+			target := s.Pages[link.Path]
+			// Links up lead to the pages scraped before
+			if target.Parent != page.Path || target.Level > depth-1 {
+				continue
+			}
+			crawled = append(crawled, target.Path)
+			if target.Kind == KindPage {
+				queue = append(queue, target)
+			}
+		}
+	}
+	return crawled
+}
+
+// Expected returns the results a crawl of the site served at the base url from its home page with the depth is
+// expected to produce, the timestamps, the response times and the transport statistics are left out
+func (s *Site) Expected(base string, depth int) *collector.ResultData {
+	data := &collector.ResultData{
+		Seed:    base + PagePath(0),
+		Depth:   depth,
+		Succeed: map[string]*collector.SucceededPage{},
+		Failed:  map[string]*collector.FailedPage{},
+	}
+	for _, path := range s.Crawled(depth) {
+		page := s.Pages[path]
+		u := base + path
+		switch page.Kind {
+		case KindMissing:
+			data.Failed[u] = &collector.FailedPage{Url: u, FailReason: "status code: 404\n", StatusCode: 404}
+		case KindRedirect:
+			moved := s.Pages[page.Target]
+			expected := s.expectedHTML(base, moved, u)
+			expected.Redirects = []string{u}
+			expected.FinalUrl = base + moved.Path
+			data.Succeed[u] = expected
+		case KindText:
+			expected := &collector.SucceededPage{
+				Url:           u,
+				Title:         page.Title,
+				Description:   page.Title,
+				ContentType:   TextContentType,
+				ContentLength: int64(len(page.Body())),
+				StatusCode:    http.StatusOK,
+				Urls:          []string{},
+				Paragrahps:    append([]string{page.Title}, page.Paragraphs...),
+				Language:      "en",
+				LanguageFrom:  collector.LanguageFromHeader,
+			}
+			fingerprint(expected)
+			data.Succeed[u] = expected
+		case KindBinary:
+			data.Succeed[u] = &collector.SucceededPage{
+				Url:           u,
+				ContentType:   BinaryContentType,
+				ContentLength: BinarySize,
+				StatusCode:    http.StatusOK,
+				Urls:          []string{},
+				Paragrahps:    []string{},
+			}
+		default:
+			data.Succeed[u] = s.expectedHTML(base, page, u)
+		}
+	}
+	data.SucceededPages = len(data.Succeed)
+	data.FailedPages = len(data.Failed)
+	data.TotalPages = data.SucceededPages + data.FailedPages
+	return data
+}
+
+func (s *Site) expectedHTML(base string, page *SitePage, u string) *collector.SucceededPage {
+	expected := &collector.SucceededPage{
+		Url:           u,
+		Title:         page.Title,
+		Description:   page.Description,
+		ContentType:   HTMLContentType,
+		ContentLength: int64(len(page.Body())),
+		StatusCode:    http.StatusOK,
+		Urls:          []string{},
+		Paragrahps:    page.Paragraphs,
+		Language:      "en",
+		LanguageFrom:  collector.LanguageFromHTML,
+		Metadata:      &collector.PageMetadata{Lang: "en"},
+	}
+	for _, link := range page.Links {
+		linkURL := base + link.Path
+		if !collector.URLExists(expected.Urls, linkURL) {
+			expected.Urls = append(expected.Urls, linkURL)
+		}
+		expected.Links = append(expected.Links, &collector.PageLink{
+			Url:    linkURL,
+			Text:   link.Text,
+			Source: collector.LinkSourceAnchor,
+		})
+	}
+	if robots := page.Robots(); robots != "" {
+		expected.Robots = &collector.RobotsDirectives{
+			NoIndex:    page.NoIndex,
+			NoFollow:   page.NoFollow,
+			Directives: strings.Split(robots, ", "),
+		}
+	}
+	fingerprint(expected)
+	return expected
+}
+
+func fingerprint(page *collector.SucceededPage) {
+	if value, ok := collector.PageFingerprint(page); ok {
+		page.Fingerprint = collector.FormatFingerprint(value)
+	}
+}
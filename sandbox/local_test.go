@@ -0,0 +1,63 @@
+package sandbox
+
+import (
+	"crawler/collector"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// A directory crawled under its public url records the pages under that url, the directories without an index
+// file and the missing files fail and the links out of the site are not followed
+func TestLocalSiteIsCrawledUnderItsPublicUrl(t *testing.T) {
+	root := t.TempDir()
+	for name, content := range map[string]string{
+		"index.html": `<html><head><title>Home</title></head><body><a href="docs/">Docs</a><a href="blog/index.html">Blog</a>` +
+			`<a href="assets/">Assets</a><a href="missing.html">Missing</a><a href="https://example.com/">Out</a></body></html>`,
+		"docs/index.html": `<html><head><title>Docs</title></head><body><a href="guide.html">Guide</a><a href="../">Home</a></body></html>`,
+		"docs/guide.html": `<html><head><title>Guide</title></head><body><a href="/site/docs/">Docs</a></body></html>`,
+		"blog/index.html": `<html><head><title>Blog</title></head><body><p>Posts</p></body></html>`,
+		"orphan.html":     `<html><head><title>Orphan</title></head><body><p>Not linked</p></body></html>`,
+		"assets/logo.txt": "logo",
+	} {
+		file := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(file, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	base := "https://docs.example.com/site/"
+	results := filepath.Join(t.TempDir(), "results.json")
+	c, err := collector.NewLocalCollector(root, base, 3, true, results)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if c.Seed != base {
+		t.Errorf("got seed %s, want %s", c.Seed, base)
+	}
+	if _, err := c.StartCrawling(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := collector.LoadResultData(results)
+	if err != nil {
+		t.Fatal(err)
+	}
+	succeeded := []string{base, base + "blog/", base + "blog/index.html", base + "docs/", base + "docs/guide.html", base + "orphan.html"}
+	if got := sortedKeys(data.Succeed); !reflect.DeepEqual(got, succeeded) {
+		t.Errorf("succeeded pages\n got: %v\nwant: %v", got, succeeded)
+	}
+	failed := []string{base + "assets/", base + "missing.html"}
+	if got := sortedKeys(data.Failed); !reflect.DeepEqual(got, failed) {
+		t.Errorf("failed pages\n got: %v\nwant: %v", got, failed)
+	}
+	for _, u := range failed {
+		if page := data.Failed[u]; page != nil && page.StatusCode != 404 {
+			t.Errorf("%s: got status code %d, want 404", u, page.StatusCode)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package sandbox
+
+import (
+	"crawler/collector"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// loginServer serves pages to the sessions logged in with the form of /login, the form carries a single use
+// csrf token. A session expires after budget pages and the pages redirect to /login without a live session.
+type loginServer struct {
+	*httptest.Server
+	budget   int
+	mutex    sync.Mutex
+	tokens   map[string]bool
+	sessions map[string]int
+	logins   int
+	visited  map[string]int
+}
+
+func serveLogin(budget int) *loginServer {
+	s := &loginServer{budget: budget, tokens: map[string]bool{}, sessions: map[string]int{}, visited: map[string]int{}}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+func (s *loginServer) serve(w http.ResponseWriter, r *http.Request) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	w.Header().Set("Content-Type", "text/html")
+	if r.URL.Path == "/login" {
+		if r.Method == http.MethodPost && s.tokens[r.PostFormValue("csrf")] &&
+			r.PostFormValue("user") == "crawler" && r.PostFormValue("password") == "secret" {
+			delete(s.tokens, r.PostFormValue("csrf"))
+			s.logins++
+			id := fmt.Sprintf("session%d", s.logins)
+			s.sessions[id] = s.budget
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: id, Path: "/"})
+			fmt.Fprint(w, `<html><body><p>Welcome</p></body></html>`)
+			return
+		}
+		token := fmt.Sprintf("token%d", len(s.tokens)+s.logins)
+		s.tokens[token] = true
+		fmt.Fprintf(w, `<html><body><form method="post" action="/login"><input type="hidden" name="csrf" value="%s">`+
+			`<input name="user"><input type="password" name="password"></form></body></html>`, token)
+		return
+	}
+	cookie, err := r.Cookie("session")
+	if err != nil || s.sessions[cookie.Value] <= 0 {
+		http.Redirect(w, r, "/login", http.StatusFound)
+		return
+	}
+	s.sessions[cookie.Value]--
+	s.visited[r.URL.Path]++
+	if r.URL.Path == "/" {
+		fmt.Fprint(w, `<html><body><a href="/a">a</a><a href="/b">b</a><a href="/blog-outline">outline</a>`+
+			`<a href="/account/sign-off">sign off</a><a href="/logout?next=/">log out</a></body></html>`)
+		return
+	}
+	fmt.Fprintf(w, `<html><head><title>%s</title></head><body><p>Members only</p></body></html>`, r.URL.Path)
+}
+
+func crawlLogin(t *testing.T, server *loginServer, maxLogins int, seeds ...string) *collector.ResultData {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), "results.json")
+	c, err := collector.NewCollector(server.URL+"/", 2, true, file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	c.Concurrency = 1
+	for _, seed := range seeds {
+		c.AddSeed(server.URL + seed)
+	}
+	form := collector.LoginForm{
+		Url:          server.URL + "/login",
+		Fields:       map[string]string{"user": "crawler", "password": "secret"},
+		CSRFSelector: "input[name=csrf]",
+		MaxLogins:    maxLogins,
+	}
+	if err := c.EnableLogin(form); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.StartCrawling(); err != nil {
+		t.Fatalf("crawling failed: %s", err)
+	}
+	data, err := collector.LoadResultData(file)
+	if err != nil {
+		t.Fatalf("results could not be loaded: %s", err)
+	}
+	return data
+}
+
+// The crawl logs in with the csrf token, keeps the session cookie and logs in again when the pages redirect
+// to the login page, the logout links are not followed
+func TestLoginRenewsExpiredSessions(t *testing.T) {
+	server := serveLogin(4)
+	defer server.Close()
+	data := crawlLogin(t, server, collector.DefaultMaxLogins)
+
+	want := []string{"/", "/a", "/b", "/blog-outline"}
+	for i := range want {
+		want[i] = server.URL + want[i]
+	}
+	if got := sortedKeys(data.Succeed); strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("succeeded pages\n got: %v\nwant: %v", got, want)
+	}
+	if len(data.Failed) > 0 {
+		t.Errorf("failed pages: %v", sortedKeys(data.Failed))
+	}
+	for _, page := range data.Succeed {
+		if len(page.Redirects) > 0 {
+			t.Errorf("page %s kept the redirects of its expired session: %v", page.Url, page.Redirects)
+		}
+	}
+	// The head and get requests of the four pages take two sessions
+	if server.logins != 2 {
+		t.Errorf("got %d logins, want 2", server.logins)
+	}
+	for _, path := range []string{"/logout", "/account/sign-off"} {
+		if server.visited[path] > 0 {
+			t.Errorf("%s is requested", path)
+		}
+	}
+}
+
+// Sessions expiring right after every login are given up after MaxLogins renewals without a page succeeding
+func TestLoginGivesUpSessionsWhichKeepExpiring(t *testing.T) {
+	server := serveLogin(0)
+	defer server.Close()
+	maxLogins := 2
+	data := crawlLogin(t, server, maxLogins, "/a", "/b", "/c")
+
+	if len(data.Succeed) > 0 {
+		t.Errorf("succeeded pages: %v", sortedKeys(data.Succeed))
+	}
+	if got := len(data.Failed); got != 4 {
+		t.Fatalf("got %d failed pages, want 4", got)
+	}
+	exhausted := 0
+	for _, page := range data.Failed {
+		if strings.Contains(page.FailReason, "session keeps expiring") {
+			exhausted++
+		}
+	}
+	if exhausted != 4-maxLogins {
+		t.Errorf("got %d pages failing on the exhausted logins, want %d", exhausted, 4-maxLogins)
+	}
+	if server.logins != 1+maxLogins {
+		t.Errorf("got %d logins, want %d", server.logins, 1+maxLogins)
+	}
+}
@@ -0,0 +1,137 @@
+package sandbox
+
+import (
+	"bytes"
+	"crawler/cli"
+	"crawler/collector"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// feedServer serves an rss feed of the items added to it, the pages of the items hold their token
+type feedServer struct {
+	*httptest.Server
+	mutex sync.Mutex
+	items []string
+}
+
+func serveFeed() *feedServer {
+	s := &feedServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/feed.xml" {
+			s.mutex.Lock()
+			defer s.mutex.Unlock()
+			w.Header().Set("Content-Type", "application/rss+xml")
+			fmt.Fprintf(w, `<?xml version="1.0"?><rss version="2.0"><channel><title>News</title>`)
+			for i, name := range s.items {
+				fmt.Fprintf(w, `<item><guid>%s</guid><title>%s</title><link>/%s.html</link><pubDate>Mon, 0%d Jan 2024 10:00:00 GMT</pubDate></item>`,
+					name, name, name, i+1)
+			}
+			fmt.Fprintf(w, `</channel></rss>`)
+			return
+		}
+		name := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/"), ".html")
+		if strings.HasPrefix(name, "missing") {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprintf(w, `<html lang="en"><head><title>%s</title></head><body><p>News about %stoken.</p></body></html>`, name, name)
+	}))
+	return s
+}
+
+func (s *feedServer) add(names ...string) {
+	s.mutex.Lock()
+	s.items = append(s.items, names...)
+	s.mutex.Unlock()
+}
+
+// The monitor mode scrapes the pages of the new feed items and indexes them into the index of the earlier polls,
+// the items whose pages keep failing are given up
+func TestMonitorIndexesNewFeedItems(t *testing.T) {
+	server := serveFeed()
+	defer server.Close()
+	dir := t.TempDir()
+	index := filepath.Join(dir, "indexes.json")
+	state := filepath.Join(dir, "feeds.json")
+	poll := func() int {
+		t.Helper()
+		var stdout, stderr bytes.Buffer
+		code := cli.Run([]string{"monitor", "-once", "-json", "-state", state, "-out", filepath.Join(dir, "results.json"),
+			"-index", index, server.URL + "/feed.xml"}, &stdout, &stderr)
+		if code != cli.ExitOK {
+			t.Fatalf("monitor exited with %d: %s", code, stderr.String())
+		}
+		var summary cli.MonitorSummary
+		if err := json.Unmarshal(stdout.Bytes(), &summary); err != nil {
+			t.Fatalf("summary %q: %s", stdout.String(), err)
+		}
+		return summary.NewPages
+	}
+	search := func(token string) []string {
+		t.Helper()
+		var stdout, stderr bytes.Buffer
+		if code := cli.Run([]string{"search", "-json", "-index", index, token}, &stdout, &stderr); code != cli.ExitOK {
+			t.Fatalf("search exited with %d: %s", code, stderr.String())
+		}
+		var out cli.SearchOutput
+		if err := json.Unmarshal(stdout.Bytes(), &out); err != nil {
+			t.Fatal(err)
+		}
+		urls := []string{}
+		for _, result := range out.Results {
+			urls = append(urls, result.Url)
+		}
+		return urls
+	}
+	seen := func() []string {
+		t.Helper()
+		content, err := ioutil.ReadFile(state)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var feeds []*collector.FeedState
+		if err := json.Unmarshal(content, &feeds); err != nil || len(feeds) != 1 {
+			t.Fatalf("state %s: %v", content, err)
+		}
+		return feeds[0].SeenIds
+	}
+
+	server.add("first", "missing")
+	if got := poll(); got != 1 {
+		t.Fatalf("first poll: %d new pages, want 1", got)
+	}
+	if got, want := search("firsttoken"), []string{server.URL + "/first.html"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("search after the first poll: got %v, want %v", got, want)
+	}
+
+	server.add("second")
+	if got := poll(); got != 1 {
+		t.Fatalf("second poll: %d new pages, want 1", got)
+	}
+	for _, name := range []string{"first", "second"} {
+		if got, want := search(name+"token"), []string{server.URL + "/" + name + ".html"}; !reflect.DeepEqual(got, want) {
+			t.Fatalf("search after the second poll: got %v, want %v", got, want)
+		}
+	}
+	if got, want := seen(), []string{"first", "second"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("seen items after the second poll: got %v, want %v", got, want)
+	}
+
+	// The third failed scrape of the missing page gives the item up
+	if got := poll(); got != 0 {
+		t.Fatalf("third poll: %d new pages, want 0", got)
+	}
+	if got, want := seen(), []string{"first", "second", "missing"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("seen items after the third poll: got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,149 @@
+package sandbox
+
+import (
+	"crawler/scheduler"
+	"crawler/searcher"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+)
+
+// The jobs of the scheduler index into the same indexer, the runs of a job replace the pages of its previous run
+// and keep the pages of the other jobs
+func TestSchedulerJobsShareTheIndex(t *testing.T) {
+	sites := map[string]*Site{}
+	servers := map[string]string{}
+	for i, name := range []string{"first", "second"} {
+		options := DefaultSiteOptions()
+		options.Seed = int64(i + 1)
+		sites[name] = NewSite(options)
+		server := sites[name].Serve()
+		defer server.Close()
+		servers[name] = server.URL
+	}
+	indexer, err := searcher.NewIndexer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	indexer.CollapseDuplicates = false
+	dir := t.TempDir()
+	s, err := scheduler.NewScheduler(filepath.Join(dir, "history.json"), indexer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	s.IndexFile = filepath.Join(dir, "index", "indexes.json")
+	if err := os.MkdirAll(filepath.Dir(s.IndexFile), 0755); err != nil {
+		t.Fatal(err)
+	}
+	run := func(name string, depth int) {
+		t.Helper()
+		job := &scheduler.CrawlJob{
+			Name:        name,
+			Seeds:       []string{servers[name] + PagePath(0)},
+			Depth:       depth,
+			Schedule:    "@daily",
+			ResultsFile: filepath.Join(dir, name+".json"),
+		}
+		if err := s.AddJob(job); err != nil {
+			t.Fatal(err)
+		}
+		record, err := s.RunJob(name)
+		if err != nil || record.Status != scheduler.RunSucceeded {
+			t.Fatalf("job %s did not succeed: %v %+v", name, err, record)
+		}
+	}
+	// Pages of the site at the index have the same token on both sites
+	search := func(index int) []string {
+		urls := []string{}
+		for _, result := range indexer.Search(Token(index)) {
+			urls = append(urls, result.Url)
+		}
+		sort.Strings(urls)
+		return urls
+	}
+	ranked := func(name string) int {
+		count := 0
+		for url := range indexer.PageRanks {
+			if len(url) > len(servers[name]) && url[:len(servers[name])] == servers[name] {
+				count++
+			}
+		}
+		return count
+	}
+	succeeded := func(name string, depth int) int {
+		return len(sites[name].Expected(servers[name], depth).Succeed)
+	}
+
+	run("first", 3)
+	run("second", 3)
+	want := []string{servers["first"] + PagePath(1), servers["second"] + PagePath(1)}
+	sort.Strings(want)
+	if got := search(1); !reflect.DeepEqual(got, want) {
+		t.Fatalf("search after both jobs: got %v, want %v", got, want)
+	}
+	for _, name := range []string{"first", "second"} {
+		if got, want := ranked(name), succeeded(name, 3); got != want {
+			t.Fatalf("page ranks of %s: got %d, want %d", name, got, want)
+		}
+	}
+
+	// The second run of the first job only reaches its home page
+	run("first", 1)
+	if got, want := search(1), []string{servers["second"] + PagePath(1)}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("search after the rerun: got %v, want %v", got, want)
+	}
+	if got, want := ranked("first"), succeeded("first", 1); got != want {
+		t.Fatalf("page ranks of first after the rerun: got %d, want %d", got, want)
+	}
+	if got, want := ranked("second"), succeeded("second", 3); got != want {
+		t.Fatalf("page ranks of second after the rerun: got %d, want %d", got, want)
+	}
+
+	// The index is saved where the scheduler is told, not into the working directory
+	saved, err := searcher.NewIndexer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := saved.LoadIndexDump(s.IndexFile); err != nil {
+		t.Fatalf("index dump could not be loaded: %s", err)
+	}
+	if !reflect.DeepEqual(saved.Indexes, indexer.Indexes) || !reflect.DeepEqual(saved.PageRanks, indexer.PageRanks) {
+		t.Fatal("saved index differs from the index of the scheduler")
+	}
+	if _, err := os.Stat(searcher.IndexDumpFile); !os.IsNotExist(err) {
+		t.Fatalf("index dump is saved into the working directory: %v", err)
+	}
+}
+
+func TestSchedulerHistoryKeepsEveryRecord(t *testing.T) {
+	dir := t.TempDir()
+	s, err := scheduler.NewScheduler(filepath.Join(dir, "history.json"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := s.AddRecord(&scheduler.RunRecord{Job: fmt.Sprintf("job%d", i), Status: scheduler.RunSkipped}); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	loaded, err := scheduler.NewScheduler(s.HistoryFile, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer loaded.Close()
+	if got := len(loaded.History); got != 50 {
+		t.Fatalf("saved history holds %d records, want 50", got)
+	}
+}
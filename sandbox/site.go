@@ -0,0 +1,441 @@
+// Package sandbox generates deterministic synthetic web sites which are served with httptest, the generated site
+// knows the results a crawl of it is expected to produce so that the crawler can be tested end to end
+package sandbox
+
+import (
+	"fmt"
+	"html"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	KindPage     = "page"
+	KindMoved    = "moved"
+	KindText     = "text"
+	KindBinary   = "binary"
+	KindMissing  = "missing"
+	KindRedirect = "redirect"
+	KindPrivate  = "private"
+)
+
+const (
+	HTMLContentType   = "text/html; charset=utf-8"
+	TextContentType   = "text/plain; charset=utf-8"
+	BinaryContentType = "application/octet-stream"
+	// Bytes of the binary documents
+	BinarySize = 512
+)
+
+// Topics of the subtrees under the home page, the pages of a subtree share its topic word
+var Topics = []string{
+	"astronomy", "botany", "chemistry", "geology", "zoology", "meteorology", "oceanography", "mineralogy",
+}
+
+// Words the paragraphs of the pages are made of
+var vocabulary = []string{
+	"river", "mountain", "engine", "window", "garden", "silver", "pencil", "harbor", "orange", "castle",
+	"violin", "planet", "forest", "bridge", "candle", "desert", "falcon", "glacier", "hammer", "island",
+	"jacket", "kettle", "lantern", "meadow", "needle", "oyster", "parrot", "quartz", "rocket", "saddle",
+	"tunnel", "valley", "wagon", "yarn", "anchor", "barrel", "copper", "dragon", "emerald", "feather",
+	"guitar", "helmet", "igloo", "jungle", "kitten", "ladder", "magnet", "nectar", "orchid", "pepper",
+	"quiver", "ribbon", "spider", "tiger", "umbrella", "velvet", "walnut", "yogurt", "zephyr", "acorn",
+	"blossom", "cactus", "dolphin", "ember", "fossil", "granite", "horizon", "ivory", "jasmine", "kayak",
+	"lemon", "marble", "nutmeg", "olive", "pebble", "quill", "raven", "summit", "thistle", "urchin",
+}
+
+// SiteOptions shape the generated site, the same options always generate the same site
+type SiteOptions struct {
+	// Html pages of the tree under the home page, the home page included
+	Pages int
+	// Children linked by every page of the tree
+	Branching int
+	// Links to the pages which do not exist
+	BrokenLinks int
+	// Links redirecting to the pages which are linked only through the redirects
+	Redirects int
+	// Pages responding after the delay
+	SlowPages int
+	SlowDelay time.Duration
+	// Plain text and binary documents linked by the pages
+	TextDocuments   int
+	BinaryDocuments int
+	// Pages with the robots nofollow and noindex meta tags
+	NoFollowPages int
+	NoIndexPages  int
+	// Paths disallowed by the robots.txt
+	Disallow []string
+	// Html pages under the first disallowed path linked by the pages
+	PrivatePages int
+	Seed         int64
+}
+
+func DefaultSiteOptions() SiteOptions {
+	return SiteOptions{
+		Pages:           40,
+		Branching:       3,
+		BrokenLinks:     4,
+		Redirects:       3,
+		SlowPages:       2,
+		SlowDelay:       50 * time.Millisecond,
+		TextDocuments:   2,
+		BinaryDocuments: 2,
+		NoFollowPages:   1,
+		NoIndexPages:    1,
+		Disallow:        []string{"/private/"},
+		PrivatePages:    2,
+		Seed:            1,
+	}
+}
+
+// SiteLink is an anchor of a page
+type SiteLink struct {
+	Path string
+	Text string
+}
+
+// SitePage is a resource of the site, the html pages of the tree link to their children, the extra resources,
+// their parents and the home page in this order
+type SitePage struct {
+	Path  string
+	Kind  string
+	Index int
+	// Level of the page under the home page, the resources are one level below the page linking them
+	Level       int
+	Parent      string
+	Topic       string
+	Title       string
+	Description string
+	Paragraphs  []string
+	Links       []SiteLink
+	Slow        bool
+	NoFollow    bool
+	NoIndex     bool
+	// Path the redirect points to
+	Target string
+}
+
+// Site is a generated site, the pages are keyed by their paths
+type Site struct {
+	Options SiteOptions
+	Pages   map[string]*SitePage
+	// Paths of the resources in the order they are generated
+	Paths []string
+}
+
+func NewSite(options SiteOptions) *Site {
+	if options.Pages < 1 {
+		options.Pages = 1
+	}
+	if options.Branching < 1 {
+		options.Branching = 1
+	}
+	random := rand.New(rand.NewSource(options.Seed))
+	s := &Site{Options: options, Pages: map[string]*SitePage{}}
+
+	// Pages of the tree, the children of the page i are the pages i*branching+1 to i*branching+branching
+	tree := make([]*SitePage, options.Pages)
+	for i := range tree {
+		page := &SitePage{Path: PagePath(i), Kind: KindPage, Index: i}
+		if i > 0 {
+			parent := tree[(i-1)/options.Branching]
+			page.Parent = parent.Path
+			page.Level = parent.Level + 1
+			page.Topic = parent.Topic
+			if parent.Index == 0 {
+				page.Topic = Topics[(i-1)%len(Topics)]
+			}
+			page.Title = fmt.Sprintf("%s %s", strings.Title(page.Topic), Token(i))
+			page.Description = fmt.Sprintf("Synthetic page %s about %s", Token(i), page.Topic)
+		} else {
+			page.Title = "Sandbox home"
+			page.Description = "Home of the synthetic sandbox site"
+		}
+		page.Paragraphs = paragraphs(random, page.Topic)
+		tree[i] = page
+		s.add(page)
+	}
+	for i := 1; i < len(tree); i++ {
+		parent := tree[(i-1)/options.Branching]
+		parent.Links = append(parent.Links, SiteLink{Path: tree[i].Path, Text: tree[i].Title})
+	}
+
+	// Extra resources are linked by the pages picked at random
+	for i := 0; i < options.BrokenLinks; i++ {
+		s.addResource(random, tree, &SitePage{Path: fmt.Sprintf("/missing/%d.html", i), Kind: KindMissing, Index: i}, "Missing")
+	}
+	for i := 0; i < options.Redirects; i++ {
+		moved := &SitePage{
+			Path:        fmt.Sprintf("/moved/%d.html", i),
+			Kind:        KindMoved,
+			Index:       i,
+			Title:       fmt.Sprintf("Moved %s", MovedToken(i)),
+			Description: fmt.Sprintf("Moved page %s", MovedToken(i)),
+			Paragraphs:  paragraphs(random, ""),
+		}
+		redirect := &SitePage{Path: fmt.Sprintf("/r/%d", i), Kind: KindRedirect, Index: i, Target: moved.Path}
+		s.addResource(random, tree, redirect, "Redirect")
+		moved.Level = redirect.Level
+		moved.Parent = redirect.Parent
+		s.add(moved)
+	}
+	for i := 0; i < options.TextDocuments; i++ {
+		text := &SitePage{
+			Path:       fmt.Sprintf("/files/notes-%d.txt", i),
+			Kind:       KindText,
+			Index:      i,
+			Title:      fmt.Sprintf("Notes %s", TextToken(i)),
+			Paragraphs: paragraphs(random, ""),
+		}
+		s.addResource(random, tree, text, "Notes")
+	}
+	for i := 0; i < options.BinaryDocuments; i++ {
+		s.addResource(random, tree, &SitePage{Path: fmt.Sprintf("/files/data-%d.bin", i), Kind: KindBinary, Index: i}, "Data")
+	}
+	for i := 0; i < options.PrivatePages && len(options.Disallow) > 0; i++ {
+		private := &SitePage{
+			Path:        fmt.Sprintf("%s%d.html", options.Disallow[0], i),
+			Kind:        KindPrivate,
+			Index:       i,
+			Title:       fmt.Sprintf("Private %s", PrivateToken(i)),
+			Description: fmt.Sprintf("Private page %s", PrivateToken(i)),
+			Paragraphs:  paragraphs(random, ""),
+		}
+		s.addResource(random, tree, private, "Private")
+	}
+
+	// Slow and robots pages are picked among the pages under the home page
+	picks := random.Perm(len(tree) - 1)
+	take := func(n int) []*SitePage {
+		taken := []*SitePage{}
+		for ; n > 0 && len(picks) > 0; n-- {
+			taken = append(taken, tree[picks[0]+1])
+			picks = picks[1:]
+		}
+		return taken
+	}
+	for _, page := range take(options.SlowPages) {
+		page.Slow = true
+	}
+	for _, page := range take(options.NoFollowPages) {
+		page.NoFollow = true
+	}
+	for _, page := range take(options.NoIndexPages) {
+		page.NoIndex = true
+	}
+
+	// Every page links back to its parent and to the home page, the links up never lead to unvisited pages
+	for _, page := range tree[1:] {
+		page.Links = append(page.Links, SiteLink{Path: page.Parent, Text: "Up"}, SiteLink{Path: PagePath(0), Text: "Home"})
+	}
+	return s
+}
+
+func (s *Site) add(page *SitePage) {
+	s.Pages[page.Path] = page
+	s.Paths = append(s.Paths, page.Path)
+}
+
+func (s *Site) addResource(random *rand.Rand, tree []*SitePage, resource *SitePage, text string) {
+	from := tree[random.Intn(len(tree))]
+	resource.Parent = from.Path
+	resource.Level = from.Level + 1
+	from.Links = append(from.Links, SiteLink{Path: resource.Path, Text: fmt.Sprintf("%s %d", text, resource.Index)})
+	s.add(resource)
+}
+
+// PagePath returns the path of the page i of the tree
+func PagePath(i int) string {
+	if i == 0 {
+		return "/"
+	}
+	return fmt.Sprintf("/pages/%d.html", i)
+}
+
+// Token returns the word found only on the page i of the tree, the words are left as they are by the stemmer
+func Token(i int) string {
+	return "zq" + letters(i) + "x"
+}
+
+// MovedToken returns the word found only on the page the redirect i points to
+func MovedToken(i int) string {
+	return "zm" + letters(i) + "x"
+}
+
+// PrivateToken returns the word found only on the private page i
+func PrivateToken(i int) string {
+	return "zp" + letters(i) + "x"
+}
+
+// TextToken returns the word found only in the text document i
+func TextToken(i int) string {
+	return "zt" + letters(i) + "x"
+}
+
+func letters(i int) string {
+	s := ""
+	for {
+		s = string(rune('b'+i%24)) + s
+		i /= 24
+		if i == 0 {
+			return s
+		}
+	}
+}
+
+func paragraphs(random *rand.Rand, topic string) []string {
+	result := make([]string, 3)
+	for i := range result {
+		words := make([]string, 12)
+		for j := range words {
+			words[j] = vocabulary[random.Intn(len(vocabulary))]
+		}
+		if topic != "" && i == 0 {
+			words[0] = topic
+		}
+		result[i] = strings.Title(words[0]) + " " + strings.Join(words[1:], " ") + "."
+	}
+	return result
+}
+
+// Serve starts the server of the site, it is closed by the caller
+func (s *Site) Serve() *httptest.Server {
+	return httptest.NewServer(s.Handler())
+}
+
+// Handler serves the resources of the site with the robots.txt and the sitemap.xml
+func (s *Site) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/robots.txt":
+			w.Header().Set("Content-Type", TextContentType)
+			_, _ = w.Write([]byte(s.RobotsTxt(baseURL(r))))
+			return
+		case "/sitemap.xml":
+			w.Header().Set("Content-Type", "application/xml")
+			_, _ = w.Write([]byte(s.SitemapXML(baseURL(r))))
+			return
+		}
+		page, exists := s.Pages[r.URL.Path]
+		if !exists || page.Kind == KindMissing {
+			http.NotFound(w, r)
+			return
+		}
+		if page.Slow {
+			time.Sleep(s.Options.SlowDelay)
+		}
+		switch page.Kind {
+		case KindRedirect:
+			http.Redirect(w, r, page.Target, http.StatusMovedPermanently)
+		case KindText:
+			w.Header().Set("Content-Type", TextContentType)
+			w.Header().Set("Content-Language", "en")
+			_, _ = w.Write(page.Body())
+		case KindBinary:
+			w.Header().Set("Content-Type", BinaryContentType)
+			_, _ = w.Write(page.Body())
+		default:
+			w.Header().Set("Content-Type", HTMLContentType)
+			_, _ = w.Write(page.Body())
+		}
+	})
+}
+
+// Body returns the content the resource is served with
+func (p *SitePage) Body() []byte {
+	switch p.Kind {
+	case KindText:
+		return []byte(p.Title + "\n\n" + strings.Join(p.Paragraphs, "\n\n") + "\n")
+	case KindBinary:
+		body := make([]byte, BinarySize)
+		for i := range body {
+			body[i] = byte((i*7 + p.Index) % 256)
+		}
+		return body
+	case KindPage, KindMoved, KindPrivate:
+		var b strings.Builder
+		b.WriteString("<!DOCTYPE html>\n<html lang=\"en\">\n<head>\n")
+		fmt.Fprintf(&b, "<title>%s</title>\n", html.EscapeString(p.Title))
+		fmt.Fprintf(&b, "<meta name=\"description\" content=\"%s\">\n", html.EscapeString(p.Description))
+		if robots := p.Robots(); robots != "" {
+			fmt.Fprintf(&b, "<meta name=\"robots\" content=\"%s\">\n", robots)
+		}
+		b.WriteString("</head>\n<body>\n")
+		for _, paragraph := range p.Paragraphs {
+			fmt.Fprintf(&b, "<p>%s</p>\n", html.EscapeString(paragraph))
+		}
+		b.WriteString("<nav>\n")
+		for _, link := range p.Links {
+			fmt.Fprintf(&b, "<a href=\"%s\">%s</a>\n", html.EscapeString(link.Path), html.EscapeString(link.Text))
+		}
+		b.WriteString("</nav>\n</body>\n</html>\n")
+		return []byte(b.String())
+	}
+	return nil
+}
+
+// Robots returns the content of the robots meta tag of the page
+func (p *SitePage) Robots() string {
+	directives := []string{}
+	if p.NoIndex {
+		directives = append(directives, "noindex")
+	}
+	if p.NoFollow {
+		directives = append(directives, "nofollow")
+	}
+	return strings.Join(directives, ", ")
+}
+
+// RobotsTxt returns the robots.txt of the site pointing to its sitemap
+func (s *Site) RobotsTxt(base string) string {
+	var b strings.Builder
+	b.WriteString("User-agent: *\n")
+	for _, path := range s.Options.Disallow {
+		fmt.Fprintf(&b, "Disallow: %s\n", path)
+	}
+	fmt.Fprintf(&b, "Sitemap: %s/sitemap.xml\n", base)
+	return b.String()
+}
+
+// SitemapXML returns the sitemap of the html pages of the tree
+func (s *Site) SitemapXML(base string) string {
+	var b strings.Builder
+	b.WriteString("<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n")
+	b.WriteString("<urlset xmlns=\"http://www.sitemaps.org/schemas/sitemap/0.9\">\n")
+	for _, path := range s.PagePaths() {
+		fmt.Fprintf(&b, "  <url><loc>%s%s</loc></url>\n", base, html.EscapeString(path))
+	}
+	b.WriteString("</urlset>\n")
+	return b.String()
+}
+
+// PagePaths returns the paths of the html pages of the tree in their order
+func (s *Site) PagePaths() []string {
+	paths := []string{}
+	for _, path := range s.Paths {
+		if s.Pages[path].Kind == KindPage {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+// PathsWithKind returns the sorted paths of the resources of the kind
+func (s *Site) PathsWithKind(kind string) []string {
+	paths := []string{}
+	for _, path := range s.Paths {
+		if s.Pages[path].Kind == kind {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func baseURL(r *http.Request) string {
+	return "http://" + r.Host
+}
@@ -0,0 +1,166 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule returns the next time a job runs after the given time
+type Schedule interface {
+	Next(after time.Time) time.Time
+}
+
+// EverySchedule runs a job at a fixed interval, the expression is "@every 1h30m"
+type EverySchedule struct {
+	Interval time.Duration
+}
+
+func (s *EverySchedule) Next(after time.Time) time.Time {
+	return after.Add(s.Interval)
+}
+
+// CronSchedule is a five field cron expression of minute, hour, day of month, month and day of week. Fields
+// are "*", numbers, ranges "1-5", lists "1,15" and steps "*/10" or "0-30/5". Sunday is 0 or 7.
+type CronSchedule struct {
+	Minutes     map[int]bool
+	Hours       map[int]bool
+	DaysOfMonth map[int]bool
+	Months      map[int]bool
+	DaysOfWeek  map[int]bool
+	// A day matches either of the day fields when both are restricted, as cron does
+	anyDayOfMonth bool
+	anyDayOfWeek  bool
+}
+
+var descriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// ParseSchedule parses a cron expression, a descriptor such as "@daily" or an interval "@every 6h"
+func ParseSchedule(expression string) (Schedule, error) {
+	expression = strings.TrimSpace(expression)
+	if strings.HasPrefix(expression, "@every ") {
+		interval, err := time.ParseDuration(strings.TrimSpace(strings.TrimPrefix(expression, "@every ")))
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("schedule interval is not valid: %s", err.Error()))
+		}
+		if interval < time.Second {
+			return nil, errors.New("schedule interval should be at least a second")
+		}
+		return &EverySchedule{Interval: interval}, nil
+	}
+	if descriptor, exists := descriptors[strings.ToLower(expression)]; exists {
+		expression = descriptor
+	}
+	fields := strings.Fields(expression)
+	if len(fields) != 5 {
+		return nil, errors.New(fmt.Sprintf("schedule should have 5 fields: %s", expression))
+	}
+	schedule := &CronSchedule{
+		anyDayOfMonth: fields[2] == "*",
+		anyDayOfWeek:  fields[4] == "*",
+	}
+	var err error
+	if schedule.Minutes, err = parseField(fields[0], 0, 59); err != nil {
+		return nil, err
+	}
+	if schedule.Hours, err = parseField(fields[1], 0, 23); err != nil {
+		return nil, err
+	}
+	if schedule.DaysOfMonth, err = parseField(fields[2], 1, 31); err != nil {
+		return nil, err
+	}
+	if schedule.Months, err = parseField(fields[3], 1, 12); err != nil {
+		return nil, err
+	}
+	if schedule.DaysOfWeek, err = parseField(fields[4], 0, 7); err != nil {
+		return nil, err
+	}
+	if schedule.DaysOfWeek[7] {
+		schedule.DaysOfWeek[0] = true
+	}
+	return schedule, nil
+}
+
+func parseField(field string, min int, max int) (map[int]bool, error) {
+	values := map[int]bool{}
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			s, err := strconv.Atoi(part[i+1:])
+			if err != nil || s <= 0 {
+				return nil, errors.New(fmt.Sprintf("schedule step is not valid: %s", part))
+			}
+			step = s
+			part = part[:i]
+		}
+		from, to := min, max
+		if part != "*" {
+			bounds := strings.SplitN(part, "-", 2)
+			var err error
+			if from, err = strconv.Atoi(bounds[0]); err != nil {
+				return nil, errors.New(fmt.Sprintf("schedule field is not valid: %s", field))
+			}
+			to = from
+			if len(bounds) == 2 {
+				if to, err = strconv.Atoi(bounds[1]); err != nil {
+					return nil, errors.New(fmt.Sprintf("schedule field is not valid: %s", field))
+				}
+			} else if step > 1 {
+				to = max
+			}
+		}
+		if from < min || to > max || from > to {
+			return nil, errors.New(fmt.Sprintf("schedule field is out of range %d-%d: %s", min, max, field))
+		}
+		for v := from; v <= to; v += step {
+			values[v] = true
+		}
+	}
+	return values, nil
+}
+
+// Next returns the first matching minute after the given time, zero time is returned if there is none
+// within five years such as for the 30th of February
+func (s *CronSchedule) Next(after time.Time) time.Time {
+	t := after.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		if !s.Months[int(t.Month())] {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !s.matchesDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !s.Hours[t.Hour()] {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if !s.Minutes[t.Minute()] {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+func (s *CronSchedule) matchesDay(t time.Time) bool {
+	dayOfMonth := s.DaysOfMonth[t.Day()]
+	dayOfWeek := s.DaysOfWeek[int(t.Weekday())]
+	if s.anyDayOfMonth || s.anyDayOfWeek {
+		return dayOfMonth && dayOfWeek
+	}
+	return dayOfMonth || dayOfWeek
+}
@@ -0,0 +1,121 @@
+package scheduler
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseSchedule(t *testing.T) {
+	for _, test := range []struct {
+		expression string
+		want       *CronSchedule
+	}{
+		{"30 2 * * *", &CronSchedule{
+			Minutes: values(30), Hours: values(2), DaysOfMonth: valueRange(1, 31, 1), Months: valueRange(1, 12, 1),
+			DaysOfWeek: valueRange(0, 7, 1), anyDayOfMonth: true, anyDayOfWeek: true,
+		}},
+		{"0,15,45 9-17 1,15 */3 1-5", &CronSchedule{
+			Minutes: values(0, 15, 45), Hours: valueRange(9, 17, 1), DaysOfMonth: values(1, 15),
+			Months: values(1, 4, 7, 10), DaysOfWeek: valueRange(1, 5, 1),
+		}},
+		{"0-30/10 5/6 * 2 *", &CronSchedule{
+			Minutes: values(0, 10, 20, 30), Hours: values(5, 11, 17, 23), DaysOfMonth: valueRange(1, 31, 1),
+			Months: values(2), DaysOfWeek: valueRange(0, 7, 1), anyDayOfMonth: true, anyDayOfWeek: true,
+		}},
+		// Sunday is 7 as well as 0
+		{"0 0 * * 7", &CronSchedule{
+			Minutes: values(0), Hours: values(0), DaysOfMonth: valueRange(1, 31, 1), Months: valueRange(1, 12, 1),
+			DaysOfWeek: values(0, 7), anyDayOfMonth: true,
+		}},
+		{"@weekly", &CronSchedule{
+			Minutes: values(0), Hours: values(0), DaysOfMonth: valueRange(1, 31, 1), Months: valueRange(1, 12, 1),
+			DaysOfWeek: values(0), anyDayOfMonth: true,
+		}},
+	} {
+		schedule, err := ParseSchedule(test.expression)
+		if err != nil {
+			t.Errorf("%s: %s", test.expression, err)
+			continue
+		}
+		if !reflect.DeepEqual(schedule, test.want) {
+			t.Errorf("%s: got %+v, want %+v", test.expression, schedule, test.want)
+		}
+	}
+}
+
+func TestParseScheduleOfIntervals(t *testing.T) {
+	schedule, err := ParseSchedule("@every 1h30m")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (&EverySchedule{Interval: 90 * time.Minute}); !reflect.DeepEqual(schedule, want) {
+		t.Errorf("got %+v, want %+v", schedule, want)
+	}
+	after := time.Date(2021, 3, 1, 10, 20, 30, 0, time.UTC)
+	if next := schedule.Next(after); !next.Equal(after.Add(90 * time.Minute)) {
+		t.Errorf("got next run %s", next)
+	}
+}
+
+func TestParseScheduleRejectsInvalidExpressions(t *testing.T) {
+	for _, expression := range []string{
+		"", "* * * *", "* * * * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "* * * 13 *", "* * * * 8",
+		"5-1 * * * *", "*/0 * * * *", "a * * * *", "1-b * * * *", "@every 1x", "@every 10ms", "@sometimes",
+	} {
+		if _, err := ParseSchedule(expression); err == nil {
+			t.Errorf("%q is accepted", expression)
+		}
+	}
+}
+
+func TestCronScheduleNext(t *testing.T) {
+	// Monday the 1st of March 2021
+	after := time.Date(2021, 3, 1, 10, 20, 30, 0, time.UTC)
+	for _, test := range []struct {
+		expression string
+		want       time.Time
+	}{
+		{"* * * * *", time.Date(2021, 3, 1, 10, 21, 0, 0, time.UTC)},
+		{"20 10 * * *", time.Date(2021, 3, 2, 10, 20, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2021, 3, 1, 10, 30, 0, 0, time.UTC)},
+		{"0 9-17/4 * * *", time.Date(2021, 3, 1, 13, 0, 0, 0, time.UTC)},
+		{"0 0 1 * *", time.Date(2021, 4, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 12 * 1,6 *", time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)},
+		{"0 0 * * 0", time.Date(2021, 3, 7, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2021, 3, 7, 0, 0, 0, 0, time.UTC)},
+		// Restricted days of month and of week match either of them
+		{"0 0 15 * 5", time.Date(2021, 3, 5, 0, 0, 0, 0, time.UTC)},
+		{"0 0 3 * 6", time.Date(2021, 3, 3, 0, 0, 0, 0, time.UTC)},
+		// A day of month with any day of week matches only that day of month
+		{"0 0 15 * *", time.Date(2021, 3, 15, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"@yearly", time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 31 2 *", time.Time{}},
+	} {
+		schedule, err := ParseSchedule(test.expression)
+		if err != nil {
+			t.Errorf("%s: %s", test.expression, err)
+			continue
+		}
+		if next := schedule.Next(after); !next.Equal(test.want) {
+			t.Errorf("%s: got next run %s, want %s", test.expression, next, test.want)
+		}
+	}
+}
+
+func values(v ...int) map[int]bool {
+	set := map[int]bool{}
+	for _, value := range v {
+		set[value] = true
+	}
+	return set
+}
+
+func valueRange(from int, to int, step int) map[int]bool {
+	set := map[int]bool{}
+	for v := from; v <= to; v += step {
+		set[v] = true
+	}
+	return set
+}
@@ -0,0 +1,387 @@
+package scheduler
+
+import (
+	"crawler/collector"
+	"crawler/searcher"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// Links are followed only to the hosts of the seeds
+	ScopeHost = "host"
+	// Links are followed to any host
+	ScopeAll = "all"
+)
+
+const (
+	RunSucceeded = "succeeded"
+	RunFailed    = "failed"
+	// The run is skipped when the previous run of the job is not finished yet
+	RunSkipped = "skipped"
+)
+
+const (
+	// Number of the most recent runs kept in the history
+	MaxRunHistory = 1000
+	// Interval the due jobs are checked at
+	TickInterval = time.Second
+)
+
+type SchedulerInterface interface {
+	AddJob(job *CrawlJob) error
+	RemoveJob(name string)
+	LoadJobs(path string) error
+	RunJob(name string) (*RunRecord, error)
+	Run(stop <-chan struct{})
+	JobHistory(name string) []*RunRecord
+	LoadHistory() error
+	SaveHistory() error
+	Close() error
+}
+
+// CrawlJob is the definition of a crawl which runs on its schedule
+type CrawlJob struct {
+	Name  string   `json:"name"`
+	Seeds []string `json:"seeds"`
+	Depth int      `json:"depth"`
+	// Either ScopeHost or ScopeAll, the allowed hosts are added to the hosts of the seeds of ScopeHost
+	Scope        string   `json:"scope"`
+	AllowedHosts []string `json:"allowed_hosts"`
+	// Crawl scrapes the urls closer to the seeds first up to this many pages, zero means no limit
+	MaxPages    int    `json:"max_pages"`
+	Schedule    string `json:"schedule"`
+	ResultsFile string `json:"results_file"`
+}
+
+type RunSummary struct {
+	TotalPages         int     `json:"total_pages"`
+	SucceededPages     int     `json:"succeeded_pages"`
+	FailedPages        int     `json:"failed_pages"`
+	SuppressedUrls     int     `json:"suppressed_urls"`
+	ExecutionInSeconds float64 `json:"execution_in_seconds"`
+	PageRatePerSec     float64 `json:"page_rate_per_sec"`
+}
+
+type RunRecord struct {
+	Job     string      `json:"job"`
+	Begin   time.Time   `json:"begin"`
+	End     time.Time   `json:"end"`
+	Status  string      `json:"status"`
+	Error   string      `json:"error,omitempty"`
+	Summary *RunSummary `json:"summary,omitempty"`
+}
+
+type Job struct {
+	Definition *CrawlJob
+	Schedule   Schedule
+	NextRun    time.Time
+	Running    bool
+}
+
+// Scheduler runs the crawl jobs on their schedules, a job never runs twice at the same time. The results of
+// every run are indexed into the indexer if it is set and the runs are recorded into the history file.
+type Scheduler struct {
+	Jobs        map[string]*Job
+	History     []*RunRecord
+	HistoryFile string
+	Indexer     *searcher.Indexer
+	// Index dump the indexer is saved into after every run, the other dumps are saved next to it
+	IndexFile string
+	// Called after every finished run, data is nil when the run failed
+	OnRun      func(record *RunRecord, data *collector.ResultData)
+	Loggers    *collector.Loggers
+	Mutex      sync.Mutex
+	IndexMutex sync.Mutex
+	// Held while the history is saved so that an older history is never written over a newer one
+	SaveMutex sync.Mutex
+	running   sync.WaitGroup
+}
+
+func NewScheduler(historyFile string, indexer *searcher.Indexer) (*Scheduler, error) {
+	loggers, err := collector.CreateLoggers(collector.LogFile)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("loggers could not be created: %s", err.Error()))
+	}
+	s := &Scheduler{
+		Jobs:        map[string]*Job{},
+		History:     []*RunRecord{},
+		HistoryFile: historyFile,
+		Indexer:     indexer,
+		IndexFile:   searcher.IndexDumpFile,
+		Loggers:     loggers,
+	}
+	if err := s.LoadHistory(); err != nil {
+		loggers.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// Close closes the log file of the scheduler
+func (s *Scheduler) Close() error {
+	return s.Loggers.Close()
+}
+
+// AddJob validates the job and schedules its next run, a job with the same name is replaced
+func (s *Scheduler) AddJob(job *CrawlJob) error {
+	if job.Name == "" {
+		return errors.New("job name should not be empty")
+	}
+	if len(job.Seeds) == 0 {
+		return errors.New(fmt.Sprintf("job %s should have at least one seed", job.Name))
+	}
+	for _, seed := range job.Seeds {
+		if _, err := url.ParseRequestURI(seed); err != nil {
+			return errors.New(fmt.Sprintf("seed of job %s is not valid url: %s", job.Name, err.Error()))
+		}
+	}
+	if job.Depth <= 0 {
+		return errors.New(fmt.Sprintf("depth of job %s should be a positive number", job.Name))
+	}
+	if job.Scope == "" {
+		job.Scope = ScopeHost
+	}
+	if job.Scope != ScopeHost && job.Scope != ScopeAll {
+		return errors.New(fmt.Sprintf("scope of job %s should be %s or %s", job.Name, ScopeHost, ScopeAll))
+	}
+	if job.ResultsFile == "" {
+		job.ResultsFile = job.Name + "_results.json"
+	}
+	schedule, err := ParseSchedule(job.Schedule)
+	if err != nil {
+		return errors.New(fmt.Sprintf("schedule of job %s is not valid: %s", job.Name, err.Error()))
+	}
+	nextRun := schedule.Next(time.Now())
+	if nextRun.IsZero() {
+		return errors.New(fmt.Sprintf("schedule of job %s never runs: %s", job.Name, job.Schedule))
+	}
+	s.Mutex.Lock()
+	defer s.Mutex.Unlock()
+	running := false
+	if existing, exists := s.Jobs[job.Name]; exists {
+		running = existing.Running
+	}
+	s.Jobs[job.Name] = &Job{
+		Definition: job,
+		Schedule:   schedule,
+		NextRun:    nextRun,
+		Running:    running,
+	}
+	return nil
+}
+
+func (s *Scheduler) RemoveJob(name string) {
+	s.Mutex.Lock()
+	delete(s.Jobs, name)
+	s.Mutex.Unlock()
+}
+
+// LoadJobs adds the jobs of a json file holding an array of job definitions
+func (s *Scheduler) LoadJobs(path string) error {
+	bytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var jobs []*CrawlJob
+	if err := json.Unmarshal(bytes, &jobs); err != nil {
+		return errors.New(fmt.Sprintf("jobs could not be parsed: %s", err.Error()))
+	}
+	for _, job := range jobs {
+		if err := s.AddJob(job); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Run starts the due jobs every tick until the stop channel is closed, then waits for the running jobs
+func (s *Scheduler) Run(stop <-chan struct{}) {
+	ticker := time.NewTicker(TickInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			s.running.Wait()
+			return
+		case now := <-ticker.C:
+			for _, name := range s.DueJobs(now) {
+				s.running.Add(1)
+				go func(name string) {
+					defer s.running.Done()
+					if _, err := s.RunJob(name); err != nil {
+						s.Loggers.Log(collector.ERROR, fmt.Sprintf("Job %s failed: %s\n", name, err.Error()))
+					}
+				}(name)
+			}
+		}
+	}
+}
+
+// DueJobs returns the names of the jobs whose run time has come and schedules their next runs
+func (s *Scheduler) DueJobs(now time.Time) []string {
+	s.Mutex.Lock()
+	defer s.Mutex.Unlock()
+	names := []string{}
+	for name, job := range s.Jobs {
+		if job.NextRun.IsZero() || now.Before(job.NextRun) {
+			continue
+		}
+		names = append(names, name)
+		job.NextRun = job.Schedule.Next(now)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// RunJob crawls the job right away, the run is recorded as skipped if the job is running already
+func (s *Scheduler) RunJob(name string) (*RunRecord, error) {
+	s.Mutex.Lock()
+	job, exists := s.Jobs[name]
+	if !exists {
+		s.Mutex.Unlock()
+		return nil, errors.New(fmt.Sprintf("job does not exist: %s", name))
+	}
+	record := &RunRecord{Job: name, Begin: time.Now()}
+	if job.Running {
+		s.Mutex.Unlock()
+		record.End = record.Begin
+		record.Status = RunSkipped
+		return record, s.AddRecord(record)
+	}
+	job.Running = true
+	definition := job.Definition
+	s.Mutex.Unlock()
+
+	// Pages of the previous run are removed from the index before the pages of the new run are indexed
+	previous, _ := collector.LoadResultData(definition.ResultsFile)
+	data, err := s.Crawl(definition)
+	if err == nil && s.Indexer != nil {
+		s.IndexMutex.Lock()
+		if previous != nil {
+			s.Indexer.RemovePages(previous.Succeed)
+		}
+		if err = s.Indexer.LoadCollectorDocument(definition.ResultsFile, false); err == nil {
+			err = s.Indexer.SaveIndexDumpTo(s.IndexFile)
+		}
+		s.IndexMutex.Unlock()
+	}
+
+	s.Mutex.Lock()
+	job.Running = false
+	s.Mutex.Unlock()
+
+	record.End = time.Now()
+	if err != nil {
+		record.Status = RunFailed
+		record.Error = err.Error()
+		data = nil
+	} else {
+		record.Status = RunSucceeded
+		record.Summary = &RunSummary{
+			TotalPages:         data.TotalPages,
+			SucceededPages:     data.SucceededPages,
+			FailedPages:        data.FailedPages,
+			SuppressedUrls:     data.SuppressedUrls,
+			ExecutionInSeconds: data.ExecutionInSeconds,
+			PageRatePerSec:     data.PageRatePerSec,
+		}
+	}
+	if s.OnRun != nil {
+		s.OnRun(record, data)
+	}
+	return record, s.AddRecord(record)
+}
+
+// Crawl runs a fresh crawl of the job and returns its saved results
+func (s *Scheduler) Crawl(job *CrawlJob) (*collector.ResultData, error) {
+	c, err := collector.NewCollector(job.Seeds[0], job.Depth, false, job.ResultsFile)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+	for _, seed := range job.Seeds[1:] {
+		if err := c.AddSeed(seed); err != nil {
+			return nil, err
+		}
+	}
+	if job.Scope == ScopeHost {
+		hosts := append([]string{}, job.AllowedHosts...)
+		for _, seed := range job.Seeds {
+			hosts = append(hosts, collector.HostOf(seed))
+		}
+		c.AllowedHosts = hosts
+	}
+	if job.MaxPages > 0 {
+		c.EnablePriorityCrawl(nil, job.MaxPages)
+	}
+	if _, err := c.StartCrawling(); err != nil {
+		return nil, err
+	}
+	c.End = time.Now()
+	if _, err := c.SaveResultsToFile(); err != nil {
+		return nil, err
+	}
+	return collector.LoadResultData(job.ResultsFile)
+}
+
+// AddRecord appends the run to the history and saves it
+func (s *Scheduler) AddRecord(record *RunRecord) error {
+	s.Mutex.Lock()
+	s.History = append(s.History, record)
+	if len(s.History) > MaxRunHistory {
+		s.History = s.History[len(s.History)-MaxRunHistory:]
+	}
+	s.Mutex.Unlock()
+	return s.SaveHistory()
+}
+
+// JobHistory returns the recorded runs of the job, oldest first
+func (s *Scheduler) JobHistory(name string) []*RunRecord {
+	s.Mutex.Lock()
+	defer s.Mutex.Unlock()
+	records := []*RunRecord{}
+	for _, record := range s.History {
+		if record.Job == name {
+			records = append(records, record)
+		}
+	}
+	return records
+}
+
+func (s *Scheduler) LoadHistory() error {
+	bytes, err := ioutil.ReadFile(s.HistoryFile)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var history []*RunRecord
+	if err := json.Unmarshal(bytes, &history); err != nil {
+		return errors.New(fmt.Sprintf("run history could not be parsed: %s", err.Error()))
+	}
+	s.Mutex.Lock()
+	s.History = history
+	s.Mutex.Unlock()
+	return nil
+}
+
+func (s *Scheduler) SaveHistory() error {
+	s.SaveMutex.Lock()
+	defer s.SaveMutex.Unlock()
+	s.Mutex.Lock()
+	file, err := json.MarshalIndent(s.History, "", "  ")
+	s.Mutex.Unlock()
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(s.HistoryFile, file, 0644)
+}
@@ -0,0 +1,34 @@
+package scheduler
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAddJobSchedulesTheNextRun(t *testing.T) {
+	s := &Scheduler{Jobs: map[string]*Job{}}
+	job := &CrawlJob{Name: "news", Seeds: []string{"https://example.com/"}, Depth: 1, Schedule: "@hourly"}
+	if err := s.AddJob(job); err != nil {
+		t.Fatal(err)
+	}
+	added := s.Jobs["news"]
+	if added == nil || added.NextRun.IsZero() || added.NextRun.Minute() != 0 || !added.NextRun.After(time.Now()) {
+		t.Fatalf("got job %+v", added)
+	}
+	if job.Scope != ScopeHost || job.ResultsFile != "news_results.json" {
+		t.Errorf("got scope %q and results file %q", job.Scope, job.ResultsFile)
+	}
+}
+
+func TestAddJobRejectsSchedulesWhichNeverRun(t *testing.T) {
+	s := &Scheduler{Jobs: map[string]*Job{}}
+	job := &CrawlJob{Name: "never", Seeds: []string{"https://example.com/"}, Depth: 1, Schedule: "0 0 31 2 *"}
+	err := s.AddJob(job)
+	if err == nil || !strings.Contains(err.Error(), "never runs") {
+		t.Fatalf("got error %v", err)
+	}
+	if len(s.Jobs) != 0 {
+		t.Errorf("got jobs %v", s.Jobs)
+	}
+}
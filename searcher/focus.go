@@ -0,0 +1,78 @@
+package searcher
+
+import (
+	"crawler/collector"
+	"strings"
+	"sync"
+)
+
+const (
+	// Share of the anchor texts in the topic score, the rest is the share of the source page
+	DefaultAnchorWeight = 0.6
+)
+
+// TopicScorer scores the discovered urls by the relevance of their anchor texts and their source pages to a
+// topic query, the texts are analyzed the same way as the indexed documents
+type TopicScorer struct {
+	Indexer      *Indexer
+	Topic        map[string]bool
+	AnchorWeight float64
+	// Relevance of the source pages keyed by url, a page is analyzed once for all of its links
+	Sources map[string]float64
+	Mutex   sync.Mutex
+}
+
+func NewTopicScorer(indexer *Indexer, topic string) *TopicScorer {
+	tokens := map[string]bool{}
+	for _, token := range indexer.Analyze(topic) {
+		tokens[token] = true
+	}
+	return &TopicScorer{
+		Indexer:      indexer,
+		Topic:        tokens,
+		AnchorWeight: DefaultAnchorWeight,
+		Sources:      map[string]float64{},
+	}
+}
+
+// Score is a collector.ScoreFunc, the seed is scored as fully relevant
+func (t *TopicScorer) Score(entry *collector.FrontierEntry, source *collector.SucceededPage) float64 {
+	if source == nil {
+		return 1
+	}
+	anchor := t.Relevance(strings.Join(entry.AnchorTexts, " ") + " " + entry.Url)
+	return t.AnchorWeight*anchor + (1-t.AnchorWeight)*t.SourceRelevance(source)
+}
+
+// SourceRelevance returns the relevance of the title, description and content of the page
+func (t *TopicScorer) SourceRelevance(page *collector.SucceededPage) float64 {
+	t.Mutex.Lock()
+	relevance, exists := t.Sources[page.Url]
+	t.Mutex.Unlock()
+	if exists {
+		return relevance
+	}
+	text := page.MainText
+	if text == "" {
+		text = strings.Join(page.Paragrahps, " ")
+	}
+	relevance = t.Relevance(page.Title + " " + page.Description + " " + text)
+	t.Mutex.Lock()
+	t.Sources[page.Url] = relevance
+	t.Mutex.Unlock()
+	return relevance
+}
+
+// Relevance returns the share of the topic tokens found in the text
+func (t *TopicScorer) Relevance(text string) float64 {
+	if len(t.Topic) == 0 {
+		return 0
+	}
+	found := map[string]bool{}
+	for _, token := range t.Indexer.Analyze(text) {
+		if t.Topic[token] {
+			found[token] = true
+		}
+	}
+	return float64(len(found)) / float64(len(t.Topic))
+}
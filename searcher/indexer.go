@@ -2,11 +2,14 @@ package searcher
 
 import (
 	"crawler/collector"
+	"crawler/graph"
 	"encoding/json"
 	"encoding/xml"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"sort"
 	"time"
 )
@@ -31,14 +34,50 @@ type IndexerInterface interface {
 	LoadWikimediaDump(path string) error
 	LoadIndexDump(path string) error
 	SaveIndexDump() error
+	SaveIndexDumpTo(path string) error
 	Analyze(s string) []string
+	AnalyzeLanguage(s string, language string) []string
+	IndexPage(url string, page *collector.SucceededPage)
+	RemovePages(pages map[string]*collector.SucceededPage)
 	AddIndex(tokens []string, url string)
+	AddFieldIndex(field string, tokens []string, url string)
 	Search(s string) []SearchResult
+	SearchField(field string, s string) []SearchResult
 	FindMax(frequency map[string]int) int
 }
 
+const (
+	IndexDumpFile      = "indexes.json"
+	FieldIndexDumpFile = "field_indexes.json"
+	PageRankDumpFile   = "page_ranks.json"
+	DuplicateDumpFile  = "duplicates.json"
+	LanguageDumpFile   = "languages.json"
+)
+
+const (
+	DefaultPageRankWeight = 0.2
+)
+
 type Indexer struct {
 	Indexes   map[string][]string
+	// Indexes of the custom extracted fields, keyed by the field name
+	FieldIndexes map[string]map[string][]string
+	// Pages with the noindex robots directive are left out unless it is set
+	IncludeNoIndex bool
+	// Page ranks of the crawled pages scaled into [0, 1] by the highest one
+	PageRanks map[string]float64
+	// Share of the page rank in the search result rank, the rest is the share of the matched tokens
+	PageRankWeight float64
+	// Near duplicate pages mapped to the first url of their cluster
+	Duplicates map[string]string
+	// Only the best ranked page of a near duplicate cluster is returned by the search when it is set
+	CollapseDuplicates bool
+	// Number of the indexed pages of each language, the queries are analyzed for every one of them
+	Languages map[string]int
+	// Stemmers of the page languages keyed by the language code, the default stemmer is english
+	Stemmers  map[string]*Stemmer
+	// Progress and timings are printed to the output, nil prints nothing
+	Output    io.Writer
 	Tokenizer *Tokenizer
 	Filterer  *Filterer
 	Stemmer   *Stemmer
@@ -49,8 +88,20 @@ func NewIndexer() (*Indexer, error) {
 	if err != nil {
 		return nil, err
 	}
+	stemmers := map[string]*Stemmer{}
+	for language := range StemmerLanguages {
+		stemmers[language] = NewLanguageStemmer(language)
+	}
 	return &Indexer{
 		Indexes:   map[string][]string{},
+		FieldIndexes: map[string]map[string][]string{},
+		PageRanks: map[string]float64{},
+		PageRankWeight: DefaultPageRankWeight,
+		Duplicates: map[string]string{},
+		CollapseDuplicates: true,
+		Languages: map[string]int{},
+		Stemmers: stemmers,
+		Output: os.Stdout,
 		Tokenizer: NewTokenizer(),
 		Filterer:  filterer,
 		Stemmer:   NewStemmer(),
@@ -65,7 +116,7 @@ func (i *Indexer) LoadCollectorDocument(path string, save bool) error {
 	defer func(jsonFile *os.File) {
 		err := jsonFile.Close()
 		if err != nil {
-			fmt.Printf("Error closing json file: %s\n", err.Error())
+			i.printf("Error closing json file: %s\n", err.Error())
 		}
 	}(jsonFile)
 
@@ -78,29 +129,138 @@ func (i *Indexer) LoadCollectorDocument(path string, save bool) error {
 		return err
 	}
 	for url, page := range resultData.Succeed {
-		// Page title
-		i.AddIndex(i.Analyze(page.Title), url)
-		// Page Description
-		i.AddIndex(i.Analyze(page.Description), url)
-		// Page paragraphs
-		for _, paragraph := range page.Paragrahps {
-			i.AddIndex(i.Analyze(paragraph), url)
-		}
+		i.IndexPage(url, page)
 	}
+	i.ComputePageRanks(&resultData)
+	i.ComputeDuplicates(&resultData)
 	if save {
 		err := i.SaveIndexDump()
 		if err != nil {
-			fmt.Printf("Saving indexes dump failed: %s\n", err.Error())
+			i.printf("Saving indexes dump failed: %s\n", err.Error())
 		}
 	}
 	return nil
 }
 
+// IndexPage adds a scraped page to the indexes, pages with the noindex directive are skipped by default
+func (i *Indexer) IndexPage(url string, page *collector.SucceededPage) {
+	if page.Robots != nil && page.Robots.NoIndex && !i.IncludeNoIndex {
+		return
+	}
+	// Page texts are analyzed in the language of the page
+	language := page.Language
+	if language != "" {
+		i.Languages[language]++
+	}
+	// Page title
+	i.AddIndex(i.AnalyzeLanguage(page.Title, language), url)
+	// Page Description
+	i.AddIndex(i.AnalyzeLanguage(page.Description, language), url)
+	// Page main content if extracted, otherwise the page paragraphs
+	if page.MainText != "" {
+		i.AddIndex(i.AnalyzeLanguage(page.MainText, language), url)
+	} else {
+		for _, paragraph := range page.Paragrahps {
+			i.AddIndex(i.AnalyzeLanguage(paragraph, language), url)
+		}
+	}
+	// Page custom fields are searchable separately
+	for field, value := range page.Fields {
+		for _, text := range FieldValues(value) {
+			i.AddFieldIndex(field, i.AnalyzeLanguage(text, language), url)
+		}
+	}
+}
+
+// ComputePageRanks computes the page ranks from the link graph of the crawl, the ranks of the pages of the other
+// crawls are kept
+func (i *Indexer) ComputePageRanks(resultData *collector.ResultData) {
+	ranks := graph.BuildLinkGraph(resultData).NormalizedPageRank()
+	for url := range resultData.Succeed {
+		i.PageRanks[url] = ranks[url]
+	}
+}
+
+// ComputeDuplicates clusters the near duplicate pages of the crawl by their content fingerprints within the
+// distance the crawl was deduplicated with, or the default one. The clusters of the pages of the other crawls are kept
+func (i *Indexer) ComputeDuplicates(resultData *collector.ResultData) {
+	for url := range resultData.Succeed {
+		delete(i.Duplicates, url)
+	}
+	distance := collector.DefaultMaxHammingDistance
+	if resultData.MaxDuplicateDistance != nil {
+		distance = *resultData.MaxDuplicateDistance
+	}
+	clusters, err := collector.ClusterDuplicates(resultData.Succeed, distance)
+	if err != nil {
+		i.printf("Computing duplicates failed: %s\n", err.Error())
+		return
+	}
+	for _, cluster := range clusters {
+		for _, url := range cluster[1:] {
+			i.Duplicates[url] = cluster[0]
+		}
+	}
+}
+
+// RemovePages removes the pages of a previous crawl from the indexes, the page ranks, the duplicates and the
+// language counts so that the pages which are gone do not show up in the search results anymore
+func (i *Indexer) RemovePages(pages map[string]*collector.SucceededPage) {
+	if len(pages) == 0 {
+		return
+	}
+	for url, page := range pages {
+		// Every page of an indexed crawl has a page rank
+		if _, indexed := i.PageRanks[url]; !indexed {
+			continue
+		}
+		delete(i.PageRanks, url)
+		indexed := page.Robots == nil || !page.Robots.NoIndex || i.IncludeNoIndex
+		if language := page.Language; indexed && language != "" && i.Languages[language] > 0 {
+			i.Languages[language]--
+			if i.Languages[language] == 0 {
+				delete(i.Languages, language)
+			}
+		}
+	}
+	for url, cluster := range i.Duplicates {
+		if _, removed := pages[url]; removed {
+			delete(i.Duplicates, url)
+		} else if _, removed := pages[cluster]; removed {
+			delete(i.Duplicates, url)
+		}
+	}
+	removeUrls(i.Indexes, pages)
+	for field, indexes := range i.FieldIndexes {
+		removeUrls(indexes, pages)
+		if len(indexes) == 0 {
+			delete(i.FieldIndexes, field)
+		}
+	}
+}
+
+// removeUrls drops the urls of the pages from the tokens, the tokens without any url left are dropped
+func removeUrls(indexes map[string][]string, pages map[string]*collector.SucceededPage) {
+	for token, urls := range indexes {
+		kept := urls[:0]
+		for _, url := range urls {
+			if _, removed := pages[url]; !removed {
+				kept = append(kept, url)
+			}
+		}
+		if len(kept) == 0 {
+			delete(indexes, token)
+		} else {
+			indexes[token] = kept
+		}
+	}
+}
+
 func (i *Indexer) LoadWikimediaDump(path string, save bool) error {
 	begin := time.Now()
 	defer func(begin time.Time) {
 		elapsed := time.Since(begin)
-		fmt.Printf("Loading wikimedia dump took %f seconds\n", elapsed.Seconds())
+		i.printf("Loading wikimedia dump took %f seconds\n", elapsed.Seconds())
 	}(begin)
 	xmlFile, err := os.Open(path)
 	if err != nil {
@@ -109,7 +269,7 @@ func (i *Indexer) LoadWikimediaDump(path string, save bool) error {
 	defer func(xmlFile *os.File) {
 		err := xmlFile.Close()
 		if err != nil {
-			fmt.Printf("Closing xml file failed: %s\n", err.Error())
+			i.printf("Closing xml file failed: %s\n", err.Error())
 		}
 	}(xmlFile)
 
@@ -122,20 +282,20 @@ func (i *Indexer) LoadWikimediaDump(path string, save bool) error {
 
 	// TODO: Implement to process documents concurrently, since it takes a lot of time to index the wiki dump
 
-	fmt.Printf("Number of documents: %d\n", len(dump.Documents))
+	i.printf("Number of documents: %d\n", len(dump.Documents))
 
 	for idx, doc := range dump.Documents {
 		i.AddIndex(i.Analyze(doc.Title), doc.Url)
 		i.AddIndex(i.Analyze(doc.Abstract), doc.Url)
 		if idx % 1000 == 0 {
-			fmt.Printf("%dk documents are indexed\n", idx / 1000)
+			i.printf("%dk documents are indexed\n", idx / 1000)
 		}
 	}
 
 	if save {
 		err := i.SaveIndexDump()
 		if err != nil {
-			fmt.Printf("Saving indexes dump failed: %s\n", err.Error())
+			i.printf("Saving indexes dump failed: %s\n", err.Error())
 		}
 	}
 	return nil
@@ -145,7 +305,7 @@ func (i *Indexer) LoadIndexDump(path string) error {
 	begin := time.Now()
 	defer func(begin time.Time) {
 		elapsed := time.Since(begin)
-		fmt.Printf("Loading indexes dump took %f seconds\n", elapsed.Seconds())
+		i.printf("Loading indexes dump took %f seconds\n", elapsed.Seconds())
 	}(begin)
 
 	jsonFile, err := os.Open(path)
@@ -155,7 +315,7 @@ func (i *Indexer) LoadIndexDump(path string) error {
 	defer func(jsonFile *os.File) {
 		err := jsonFile.Close()
 		if err != nil {
-			fmt.Printf("Error closing json file: %s\n", err.Error())
+			i.printf("Error closing json file: %s\n", err.Error())
 		}
 	}(jsonFile)
 
@@ -168,21 +328,62 @@ func (i *Indexer) LoadIndexDump(path string) error {
 		return err
 	}
 	i.Indexes = indexes
+
+	// Field indexes, page ranks and duplicates are dumped next to the indexes if there are any
+	dir := filepath.Dir(path)
+	if err := loadOptionalDump(filepath.Join(dir, FieldIndexDumpFile), &i.FieldIndexes); err != nil {
+		return err
+	}
+	if err := loadOptionalDump(filepath.Join(dir, PageRankDumpFile), &i.PageRanks); err != nil {
+		return err
+	}
+	if err := loadOptionalDump(filepath.Join(dir, DuplicateDumpFile), &i.Duplicates); err != nil {
+		return err
+	}
+	if err := loadOptionalDump(filepath.Join(dir, LanguageDumpFile), &i.Languages); err != nil {
+		return err
+	}
 	return nil
 }
 
 func (i *Indexer) SaveIndexDump() error {
+	return i.SaveIndexDumpTo(IndexDumpFile)
+}
+
+// SaveIndexDumpTo saves the indexes into the file and the other dumps next to it, where LoadIndexDump reads them
+func (i *Indexer) SaveIndexDumpTo(path string) error {
 	file, err := json.MarshalIndent(i.Indexes, "", "  ")
 	if err != nil {
-		fmt.Printf("Error marshalling to json the results: %s\n", err.Error())
+		i.printf("Error marshalling to json the results: %s\n", err.Error())
 		return err
 	}
-	err = ioutil.WriteFile("indexes.json", file, 0644)
+	err = ioutil.WriteFile(path, file, 0644)
 	if err != nil {
-		fmt.Printf("Error saving the indexes dump into the file: %s\n", err.Error())
+		i.printf("Error saving the indexes dump into the file: %s\n", err.Error())
 		return err
 	}
-	fmt.Printf("Indexes dump saved successfully into the file\n")
+	dir := filepath.Dir(path)
+	if len(i.FieldIndexes) > 0 {
+		if err := i.saveDump(filepath.Join(dir, FieldIndexDumpFile), i.FieldIndexes); err != nil {
+			return err
+		}
+	}
+	if len(i.PageRanks) > 0 {
+		if err := i.saveDump(filepath.Join(dir, PageRankDumpFile), i.PageRanks); err != nil {
+			return err
+		}
+	}
+	if len(i.Duplicates) > 0 {
+		if err := i.saveDump(filepath.Join(dir, DuplicateDumpFile), i.Duplicates); err != nil {
+			return err
+		}
+	}
+	if len(i.Languages) > 0 {
+		if err := i.saveDump(filepath.Join(dir, LanguageDumpFile), i.Languages); err != nil {
+			return err
+		}
+	}
+	i.printf("Indexes dump saved successfully into the file\n")
 	return nil
 }
 
@@ -194,6 +395,53 @@ func (i *Indexer) Analyze(s string) []string {
 	return tokens
 }
 
+// AnalyzeLanguage analyzes the text with the stemmer of the language, english and unknown languages are
+// analyzed by Analyze. The stop words are english so they are removed only from the english texts. The
+// languages without a stemmer, such as german or italian, fall back to their lowercase words so that they are
+// matched on the words as they are written rather than stemmed as english.
+func (i *Indexer) AnalyzeLanguage(s string, language string) []string {
+	if language == "" || language == "en" {
+		return i.Analyze(s)
+	}
+	tokens := i.Tokenizer.Tokenize(s)
+	tokens = i.Filterer.Lowercase(tokens)
+	stemmer, exists := i.Stemmers[language]
+	if !exists {
+		return tokens
+	}
+	return stemmer.Stem(tokens)
+}
+
+// QueryTokens analyzes the query with the default analyzer and adds the tokens of the analyzers of the other
+// languages of the indexed pages, so that a query matches the pages of every language
+func (i *Indexer) QueryTokens(s string) []string {
+	tokens := i.Analyze(s)
+	if len(i.Languages) == 0 {
+		return tokens
+	}
+	seen := make(map[string]bool, len(tokens))
+	for _, token := range tokens {
+		seen[token] = true
+	}
+	languages := make([]string, 0, len(i.Languages))
+	for language := range i.Languages {
+		languages = append(languages, language)
+	}
+	sort.Strings(languages)
+	for _, language := range languages {
+		if language == "en" {
+			continue
+		}
+		for _, token := range i.AnalyzeLanguage(s, language) {
+			if !seen[token] {
+				seen[token] = true
+				tokens = append(tokens, token)
+			}
+		}
+	}
+	return tokens
+}
+
 func (i *Indexer) AddIndex(tokens []string, url string) {
 	for _, token := range tokens {
 		urls, exists := i.Indexes[token]
@@ -208,18 +456,50 @@ func (i *Indexer) AddIndex(tokens []string, url string) {
 	}
 }
 
+func (i *Indexer) AddFieldIndex(field string, tokens []string, url string) {
+	indexes, exists := i.FieldIndexes[field]
+	if !exists {
+		indexes = map[string][]string{}
+		i.FieldIndexes[field] = indexes
+	}
+	for _, token := range tokens {
+		urls := indexes[token]
+		if !collector.URLExists(urls, url) {
+			indexes[token] = append(urls, url)
+		}
+	}
+}
+
 func (i *Indexer) Search(s string) []SearchResult {
 	begin := time.Now()
 	defer func(begin time.Time, phrase string) {
 		elapsed := time.Since(begin)
-		fmt.Printf("Search took %d micro seconds for phrase: %s\n", elapsed.Microseconds(), phrase)
+		i.printf("Search took %d micro seconds for phrase: %s\n", elapsed.Microseconds(), phrase)
+	}(begin, s)
+
+	return i.SearchIndexes(i.Indexes, s)
+}
+
+func (i *Indexer) SearchField(field string, s string) []SearchResult {
+	begin := time.Now()
+	defer func(begin time.Time, phrase string) {
+		elapsed := time.Since(begin)
+		i.printf("Search took %d micro seconds for phrase: %s in field: %s\n", elapsed.Microseconds(), phrase, field)
 	}(begin, s)
 
+	indexes, exists := i.FieldIndexes[field]
+	if !exists {
+		return []SearchResult{}
+	}
+	return i.SearchIndexes(indexes, s)
+}
+
+func (i *Indexer) SearchIndexes(indexes map[string][]string, s string) []SearchResult {
 	results := []SearchResult{}
 	frequency := map[string]int{}
-	tokens := i.Analyze(s)
+	tokens := i.QueryTokens(s)
 	for _, token := range tokens {
-		urls, exists := i.Indexes[token]
+		urls, exists := indexes[token]
 		if exists {
 			for _, url := range urls {
 				v, ok := frequency[url]
@@ -234,17 +514,63 @@ func (i *Indexer) Search(s string) []SearchResult {
 	max := i.FindMax(frequency)
 	for url, freq := range frequency {
 		rank := float64(freq) / float64(max)
+		if len(i.PageRanks) > 0 && i.PageRankWeight > 0 {
+			rank = (1-i.PageRankWeight)*rank + i.PageRankWeight*i.PageRanks[url]
+		}
 		results = append(results, SearchResult{
 			Url:  url,
 			Rank: rank,
 		})
 	}
 	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Rank == results[j].Rank {
+			return results[i].Url < results[j].Url
+		}
 		return results[i].Rank > results[j].Rank
 	})
+	if i.CollapseDuplicates && len(i.Duplicates) > 0 {
+		results = i.Collapse(results)
+	}
 	return results
 }
 
+// Collapse keeps only the first result of every near duplicate cluster, the results are expected to be sorted
+func (i *Indexer) Collapse(results []SearchResult) []SearchResult {
+	collapsed := make([]SearchResult, 0, len(results))
+	clusters := map[string]bool{}
+	for _, result := range results {
+		cluster, exists := i.Duplicates[result.Url]
+		if !exists {
+			cluster = result.Url
+		}
+		if clusters[cluster] {
+			continue
+		}
+		clusters[cluster] = true
+		collapsed = append(collapsed, result)
+	}
+	return collapsed
+}
+
+// FieldValues returns the texts of an extracted field value which is either a string or a list of strings
+func FieldValues(value interface{}) []string {
+	switch v := value.(type) {
+	case string:
+		return []string{v}
+	case []string:
+		return v
+	case []interface{}:
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			if text, ok := item.(string); ok {
+				values = append(values, text)
+			}
+		}
+		return values
+	}
+	return []string{}
+}
+
 func (i *Indexer) FindMax(frequency map[string]int) int {
 	max := 0
 	for _, freq := range frequency {
@@ -254,3 +580,36 @@ func (i *Indexer) FindMax(frequency map[string]int) int {
 	}
 	return max
 }
+
+func (i *Indexer) saveDump(path string, v interface{}) error {
+	file, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		i.printf("Error marshalling to json the dump %s: %s\n", path, err.Error())
+		return err
+	}
+	err = ioutil.WriteFile(path, file, 0644)
+	if err != nil {
+		i.printf("Error saving the dump into the file %s: %s\n", path, err.Error())
+		return err
+	}
+	return nil
+}
+
+// printf prints the progress to the output of the indexer
+func (i *Indexer) printf(format string, args ...interface{}) {
+	if i.Output != nil {
+		fmt.Fprintf(i.Output, format, args...)
+	}
+}
+
+// loadOptionalDump reads a json dump into v, a missing file leaves v as it is
+func loadOptionalDump(path string, v interface{}) error {
+	bytes, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(bytes, v)
+}
@@ -0,0 +1,115 @@
+package searcher
+
+import (
+	"crawler/collector"
+	"os"
+	"reflect"
+	"testing"
+)
+
+// The indexer reads its stop words relative to the working directory, the tests run from the module root
+func TestMain(m *testing.M) {
+	if err := os.Chdir(".."); err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
+
+func newTestIndexer(t *testing.T) *Indexer {
+	t.Helper()
+	indexer, err := NewIndexer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	indexer.Output = nil
+	return indexer
+}
+
+func TestSearchBlendsThePageRanks(t *testing.T) {
+	// Both pages match the query equally, the hub is linked by every other page
+	data := &collector.ResultData{
+		Seed: "https://example.com/a",
+		Succeed: map[string]*collector.SucceededPage{
+			"https://example.com/a":   {Title: "Crawler guide", Urls: []string{"https://example.com/hub"}},
+			"https://example.com/b":   {Title: "Unrelated", Urls: []string{"https://example.com/hub"}},
+			"https://example.com/hub": {Title: "Crawler guide"},
+		},
+	}
+	indexer := newTestIndexer(t)
+	for url, page := range data.Succeed {
+		indexer.IndexPage(url, page)
+	}
+	results := indexer.Search("crawler guide")
+	if len(results) != 2 || results[0].Rank != results[1].Rank {
+		t.Fatalf("got results %v before the page ranks", results)
+	}
+
+	indexer.ComputePageRanks(data)
+	if indexer.PageRanks["https://example.com/hub"] != 1 {
+		t.Errorf("got page ranks %v, want the hub ranked 1", indexer.PageRanks)
+	}
+	results = indexer.Search("crawler guide")
+	if len(results) != 2 || results[0].Url != "https://example.com/hub" {
+		t.Fatalf("got results %v, want the hub first", results)
+	}
+	if want := (1 - DefaultPageRankWeight) + DefaultPageRankWeight; results[0].Rank != want {
+		t.Errorf("got rank %f of the hub, want %f", results[0].Rank, want)
+	}
+	if results[1].Rank >= results[0].Rank || results[1].Rank < 1-DefaultPageRankWeight {
+		t.Errorf("got rank %f of the other page", results[1].Rank)
+	}
+
+	// Without a page rank weight only the matched tokens count
+	indexer.PageRankWeight = 0
+	if results := indexer.Search("crawler guide"); results[0].Rank != results[1].Rank {
+		t.Errorf("got results %v without a page rank weight", results)
+	}
+}
+
+func TestAnalyzeLanguage(t *testing.T) {
+	indexer := newTestIndexer(t)
+	for _, test := range []struct {
+		language string
+		text     string
+		want     []string
+	}{
+		// English and the pages without a language lose the stop words and are stemmed as english
+		{"", "The crawled houses", []string{"crawl", "hous"}},
+		{"en", "The crawled houses", []string{"crawl", "hous"}},
+		{"fr", "Les maisons anciennes", []string{"le", "maison", "ancien"}},
+		// The languages without a stemmer fall back to their lowercase words
+		{"de", "Die Häuser der Stadt", []string{"die", "häuser", "der", "stadt"}},
+		{"ja", "クローラー", []string{"クローラー"}},
+	} {
+		if got := indexer.AnalyzeLanguage(test.text, test.language); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%q in %q: got %q, want %q", test.text, test.language, got, test.want)
+		}
+	}
+}
+
+func TestSearchMatchesThePagesOfEveryLanguage(t *testing.T) {
+	indexer := newTestIndexer(t)
+	pages := map[string]*collector.SucceededPage{
+		"https://example.com/en": {Title: "Old houses of the city", Language: "en"},
+		"https://example.com/fr": {Title: "Les maisons de la ville", Language: "fr"},
+		"https://example.com/de": {Title: "Die Häuser der Stadt", Language: "de"},
+	}
+	for url, page := range pages {
+		indexer.IndexPage(url, page)
+	}
+	for query, want := range map[string]string{
+		"house":  "https://example.com/en",
+		"maison": "https://example.com/fr",
+		"häuser": "https://example.com/de",
+		"Stadt":  "https://example.com/de",
+	} {
+		results := indexer.Search(query)
+		if len(results) != 1 || results[0].Url != want {
+			t.Errorf("%q: got results %v, want %s", query, results, want)
+		}
+	}
+	// The words of a language without a stemmer are not stemmed, so their other forms are not matched
+	if results := indexer.Search("Haus"); len(results) != 0 {
+		t.Errorf("got results %v of another form of an unstemmed word", results)
+	}
+}
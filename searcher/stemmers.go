@@ -2,23 +2,50 @@ package searcher
 
 import "github.com/kljensen/snowball"
 
+const (
+	DefaultStemmerLanguage = "english"
+)
+
+// Snowball stemmers of the languages detected by the collector, keyed by the language code
+var StemmerLanguages = map[string]string{
+	"en": "english",
+	"fr": "french",
+	"es": "spanish",
+	"ru": "russian",
+	"sv": "swedish",
+	"no": "norwegian",
+}
+
 type StemmerInterface interface {
 	Stem(tokens []string) []string
 }
 
-type Stemmer struct {}
+// Stemmer stems the tokens with the snowball stemmer of the language, the tokens are kept as they are when
+// the language is empty
+type Stemmer struct {
+	Language string
+}
 
 func NewStemmer() *Stemmer {
-	return &Stemmer{}
+	return &Stemmer{Language: DefaultStemmerLanguage}
+}
+
+// NewLanguageStemmer returns the stemmer of the language code, the languages without a snowball stemmer are
+// not stemmed
+func NewLanguageStemmer(language string) *Stemmer {
+	return &Stemmer{Language: StemmerLanguages[language]}
 }
 
 func (s *Stemmer) Stem(tokens []string) []string {
+	if s.Language == "" {
+		return tokens
+	}
 	newTokens := make([]string, 0, len(tokens))
 	for _, token := range tokens {
-		stemmed, err := snowball.Stem(token, "english", false)
+		stemmed, err := snowball.Stem(token, s.Language, false)
 		if err == nil {
 			newTokens = append(newTokens, stemmed)
 		}
 	}
 	return newTokens
-}
\ No newline at end of file
+}